@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// emitter emits events through the wails runtime, the context is set on startup.
+type emitter struct {
+	ctx context.Context
+}
+
+func (e *emitter) Emit(name string, data ...any) {
+	if e.ctx == nil {
+		return
+	}
+	runtime.EventsEmit(e.ctx, name, data...)
+}
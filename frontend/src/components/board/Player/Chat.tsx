@@ -0,0 +1,68 @@
+import { Component, createSignal, For, onCleanup, onMount, Show } from 'solid-js'
+import { ChatEvent, Room } from '../../../service/types'
+import { subscribeChat } from '../../../service/chat'
+
+// maxEvents is the number of the latest events kept on the overlay
+const maxEvents = 20
+
+type Props = {
+  boardId: string
+  room: Room
+}
+
+const Chat: Component<Props> = (props) => {
+  const [events, setEvents] = createSignal<ChatEvent[]>([])
+  let unsubscribe: (() => void) | undefined
+  let disposed = false
+  onMount(async () => {
+    const off = await subscribeChat(
+      props.boardId,
+      props.room.platform.id,
+      props.room.id,
+      (e) => {
+        if (e.kind === 'onlineCount') {
+          return
+        }
+        setEvents((pre) => [...pre, e].slice(-maxEvents))
+      },
+    )
+    // the player may be closed while subscribing
+    if (disposed) {
+      off()
+      return
+    }
+    unsubscribe = off
+  })
+  onCleanup(() => {
+    disposed = true
+    unsubscribe?.()
+  })
+  return (
+    <div
+      class={
+        'absolute bottom-12 left-0 !w-1/2 !h-1/2 px-2 flex flex-col justify-end overflow-hidden pointer-events-none text-sm text-zinc-50'
+      }
+    >
+      <For each={events()}>
+        {(e) => (
+          <div
+            class={
+              'w-fit max-w-full mt-1 px-2 py-0.5 rounded bg-zinc-800/60 break-words'
+            }
+          >
+            <span class={'font-bold mr-1'}>{e.user.name}:</span>
+            <Show when={e.amount > 0}>
+              <span class={'text-warning mr-1'}>
+                <span class={'iconify ph--coins align-middle mr-0.5'} />
+                {e.amount / 1000}
+              </span>
+            </Show>
+            {e.text}
+          </div>
+        )}
+      </For>
+    </div>
+  )
+}
+
+export default Chat
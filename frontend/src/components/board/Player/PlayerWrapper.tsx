@@ -3,9 +3,11 @@ import { Room } from '../../../service/types'
 import Loading from './Loading'
 import Offline from './Offline'
 import Video from './Video'
+import Chat from './Chat'
 import { usePlayerMeta } from './playerMeta'
 
 type Props = {
+  boardId: string
   room: Room
 }
 
@@ -41,6 +43,7 @@ const PlayerWrapper: Component<Props> = (props) => {
               <Video poster={props.room.coverUrl} />
             </Show>
           </Show>
+          <Chat boardId={props.boardId} room={props.room} />
         </Show>
         <Show when={props.room}>
           <div
@@ -37,7 +37,11 @@ const Video: Component<Props> = (props) => {
     if (!videoRef) {
       return
     }
-    const u = new URL(playerMeta.selectedLiveUrl()!.url)
+    let u = new URL(playerMeta.selectedLiveUrl()!.url)
+    // the relayed stream keeps its original url in the origin parameter
+    if (u.pathname === '/stream' && u.searchParams.has('origin')) {
+      u = new URL(u.searchParams.get('origin')!)
+    }
     if (!u.pathname.endsWith('.m3u8')) {
       videoRef.src = playerMeta.selectedLiveUrl()!.url
       return
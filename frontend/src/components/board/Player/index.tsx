@@ -4,13 +4,14 @@ import { PlayerMetaProvider } from './playerMeta'
 import PlayerWrapper from './PlayerWrapper'
 
 type Props = {
+  boardId: string
   room: Room
 }
 
 const Player: Component<Props> = (props) => {
   return (
     <PlayerMetaProvider room={props.room}>
-      <PlayerWrapper room={props.room} />
+      <PlayerWrapper boardId={props.boardId} room={props.room} />
     </PlayerMetaProvider>
   )
 }
@@ -11,6 +11,7 @@ import {
 import { LiveUrl, Quality, Room } from '../../../service/types'
 import { createAsync } from '@solidjs/router'
 import { cachedGetLiveUrls, cachedGetQualities } from './cacheService'
+import { getFailoverUrl } from '../../../service/platform'
 
 type VideoInfo = {
   width: number
@@ -53,15 +54,31 @@ export const PlayerMetaProvider: ParentComponent<{ room: Room }> = (props) => {
   createEffect(() => {
     setSelectedQualityId(qualities()[0]?.id || null)
   })
-  const liveUrls = createAsync(
+  const lines = createAsync(
     () => cachedGetLiveUrls(props.room, selectedQualityId()),
     { initialValue: [] },
   )
+  // the lines are played right away, the failover url is added when the probing is done
+  const [failoverUrl, setFailoverUrl] = createSignal<LiveUrl | null>(null)
+  createEffect(() => {
+    const ls = lines()
+    setFailoverUrl(null)
+    getFailoverUrl(props.room.platform.id, ls).then((fu) => {
+      // the quality may be changed during the probing
+      if (lines() === ls) {
+        setFailoverUrl(fu)
+      }
+    })
+  })
+  const liveUrls = createMemo(() => {
+    const fu = failoverUrl()
+    return fu ? [fu, ...lines()] : lines()
+  })
   const [selectedLiveUrl, setSelectedLiveUrl] = createSignal<LiveUrl | null>(
-    liveUrls()[0] || null,
+    lines()[0] || null,
   )
   createEffect(() => {
-    setSelectedLiveUrl(liveUrls()[0] || null)
+    setSelectedLiveUrl(lines()[0] || null)
   })
   const [videoInfo, setVideoInfo] = createSignal<VideoInfo | null>(null)
   const value: Ctx = {
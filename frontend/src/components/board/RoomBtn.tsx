@@ -8,7 +8,7 @@ import {
 } from 'solid-js'
 import Owner from '../Owner'
 import { BoardRoom, Room } from '../../service/types'
-import { getRoom } from '../../service/platform'
+import { getRoomBatched } from '../../service/platform'
 import { Portal } from 'solid-js/web'
 
 const minTimeout = 30000 // 30 seconds
@@ -28,12 +28,16 @@ type Props = {
 const RoomBtn: Component<Props> = (props) => {
   const [room, setRoom] = createSignal<Room>()
   onMount(() => {
-    getRoom(props.room.platformId, props.room.id).then((r) => r && setRoom(r))
+    getRoomBatched(props.room.platformId, props.room.id).then(
+      (r) => r && setRoom(r),
+    )
     let timer: number
     function refetch() {
       // @ts-expect-error TS2322
       timer = setTimeout(refetch, getRandomRefetchTimeout())
-      getRoom(props.room.platformId, props.room.id).then((r) => r && setRoom(r))
+      getRoomBatched(props.room.platformId, props.room.id).then(
+        (r) => r && setRoom(r),
+      )
     }
     // @ts-expect-error TS2322
     timer = setTimeout(refetch, getRandomRefetchTimeout())
@@ -1,14 +1,31 @@
 import {
   Component,
   JSX,
+  Show,
   createResource,
   createMemo,
   createSignal,
+  onCleanup,
 } from 'solid-js'
-import { getBiliCookie, setBiliCookie } from '../../service/settings'
+import {
+  checkBiliCookie,
+  getBiliCookie,
+  setBiliCookie,
+} from '../../service/settings'
+import { getLoginQrCode, logout, pollLogin } from '../../service/platform'
+import { LoginQrCode, LoginState } from '../../service/types'
+
+const pollInterval = 2000
+
+const loginStateText: Record<LoginState, string> = {
+  waiting: '请使用哔哩哔哩客户端扫码',
+  scanned: '已扫码，请在手机上确认',
+  confirmed: '登录成功',
+  expired: '二维码已过期',
+}
 
 const BiliCookie: Component = () => {
-  const [cookie, { mutate }] = createResource(getBiliCookie, {
+  const [cookie, { mutate, refetch }] = createResource(getBiliCookie, {
     initialValue: '',
   })
   const handleChange: JSX.EventHandler<HTMLInputElement, Event> = async (
@@ -18,6 +35,8 @@ const BiliCookie: Component = () => {
     const c = await setBiliCookie(value)
     mutate(c)
   }
+  // the account is checked again whenever the cookie changes
+  const [account] = createResource(cookie, () => checkBiliCookie())
   const [show, setShow] = createSignal(false)
   const showtype = createMemo(() => {
     if (show()) {
@@ -25,6 +44,45 @@ const BiliCookie: Component = () => {
     }
     return 'password'
   })
+
+  const [qrCode, setQrCode] = createSignal<LoginQrCode | null>(null)
+  const [loginState, setLoginState] = createSignal<LoginState>('waiting')
+  let timer: ReturnType<typeof setTimeout> | undefined
+  const stopPolling = () => clearTimeout(timer)
+  onCleanup(stopPolling)
+  const poll = async (key: string) => {
+    const s = await pollLogin('bili', key)
+    if (qrCode()?.key !== key) {
+      return
+    }
+    if (s) {
+      setLoginState(s)
+    }
+    if (s === 'confirmed') {
+      setQrCode(null)
+      await refetch()
+      return
+    }
+    if (s !== 'expired') {
+      timer = setTimeout(() => poll(key), pollInterval)
+    }
+  }
+  const handleLogin = async () => {
+    stopPolling()
+    const qc = await getLoginQrCode('bili')
+    setQrCode(qc)
+    setLoginState('waiting')
+    if (qc) {
+      timer = setTimeout(() => poll(qc.key), pollInterval)
+    }
+  }
+  const handleLogout = async () => {
+    stopPolling()
+    setQrCode(null)
+    if (await logout('bili')) {
+      mutate('')
+    }
+  }
   return (
     <div>
       <h2 class={'mb-2 flex items-center'}>
@@ -37,12 +95,45 @@ const BiliCookie: Component = () => {
             'ph--eye-slash-fill': show(),
           }}
         />
+        <button onClick={handleLogin} class={'btn btn-sm ml-auto'}>
+          扫码登录
+        </button>
+        <Show when={cookie()}>
+          <button onClick={handleLogout} class={'btn btn-sm ml-2'}>
+            退出登录
+          </button>
+        </Show>
       </h2>
+      <Show when={qrCode()}>
+        {(qc) => (
+          <div class={'mb-2 flex flex-col items-center gap-2'}>
+            <img src={qc().pngDataUrl} alt={qc().url} class={'w-48 h-48'} />
+            <span class={'text-sm'}>{loginStateText[loginState()]}</span>
+            <Show when={loginState() === 'expired'}>
+              <button onClick={handleLogin} class={'btn btn-sm'}>
+                刷新二维码
+              </button>
+            </Show>
+          </div>
+        )}
+      </Show>
+      <Show when={cookie() ? account() : null}>
+        {(a) => (
+          <p class={'mb-2 text-sm'}>
+            {a().isLogin
+              ? `已登录：${a().name}（${a().uid}）${a().isVip ? ' 大会员' : ''}`
+              : 'Cookie 已失效，请重新登录'}
+            <Show when={a().isLogin && a().expiresAt}>
+              ，有效期至 {new Date(a().expiresAt).toLocaleDateString()}
+            </Show>
+          </p>
+        )}
+      </Show>
       <input
         type={showtype()}
         value={cookie()}
         onChange={handleChange}
-        placeholder={'填写 Cookie 才能拿到更高清直播源'}
+        placeholder={'填写 Cookie 或扫码登录才能拿到更高清直播源'}
         class={'input input-bordered w-full'}
       />
     </div>
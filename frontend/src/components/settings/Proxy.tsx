@@ -0,0 +1,71 @@
+import { Component, For, Show, createResource, createSignal } from 'solid-js'
+import { getPlatforms } from '../../service/platform'
+import { getProxySetting, setProxySetting } from '../../service/settings'
+import { ProxySetting } from '../../service/types'
+
+const Proxy: Component = () => {
+  const [setting, { mutate }] = createResource(getProxySetting)
+  const [platforms] = createResource(getPlatforms, { initialValue: [] })
+  const [invalid, setInvalid] = createSignal(false)
+  const save = async (change: Partial<ProxySetting>) => {
+    const s = setting()
+    if (!s) {
+      return
+    }
+    const ns = await setProxySetting({ ...s, ...change })
+    setInvalid(!ns)
+    if (ns) {
+      mutate(ns)
+    }
+  }
+  return (
+    <div>
+      <h2 class={'mb-2'}>代理</h2>
+      <Show when={setting()}>
+        {(s) => (
+          <div class={'flex flex-col gap-2'}>
+            <input
+              value={s().url}
+              onChange={(e) => save({ url: e.currentTarget.value })}
+              placeholder={'http://、https:// 或 socks5://，留空则直连'}
+              class={'input input-bordered w-full'}
+            />
+            <input
+              value={s().bypass.join(',')}
+              onChange={(e) =>
+                save({ bypass: e.currentTarget.value.split(',') })
+              }
+              placeholder={'直连的域名或 IP，用逗号分隔，如 .example.com,10.0.0.0/8'}
+              class={'input input-bordered w-full'}
+            />
+            <For each={platforms()}>
+              {(p) => (
+                <div class={'flex items-center gap-2'}>
+                  <span class={'w-32 truncate'}>{p.name}</span>
+                  <input
+                    value={s().platforms[p.id] ?? ''}
+                    onChange={(e) =>
+                      save({
+                        platforms: {
+                          ...s().platforms,
+                          [p.id]: e.currentTarget.value,
+                        },
+                      })
+                    }
+                    placeholder={'使用全局代理，填写 direct 则直连'}
+                    class={'input input-bordered input-sm flex-1'}
+                  />
+                </div>
+              )}
+            </For>
+            <Show when={invalid()}>
+              <span class={'text-sm text-error'}>代理设置无效，未保存</span>
+            </Show>
+          </div>
+        )}
+      </Show>
+    </div>
+  )
+}
+
+export default Proxy
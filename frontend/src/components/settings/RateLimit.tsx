@@ -0,0 +1,65 @@
+import { Component, For, JSX, createResource } from 'solid-js'
+import { getPlatforms } from '../../service/platform'
+import {
+  getPlatformRateLimit,
+  setPlatformRateLimit,
+} from '../../service/settings'
+import { Platform } from '../../service/types'
+
+const PlatformRateLimit: Component<{ platform: Platform }> = (props) => {
+  const [limit, { mutate }] = createResource(
+    () => props.platform.id,
+    getPlatformRateLimit,
+  )
+  const handleChange =
+    (field: 'rate' | 'burst'): JSX.EventHandler<HTMLInputElement, Event> =>
+    async (event) => {
+      const l = limit()
+      if (!l) {
+        return
+      }
+      const value = event.currentTarget.valueAsNumber
+      const nl = await setPlatformRateLimit(props.platform.id, {
+        ...l,
+        [field]: Number.isNaN(value) ? 0 : value,
+      })
+      mutate(nl)
+    }
+  return (
+    <div class={'flex items-center gap-2'}>
+      <span class={'w-32 truncate'}>{props.platform.name}</span>
+      <input
+        type={'number'}
+        min={0}
+        step={0.5}
+        value={limit()?.rate ?? ''}
+        onChange={handleChange('rate')}
+        title={'每秒请求数，0 表示不限制'}
+        class={'input input-bordered input-sm w-24'}
+      />
+      <span class={'text-sm'}>次/秒，突发</span>
+      <input
+        type={'number'}
+        min={1}
+        value={limit()?.burst ?? ''}
+        onChange={handleChange('burst')}
+        class={'input input-bordered input-sm w-24'}
+      />
+      <span class={'text-sm'}>次</span>
+    </div>
+  )
+}
+
+const RateLimit: Component = () => {
+  const [platforms] = createResource(getPlatforms, { initialValue: [] })
+  return (
+    <div>
+      <h2 class={'mb-2'}>请求频率限制</h2>
+      <div class={'flex flex-col gap-2'}>
+        <For each={platforms()}>{(p) => <PlatformRateLimit platform={p} />}</For>
+      </div>
+    </div>
+  )
+}
+
+export default RateLimit
@@ -4,6 +4,7 @@ import {
   createSignal,
   For,
   JSX,
+  onCleanup,
   Show,
 } from 'solid-js'
 import { useParams } from '@solidjs/router'
@@ -12,10 +13,12 @@ import { getBoard, updateBoard } from '../service/board'
 import { Room } from '../service/types'
 import Player from '../components/board/Player'
 import Empty from '../components/Empty'
+import { closeBoardChat } from '../service/chat'
 
 const Board: Component = () => {
   const id = useParams().id
   const [board, { mutate }] = createResource(() => getBoard(id))
+  onCleanup(() => closeBoardChat(id))
   const handleAdd = async (room: Room) => {
     for (const r of board()!.rooms) {
       if (r.id === room.id && r.platformId === room.platform.id) {
@@ -159,7 +162,7 @@ const Board: Component = () => {
           }}
         >
           <For each={selectedRooms()} fallback={<Empty />}>
-            {(room) => <Player room={room} />}
+            {(room) => <Player boardId={id} room={room} />}
           </For>
         </div>
       </div>
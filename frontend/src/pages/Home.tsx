@@ -1,7 +1,20 @@
-import { Component, createResource, For, Index, Show } from 'solid-js'
+import {
+  Component,
+  createResource,
+  createSignal,
+  For,
+  Index,
+  Show,
+} from 'solid-js'
 import { A, createAsync } from '@solidjs/router'
 import { Board as BoardType } from '../service/types'
-import { addBoard, getBorders, removeBoard } from '../service/board'
+import {
+  addBoard,
+  addBoardWithRooms,
+  getBorders,
+  removeBoard,
+} from '../service/board'
+import { getFollowedRooms } from '../service/platform'
 import { getVersion } from '../service/version'
 
 const Home: Component = () => {
@@ -12,6 +25,22 @@ const Home: Component = () => {
     const nb = await addBoard()
     mutate((boards) => [...boards, nb])
   }
+  const [importing, setImporting] = createSignal(false)
+  // creates a board of the followed streamers who are live
+  const handleImport = async () => {
+    setImporting(true)
+    try {
+      const rooms = await getFollowedRooms('bili')
+      const live = rooms.filter((r) => r.isOnline)
+      if (live.length === 0) {
+        return
+      }
+      const nb = await addBoardWithRooms('哔哩哔哩关注', live)
+      mutate((boards) => [...boards, nb])
+    } finally {
+      setImporting(false)
+    }
+  }
   const handleRemove = async (board: BoardType) => {
     const b = await removeBoard(board.id)
     if (b.id === board.id) {
@@ -54,6 +83,22 @@ const Home: Component = () => {
         >
           <span class={'iconify ph--plus-bold text-2xl'}> </span>
         </button>
+        <button
+          onClick={handleImport}
+          disabled={importing()}
+          class={
+            'flex justify-center items-center hover:outline rounded-box border outline-offset-2 outline-accent'
+          }
+          title={'从哔哩哔哩关注导入正在直播的主播'}
+        >
+          <span
+            class={'iconify text-2xl'}
+            classList={{
+              'ph--user-list': !importing(),
+              'ph--spinner animate-spin': importing(),
+            }}
+          />
+        </button>
         <A
           href={'/settings'}
           class={
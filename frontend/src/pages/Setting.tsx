@@ -1,5 +1,7 @@
 import { Component } from 'solid-js'
 import BiliCookie from '../components/settings/BiliCookie'
+import RateLimit from '../components/settings/RateLimit'
+import Proxy from '../components/settings/Proxy'
 import { A } from '@solidjs/router'
 
 const Setting: Component = () => {
@@ -16,6 +18,8 @@ const Setting: Component = () => {
         <span class={'font-bold text-lg ml-2'}>设置</span>
       </h1>
       <BiliCookie />
+      <RateLimit />
+      <Proxy />
     </div>
   )
 }
@@ -0,0 +1,49 @@
+import { RecordHistory, RecordRule, Recording } from './types'
+import {
+  AddRecordRule,
+  ClearRecordHistory,
+  GetActiveRecordings,
+  GetRecordHistory,
+  GetRecordRules,
+  RemoveRecordRule,
+  UpdateRecordRule,
+} from 'wails/go/service/AutoRecordService'
+import { service } from 'wails/go/models'
+import { nanoid } from 'nanoid'
+
+export const getRecordRules = async (): Promise<RecordRule[]> => {
+  const rs = await GetRecordRules()
+  return (rs ?? []) as RecordRule[]
+}
+
+export const addRecordRule = async (
+  rule: Omit<RecordRule, 'id'>,
+): Promise<RecordRule | null> => {
+  const r = await AddRecordRule(
+    new service.RecordRuleDTO({ ...rule, id: nanoid() }),
+  )
+  return (r as RecordRule) ?? null
+}
+
+export const updateRecordRule = async (
+  rule: RecordRule,
+): Promise<RecordRule | null> => {
+  const r = await UpdateRecordRule(new service.RecordRuleDTO(rule))
+  return (r as RecordRule) ?? null
+}
+
+export const removeRecordRule = RemoveRecordRule
+
+export const getActiveRecordings = async (): Promise<
+  Record<string, Recording>
+> => {
+  const rs = await GetActiveRecordings()
+  return (rs ?? {}) as Record<string, Recording>
+}
+
+export const getRecordHistory = async (): Promise<RecordHistory[]> => {
+  const hs = await GetRecordHistory()
+  return (hs ?? []) as RecordHistory[]
+}
+
+export const clearRecordHistory = ClearRecordHistory
@@ -1,4 +1,4 @@
-import { Board } from './types'
+import { Board, Room } from './types'
 import {
   AddBoard,
   GetBoard,
@@ -64,6 +64,35 @@ export const addBoard = async (): Promise<Board> => {
   }
 }
 
+// addBoardWithRooms creates a board with the rooms, e.g. the followed rooms which are live.
+export const addBoardWithRooms = async (
+  name: string,
+  rooms: Room[],
+): Promise<Board> => {
+  const nb = await AddBoard(
+    new service.BoardDTO({
+      id: nanoid(),
+      name,
+      rooms: rooms.map((r) => ({
+        id: r.id,
+        platformId: r.platform.id,
+        avatarUrl: r.owner.avatarUrl,
+      })),
+    }),
+  )
+  return {
+    id: nb.id,
+    name: nb.name,
+    rooms: nb.rooms.map((r) => {
+      return {
+        id: r.id,
+        platformId: r.platformId,
+        avatarUrl: r.avatarUrl,
+      }
+    }),
+  }
+}
+
 export const removeBoard = async (id: string): Promise<Board> => {
   const b = await RemoveBoard(id)
   return {
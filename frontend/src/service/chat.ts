@@ -0,0 +1,28 @@
+import { ChatEvent } from './types'
+import {
+  ChatEventName,
+  CloseBoard,
+  Subscribe,
+  Unsubscribe,
+} from 'wails/go/service/ChatService'
+import { EventsOn } from 'wails/runtime/runtime'
+
+export const subscribeChat = async (
+  boardId: string,
+  platformId: string,
+  roomId: string,
+  onEvent: (e: ChatEvent) => void,
+): Promise<() => void> => {
+  const ok = await Subscribe(boardId, platformId, roomId)
+  if (!ok) {
+    return () => {}
+  }
+  const name = await ChatEventName(platformId, roomId)
+  const off = EventsOn(name, onEvent)
+  return () => {
+    off()
+    Unsubscribe(boardId, platformId, roomId)
+  }
+}
+
+export const closeBoardChat = CloseBoard
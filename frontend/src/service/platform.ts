@@ -1,9 +1,29 @@
-import { LiveUrl, Platform, Quality, Room } from './types'
 import {
+  LiveStream,
+  LiveUrl,
+  LiveUrlHealth,
+  LoginQrCode,
+  LoginState,
+  Platform,
+  Quality,
+  Room,
+} from './types'
+import {
+  GetFailoverUrl,
+  GetFollowedRooms,
+  GetLiveStreams,
   GetLiveUrls,
+  GetLoginQrCode,
   GetPlatforms,
   GetQualities,
   GetRoom,
+  GetRooms,
+  InvalidateRoom,
+  Logout,
+  PollLogin,
+  RankLiveUrls,
+  ResolveInput,
+  SearchRooms,
 } from 'wails/go/service/PlatformService'
 
 export const getRoom = async (
@@ -32,6 +52,66 @@ export const getRoom = async (
   }
 }
 
+export const getRooms = async (
+  platformId: string,
+  roomIds: string[],
+): Promise<Record<string, Room>> => {
+  const rs = await GetRooms(platformId, roomIds)
+  if (!rs) {
+    return {}
+  }
+  const rooms: Record<string, Room> = {}
+  for (const [id, r] of Object.entries(rs)) {
+    rooms[id] = {
+      id: r.id,
+      title: r.title,
+      owner: {
+        id: r.owner.id,
+        name: r.owner.name,
+        avatarUrl: r.owner.avatarUrl,
+      },
+      isOnline: r.isOnline,
+      coverUrl: r.coverUrl,
+      platform: {
+        id: r.platform.id,
+        name: r.platform.name,
+        iconUrl: r.platform.iconUrl,
+      },
+    }
+  }
+  return rooms
+}
+
+type pendingRoom = {
+  roomId: string
+  resolve: (r: Room | null) => void
+}
+
+const pendingRooms = new Map<string, pendingRoom[]>()
+
+// getRoomBatched collects the calls in the same tick and gets the rooms of a platform with a single
+// GetRooms call, so that opening a board does not fire a request per room.
+export const getRoomBatched = (
+  platformId: string,
+  roomId: string,
+): Promise<Room | null> => {
+  return new Promise((resolve) => {
+    let ps = pendingRooms.get(platformId)
+    if (!ps) {
+      ps = []
+      pendingRooms.set(platformId, ps)
+      setTimeout(async () => {
+        const batch = pendingRooms.get(platformId) ?? []
+        pendingRooms.delete(platformId)
+        const ids = [...new Set(batch.map((p) => p.roomId))]
+        const rooms = await getRooms(platformId, ids)
+        batch.forEach((p) => p.resolve(rooms[p.roomId] ?? null))
+      })
+    }
+    ps.push({ roomId, resolve })
+  })
+}
+
 export const getPlatforms = async (): Promise<Platform[]> => {
   const ps = await GetPlatforms()
   return ps.map((p) => ({
@@ -64,3 +144,133 @@ export const getLiveUrls = async (
     url: u,
   }))
 }
+
+// getFailoverUrl returns the url which switches to the next line automatically, the lines are probed
+// so that it should be called in background after the lines are shown.
+export const getFailoverUrl = async (
+  platformId: string,
+  lines: LiveUrl[],
+): Promise<LiveUrl | null> => {
+  if (lines.length < 2) {
+    return null
+  }
+  const fu = await GetFailoverUrl(platformId, lines.map((l) => l.url))
+  return fu ? { name: '自动', url: fu } : null
+}
+
+export const getLiveStreams = async (
+  platformId: string,
+  roomId: string,
+  qualityId: string,
+): Promise<LiveStream[]> => {
+  const ss = await GetLiveStreams(platformId, roomId, qualityId)
+  if (!ss) {
+    return []
+  }
+  return ss.map((s) => ({
+    url: s.url,
+    protocol: s.protocol,
+    format: s.format,
+    codec: s.codec,
+    expiresAt: s.expiresAt,
+  }))
+}
+
+export const rankLiveUrls = async (
+  platformId: string,
+  urls: string[],
+): Promise<LiveUrlHealth[]> => {
+  const rs = await RankLiveUrls(platformId, urls)
+  return (rs ?? []) as LiveUrlHealth[]
+}
+
+export const searchRooms = async (
+  platformId: string,
+  keyword: string,
+  page: number,
+): Promise<Room[]> => {
+  const rs = await SearchRooms(platformId, keyword, page)
+  if (!rs) {
+    return []
+  }
+  return rs.map((r) => ({
+    id: r.id,
+    title: r.title,
+    owner: {
+      id: r.owner.id,
+      name: r.owner.name,
+      avatarUrl: r.owner.avatarUrl,
+    },
+    isOnline: r.isOnline,
+    coverUrl: r.coverUrl,
+    platform: {
+      id: r.platform.id,
+      name: r.platform.name,
+      iconUrl: r.platform.iconUrl,
+    },
+  }))
+}
+
+// getFollowedRooms returns the rooms followed by the logged-in user with the online ones first.
+export const getFollowedRooms = async (platformId: string): Promise<Room[]> => {
+  const rs = await GetFollowedRooms(platformId)
+  if (!rs) {
+    return []
+  }
+  return rs.map((r) => ({
+    id: r.id,
+    title: r.title,
+    owner: {
+      id: r.owner.id,
+      name: r.owner.name,
+      avatarUrl: r.owner.avatarUrl,
+    },
+    isOnline: r.isOnline,
+    coverUrl: r.coverUrl,
+    platform: {
+      id: r.platform.id,
+      name: r.platform.name,
+      iconUrl: r.platform.iconUrl,
+    },
+  }))
+}
+
+export const resolveInput = async (
+  input: string,
+): Promise<[string, string] | null> => {
+  const r = await ResolveInput(input)
+  if (!r) {
+    return null
+  }
+  return [r.platformId, r.roomId]
+}
+
+export const getLoginQrCode = async (
+  platformId: string,
+): Promise<LoginQrCode | null> => {
+  const qc = await GetLoginQrCode(platformId)
+  if (!qc) {
+    return null
+  }
+  return {
+    key: qc.key,
+    url: qc.url,
+    pngDataUrl: qc.pngDataUrl,
+  }
+}
+
+// pollLogin returns null if the state cannot be got.
+export const pollLogin = async (
+  platformId: string,
+  key: string,
+): Promise<LoginState | null> => {
+  const s = await PollLogin(platformId, key)
+  return s ? (s as LoginState) : null
+}
+
+export const logout = (platformId: string) => Logout(platformId)
+
+// invalidateRoom drops the cached room, qualities and live urls of the room, so
+// that they are got from the platform next time.
+export const invalidateRoom = (platformId: string, roomId: string) =>
+  InvalidateRoom(platformId, roomId)
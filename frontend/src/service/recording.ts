@@ -0,0 +1,27 @@
+import { Recording } from './types'
+import {
+  ListRecordings,
+  StartRecording,
+  StopRecording,
+} from 'wails/go/service/RecordingService'
+import { EventsOn } from 'wails/runtime/runtime'
+
+export const startRecording = async (
+  platformId: string,
+  roomId: string,
+  qualityId: string,
+): Promise<string | null> => {
+  const id = await StartRecording(platformId, roomId, qualityId)
+  return id || null
+}
+
+export const stopRecording = StopRecording
+
+export const listRecordings = async (): Promise<Recording[]> => {
+  const rs = await ListRecordings()
+  return (rs ?? []) as Recording[]
+}
+
+export const onRecordingProgress = (
+  cb: (r: Recording) => void,
+): (() => void) => EventsOn('recording:progress', cb)
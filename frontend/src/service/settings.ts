@@ -1,5 +1,97 @@
-import { GetBiliCookie, SetBiliCookie } from 'wails/go/service/SettingService'
+import { BiliAccount, ProxySetting, RateLimit } from './types'
+import {
+  CheckBiliCookie,
+  GetBiliCookie,
+  GetPlatformRateLimit,
+  GetProxySetting,
+  GetRecordingDir,
+  GetRecordingTemplate,
+  GetWatchedBoards,
+  GetWatcherInterval,
+  SetBiliCookie,
+  SetPlatformRateLimit,
+  SetProxySetting,
+  SetRecordingDir,
+  SetRecordingTemplate,
+  SetWatchedBoards,
+  SetWatcherInterval,
+} from 'wails/go/service/SettingService'
+import { EventsOn } from 'wails/runtime/runtime'
+import { setting } from 'wails/go/models'
 
 export const getBiliCookie = GetBiliCookie
 
 export const setBiliCookie = (cookie: string) => SetBiliCookie(cookie)
+
+// checkBiliCookie returns null if the account cannot be got.
+export const checkBiliCookie = async (): Promise<BiliAccount | null> => {
+  const a = await CheckBiliCookie()
+  if (!a) {
+    return null
+  }
+  return {
+    isLogin: a.isLogin,
+    uid: a.uid,
+    name: a.name,
+    isVip: a.isVip,
+    expiresAt: a.expiresAt,
+  }
+}
+
+// onBiliCookieExpiry is called when the cookie has expired or will expire soon.
+export const onBiliCookieExpiry = (
+  cb: (a: BiliAccount) => void,
+): (() => void) => EventsOn('setting:biliCookieExpiry', cb)
+
+export const getWatcherInterval = GetWatcherInterval
+
+export const setWatcherInterval = (seconds: number) =>
+  SetWatcherInterval(seconds)
+
+export const getWatchedBoards = GetWatchedBoards
+
+export const setWatchedBoards = (boardIds: string[]) =>
+  SetWatchedBoards(boardIds)
+
+export const getRecordingDir = GetRecordingDir
+
+export const setRecordingDir = (dir: string) => SetRecordingDir(dir)
+
+export const getRecordingTemplate = GetRecordingTemplate
+
+export const setRecordingTemplate = (template: string) =>
+  SetRecordingTemplate(template)
+
+export const getPlatformRateLimit = async (
+  platformId: string,
+): Promise<RateLimit> => {
+  const l = await GetPlatformRateLimit(platformId)
+  return { rate: l.rate, burst: l.burst }
+}
+
+export const setPlatformRateLimit = async (
+  platformId: string,
+  limit: RateLimit,
+): Promise<RateLimit> => {
+  const l = await SetPlatformRateLimit(
+    platformId,
+    new setting.PlatformRateLimit(limit),
+  )
+  return { rate: l.rate, burst: l.burst }
+}
+
+export const getProxySetting = async (): Promise<ProxySetting> => {
+  const ps = await GetProxySetting()
+  return { url: ps.url, bypass: ps.bypass, platforms: ps.platforms }
+}
+
+// setProxySetting returns null if the setting is invalid or cannot be saved.
+export const setProxySetting = async (
+  ps: ProxySetting,
+): Promise<ProxySetting | null> => {
+  const r = await SetProxySetting(new setting.ProxySetting(ps))
+  if (!r) {
+    return null
+  }
+  return { url: r.url, bypass: r.bypass, platforms: r.platforms }
+}
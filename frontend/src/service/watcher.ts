@@ -0,0 +1,8 @@
+import { RoomChange } from './types'
+import { GetStatuses } from 'wails/go/service/WatcherService'
+import { EventsOn } from 'wails/runtime/runtime'
+
+export const getRoomStatuses = GetStatuses
+
+export const onRoomChange = (cb: (c: RoomChange) => void): (() => void) =>
+  EventsOn('watcher:change', cb)
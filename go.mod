@@ -5,6 +5,8 @@ go 1.21
 toolchain go1.22.5
 
 require (
+	github.com/andybalholm/brotli v1.1.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/stretchr/testify v1.9.0
 	github.com/wailsapp/wails/v2 v2.9.1
 )
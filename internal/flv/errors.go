@@ -0,0 +1,17 @@
+package flv
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidSignature is returned if the stream does not start with the flv signature.
+var ErrInvalidSignature = errors.New("invalid flv signature")
+
+func ErrReadHeader(err error) error {
+	return fmt.Errorf("failed to read flv header: %w", err)
+}
+
+func ErrReadTag(err error) error {
+	return fmt.Errorf("failed to read flv tag: %w", err)
+}
@@ -0,0 +1,128 @@
+// Package flv reads the tags of a flv stream, the tags are kept as is so that they can be written out
+// without being encoded again.
+package flv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	TagAudio  = 8
+	TagVideo  = 9
+	TagScript = 18
+
+	// HeaderSize is the minimal size of the file header.
+	HeaderSize = 9
+	// TagHeaderSize is the size of the tag header, the tag data and the previous tag size follow it.
+	TagHeaderSize = 11
+)
+
+// maxTagSize limits the memory of a broken stream.
+const maxTagSize = 16 * 1024 * 1024 // 16 MB
+
+// Header is the file header including the first previous tag size.
+type Header []byte
+
+// HasVideo reports whether the video flag is set.
+func (h Header) HasVideo() bool {
+	return h[4]&0x01 != 0
+}
+
+// ReadHeader reads the file header and the first previous tag size.
+func ReadHeader(r io.Reader) (Header, error) {
+	h := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, ErrReadHeader(err)
+	}
+	if string(h[:3]) != "FLV" {
+		return nil, ErrReadHeader(ErrInvalidSignature)
+	}
+	size := binary.BigEndian.Uint32(h[5:9])
+	if size < HeaderSize || size > maxTagSize {
+		return nil, ErrReadHeader(fmt.Errorf("invalid header size: %d", size))
+	}
+	h = append(h, make([]byte, size-HeaderSize+4)...)
+	if _, err := io.ReadFull(r, h[HeaderSize:]); err != nil {
+		return nil, ErrReadHeader(err)
+	}
+	return h, nil
+}
+
+// Tag is a whole tag including the previous tag size after the data.
+type Tag []byte
+
+// ReadTag returns io.EOF if the stream ends between two tags, the missing previous tag size of the last
+// tag is tolerated.
+func ReadTag(r io.Reader) (Tag, error) {
+	var h [TagHeaderSize]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, ErrReadTag(err)
+	}
+	size := int(h[1])<<16 | int(h[2])<<8 | int(h[3])
+	if size > maxTagSize {
+		return nil, ErrReadTag(fmt.Errorf("tag is too large: %d", size))
+	}
+	t := make(Tag, TagHeaderSize+size+4)
+	copy(t, h[:])
+	if n, err := io.ReadFull(r, t[TagHeaderSize:]); err != nil && n < size {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, ErrReadTag(err)
+	}
+	return t, nil
+}
+
+// Type returns TagAudio, TagVideo, TagScript or another reserved type.
+func (t Tag) Type() uint8 {
+	return t[0] & 0x1f
+}
+
+// Timestamp returns the timestamp in milliseconds.
+func (t Tag) Timestamp() uint32 {
+	return uint32(t[7])<<24 | uint32(t[4])<<16 | uint32(t[5])<<8 | uint32(t[6])
+}
+
+func (t Tag) SetTimestamp(ts uint32) {
+	t[4], t[5], t[6], t[7] = byte(ts>>16), byte(ts>>8), byte(ts), byte(ts>>24)
+}
+
+// Data returns the tag data without the header and the previous tag size.
+func (t Tag) Data() []byte {
+	return t[TagHeaderSize : len(t)-4]
+}
+
+// IsSequenceHeader reports whether the tag is the sequence header of avc, hevc or aac.
+func (t Tag) IsSequenceHeader() bool {
+	data := t.Data()
+	if len(data) < 2 {
+		return false
+	}
+	switch t.Type() {
+	case TagVideo:
+		if data[0]&0x80 != 0 {
+			// the enhanced header, the packet type 0 is the sequence start
+			return data[0]&0x0f == 0
+		}
+		return data[1] == 0
+	case TagAudio:
+		return data[0]>>4 == 10 && data[1] == 0
+	}
+	return false
+}
+
+// IsKeyframe reports whether the tag is a video keyframe other than the sequence header.
+func (t Tag) IsKeyframe() bool {
+	data := t.Data()
+	if t.Type() != TagVideo || len(data) == 0 {
+		return false
+	}
+	// the frame type takes the same bits in the legacy and the enhanced headers
+	return (data[0]>>4)&0x07 == 1 && !t.IsSequenceHeader()
+}
@@ -0,0 +1,100 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadTag(t *testing.T) {
+	t.Run("should read all tags of the fixture", func(t *testing.T) {
+		data, err := os.ReadFile("../remux/testData/sample.flv")
+		assert.NoError(t, err)
+		r := bytes.NewReader(data)
+		h, err := ReadHeader(r)
+		assert.NoError(t, err)
+		assert.Equal(t, data[:13], []byte(h))
+		assert.True(t, h.HasVideo())
+		counts := make(map[uint8]int)
+		var out bytes.Buffer
+		out.Write(h)
+		var last Tag
+		for {
+			tg, err := ReadTag(r)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			assert.NoError(t, err)
+			counts[tg.Type()]++
+			out.Write(tg)
+			last = tg
+		}
+		assert.Equal(t, 1, counts[TagScript])
+		assert.Equal(t, 76, counts[TagVideo])
+		assert.Equal(t, 131, counts[TagAudio])
+		assert.Equal(t, uint32(2995), last.Timestamp())
+		// the tags are kept as is
+		assert.Equal(t, data, out.Bytes())
+	})
+
+	t.Run("should return error for invalid signature", func(t *testing.T) {
+		_, err := ReadHeader(bytes.NewReader([]byte("MP4\x01\x05\x00\x00\x00\x09\x00\x00\x00\x00")))
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("should return error for truncated tag", func(t *testing.T) {
+		data := []byte("\x09\x00\x00\x10\x00\x00\x00\x00\x00\x00\x00\x17")
+		_, err := ReadTag(bytes.NewReader(data))
+		assert.EqualError(t, err, "failed to read flv tag: unexpected EOF")
+	})
+
+	t.Run("should tolerate the missing previous tag size of the last tag", func(t *testing.T) {
+		data := []byte("\x09\x00\x00\x02\x00\x00\x00\x00\x00\x00\x00\x17\x01")
+		tg, err := ReadTag(bytes.NewReader(data))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0x17, 0x01}, tg.Data())
+	})
+}
+
+func TestTag_SetTimestamp(t *testing.T) {
+	tg := make(Tag, TagHeaderSize+4)
+	tg.SetTimestamp(0x12345678)
+	assert.Equal(t, uint32(0x12345678), tg.Timestamp())
+	// the extended byte is the highest 8 bits
+	assert.Equal(t, byte(0x12), tg[7])
+}
+
+func tag(typ uint8, data ...byte) Tag {
+	t := make(Tag, TagHeaderSize, TagHeaderSize+len(data)+4)
+	t[0] = typ
+	t = append(t, data...)
+	return append(t, 0, 0, 0, 0)
+}
+
+func TestTag_IsSequenceHeader_IsKeyframe(t *testing.T) {
+	tests := []struct {
+		name         string
+		tag          Tag
+		wantSequence bool
+		wantKeyframe bool
+	}{
+		{name: "avc sequence header", tag: tag(TagVideo, 0x17, 0x00), wantSequence: true},
+		{name: "avc keyframe", tag: tag(TagVideo, 0x17, 0x01), wantKeyframe: true},
+		{name: "avc inter frame", tag: tag(TagVideo, 0x27, 0x01)},
+		{name: "enhanced sequence start", tag: tag(TagVideo, 0x90, 'h', 'v', 'c', '1'), wantSequence: true},
+		{name: "enhanced keyframe", tag: tag(TagVideo, 0x91, 'h', 'v', 'c', '1'), wantKeyframe: true},
+		{name: "aac sequence header", tag: tag(TagAudio, 0xaf, 0x00), wantSequence: true},
+		{name: "aac frame", tag: tag(TagAudio, 0xaf, 0x01)},
+		{name: "script", tag: tag(TagScript, 0x02, 0x00)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantSequence, tt.tag.IsSequenceHeader())
+			assert.Equal(t, tt.wantKeyframe, tt.tag.IsKeyframe())
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrStalled = errors.New("no data received")
+
+var ErrNoSegment = errors.New("no segment in the playlist")
+
+func ErrProbe(err error) error {
+	return fmt.Errorf("failed to probe: %w", err)
+}
+
+func errStatus(code int) error {
+	return fmt.Errorf("unexpected status code: %d", code)
+}
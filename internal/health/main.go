@@ -0,0 +1,227 @@
+// Package health probes the candidate urls of a live stream and ranks them, so that the fastest cdn is
+// played first.
+package health
+
+import (
+	"asmblive/internal/platform/hls"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout    = 3 * time.Second
+	defaultSampleSize = 256 * 1024 // 256 KB
+	// maxPlaylistSize is the max size of a probed playlist.
+	maxPlaylistSize = 4 * 1024 * 1024 // 4 MB
+)
+
+// Result is the health of a candidate url.
+type Result struct {
+	Url url.URL
+	// StatusCode is 0 if the request fails before the response.
+	StatusCode int
+	// TTFB is the time to the first byte of the stream, or of the playlist for hls.
+	TTFB time.Duration
+	// Speed is the download speed of the first segment for hls, or of the first bytes of the stream,
+	// in bytes per second.
+	Speed float64
+	Err   error
+}
+
+func (r Result) Healthy() bool {
+	return r.Err == nil
+}
+
+type Options struct {
+	// Timeout is the max duration of a probe, 3 seconds by default.
+	Timeout time.Duration
+	// SampleSize is the max number of bytes downloaded to measure the speed, 256 KB by default.
+	SampleSize int64
+}
+
+// Checker probes the urls, it is safe for concurrent use.
+type Checker struct {
+	log    *slog.Logger
+	client *http.Client
+	opts   Options
+}
+
+func New(log *slog.Logger, client *http.Client, opts Options) *Checker {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.SampleSize <= 0 {
+		opts.SampleSize = defaultSampleSize
+	}
+	return &Checker{
+		log:    log.With("module", "health"),
+		client: client,
+		opts:   opts,
+	}
+}
+
+// Check probes the url with the header, the result is unhealthy if the request fails, the status code
+// is not 200, or no data is received within the timeout.
+func (c *Checker) Check(ctx context.Context, u url.URL, header http.Header) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	r := Result{Url: u}
+	start := time.Now()
+	res, err := c.get(ctx, u, header)
+	if err != nil {
+		r.Err = ErrProbe(err)
+		return r
+	}
+	defer c.close(res.Body)
+	r.StatusCode = res.StatusCode
+	if res.StatusCode != http.StatusOK {
+		r.Err = ErrProbe(errStatus(res.StatusCode))
+		return r
+	}
+	body := bufio.NewReader(res.Body)
+	if _, err := body.Peek(1); err != nil {
+		r.Err = ErrProbe(stalled(err))
+		return r
+	}
+	r.TTFB = time.Since(start)
+	if isPlaylist(res) {
+		r.Speed, err = c.segmentSpeed(ctx, body, *res.Request.URL, header)
+	} else {
+		r.Speed, err = c.sampleSpeed(body)
+	}
+	if err != nil {
+		r.Err = ErrProbe(err)
+	}
+	return r
+}
+
+// Rank sorts the healthy results by speed and ttfb, the unhealthy ones are moved to the end in the
+// original order.
+func Rank(rs []Result) []Result {
+	sorted := append([]Result(nil), rs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Healthy() != b.Healthy() {
+			return a.Healthy()
+		}
+		if !a.Healthy() {
+			return false
+		}
+		if a.Speed != b.Speed {
+			return a.Speed > b.Speed
+		}
+		return a.TTFB < b.TTFB
+	})
+	return sorted
+}
+
+// sampleSpeed reads the stream until SampleSize or the timeout, a live stream is slow after the cached data.
+func (c *Checker) sampleSpeed(body io.Reader) (float64, error) {
+	start := time.Now()
+	n, err := io.Copy(io.Discard, io.LimitReader(body, c.opts.SampleSize))
+	if n == 0 {
+		return 0, stalled(err)
+	}
+	// the timeout is expected since the live stream never ends
+	return speed(n, time.Since(start)), nil
+}
+
+// segmentSpeed downloads the first segment of the playlist, the first variant is used for a master playlist.
+func (c *Checker) segmentSpeed(ctx context.Context, body io.Reader, base url.URL, header http.Header) (float64, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxPlaylistSize))
+	if err != nil {
+		return 0, err
+	}
+	vs, err := hls.ParseMaster(bytes.NewReader(data), base)
+	if err != nil {
+		return 0, err
+	}
+	if len(vs) > 0 {
+		res, err := c.get(ctx, vs[0].Url, header)
+		if err != nil {
+			return 0, err
+		}
+		defer c.close(res.Body)
+		if res.StatusCode != http.StatusOK {
+			return 0, errStatus(res.StatusCode)
+		}
+		if data, err = io.ReadAll(io.LimitReader(res.Body, maxPlaylistSize)); err != nil {
+			return 0, err
+		}
+		base = *res.Request.URL
+	}
+	mp, err := hls.ParseMedia(bytes.NewReader(data), base)
+	if err != nil {
+		return 0, err
+	}
+	if len(mp.Segments) == 0 {
+		return 0, ErrNoSegment
+	}
+	start := time.Now()
+	res, err := c.get(ctx, mp.Segments[0].Url, header)
+	if err != nil {
+		return 0, err
+	}
+	defer c.close(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return 0, errStatus(res.StatusCode)
+	}
+	n, err := io.Copy(io.Discard, io.LimitReader(res.Body, c.opts.SampleSize))
+	if n == 0 {
+		return 0, stalled(err)
+	}
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return 0, err
+	}
+	return speed(n, time.Since(start)), nil
+}
+
+func (c *Checker) get(ctx context.Context, u url.URL, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	return c.client.Do(req)
+}
+
+func (c *Checker) close(rc io.Closer) {
+	if err := rc.Close(); err != nil {
+		c.log.Warn("failed to close response body", "err", err)
+	}
+}
+
+func isPlaylist(res *http.Response) bool {
+	if strings.Contains(strings.ToLower(res.Header.Get("Content-Type")), "mpegurl") {
+		return true
+	}
+	return strings.HasSuffix(res.Request.URL.Path, ".m3u8")
+}
+
+// stalled returns ErrStalled for the timeout, so that a slow stream is not reported as a network error.
+func stalled(err error) error {
+	if err == nil || errors.Is(err, io.EOF) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrStalled
+	}
+	return err
+}
+
+func speed(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		d = time.Millisecond
+	}
+	return float64(n) / d.Seconds()
+}
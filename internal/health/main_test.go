@@ -0,0 +1,130 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker_Check(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Referer") != "https://example.com/" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/live.flv":
+			_, _ = w.Write(make([]byte, 1024))
+			w.(http.Flusher).Flush()
+			// the live stream never ends
+			<-r.Context().Done()
+		case "/stalled.flv":
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case "/master.m3u8":
+			_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000\nlive/index.m3u8\n"))
+		case "/live/index.m3u8":
+			_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:1\n#EXTINF:1,\n0.ts\n"))
+		case "/live/0.ts":
+			_, _ = w.Write(make([]byte, 2048))
+		case "/empty.m3u8":
+			_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:1\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	c := New(slog.Default(), http.DefaultClient, Options{Timeout: 200 * time.Millisecond})
+	header := http.Header{"Referer": {"https://example.com/"}}
+
+	tests := []struct {
+		name       string
+		path       string
+		header     http.Header
+		statusCode int
+		wantErr    string
+	}{
+		{
+			name:       "should sample the flv stream until timeout",
+			path:       "/live.flv",
+			header:     header,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "should download the first segment of the first variant",
+			path:       "/master.m3u8",
+			header:     header,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "should be unhealthy since no data",
+			path:       "/stalled.flv",
+			header:     header,
+			statusCode: http.StatusOK,
+			wantErr:    "failed to probe: no data received",
+		},
+		{
+			name:       "should be unhealthy since no segment",
+			path:       "/empty.m3u8",
+			header:     header,
+			statusCode: http.StatusOK,
+			wantErr:    "failed to probe: no segment in the playlist",
+		},
+		{
+			name:       "should be unhealthy since not found",
+			path:       "/missing.flv",
+			header:     header,
+			statusCode: http.StatusNotFound,
+			wantErr:    "failed to probe: unexpected status code: 404",
+		},
+		{
+			name:       "should be unhealthy since forbidden without header",
+			path:       "/live.flv",
+			statusCode: http.StatusForbidden,
+			wantErr:    "failed to probe: unexpected status code: 403",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse(ts.URL + tt.path)
+			r := c.Check(context.Background(), *u, tt.header)
+			assert.Equal(t, *u, r.Url)
+			assert.Equal(t, tt.statusCode, r.StatusCode)
+			if tt.wantErr != "" {
+				assert.False(t, r.Healthy())
+				assert.EqualError(t, r.Err, tt.wantErr)
+				return
+			}
+			assert.True(t, r.Healthy())
+			assert.NoError(t, r.Err)
+			assert.Greater(t, r.TTFB, time.Duration(0))
+			assert.Greater(t, r.Speed, float64(0))
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	u := func(h string) url.URL {
+		return url.URL{Scheme: "https", Host: h}
+	}
+	rs := Rank([]Result{
+		{Url: u("a"), Err: errors.New("403")},
+		{Url: u("b"), Speed: 100, TTFB: time.Second},
+		{Url: u("c"), Err: errors.New("404")},
+		{Url: u("d"), Speed: 200, TTFB: 2 * time.Second},
+		{Url: u("e"), Speed: 100, TTFB: 500 * time.Millisecond},
+	})
+	hosts := make([]string, len(rs))
+	for i, r := range rs {
+		hosts[i] = r.Url.Host
+	}
+	assert.Equal(t, []string{"d", "e", "b", "a", "c"}, hosts)
+}
@@ -0,0 +1,7 @@
+package notify
+
+import "fmt"
+
+func ErrSend(err error) error {
+	return fmt.Errorf("failed to send notification: %w", err)
+}
@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const appName = "Asmblive"
+
+// Send shows a native desktop notification with the tools shipped by the os.
+func Send(title string, body string) error {
+	cmd, err := command(runtime.GOOS, title, body)
+	if err != nil {
+		return ErrSend(err)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return ErrSend(fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out))))
+	}
+	return nil
+}
+
+func command(goos string, title string, body string) (*exec.Cmd, error) {
+	switch goos {
+	case "windows":
+		script := fmt.Sprintf(`[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$x = $t.GetElementsByTagName('text')
+$x.Item(0).AppendChild($t.CreateTextNode(%s)) > $null
+$x.Item(1).AppendChild($t.CreateTextNode(%s)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier(%s).Show([Windows.UI.Notifications.ToastNotification]::new($t))`,
+			quotePowerShell(title), quotePowerShell(body), quotePowerShell(appName))
+		return exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script), nil
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s subtitle %s",
+			quoteAppleScript(body), quoteAppleScript(appName), quoteAppleScript(title))
+		return exec.Command("osascript", "-e", script), nil
+	case "linux":
+		// the title and body may start with `-`, they must not be parsed as options
+		return exec.Command("notify-send", "--app-name="+appName, "--", title, body), nil
+	default:
+		return nil, fmt.Errorf("unsupported os for notification: %s", goos)
+	}
+}
+
+// quotePowerShell returns a single-quoted string literal, single quotes are escaped by doubling.
+func quotePowerShell(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// quoteAppleScript returns a double-quoted string literal.
+func quoteAppleScript(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
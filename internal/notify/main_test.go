@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_command(t *testing.T) {
+	t.Run("should build linux command", func(t *testing.T) {
+		cmd, err := command("linux", "痒局长 开播了", "俺也玩鸣潮")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"notify-send", "--app-name=Asmblive", "--", "痒局长 开播了", "俺也玩鸣潮"}, cmd.Args)
+	})
+
+	t.Run("should not parse linux title as option", func(t *testing.T) {
+		cmd, err := command("linux", "-u critical", "body")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"notify-send", "--app-name=Asmblive", "--", "-u critical", "body"}, cmd.Args)
+	})
+
+	t.Run("should escape darwin script", func(t *testing.T) {
+		cmd, err := command("darwin", `say "hi"`, `a\b`)
+		assert.NoError(t, err)
+		assert.Equal(t, `display notification "a\\b" with title "Asmblive" subtitle "say \"hi\""`, cmd.Args[2])
+	})
+
+	t.Run("should escape windows script", func(t *testing.T) {
+		cmd, err := command("windows", "it's live", "title")
+		assert.NoError(t, err)
+		assert.Contains(t, cmd.Args[4], `CreateTextNode('it''s live')`)
+	})
+
+	t.Run("should return error since unsupported os", func(t *testing.T) {
+		_, err := command("plan9", "a", "b")
+		assert.ErrorContains(t, err, "unsupported os for notification: plan9")
+	})
+}
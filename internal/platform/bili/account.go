@@ -0,0 +1,79 @@
+package bili
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// notLoggedInCode is the response code if the cookie is missing or expired.
+const notLoggedInCode = -101
+
+// Account is the logged-in user of the stored cookie.
+type Account struct {
+	IsLogin bool
+	Uid     string
+	Name    string
+	IsVip   bool
+	// ExpiresAt is the expiry of the SESSDATA cookie, zero if unknown.
+	ExpiresAt time.Time
+}
+
+// GetAccount requests the user info with the stored cookie, IsLogin is false if the cookie is missing
+// or rejected.
+func (b Bili) GetAccount(ctx context.Context) (Account, error) {
+	bc := biliClient[nav]{b.pc, b.log, b.st, b.wbi}
+	u := url.URL{
+		Scheme: "https",
+		Host:   "api.bilibili.com",
+		Path:   "/x/web-interface/nav",
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Account{}, ErrGetAccount(err)
+	}
+	n, err := bc.getJson(req)
+	if isCode(err, notLoggedInCode) {
+		return Account{}, nil
+	}
+	if err != nil {
+		return Account{}, ErrGetAccount(err)
+	}
+	if !n.IsLogin {
+		return Account{}, nil
+	}
+	return Account{
+		IsLogin:   true,
+		Uid:       strconv.FormatInt(n.Mid, 10),
+		Name:      n.Uname,
+		IsVip:     n.VipStatus == 1,
+		ExpiresAt: sessdataExpiry(b.st.GetBiliCookie()),
+	}, nil
+}
+
+// sessdataExpiry returns the expiry carried by the SESSDATA cookie, whose value is like
+// `abc%2C1737187200%2C8f2a1%2A71`, i.e. the url-encoded `abc,1737187200,8f2a1*71`.
+func sessdataExpiry(cookie string) time.Time {
+	for _, p := range strings.Split(cookie, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok || k != "SESSDATA" {
+			continue
+		}
+		if uv, err := url.QueryUnescape(v); err == nil {
+			v = uv
+		}
+		fs := strings.Split(v, ",")
+		if len(fs) < 2 {
+			return time.Time{}
+		}
+		sec, err := strconv.ParseInt(fs[1], 10, 64)
+		if err != nil || sec <= 0 {
+			return time.Time{}
+		}
+		return time.Unix(sec, 0)
+	}
+	return time.Time{}
+}
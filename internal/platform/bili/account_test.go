@@ -0,0 +1,87 @@
+package bili
+
+import (
+	"asmblive/internal/setting"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBili_GetAccount(t *testing.T) {
+	tests := []struct {
+		name    string
+		res     string
+		want    Account
+		wantErr string
+	}{
+		{
+			name: "should return the logged-in account",
+			res:  `{"code":0,"message":"0","data":{"isLogin":true,"mid":2,"uname":"碧诗","vipStatus":1}}`,
+			want: Account{IsLogin: true, Uid: "2", Name: "碧诗", IsVip: true, ExpiresAt: time.Unix(1737187200, 0)},
+		},
+		{
+			name: "should not be logged in since the cookie is rejected",
+			res:  `{"code":-101,"message":"账号未登录","data":{"isLogin":false}}`,
+			want: Account{},
+		},
+		{
+			name:    "should return error since unexpected code",
+			res:     `{"code":-412,"message":"请求被拦截","data":null}`,
+			wantErr: "failed to get account: request failed: unexpected response code: -412, message: 请求被拦截",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Bili{
+				log: slog.Default(),
+				pc:  mc{res: []byte(tt.res)},
+				st: &setting.Bili{
+					Store: mapStore{"bili_cookie": "SESSDATA=abc%2C1737187200%2C8f2a1%2A71; bili_jct=jct"},
+					Log:   slog.Default(),
+				},
+			}
+			got, err := b.GetAccount(context.TODO())
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_sessdataExpiry(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie string
+		want   time.Time
+	}{
+		{
+			name:   "should parse the encoded value",
+			cookie: "DedeUserID=2; SESSDATA=abc%2C1737187200%2C8f2a1%2A71",
+			want:   time.Unix(1737187200, 0),
+		},
+		{
+			name:   "should parse the decoded value",
+			cookie: "SESSDATA=abc,1737187200,8f2a1*71",
+			want:   time.Unix(1737187200, 0),
+		},
+		{
+			name:   "should return zero since no SESSDATA",
+			cookie: "bili_jct=jct",
+		},
+		{
+			name:   "should return zero since no expiry",
+			cookie: "SESSDATA=abc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sessdataExpiry(tt.cookie))
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package bili
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// batchSize is the max number of ids in one batch request.
+const batchSize = 50
+
+// GetRooms gets the rooms with getRoomBaseInfo and the avatars of the owners with get_status_info_by_uids,
+// so that a board needs 2 requests in most cases instead of one per room.
+func (b Bili) GetRooms(ctx context.Context, roomIds []string) (map[string]platform.Room, error) {
+	rooms := make(map[string]platform.Room, len(roomIds))
+	for start := 0; start < len(roomIds); start += batchSize {
+		end := min(start+batchSize, len(roomIds))
+		if err := b.getRooms(ctx, roomIds[start:end], rooms); err != nil {
+			return nil, ErrGetRooms(err)
+		}
+	}
+	return rooms, nil
+}
+
+func (b Bili) getRooms(ctx context.Context, roomIds []string, rooms map[string]platform.Room) error {
+	bis, err := b.getRoomBaseInfos(ctx, roomIds)
+	if err != nil {
+		return err
+	}
+	uids := make([]string, 0, len(bis.ByRoomIds))
+	for _, bi := range bis.ByRoomIds {
+		uids = append(uids, strconv.FormatInt(bi.Uid, 10))
+	}
+	sis, err := b.getStatusInfos(ctx, uids)
+	if err != nil {
+		return err
+	}
+	// the result is keyed by the long room id, but the short one may be requested
+	requested := make(map[string]bool, len(roomIds))
+	for _, id := range roomIds {
+		requested[id] = true
+	}
+	for _, bi := range bis.ByRoomIds {
+		cu, err := parseSchemelessUrl(bi.Cover)
+		if err != nil {
+			return fmt.Errorf("failed to parse cover url: %w", err)
+		}
+		uid := strconv.FormatInt(bi.Uid, 10)
+		au, err := parseSchemelessUrl(sis[uid].Face)
+		if err != nil {
+			return fmt.Errorf("failed to parse avatar url: %w", err)
+		}
+		r := platform.Room{
+			Id:       strconv.FormatInt(bi.RoomId, 10),
+			Title:    bi.Title,
+			IsOnline: bi.LiveStatus == 1,
+			CoverUrl: b.srv.GetCorsProxyUrl(*cu),
+			Owner: platform.Owner{
+				Id:        uid,
+				Name:      bi.Uname,
+				AvatarUrl: b.srv.GetCorsProxyUrl(*au),
+			},
+		}
+		if requested[r.Id] {
+			rooms[r.Id] = r
+		}
+		if sid := strconv.FormatInt(bi.ShortId, 10); bi.ShortId != 0 && requested[sid] {
+			rooms[sid] = r
+		}
+	}
+	return nil
+}
+
+func (b Bili) getRoomBaseInfos(ctx context.Context, roomIds []string) (*roomBaseInfos, error) {
+	bc := biliClient[roomBaseInfos]{b.pc, b.log, b.st, b.wbi}
+	u := url.URL{
+		Scheme: "https",
+		Host:   "api.live.bilibili.com",
+		Path:   "/xlive/web-room/v1/index/getRoomBaseInfo",
+	}
+	q := u.Query()
+	q.Set("req_biz", "web_room_componet")
+	for _, id := range roomIds {
+		q.Add("room_ids", id)
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errGetRoomBaseInfos(err)
+	}
+	bis, err := bc.getJson(req)
+	if err != nil {
+		return nil, errGetRoomBaseInfos(err)
+	}
+	return bis, nil
+}
+
+func (b Bili) getStatusInfos(ctx context.Context, uids []string) (statusInfos, error) {
+	if len(uids) == 0 {
+		return statusInfos{}, nil
+	}
+	bc := biliClient[statusInfos]{b.pc, b.log, b.st, b.wbi}
+	u := url.URL{
+		Scheme: "https",
+		Host:   "api.live.bilibili.com",
+		Path:   "/room/v1/Room/get_status_info_by_uids",
+	}
+	q := u.Query()
+	for _, uid := range uids {
+		q.Add("uids[]", uid)
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errGetStatusInfos(err)
+	}
+	sis, err := bc.getJson(req)
+	if err != nil {
+		return nil, errGetStatusInfos(err)
+	}
+	return *sis, nil
+}
@@ -0,0 +1,109 @@
+package bili
+
+import (
+	"asmblive/internal/platform"
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pathClient responds with the content of the file keyed by the request path.
+type pathClient map[string]string
+
+func (c pathClient) Do(req *http.Request) (*http.Response, error) {
+	f, ok := c[req.URL.Path]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody}, nil
+	}
+	data, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+	}, nil
+}
+
+func TestBili_GetRooms(t *testing.T) {
+	pc := pathClient{
+		"/xlive/web-room/v1/index/getRoomBaseInfo": "testData/getRoomBaseInfo.json",
+		"/room/v1/Room/get_status_info_by_uids":    "testData/getStatusInfoByUids.json",
+	}
+	room := platform.Room{
+		Id:       "5441",
+		Title:    "俺也玩鸣潮",
+		IsOnline: false,
+		CoverUrl: url.URL{Scheme: "https", Host: "i0.hdslb.com", Path: "/bfs/live/new_room_cover/cover2.jpg"},
+		Owner: platform.Owner{
+			Id:        "322892",
+			Name:      "痒局长",
+			AvatarUrl: url.URL{Scheme: "https", Host: "i2.hdslb.com", Path: "/bfs/face/face2.jpg"},
+		},
+	}
+	tests := []struct {
+		name    string
+		pc      platform.Client
+		roomIds []string
+		want    map[string]platform.Room
+		wantErr string
+	}{
+		{
+			name:    "should return rooms keyed by requested ids",
+			pc:      pc,
+			roomIds: []string{"21452505", "528", "404"},
+			want: map[string]platform.Room{
+				"21452505": {
+					Id:       "21452505",
+					Title:    "鸣潮 & 原神",
+					IsOnline: true,
+					CoverUrl: url.URL{Scheme: "https", Host: "i0.hdslb.com", Path: "/bfs/live/user_cover/cover.jpg"},
+					Owner: platform.Owner{
+						Id:        "1104048496",
+						Name:      "七海Nana7mi",
+						AvatarUrl: url.URL{Scheme: "https", Host: "i1.hdslb.com", Path: "/bfs/face/face1.jpg"},
+					},
+				},
+				"528": room,
+			},
+		},
+		{
+			name:    "should return rooms requested by both short and long ids",
+			pc:      pc,
+			roomIds: []string{"528", "5441"},
+			want:    map[string]platform.Room{"528": room, "5441": room},
+		},
+		{
+			name:    "should return empty map if no ids",
+			pc:      pc,
+			roomIds: []string{},
+			want:    map[string]platform.Room{},
+		},
+		{
+			name:    "should return error if status infos are unavailable",
+			pc:      pathClient{"/xlive/web-room/v1/index/getRoomBaseInfo": "testData/getRoomBaseInfo.json"},
+			roomIds: []string{"5441"},
+			wantErr: "failed to get rooms: failed to get status infos: request failed: unexpected content type: ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBili(slog.Default(), tt.pc, nil, nil, mockServer{})
+			got, err := b.GetRooms(context.Background(), tt.roomIds)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
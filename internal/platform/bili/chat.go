@@ -0,0 +1,97 @@
+package bili
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Subscribe implements platform.ChatSource on top of the danmaku connection.
+func (b Bili) Subscribe(ctx context.Context, roomId string) (<-chan platform.ChatEvent, error) {
+	dch, err := b.ConnectDanmaku(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan platform.ChatEvent, eventBufferSize)
+	go func() {
+		defer close(ch)
+		for de := range dch {
+			ce, ok := toChatEvent(de)
+			if !ok {
+				b.log.Warn("drop unknown danmaku event", "type", fmt.Sprintf("%T", de))
+				continue
+			}
+			select {
+			case ch <- ce:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// toChatEvent converts the danmaku event, the amounts are in 1/1000 yuan. It returns false if the
+// event is unknown.
+func toChatEvent(de DanmakuEvent) (platform.ChatEvent, bool) {
+	switch e := de.(type) {
+	case ChatMessage:
+		return platform.ChatEvent{
+			Kind: platform.ChatEventMessage,
+			User: chatUser(e.Uid, e.Uname),
+			Text: e.Text,
+			Time: e.Time,
+			Raw:  e.Raw,
+		}, true
+	case Gift:
+		var amount int64
+		// silver gifts are free
+		if e.CoinType == "gold" {
+			amount = e.Price * int64(e.Num)
+		}
+		return platform.ChatEvent{
+			Kind:   platform.ChatEventGift,
+			User:   chatUser(e.Uid, e.Uname),
+			Text:   fmt.Sprintf("%s x%d", e.GiftName, e.Num),
+			Amount: amount,
+			Time:   e.Time,
+			Raw:    e.Raw,
+		}, true
+	case SuperChat:
+		return platform.ChatEvent{
+			Kind:   platform.ChatEventSuperChat,
+			User:   chatUser(e.Uid, e.Uname),
+			Text:   e.Message,
+			Amount: e.Price * 1000,
+			Time:   e.Time,
+			Raw:    e.Raw,
+		}, true
+	case GuardBuy:
+		return platform.ChatEvent{
+			Kind:   platform.ChatEventMembership,
+			User:   chatUser(e.Uid, e.Uname),
+			Text:   fmt.Sprintf("%s x%d", e.GiftName, e.Num),
+			Amount: e.Price * int64(e.Num),
+			Time:   e.Time,
+			Raw:    e.Raw,
+		}, true
+	case OnlineRankCount:
+		return platform.ChatEvent{
+			Kind:   platform.ChatEventOnlineCount,
+			Amount: e.OnlineCount,
+			Time:   time.Now(),
+			Raw:    e.Raw,
+		}, true
+	default:
+		return platform.ChatEvent{}, false
+	}
+}
+
+func chatUser(uid int64, uname string) platform.ChatUser {
+	return platform.ChatUser{
+		Id:   strconv.FormatInt(uid, 10),
+		Name: uname,
+	}
+}
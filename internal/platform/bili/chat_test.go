@@ -0,0 +1,67 @@
+package bili
+
+import (
+	"asmblive/internal/platform"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_toChatEvent(t *testing.T) {
+	ts := time.Unix(1721800000, 0)
+	tests := []struct {
+		name string
+		de   DanmakuEvent
+		want platform.ChatEvent
+	}{
+		{
+			name: "should convert chat message",
+			de:   ChatMessage{Uid: 1, Uname: "a", Text: "hi", Time: ts},
+			want: platform.ChatEvent{Kind: platform.ChatEventMessage, User: platform.ChatUser{Id: "1", Name: "a"}, Text: "hi", Time: ts},
+		},
+		{
+			name: "should convert gold gift with total price",
+			de:   Gift{Uid: 1, Uname: "a", GiftName: "辣条", Num: 3, Price: 100, CoinType: "gold", Time: ts},
+			want: platform.ChatEvent{Kind: platform.ChatEventGift, User: platform.ChatUser{Id: "1", Name: "a"}, Text: "辣条 x3", Amount: 300, Time: ts},
+		},
+		{
+			name: "should convert silver gift as free",
+			de:   Gift{Uid: 1, Uname: "a", GiftName: "小心心", Num: 1, Price: 5000, CoinType: "silver", Time: ts},
+			want: platform.ChatEvent{Kind: platform.ChatEventGift, User: platform.ChatUser{Id: "1", Name: "a"}, Text: "小心心 x1", Time: ts},
+		},
+		{
+			name: "should convert super chat in 1/1000 yuan",
+			de:   SuperChat{Uid: 2, Uname: "b", Message: "加油", Price: 30, Time: ts},
+			want: platform.ChatEvent{Kind: platform.ChatEventSuperChat, User: platform.ChatUser{Id: "2", Name: "b"}, Text: "加油", Amount: 30000, Time: ts},
+		},
+		{
+			name: "should convert guard buy",
+			de:   GuardBuy{Uid: 3, Uname: "c", GuardLevel: 3, GiftName: "舰长", Num: 2, Price: 198000, Time: ts},
+			want: platform.ChatEvent{Kind: platform.ChatEventMembership, User: platform.ChatUser{Id: "3", Name: "c"}, Text: "舰长 x2", Amount: 396000, Time: ts},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := toChatEvent(tt.de)
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("should convert online rank count", func(t *testing.T) {
+		got, ok := toChatEvent(OnlineRankCount{Count: 1, OnlineCount: 2048})
+		assert.True(t, ok)
+		assert.Equal(t, platform.ChatEventOnlineCount, got.Kind)
+		assert.Equal(t, int64(2048), got.Amount)
+	})
+
+	t.Run("should return false since unknown event", func(t *testing.T) {
+		_, ok := toChatEvent(unknownEvent{})
+		assert.False(t, ok)
+	})
+}
+
+type unknownEvent struct{}
+
+func (unknownEvent) danmakuEvent() {}
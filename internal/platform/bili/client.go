@@ -4,6 +4,7 @@ import (
 	"asmblive/internal/platform"
 	"asmblive/internal/setting"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -14,13 +15,69 @@ var commonHeaders = map[string]string{
 	"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/93.0.4577.82 Safari/537.36 OPR/79.0.4143.50",
 }
 
+// streamHeaders returns the headers of the live stream requests.
+func streamHeaders() http.Header {
+	h := http.Header{}
+	for k, v := range commonHeaders {
+		h.Set(k, v)
+	}
+	h.Set("Referer", "https://live.bilibili.com/")
+	h.Set("Origin", "https://live.bilibili.com")
+	return h
+}
+
+// codeError is returned if the code of the response is not 0.
+type codeError struct {
+	Code    int
+	Message string
+}
+
+func (e codeError) Error() string {
+	return fmt.Sprintf("unexpected response code: %d, message: %s", e.Code, e.Message)
+}
+
+// isCode reports whether the error is caused by the response code.
+func isCode(err error, code int) bool {
+	var ce codeError
+	return errors.As(err, &ce) && ce.Code == code
+}
+
 type biliClient[T any] struct {
 	platform.Client
 	log *slog.Logger
 	st  *setting.Bili
+	// wbi signs the requests of the wbi endpoints, nothing is signed if it is nil.
+	wbi *wbi
 }
 
 func (c biliClient[T]) getJson(req *http.Request) (*T, error) {
+	d, _, err := c.getJsonWithCookies(req)
+	return d, err
+}
+
+// getJsonWithCookies is getJson which also returns the cookies set by the response, e.g. the login credentials.
+// The request of a wbi endpoint is signed, and signed again with the refreshed keys if the signature is rejected.
+func (c biliClient[T]) getJsonWithCookies(req *http.Request) (*T, []*http.Cookie, error) {
+	if c.wbi == nil || !isWbiPath(req.URL) {
+		return c.do(req)
+	}
+	if err := c.wbi.sign(req); err != nil {
+		return nil, nil, platform.ErrRequest(err)
+	}
+	d, cs, err := c.do(req)
+	if !isCode(err, wbiRejectedCode) {
+		return d, cs, err
+	}
+	c.log.Warn("wbi signature is rejected, refresh the keys", "path", req.URL.Path)
+	c.wbi.invalidate()
+	req = req.Clone(req.Context())
+	if err := c.wbi.sign(req); err != nil {
+		return nil, nil, platform.ErrRequest(err)
+	}
+	return c.do(req)
+}
+
+func (c biliClient[T]) do(req *http.Request) (*T, []*http.Cookie, error) {
 	for k, v := range commonHeaders {
 		req.Header.Set(k, v)
 	}
@@ -33,7 +90,7 @@ func (c biliClient[T]) getJson(req *http.Request) (*T, error) {
 	}
 	res, err := c.Do(req)
 	if err != nil {
-		return nil, platform.ErrRequest(err)
+		return nil, nil, platform.ErrRequest(err)
 	}
 	defer func() {
 		if err := res.Body.Close(); err != nil {
@@ -42,15 +99,15 @@ func (c biliClient[T]) getJson(req *http.Request) (*T, error) {
 	}()
 	ct := res.Header.Get("Content-Type")
 	if !strings.Contains(ct, "application/json") {
-		return nil, platform.ErrRequest(fmt.Errorf("unexpected content type: %s", ct))
+		return nil, nil, platform.ErrRequest(fmt.Errorf("unexpected content type: %s", ct))
 	}
 	var rb response[T]
 	if err := json.NewDecoder(res.Body).Decode(&rb); err != nil {
 		c.log.Error("failed to decode response body", "error", err)
-		return nil, platform.ErrRequest(fmt.Errorf("failed to decode response body: %w", err))
+		return nil, nil, platform.ErrRequest(fmt.Errorf("failed to decode response body: %w", err))
 	}
 	if rb.Code != 0 {
-		return nil, platform.ErrRequest(fmt.Errorf("unexpected response code: %d, message: %s", rb.Code, rb.Message))
+		return nil, nil, platform.ErrRequest(codeError{Code: rb.Code, Message: rb.Message})
 	}
-	return &rb.Data, nil
+	return &rb.Data, res.Cookies(), nil
 }
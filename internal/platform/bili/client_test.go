@@ -66,7 +66,7 @@ func Test_biliClient_getJson(t *testing.T) {
 				_, _ = w.Write(tt.fields.body)
 			}))
 			c := biliClient[testData]{
-				Client: platform.NewClient(slog.Default(), platform.Headers{}),
+				Client: platform.NewClient(slog.Default(), platform.Headers{}, platform.ClientOptions{}),
 				log:    slog.Default(),
 			}
 			req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
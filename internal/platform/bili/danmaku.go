@@ -0,0 +1,228 @@
+package bili
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	heartbeatInterval = 30 * time.Second
+	authTimeout       = 10 * time.Second
+	eventBufferSize   = 64
+)
+
+// ConnectDanmaku connects to the broadcast server of the room and returns the events of it,
+// the channel is closed when the context is done or the connection is broken.
+func (b Bili) ConnectDanmaku(ctx context.Context, roomId string) (<-chan DanmakuEvent, error) {
+	// the auth packet requires the long room id
+	r, err := b.GetRoom(ctx, roomId)
+	if err != nil {
+		return nil, ErrConnectDanmaku(err)
+	}
+	rid, err := strconv.ParseInt(r.Id, 10, 64)
+	if err != nil {
+		return nil, ErrConnectDanmaku(err)
+	}
+	di, err := b.getDanmuInfo(ctx, r.Id)
+	if err != nil {
+		return nil, ErrConnectDanmaku(err)
+	}
+	conn, err := b.dialDanmaku(ctx, di)
+	if err != nil {
+		return nil, ErrConnectDanmaku(err)
+	}
+	if err := b.authDanmaku(conn, rid, di.Token); err != nil {
+		_ = conn.Close()
+		return nil, ErrConnectDanmaku(err)
+	}
+	log := b.log.With("roomId", r.Id)
+	log.Info("danmaku connected", "host", conn.RemoteAddr())
+	ch := make(chan DanmakuEvent, eventBufferSize)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		_ = conn.Close()
+	}()
+	go func() {
+		t := time.NewTicker(heartbeatInterval)
+		defer t.Stop()
+		for {
+			hb := encodePacket(packet{proto: protoInt, op: opHeartbeat, body: []byte("[object Object]")})
+			if err := conn.WriteMessage(websocket.BinaryMessage, hb); err != nil {
+				log.Info("danmaku heartbeat stopped", "err", err)
+				return
+			}
+			select {
+			case <-done:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	go func() {
+		defer close(ch)
+		defer close(done)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				log.Info("danmaku disconnected", "err", err)
+				return
+			}
+			ps, err := decodePackets(data)
+			if err != nil {
+				log.Warn("failed to decode danmaku packets", "err", err)
+				continue
+			}
+			for _, p := range ps {
+				if p.op == opHeartbeatReply {
+					if pop, err := p.popularity(); err == nil {
+						log.Debug("danmaku heartbeat reply", "popularity", pop)
+					}
+					continue
+				}
+				if p.op != opMessage {
+					continue
+				}
+				e, err := decodeEvent(p.body)
+				if err != nil {
+					log.Warn("failed to decode danmaku event", "err", err)
+					continue
+				}
+				if e == nil {
+					continue
+				}
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (b Bili) getDanmuInfo(ctx context.Context, roomId string) (*danmuInfo, error) {
+	bc := biliClient[danmuInfo]{b.pc, b.log, b.st, b.wbi}
+	u := url.URL{
+		Scheme: "https",
+		Host:   "api.live.bilibili.com",
+		Path:   "/xlive/web-room/v1/index/getDanmuInfo",
+	}
+	q := u.Query()
+	q.Set("id", roomId)
+	q.Set("type", "0")
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errGetDanmuInfo(err)
+	}
+	di, err := bc.getJson(req)
+	if err != nil {
+		return nil, errGetDanmuInfo(err)
+	}
+	if len(di.HostList) == 0 {
+		return nil, errGetDanmuInfo(errors.New("empty host list"))
+	}
+	return di, nil
+}
+
+// dialDanmaku tries the hosts in order and returns the first connection.
+func (b Bili) dialDanmaku(ctx context.Context, di *danmuInfo) (*websocket.Conn, error) {
+	d := b.wsd
+	if d == nil {
+		d = websocket.DefaultDialer
+	}
+	h := http.Header{}
+	for k, v := range commonHeaders {
+		h.Set(k, v)
+	}
+	h.Set("Origin", "https://live.bilibili.com")
+	if b.st != nil {
+		if ck := b.st.GetBiliCookie(); ck != "" {
+			h.Set("Cookie", ck)
+		}
+	}
+	var errs []error
+	for _, host := range di.HostList {
+		u := url.URL{
+			Scheme: "wss",
+			Host:   net.JoinHostPort(host.Host, strconv.Itoa(host.WssPort)),
+			Path:   "/sub",
+		}
+		conn, _, err := d.DialContext(ctx, u.String(), h)
+		if err != nil {
+			b.log.Warn("failed to dial danmaku host", "host", u.Host, "err", err)
+			errs = append(errs, err)
+			continue
+		}
+		return conn, nil
+	}
+	return nil, fmt.Errorf("all hosts failed: %w", errors.Join(errs...))
+}
+
+func (b Bili) authDanmaku(conn *websocket.Conn, roomId int64, token string) error {
+	var uid int64
+	var buvid string
+	if b.st != nil {
+		req := http.Request{Header: http.Header{"Cookie": {b.st.GetBiliCookie()}}}
+		if c, err := req.Cookie("DedeUserID"); err == nil {
+			uid, _ = strconv.ParseInt(c.Value, 10, 64)
+		}
+		if c, err := req.Cookie("buvid3"); err == nil {
+			buvid = c.Value
+		}
+	}
+	body, err := json.Marshal(map[string]any{
+		"uid":      uid,
+		"roomid":   roomId,
+		"protover": protoBrotli,
+		"buvid":    buvid,
+		"platform": "web",
+		"type":     2,
+		"key":      token,
+	})
+	if err != nil {
+		return err
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, encodePacket(packet{proto: protoInt, op: opAuth, body: body})); err != nil {
+		return err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(authTimeout))
+	defer func() {
+		_ = conn.SetReadDeadline(time.Time{})
+	}()
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	ps, err := decodePackets(data)
+	if err != nil {
+		return err
+	}
+	if len(ps) == 0 || ps[0].op != opAuthReply {
+		return errors.New("unexpected auth reply")
+	}
+	var rb struct {
+		Code int `json:"code"`
+	}
+	if err := json.Unmarshal(ps[0].body, &rb); err != nil {
+		return err
+	}
+	if rb.Code != 0 {
+		return fmt.Errorf("auth failed with code: %d", rb.Code)
+	}
+	return nil
+}
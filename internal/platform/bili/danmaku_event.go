@@ -0,0 +1,217 @@
+package bili
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// DanmakuEvent is one of ChatMessage, Gift, SuperChat, GuardBuy and OnlineRankCount.
+type DanmakuEvent interface {
+	danmakuEvent()
+}
+
+type ChatMessage struct {
+	Uid   int64
+	Uname string
+	Text  string
+	Time  time.Time
+	Raw   json.RawMessage
+}
+
+type Gift struct {
+	Uid      int64
+	Uname    string
+	GiftName string
+	Num      int
+	// Price is the price of a single gift, in 1/1000 yuan if the coin type is `gold`.
+	Price    int64
+	CoinType string
+	Time     time.Time
+	Raw      json.RawMessage
+}
+
+type SuperChat struct {
+	Uid     int64
+	Uname   string
+	Message string
+	// Price is in yuan.
+	Price int64
+	Time  time.Time
+	Raw   json.RawMessage
+}
+
+type GuardBuy struct {
+	Uid   int64
+	Uname string
+	// GuardLevel is 1 for 总督, 2 for 提督 and 3 for 舰长.
+	GuardLevel int
+	GiftName   string
+	Num        int
+	// Price is in 1/1000 yuan.
+	Price int64
+	Time  time.Time
+	Raw   json.RawMessage
+}
+
+type OnlineRankCount struct {
+	Count       int64
+	OnlineCount int64
+	Raw         json.RawMessage
+}
+
+func (ChatMessage) danmakuEvent()     {}
+func (Gift) danmakuEvent()            {}
+func (SuperChat) danmakuEvent()       {}
+func (GuardBuy) danmakuEvent()        {}
+func (OnlineRankCount) danmakuEvent() {}
+
+type command struct {
+	Cmd  string          `json:"cmd"`
+	Info json.RawMessage `json:"info"`
+	Data json.RawMessage `json:"data"`
+}
+
+// decodeEvent decodes the body of a message packet, it returns nil if the command is not interesting.
+func decodeEvent(body []byte) (DanmakuEvent, error) {
+	var c command
+	if err := json.Unmarshal(body, &c); err != nil {
+		return nil, errDecodeEvent(err)
+	}
+	raw := json.RawMessage(body)
+	// the command may have suffixes, e.g. `DANMU_MSG:4:0:2:2:2:0`
+	cmd, _, _ := strings.Cut(c.Cmd, ":")
+	switch cmd {
+	case "DANMU_MSG":
+		return decodeChatMessage(c.Info, raw)
+	case "SEND_GIFT":
+		var d struct {
+			Uid       int64  `json:"uid"`
+			Uname     string `json:"uname"`
+			GiftName  string `json:"giftName"`
+			Num       int    `json:"num"`
+			Price     int64  `json:"price"`
+			CoinType  string `json:"coin_type"`
+			Timestamp int64  `json:"timestamp"`
+		}
+		if err := json.Unmarshal(c.Data, &d); err != nil {
+			return nil, errDecodeEvent(err)
+		}
+		return Gift{
+			Uid:      d.Uid,
+			Uname:    d.Uname,
+			GiftName: d.GiftName,
+			Num:      d.Num,
+			Price:    d.Price,
+			CoinType: d.CoinType,
+			Time:     time.Unix(d.Timestamp, 0),
+			Raw:      raw,
+		}, nil
+	case "SUPER_CHAT_MESSAGE":
+		var d struct {
+			Uid       int64  `json:"uid"`
+			Message   string `json:"message"`
+			Price     int64  `json:"price"`
+			StartTime int64  `json:"start_time"`
+			UserInfo  struct {
+				Uname string `json:"uname"`
+			} `json:"user_info"`
+		}
+		if err := json.Unmarshal(c.Data, &d); err != nil {
+			return nil, errDecodeEvent(err)
+		}
+		return SuperChat{
+			Uid:     d.Uid,
+			Uname:   d.UserInfo.Uname,
+			Message: d.Message,
+			Price:   d.Price,
+			Time:    time.Unix(d.StartTime, 0),
+			Raw:     raw,
+		}, nil
+	case "GUARD_BUY":
+		var d struct {
+			Uid        int64  `json:"uid"`
+			Username   string `json:"username"`
+			GuardLevel int    `json:"guard_level"`
+			GiftName   string `json:"gift_name"`
+			Num        int    `json:"num"`
+			Price      int64  `json:"price"`
+			StartTime  int64  `json:"start_time"`
+		}
+		if err := json.Unmarshal(c.Data, &d); err != nil {
+			return nil, errDecodeEvent(err)
+		}
+		return GuardBuy{
+			Uid:        d.Uid,
+			Uname:      d.Username,
+			GuardLevel: d.GuardLevel,
+			GiftName:   d.GiftName,
+			Num:        d.Num,
+			Price:      d.Price,
+			Time:       time.Unix(d.StartTime, 0),
+			Raw:        raw,
+		}, nil
+	case "ONLINE_RANK_COUNT":
+		var d struct {
+			Count       int64 `json:"count"`
+			OnlineCount int64 `json:"online_count"`
+		}
+		if err := json.Unmarshal(c.Data, &d); err != nil {
+			return nil, errDecodeEvent(err)
+		}
+		return OnlineRankCount{
+			Count:       d.Count,
+			OnlineCount: d.OnlineCount,
+			Raw:         raw,
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// decodeChatMessage decodes the info of `DANMU_MSG`, it is an array:
+// info[0][4] is the timestamp in milliseconds, info[1] is the text, info[2][0] and info[2][1] are the uid and the name.
+func decodeChatMessage(info json.RawMessage, raw json.RawMessage) (DanmakuEvent, error) {
+	var is []json.RawMessage
+	if err := json.Unmarshal(info, &is); err != nil {
+		return nil, errDecodeEvent(err)
+	}
+	if len(is) < 3 {
+		return nil, errDecodeEvent(errors.New("short danmu info"))
+	}
+	var meta []json.RawMessage
+	if err := json.Unmarshal(is[0], &meta); err != nil {
+		return nil, errDecodeEvent(err)
+	}
+	var ts int64
+	if len(meta) > 4 {
+		_ = json.Unmarshal(meta[4], &ts)
+	}
+	var text string
+	if err := json.Unmarshal(is[1], &text); err != nil {
+		return nil, errDecodeEvent(err)
+	}
+	var user []json.RawMessage
+	if err := json.Unmarshal(is[2], &user); err != nil {
+		return nil, errDecodeEvent(err)
+	}
+	if len(user) < 2 {
+		return nil, errDecodeEvent(errors.New("short danmu user info"))
+	}
+	var uid int64
+	var uname string
+	if err := json.Unmarshal(user[0], &uid); err != nil {
+		return nil, errDecodeEvent(err)
+	}
+	if err := json.Unmarshal(user[1], &uname); err != nil {
+		return nil, errDecodeEvent(err)
+	}
+	return ChatMessage{
+		Uid:   uid,
+		Uname: uname,
+		Text:  text,
+		Time:  time.UnixMilli(ts),
+		Raw:   raw,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package bili
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_decodeEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    DanmakuEvent
+		wantErr string
+	}{
+		{
+			name: "should decode chat message",
+			body: `{"cmd":"DANMU_MSG:4:0:2:2:2:0","info":[[0,1,25,16777215,1721800000123,0,0,"",0,0,0,"",0],"主播好",[322892,"痒局长",0,0,0,10000,1,""]]}`,
+			want: ChatMessage{Uid: 322892, Uname: "痒局长", Text: "主播好", Time: time.UnixMilli(1721800000123)},
+		},
+		{
+			name: "should decode gift",
+			body: `{"cmd":"SEND_GIFT","data":{"uid":1,"uname":"a","giftName":"辣条","num":3,"price":100,"coin_type":"gold","timestamp":1721800000}}`,
+			want: Gift{Uid: 1, Uname: "a", GiftName: "辣条", Num: 3, Price: 100, CoinType: "gold", Time: time.Unix(1721800000, 0)},
+		},
+		{
+			name: "should decode super chat",
+			body: `{"cmd":"SUPER_CHAT_MESSAGE","data":{"uid":2,"message":"加油","price":30,"start_time":1721800000,"user_info":{"uname":"b"}}}`,
+			want: SuperChat{Uid: 2, Uname: "b", Message: "加油", Price: 30, Time: time.Unix(1721800000, 0)},
+		},
+		{
+			name: "should decode guard buy",
+			body: `{"cmd":"GUARD_BUY","data":{"uid":3,"username":"c","guard_level":3,"num":1,"price":198000,"gift_name":"舰长","start_time":1721800000}}`,
+			want: GuardBuy{Uid: 3, Uname: "c", GuardLevel: 3, GiftName: "舰长", Num: 1, Price: 198000, Time: time.Unix(1721800000, 0)},
+		},
+		{
+			name: "should decode online rank count",
+			body: `{"cmd":"ONLINE_RANK_COUNT","data":{"count":1024,"online_count":2048}}`,
+			want: OnlineRankCount{Count: 1024, OnlineCount: 2048},
+		},
+		{
+			name: "should ignore unknown command",
+			body: `{"cmd":"INTERACT_WORD","data":{}}`,
+			want: nil,
+		},
+		{
+			name:    "should return error since invalid danmu info",
+			body:    `{"cmd":"DANMU_MSG","info":[[0]]}`,
+			wantErr: "short danmu info",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeEvent([]byte(tt.body))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			// the raw body is checked separately
+			raw := json.RawMessage(tt.body)
+			switch e := got.(type) {
+			case ChatMessage:
+				assert.Equal(t, raw, e.Raw)
+				e.Raw = nil
+				got = e
+			case Gift:
+				e.Raw = nil
+				got = e
+			case SuperChat:
+				e.Raw = nil
+				got = e
+			case GuardBuy:
+				e.Raw = nil
+				got = e
+			case OnlineRankCount:
+				e.Raw = nil
+				got = e
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package bili
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// the layout of the packet header, all fields are big endian:
+//
+//	| packet length (4) | header length (2) | protocol version (2) | operation (4) | sequence (4) |
+const packetHeaderLen = 16
+
+// protocol versions, the body of the compressed packets is a sequence of packets.
+const (
+	protoJson    uint16 = 0
+	protoInt     uint16 = 1
+	protoZlib    uint16 = 2
+	protoBrotli  uint16 = 3
+	maxPacketLen        = 16 * 1024 * 1024 // 16 MB
+)
+
+// operations of the packet.
+const (
+	opHeartbeat      uint32 = 2
+	opHeartbeatReply uint32 = 3
+	opMessage        uint32 = 5
+	opAuth           uint32 = 7
+	opAuthReply      uint32 = 8
+)
+
+type packet struct {
+	proto uint16
+	op    uint32
+	body  []byte
+}
+
+func encodePacket(p packet) []byte {
+	buf := make([]byte, packetHeaderLen+len(p.body))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(buf)))
+	binary.BigEndian.PutUint16(buf[4:6], packetHeaderLen)
+	binary.BigEndian.PutUint16(buf[6:8], p.proto)
+	binary.BigEndian.PutUint32(buf[8:12], p.op)
+	binary.BigEndian.PutUint32(buf[12:16], 1)
+	copy(buf[packetHeaderLen:], p.body)
+	return buf
+}
+
+// decodePackets decodes all packets in the data, the compressed packets are expanded recursively.
+func decodePackets(data []byte) ([]packet, error) {
+	ps := make([]packet, 0, 1)
+	for len(data) > 0 {
+		if len(data) < packetHeaderLen {
+			return nil, errDecodePacket(fmt.Errorf("short header: %d bytes", len(data)))
+		}
+		pl := binary.BigEndian.Uint32(data[0:4])
+		hl := binary.BigEndian.Uint16(data[4:6])
+		if pl > maxPacketLen || int(pl) > len(data) || int(hl) > int(pl) || hl < packetHeaderLen {
+			return nil, errDecodePacket(fmt.Errorf("invalid length, packet: %d, header: %d, data: %d", pl, hl, len(data)))
+		}
+		p := packet{
+			proto: binary.BigEndian.Uint16(data[6:8]),
+			op:    binary.BigEndian.Uint32(data[8:12]),
+			body:  data[hl:pl],
+		}
+		data = data[pl:]
+		var r io.Reader
+		switch p.proto {
+		case protoZlib:
+			zr, err := zlib.NewReader(bytes.NewReader(p.body))
+			if err != nil {
+				return nil, errDecodePacket(err)
+			}
+			r = zr
+		case protoBrotli:
+			r = brotli.NewReader(bytes.NewReader(p.body))
+		default:
+			ps = append(ps, p)
+			continue
+		}
+		body, err := io.ReadAll(io.LimitReader(r, maxPacketLen))
+		if err != nil {
+			return nil, errDecodePacket(err)
+		}
+		inner, err := decodePackets(body)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, inner...)
+	}
+	return ps, nil
+}
+
+// popularity returns the number carried by the heartbeat reply.
+func (p packet) popularity() (uint32, error) {
+	if len(p.body) < 4 {
+		return 0, errDecodePacket(errors.New("short heartbeat reply"))
+	}
+	return binary.BigEndian.Uint32(p.body[0:4]), nil
+}
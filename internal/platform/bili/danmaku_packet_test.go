@@ -0,0 +1,90 @@
+package bili
+
+import (
+	"bytes"
+	"compress/zlib"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+func zlibCompress(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, _ = w.Write(data)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func brotliCompress(data []byte) []byte {
+	var buf bytes.Buffer
+	w := brotli.NewWriter(&buf)
+	_, _ = w.Write(data)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func Test_encodePacket(t *testing.T) {
+	t.Run("should encode header and body", func(t *testing.T) {
+		got := encodePacket(packet{proto: protoInt, op: opHeartbeat, body: []byte("hi")})
+		assert.Equal(t, []byte{0, 0, 0, 18, 0, 16, 0, 1, 0, 0, 0, 2, 0, 0, 0, 1, 'h', 'i'}, got)
+	})
+}
+
+func Test_decodePackets(t *testing.T) {
+	msg1 := packet{proto: protoJson, op: opMessage, body: []byte(`{"cmd":"A"}`)}
+	msg2 := packet{proto: protoJson, op: opMessage, body: []byte(`{"cmd":"B"}`)}
+	inner := append(encodePacket(msg1), encodePacket(msg2)...)
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []packet
+		wantErr string
+	}{
+		{
+			name: "should decode plain packets",
+			data: inner,
+			want: []packet{msg1, msg2},
+		},
+		{
+			name: "should decode zlib compressed packets",
+			data: encodePacket(packet{proto: protoZlib, op: opMessage, body: zlibCompress(inner)}),
+			want: []packet{msg1, msg2},
+		},
+		{
+			name: "should decode brotli compressed packets",
+			data: encodePacket(packet{proto: protoBrotli, op: opMessage, body: brotliCompress(inner)}),
+			want: []packet{msg1, msg2},
+		},
+		{
+			name:    "should return error since truncated",
+			data:    inner[:20],
+			wantErr: "invalid length",
+		},
+		{
+			name:    "should return error since short header",
+			data:    inner[:10],
+			wantErr: "short header",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodePackets(tt.data)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_packet_popularity(t *testing.T) {
+	t.Run("should return popularity", func(t *testing.T) {
+		got, err := packet{proto: protoInt, op: opHeartbeatReply, body: []byte{0, 0, 1, 0}}.popularity()
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(256), got)
+	})
+}
@@ -0,0 +1,148 @@
+package bili
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// danmakuClient serves the room detail and the danmu info pointing to the stand-in server.
+type danmakuClient struct {
+	host string
+	port string
+}
+
+func (c danmakuClient) Do(req *http.Request) (*http.Response, error) {
+	var data []byte
+	switch req.URL.Path {
+	case "/xlive/web-room/v1/index/getH5InfoByRoom":
+		data, _ = os.ReadFile("testData/roomDetail.json")
+	case "/xlive/web-room/v1/index/getDanmuInfo":
+		data = []byte(fmt.Sprintf(`{"code":0,"message":"0","data":{"token":"test_token","host_list":[{"host":"%s","port":2243,"wss_port":%s,"ws_port":2244}]}}`, c.host, c.port))
+	default:
+		return nil, fmt.Errorf("unexpected path: %s", req.URL.Path)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+	}, nil
+}
+
+func newDanmakuServer(t *testing.T, authCode int, heartbeat chan<- struct{}) *httptest.Server {
+	up := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool {
+		return r.Header.Get("Origin") == "https://live.bilibili.com"
+	}}
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/sub", r.URL.Path)
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		// auth
+		_, data, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		ps, err := decodePackets(data)
+		assert.NoError(t, err)
+		assert.Equal(t, opAuth, ps[0].op)
+		var auth map[string]any
+		assert.NoError(t, json.Unmarshal(ps[0].body, &auth))
+		assert.Equal(t, float64(5441), auth["roomid"])
+		assert.Equal(t, "test_token", auth["key"])
+		reply := encodePacket(packet{proto: protoInt, op: opAuthReply, body: []byte(fmt.Sprintf(`{"code":%d}`, authCode))})
+		_ = conn.WriteMessage(websocket.BinaryMessage, reply)
+		if authCode != 0 {
+			return
+		}
+		// heartbeat
+		_, data, err = conn.ReadMessage()
+		assert.NoError(t, err)
+		ps, err = decodePackets(data)
+		assert.NoError(t, err)
+		assert.Equal(t, opHeartbeat, ps[0].op)
+		heartbeat <- struct{}{}
+		hbReply := encodePacket(packet{proto: protoInt, op: opHeartbeatReply, body: []byte{0, 0, 0, 1}})
+		_ = conn.WriteMessage(websocket.BinaryMessage, hbReply)
+		// messages
+		msgs := append(
+			encodePacket(packet{proto: protoJson, op: opMessage, body: []byte(`{"cmd":"DANMU_MSG","info":[[0,1,25,16777215,1721800000123],"主播好",[322892,"痒局长"]]}`)}),
+			encodePacket(packet{proto: protoJson, op: opMessage, body: []byte(`{"cmd":"ONLINE_RANK_COUNT","data":{"count":1024,"online_count":2048}}`)})...,
+		)
+		_ = conn.WriteMessage(websocket.BinaryMessage, encodePacket(packet{proto: protoBrotli, op: opMessage, body: brotliCompress(msgs)}))
+		// wait for closing
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func newDanmakuBili(ts *httptest.Server) Bili {
+	u, _ := url.Parse(ts.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	return Bili{
+		log: slog.Default(),
+		pc:  danmakuClient{host: host, port: port},
+		srv: &mockServer{},
+		wsd: &websocket.Dialer{TLSClientConfig: ts.Client().Transport.(*http.Transport).TLSClientConfig},
+	}
+}
+
+func TestBili_ConnectDanmaku(t *testing.T) {
+	t.Run("should receive decoded events", func(t *testing.T) {
+		hb := make(chan struct{}, 1)
+		ts := newDanmakuServer(t, 0, hb)
+		defer ts.Close()
+		b := newDanmakuBili(ts)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ch, err := b.ConnectDanmaku(ctx, "528")
+		assert.NoError(t, err)
+		select {
+		case <-hb:
+		case <-time.After(5 * time.Second):
+			t.Fatal("heartbeat not received")
+		}
+		e := <-ch
+		cm, ok := e.(ChatMessage)
+		assert.True(t, ok)
+		assert.Equal(t, "主播好", cm.Text)
+		assert.Equal(t, "痒局长", cm.Uname)
+		e = <-ch
+		orc, ok := e.(OnlineRankCount)
+		assert.True(t, ok)
+		assert.Equal(t, int64(2048), orc.OnlineCount)
+		cancel()
+		select {
+		case _, ok := <-ch:
+			assert.False(t, ok)
+		case <-time.After(5 * time.Second):
+			t.Fatal("channel not closed")
+		}
+	})
+
+	t.Run("should return error since auth failed", func(t *testing.T) {
+		ts := newDanmakuServer(t, -101, nil)
+		defer ts.Close()
+		b := newDanmakuBili(ts)
+		_, err := b.ConnectDanmaku(context.Background(), "5441")
+		assert.ErrorContains(t, err, "auth failed with code: -101")
+	})
+}
@@ -17,3 +17,63 @@ func ErrGetLiveUrls(err error) error {
 func errGetRoomPlayInfo(err error) error {
 	return fmt.Errorf("failed to get room play info: %w", err)
 }
+
+func ErrSearchRooms(err error) error {
+	return fmt.Errorf("failed to search rooms: %w", err)
+}
+
+func ErrResolveRoomId(err error) error {
+	return fmt.Errorf("failed to resolve room id: %w", err)
+}
+
+func ErrConnectDanmaku(err error) error {
+	return fmt.Errorf("failed to connect danmaku: %w", err)
+}
+
+func errGetDanmuInfo(err error) error {
+	return fmt.Errorf("failed to get danmu info: %w", err)
+}
+
+func errDecodePacket(err error) error {
+	return fmt.Errorf("failed to decode packet: %w", err)
+}
+
+func errDecodeEvent(err error) error {
+	return fmt.Errorf("failed to decode event: %w", err)
+}
+
+func ErrGetRooms(err error) error {
+	return fmt.Errorf("failed to get rooms: %w", err)
+}
+
+func errGetRoomBaseInfos(err error) error {
+	return fmt.Errorf("failed to get room base infos: %w", err)
+}
+
+func errGetStatusInfos(err error) error {
+	return fmt.Errorf("failed to get status infos: %w", err)
+}
+
+func ErrGetLoginQrCode(err error) error {
+	return fmt.Errorf("failed to get login qr code: %w", err)
+}
+
+func ErrPollLogin(err error) error {
+	return fmt.Errorf("failed to poll login: %w", err)
+}
+
+func ErrLogout(err error) error {
+	return fmt.Errorf("failed to logout: %w", err)
+}
+
+func ErrGetAccount(err error) error {
+	return fmt.Errorf("failed to get account: %w", err)
+}
+
+func errSignWbi(err error) error {
+	return fmt.Errorf("failed to sign wbi: %w", err)
+}
+
+func ErrGetFollowedRooms(err error) error {
+	return fmt.Errorf("failed to get followed rooms: %w", err)
+}
@@ -0,0 +1,82 @@
+package bili
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+const (
+	followingPageSize = 10
+	// maxFollowingPages limits the requests for the users following too many streamers.
+	maxFollowingPages = 50
+)
+
+// GetFollowedRooms returns the rooms followed by the user of the stored cookie, it fails if the cookie is
+// missing or expired.
+func (b Bili) GetFollowedRooms(ctx context.Context) ([]platform.Room, error) {
+	rooms := make([]platform.Room, 0)
+	for page := 1; page <= maxFollowingPages; page++ {
+		f, err := b.getFollowing(ctx, page)
+		if isCode(err, notLoggedInCode) {
+			return nil, ErrGetFollowedRooms(errors.New("not logged in"))
+		}
+		if err != nil {
+			return nil, ErrGetFollowedRooms(err)
+		}
+		for _, r := range f.List {
+			cu, err := parseSchemelessUrl(r.RoomCover)
+			if err != nil {
+				return nil, ErrGetFollowedRooms(fmt.Errorf("failed to parse cover url: %w", err))
+			}
+			au, err := parseSchemelessUrl(r.Face)
+			if err != nil {
+				return nil, ErrGetFollowedRooms(fmt.Errorf("failed to parse avatar url: %w", err))
+			}
+			rooms = append(rooms, platform.Room{
+				Id:       strconv.FormatInt(r.RoomId, 10),
+				Title:    r.Title,
+				IsOnline: r.LiveStatus == 1,
+				CoverUrl: b.srv.GetCorsProxyUrl(*cu),
+				Owner: platform.Owner{
+					Id:        strconv.FormatInt(r.Uid, 10),
+					Name:      r.Uname,
+					AvatarUrl: b.srv.GetCorsProxyUrl(*au),
+				},
+			})
+		}
+		if page >= f.TotalPage || len(f.List) == 0 {
+			break
+		}
+	}
+	sort.SliceStable(rooms, func(i, j int) bool {
+		return rooms[i].IsOnline && !rooms[j].IsOnline
+	})
+	return rooms, nil
+}
+
+func (b Bili) getFollowing(ctx context.Context, page int) (*following, error) {
+	bc := biliClient[following]{b.pc, b.log, b.st, b.wbi}
+	u := url.URL{
+		Scheme: "https",
+		Host:   "api.live.bilibili.com",
+		Path:   "/xlive/web-ucenter/user/following",
+	}
+	q := u.Query()
+	q.Set("page", strconv.Itoa(page))
+	q.Set("page_size", strconv.Itoa(followingPageSize))
+	q.Set("ignoreRecord", "1")
+	q.Set("hit_ab", "true")
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Referer", "https://link.bilibili.com/")
+	return bc.getJson(req)
+}
@@ -0,0 +1,89 @@
+package bili
+
+import (
+	"asmblive/internal/platform"
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// followingClient responds with testData/following_<page>.json.
+type followingClient struct{}
+
+func (c followingClient) Do(req *http.Request) (*http.Response, error) {
+	data, err := os.ReadFile("testData/following_" + req.URL.Query().Get("page") + ".json")
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+	}, nil
+}
+
+func TestBili_GetFollowedRooms(t *testing.T) {
+	tests := []struct {
+		name    string
+		pc      platform.Client
+		want    []platform.Room
+		wantErr string
+	}{
+		{
+			name: "should return rooms of all pages with the online ones first",
+			pc:   followingClient{},
+			want: []platform.Room{
+				{
+					Id:       "21452505",
+					Title:    "鸣潮 & 原神",
+					IsOnline: true,
+					CoverUrl: url.URL{Scheme: "https", Host: "i0.hdslb.com", Path: "/bfs/live/user_cover/cover.jpg"},
+					Owner: platform.Owner{
+						Id:        "1104048496",
+						Name:      "七海Nana7mi",
+						AvatarUrl: url.URL{Scheme: "https", Host: "i1.hdslb.com", Path: "/bfs/face/face1.jpg"},
+					},
+				},
+				{
+					Id:       "5441",
+					Title:    "【二台】俺也玩鸣潮",
+					IsOnline: false,
+					CoverUrl: url.URL{Scheme: "https", Host: "i0.hdslb.com", Path: "/bfs/live/new_room_cover/cover2.jpg"},
+					Owner: platform.Owner{
+						Id:        "322892",
+						Name:      "痒局长",
+						AvatarUrl: url.URL{Scheme: "https", Host: "i2.hdslb.com", Path: "/bfs/face/face2.jpg"},
+					},
+				},
+			},
+		},
+		{
+			name:    "should return error since not logged in",
+			pc:      mc{res: []byte(`{"code":-101,"message":"账号未登录","ttl":1}`)},
+			wantErr: "failed to get followed rooms: not logged in",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Bili{
+				log: slog.Default(),
+				pc:  tt.pc,
+				srv: &mockServer{},
+			}
+			got, err := b.GetFollowedRooms(context.TODO())
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
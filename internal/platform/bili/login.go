@@ -0,0 +1,106 @@
+package bili
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// the codes of the login poll, see https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/docs/login/login_action/QR.md
+const (
+	loginPollConfirmed = 0
+	loginPollExpired   = 86038
+	loginPollScanned   = 86090
+	loginPollWaiting   = 86101
+)
+
+func (b Bili) GetLoginQrCode(ctx context.Context) (platform.LoginQrCode, error) {
+	bc := biliClient[loginQrCode]{b.pc, b.log, nil, b.wbi}
+	u := url.URL{
+		Scheme: "https",
+		Host:   "passport.bilibili.com",
+		Path:   "/x/passport-login/web/qrcode/generate",
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return platform.LoginQrCode{}, ErrGetLoginQrCode(err)
+	}
+	qc, err := bc.getJson(req)
+	if err != nil {
+		return platform.LoginQrCode{}, ErrGetLoginQrCode(err)
+	}
+	qu, err := url.Parse(qc.Url)
+	if err != nil {
+		return platform.LoginQrCode{}, ErrGetLoginQrCode(err)
+	}
+	return platform.LoginQrCode{
+		Key: qc.QrcodeKey,
+		Url: *qu,
+	}, nil
+}
+
+func (b Bili) PollLogin(ctx context.Context, key string) (platform.LoginState, error) {
+	// the stored cookie is not sent, it may belong to another account
+	bc := biliClient[loginPoll]{b.pc, b.log, nil, b.wbi}
+	u := url.URL{
+		Scheme: "https",
+		Host:   "passport.bilibili.com",
+		Path:   "/x/passport-login/web/qrcode/poll",
+	}
+	q := u.Query()
+	q.Set("qrcode_key", key)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", ErrPollLogin(err)
+	}
+	lp, cs, err := bc.getJsonWithCookies(req)
+	if err != nil {
+		return "", ErrPollLogin(err)
+	}
+	switch lp.Code {
+	case loginPollWaiting:
+		return platform.LoginWaiting, nil
+	case loginPollScanned:
+		return platform.LoginScanned, nil
+	case loginPollExpired:
+		return platform.LoginExpired, nil
+	case loginPollConfirmed:
+	default:
+		return "", ErrPollLogin(fmt.Errorf("unexpected poll code: %d, message: %s", lp.Code, lp.Message))
+	}
+	cookie := joinCookies(cs)
+	if !strings.Contains(cookie, "SESSDATA=") {
+		return "", ErrPollLogin(errors.New("no SESSDATA in the response"))
+	}
+	if b.st.SetBiliCookie(cookie) != cookie {
+		return "", ErrPollLogin(errors.New("failed to store the cookie"))
+	}
+	b.log.Info("logged in by qr code")
+	return platform.LoginConfirmed, nil
+}
+
+// Logout only removes the stored cookie, the session is not revoked on the server.
+func (b Bili) Logout(_ context.Context) error {
+	if !b.st.DeleteBiliCookie() {
+		return ErrLogout(errors.New("failed to delete the cookie"))
+	}
+	b.log.Info("logged out")
+	return nil
+}
+
+// joinCookies returns the value of the Cookie header, the deleted cookies are omitted.
+func joinCookies(cs []*http.Cookie) string {
+	ps := make([]string, 0, len(cs))
+	for _, c := range cs {
+		if c.Value == "" || c.MaxAge < 0 {
+			continue
+		}
+		ps = append(ps, c.Name+"="+c.Value)
+	}
+	return strings.Join(ps, "; ")
+}
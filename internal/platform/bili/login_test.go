@@ -0,0 +1,142 @@
+package bili
+
+import (
+	"asmblive/internal/platform"
+	"asmblive/internal/setting"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mapStore map[string]string
+
+func (m mapStore) Read() (map[string]string, error) {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c, nil
+}
+
+func (m mapStore) Write(v map[string]string) error {
+	for k := range m {
+		delete(m, k)
+	}
+	for k, v := range v {
+		m[k] = v
+	}
+	return nil
+}
+
+// loginClient responds with the body and the cookies, the stored cookie must not be sent.
+type loginClient struct {
+	t       *testing.T
+	body    string
+	cookies []string
+}
+
+func (c loginClient) Do(req *http.Request) (*http.Response, error) {
+	assert.Empty(c.t, req.Header.Get("Cookie"))
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type": []string{"application/json"},
+			"Set-Cookie":   c.cookies,
+		},
+		Body: io.NopCloser(strings.NewReader(c.body)),
+	}, nil
+}
+
+func TestBili_GetLoginQrCode(t *testing.T) {
+	b := Bili{
+		log: slog.Default(),
+		pc:  loginClient{t: t, body: `{"code":0,"message":"0","data":{"url":"https://account.bilibili.com/h5/account-passport-login/mobile/scan-login?qrcode_key=abc","qrcode_key":"abc"}}`},
+	}
+	qc, err := b.GetLoginQrCode(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", qc.Key)
+	assert.Equal(t, "https://account.bilibili.com/h5/account-passport-login/mobile/scan-login?qrcode_key=abc", qc.Url.String())
+}
+
+func TestBili_PollLogin(t *testing.T) {
+	poll := func(code int) string {
+		return fmt.Sprintf(`{"code":0,"message":"0","data":{"url":"","refresh_token":"","timestamp":0,"code":%d,"message":""}}`, code)
+	}
+	tests := []struct {
+		name       string
+		pc         loginClient
+		want       platform.LoginState
+		wantCookie string
+		wantErr    string
+	}{
+		{
+			name: "should be waiting",
+			pc:   loginClient{body: poll(86101)},
+			want: platform.LoginWaiting,
+		},
+		{
+			name: "should be scanned",
+			pc:   loginClient{body: poll(86090)},
+			want: platform.LoginScanned,
+		},
+		{
+			name: "should be expired",
+			pc:   loginClient{body: poll(86038)},
+			want: platform.LoginExpired,
+		},
+		{
+			name: "should store the cookies since confirmed",
+			pc: loginClient{body: poll(0), cookies: []string{
+				"SESSDATA=sess%2C1737187200; Path=/; Domain=bilibili.com; HttpOnly; Secure",
+				"bili_jct=jct; Path=/; Domain=bilibili.com",
+				"DedeUserID=2; Path=/; Domain=bilibili.com",
+				"sid=; Path=/; Max-Age=0",
+			}},
+			want:       platform.LoginConfirmed,
+			wantCookie: "SESSDATA=sess%2C1737187200; bili_jct=jct; DedeUserID=2",
+		},
+		{
+			name:    "should fail since no SESSDATA",
+			pc:      loginClient{body: poll(0)},
+			wantErr: "failed to poll login: no SESSDATA in the response",
+		},
+		{
+			name:    "should fail since unknown code",
+			pc:      loginClient{body: poll(1)},
+			wantErr: "failed to poll login: unexpected poll code: 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &setting.Bili{Store: mapStore{"bili_cookie": "old"}, Log: slog.Default()}
+			tt.pc.t = t
+			b := Bili{log: slog.Default(), pc: tt.pc, st: st}
+			got, err := b.PollLogin(context.TODO(), "abc")
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.Equal(t, "old", st.GetBiliCookie())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			if tt.wantCookie != "" {
+				assert.Equal(t, tt.wantCookie, st.GetBiliCookie())
+			} else {
+				assert.Equal(t, "old", st.GetBiliCookie())
+			}
+		})
+	}
+}
+
+func TestBili_Logout(t *testing.T) {
+	s := mapStore{"bili_cookie": "SESSDATA=sess", "other": "value"}
+	b := Bili{log: slog.Default(), st: &setting.Bili{Store: s, Log: slog.Default()}}
+	assert.NoError(t, b.Logout(context.TODO()))
+	assert.Equal(t, mapStore{"other": "value"}, s)
+}
@@ -12,6 +12,8 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/gorilla/websocket"
 )
 
 type Bili struct {
@@ -19,15 +21,40 @@ type Bili struct {
 	srv server.Server
 	pc  platform.Client
 	st  *setting.Bili
+	// wbi is shared by the copies of Bili, so that the keys are fetched once.
+	wbi *wbi
+
+	// wsd is the dialer of the danmaku connections, it is set by NewBili with the proxy of the platform.
+	// websocket.DefaultDialer is used if it is nil.
+	wsd *websocket.Dialer
 }
 
-func NewBili(log *slog.Logger, pc platform.Client, st *setting.Bili, srv server.Server) *Bili {
+// proxyHosts is the hosts of the icon, covers and avatars, which are fetched through the cors proxy.
+var proxyHosts = []string{"www.bilibili.com", ".hdslb.com"}
+
+// streamHosts is the cdn hosts of the live streams and their segments, which are fetched through the stream
+// relay.
+var streamHosts = []string{".bilivideo.com", ".bilivideo.cn"}
+
+// NewBili returns a new instance of Bili, the danmaku connections are sent through the proxy as the
+// requests of pc are, a nil proxy means http.ProxyFromEnvironment.
+func NewBili(log *slog.Logger, pc platform.Client, proxy func(*http.Request) (*url.URL, error), st *setting.Bili, srv server.Server) *Bili {
 	log = log.With("module", "platform/bili")
+	if srv != nil {
+		srv.AllowProxyHost("bili", proxyHosts...)
+		srv.AllowProxyHost("bili", streamHosts...)
+	}
+	wsd := *websocket.DefaultDialer
+	if proxy != nil {
+		wsd.Proxy = proxy
+	}
 	return &Bili{
 		log: log,
 		pc:  pc,
 		st:  st,
 		srv: srv,
+		wbi: newWbi(log, pc),
+		wsd: &wsd,
 	}
 }
 
@@ -49,7 +76,7 @@ func (b Bili) IconUrl() url.URL {
 }
 
 func (b Bili) GetRoom(ctx context.Context, roomId string) (platform.Room, error) {
-	bc := biliClient[roomDetail]{b.pc, b.log, b.st}
+	bc := biliClient[roomDetail]{b.pc, b.log, b.st, b.wbi}
 	u := url.URL{
 		Scheme: "https",
 		Host:   "api.live.bilibili.com",
@@ -119,15 +146,27 @@ func (b Bili) GetQualities(ctx context.Context, roomId string) ([]platform.Quali
 }
 
 func (b Bili) GetLiveUrls(ctx context.Context, roomId string, qualityId string) ([]url.URL, error) {
+	lss, err := b.GetLiveStreams(ctx, roomId, qualityId)
+	if err != nil {
+		return []url.URL{}, err
+	}
+	urls := make([]url.URL, len(lss))
+	for i, ls := range lss {
+		urls[i] = ls.Url
+	}
+	return urls, nil
+}
+
+func (b Bili) GetLiveStreams(ctx context.Context, roomId string, qualityId string) ([]platform.LiveStream, error) {
 	rpi, err := b.getRoomPlayInfo(ctx, roomId, qualityId)
 	if err != nil {
-		return []url.URL{}, ErrGetLiveUrls(err)
+		return []platform.LiveStream{}, ErrGetLiveUrls(err)
 	}
 	if len(rpi.PlayurlInfo.Playurl.Stream) == 0 ||
 		len(rpi.PlayurlInfo.Playurl.Stream[0].Format) == 0 {
-		return make([]url.URL, 0), nil
+		return make([]platform.LiveStream, 0), nil
 	}
-	urls := make([]url.URL, 0)
+	lss := make([]platform.LiveStream, 0)
 	for _, s := range rpi.PlayurlInfo.Playurl.Stream {
 		for _, f := range s.Format {
 			for _, c := range f.Codec {
@@ -136,21 +175,38 @@ func (b Bili) GetLiveUrls(ctx context.Context, roomId string, qualityId string)
 					if err != nil {
 						continue
 					}
-					urls = append(urls, *u)
+					lss = append(lss, platform.LiveStream{
+						Url:       *u,
+						Protocol:  s.ProtocolName,
+						Format:    f.FormatName,
+						Codec:     c.CodecName,
+						ExpiresAt: platform.ParseExpiry(*u),
+					})
 				}
 			}
 		}
 	}
 	// ensure mcdn urls are at the end
-	sort.Slice(urls, func(i int, _ int) bool {
-		u := urls[i]
-		return !strings.Contains(u.Host, "mcdn")
+	sort.SliceStable(lss, func(i int, j int) bool {
+		return !strings.Contains(lss[i].Url.Host, "mcdn") && strings.Contains(lss[j].Url.Host, "mcdn")
 	})
-	return urls, nil
+	// the cdn rejects the requests without referer
+	h := streamHeaders()
+	for i, ls := range lss {
+		lss[i].Origin = ls.Url
+		lss[i].Header = h
+		// the player cannot play flv, which is remuxed into hls
+		if strings.HasSuffix(ls.Url.Path, ".flv") {
+			lss[i].Url = b.srv.GetRemuxUrl(ls.Url, h)
+		} else {
+			lss[i].Url = b.srv.GetStreamUrl(ls.Url, h)
+		}
+	}
+	return lss, nil
 }
 
 func (b Bili) getRoomPlayInfo(ctx context.Context, roomId string, qualityId string) (*roomPlayInfo, error) {
-	bc := biliClient[roomPlayInfo]{b.pc, b.log, b.st}
+	bc := biliClient[roomPlayInfo]{b.pc, b.log, b.st, b.wbi}
 	u := url.URL{
 		Scheme: "https",
 		Host:   "api.live.bilibili.com",
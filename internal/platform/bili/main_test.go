@@ -1,7 +1,9 @@
 package bili
 
 import (
+	"asmblive/internal/health"
 	"asmblive/internal/platform"
+	"asmblive/internal/proxy"
 	"bytes"
 	"context"
 	"github.com/stretchr/testify/assert"
@@ -12,6 +14,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 type mockServer struct{}
@@ -32,26 +35,57 @@ func (m mockServer) BaseUrl() url.URL {
 	panic("should not call")
 }
 
+func (m mockServer) AllowProxyHost(string, ...string) {}
+
 func (m mockServer) GetCorsProxyUrl(oringin url.URL) url.URL {
 	return oringin
 }
 
+func (m mockServer) GetStreamUrl(origin url.URL, _ http.Header) url.URL {
+	return origin
+}
+
+func (m mockServer) GetRemuxUrl(origin url.URL, _ http.Header) url.URL {
+	return origin
+}
+
+func (m mockServer) CheckStreams(_ context.Context, _ string, urls []url.URL) []health.Result {
+	rs := make([]health.Result, len(urls))
+	for i, u := range urls {
+		rs[i] = health.Result{Url: u}
+	}
+	return rs
+}
+
+func (m mockServer) GetFailoverUrl(_ string, candidates []url.URL) url.URL {
+	return candidates[0]
+}
+
 func TestNewBili(t *testing.T) {
 	t.Run("should return an id", func(t *testing.T) {
-		bili := NewBili(slog.Default(), nil, nil, nil)
+		bili := NewBili(slog.Default(), nil, nil, nil, nil)
 		assert.Equal(t, "bili", bili.Id())
 	})
 
 	t.Run("should return a name", func(t *testing.T) {
-		bili := NewBili(slog.Default(), nil, nil, nil)
+		bili := NewBili(slog.Default(), nil, nil, nil, nil)
 		assert.Equal(t, "哔哩哔哩直播", bili.Name())
 	})
 
 	t.Run("should return an icon", func(t *testing.T) {
-		bili := NewBili(slog.Default(), nil, nil, &mockServer{})
+		bili := NewBili(slog.Default(), nil, nil, nil, &mockServer{})
 		u := bili.IconUrl()
 		assert.Equal(t, "https://www.bilibili.com/favicon.ico", u.String())
 	})
+
+	t.Run("should dial the danmaku through the proxy", func(t *testing.T) {
+		pu, _ := url.Parse("socks5://127.0.0.1:1080")
+		bili := NewBili(slog.Default(), nil, http.ProxyURL(pu), nil, nil)
+		req, _ := http.NewRequest(http.MethodGet, "https://broadcastlv.chat.bilibili.com/sub", nil)
+		got, err := bili.wsd.Proxy(req)
+		assert.NoError(t, err)
+		assert.Equal(t, pu, got)
+	})
 }
 
 type mc struct {
@@ -316,3 +350,38 @@ func TestBili_GetLiveUrls(t *testing.T) {
 		})
 	}
 }
+
+func TestBili_GetLiveStreams(t *testing.T) {
+	data, _ := os.ReadFile("testData/getRoomPlayInfo.json")
+	b := Bili{
+		log: slog.Default(),
+		pc:  mc{res: data},
+		srv: &mockServer{},
+	}
+	got, err := b.GetLiveStreams(context.TODO(), "6", "")
+	assert.NoError(t, err)
+	assert.Len(t, got, 12)
+	assert.Equal(t, platform.LiveStream{
+		Url:       got[0].Url,
+		Origin:    got[0].Url,
+		Header:    streamHeaders(),
+		Protocol:  platform.ProtocolHttpStream,
+		Format:    platform.FormatFlv,
+		Codec:     platform.CodecAvc,
+		ExpiresAt: time.Unix(1721816094, 0),
+	}, got[0])
+	hevc := 0
+	for _, ls := range got {
+		assert.True(t, proxy.Bypassed(ls.Origin.Host, streamHosts), ls.Origin.Host)
+		assert.Contains(t, []string{platform.ProtocolHttpStream, platform.ProtocolHls}, ls.Protocol)
+		assert.Contains(t, []string{platform.FormatFlv, platform.FormatTs, platform.FormatFmp4}, ls.Format)
+		if ls.Codec == platform.CodecHevc {
+			hevc++
+		}
+	}
+	assert.Equal(t, 6, hevc)
+	// the metadata follows the url while sorting
+	last := got[len(got)-1]
+	assert.Contains(t, last.Url.Host, "mcdn")
+	assert.Equal(t, platform.FormatFmp4, last.Format)
+}
@@ -0,0 +1,42 @@
+package bili
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ResolveRoomId accepts the links like `https://live.bilibili.com/21452505?broadcast_type=0`
+// and the short links of `b23.tv`, the short room id is normalised to the long one.
+func (b Bili) ResolveRoomId(ctx context.Context, input string) (string, error) {
+	u, ok := platform.ParseInputUrl(input)
+	if !ok {
+		return "", ErrResolveRoomId(platform.ErrUnsupportedInput)
+	}
+	if platform.MatchHost(u, "b23.tv", "bili2233.cn") {
+		ru, err := platform.FollowRedirects(ctx, b.pc, u)
+		if err != nil {
+			return "", ErrResolveRoomId(err)
+		}
+		u = ru
+	}
+	if !platform.MatchHost(u, "live.bilibili.com") {
+		return "", ErrResolveRoomId(platform.ErrUnsupportedInput)
+	}
+	// the room id is the last numeric segment, e.g. `/h5/6` and `/blanc/6`
+	var id string
+	for _, seg := range strings.Split(u.Path, "/") {
+		if seg != "" && strings.Trim(seg, "0123456789") == "" {
+			id = seg
+		}
+	}
+	if id == "" {
+		return "", ErrResolveRoomId(fmt.Errorf("no room id in link: %s", u.String()))
+	}
+	r, err := b.GetRoom(ctx, id)
+	if err != nil {
+		return "", ErrResolveRoomId(err)
+	}
+	return r.Id, nil
+}
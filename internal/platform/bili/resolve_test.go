@@ -0,0 +1,86 @@
+package bili
+
+import (
+	"asmblive/internal/platform"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// redirectClient redirects the short links to the room 528, and returns the room detail for others.
+type redirectClient struct{}
+
+func (c redirectClient) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == "b23.tv" {
+		u, _ := url.Parse("https://live.bilibili.com/528?share_source=copy_link")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader(nil)),
+			Request:    &http.Request{URL: u},
+		}, nil
+	}
+	if req.URL.Query().Get("room_id") != "528" {
+		return nil, errors.New("unexpected room id: " + req.URL.Query().Get("room_id"))
+	}
+	data, _ := os.ReadFile("testData/roomDetail.json")
+	return mc{res: data}.Do(req)
+}
+
+func TestBili_ResolveRoomId(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "should resolve room link with short id",
+			input: "https://live.bilibili.com/528?broadcast_type=0",
+			want:  "5441",
+		},
+		{
+			name:  "should resolve h5 room link",
+			input: "live.bilibili.com/h5/528",
+			want:  "5441",
+		},
+		{
+			name:  "should resolve short link",
+			input: "https://b23.tv/AbCdEf",
+			want:  "5441",
+		},
+		{
+			name:    "should return unsupported since other platform",
+			input:   "https://www.douyu.com/288016",
+			wantErr: platform.ErrUnsupportedInput,
+		},
+		{
+			name:    "should return unsupported since bare room id",
+			input:   "528",
+			wantErr: platform.ErrUnsupportedInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Bili{
+				log: slog.Default(),
+				pc:  redirectClient{},
+				srv: &mockServer{},
+			}
+			got, err := b.ResolveRoomId(context.TODO(), tt.input)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
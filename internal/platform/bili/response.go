@@ -32,11 +32,14 @@ type roomPlayInfo struct {
 			} `json:"g_qn_desc"`
 
 			Stream []struct {
-				Format []struct {
-					Codec []struct {
-						AcceptQn []int  `json:"accept_qn"`
-						BaseUrl  string `json:"base_url"`
-						UrlInfo  []struct {
+				ProtocolName string `json:"protocol_name"`
+				Format       []struct {
+					FormatName string `json:"format_name"`
+					Codec      []struct {
+						CodecName string `json:"codec_name"`
+						AcceptQn  []int  `json:"accept_qn"`
+						BaseUrl   string `json:"base_url"`
+						UrlInfo   []struct {
 							Host  string `json:"host"`
 							Extra string `json:"extra"`
 						} `json:"url_info"`
@@ -46,3 +49,79 @@ type roomPlayInfo struct {
 		} `json:"playurl"`
 	} `json:"playurl_info"`
 }
+
+type liveRoomSearch struct {
+	Result []struct {
+		RoomId     int64  `json:"roomid"`
+		Uid        int64  `json:"uid"`
+		Title      string `json:"title"`
+		Uname      string `json:"uname"`
+		Uface      string `json:"uface"`
+		UserCover  string `json:"user_cover"`
+		LiveStatus int8   `json:"live_status"`
+	} `json:"result"`
+}
+
+type danmuInfo struct {
+	Token    string `json:"token"`
+	HostList []struct {
+		Host    string `json:"host"`
+		Port    int    `json:"port"`
+		WssPort int    `json:"wss_port"`
+		WsPort  int    `json:"ws_port"`
+	} `json:"host_list"`
+}
+
+type roomBaseInfos struct {
+	ByRoomIds map[string]struct {
+		RoomId     int64  `json:"room_id"`
+		ShortId    int64  `json:"short_id"`
+		Uid        int64  `json:"uid"`
+		Uname      string `json:"uname"`
+		Title      string `json:"title"`
+		Cover      string `json:"cover"`
+		LiveStatus int8   `json:"live_status"`
+	} `json:"by_room_ids"`
+}
+
+// statusInfos is keyed by uid.
+type statusInfos map[string]struct {
+	Face string `json:"face"`
+}
+
+type loginQrCode struct {
+	Url       string `json:"url"`
+	QrcodeKey string `json:"qrcode_key"`
+}
+
+type loginPoll struct {
+	Url          string `json:"url"`
+	RefreshToken string `json:"refresh_token"`
+	Timestamp    int64  `json:"timestamp"`
+	Code         int    `json:"code"`
+	Message      string `json:"message"`
+}
+
+type nav struct {
+	IsLogin   bool   `json:"isLogin"`
+	Mid       int64  `json:"mid"`
+	Uname     string `json:"uname"`
+	VipStatus int    `json:"vipStatus"`
+	WbiImg    struct {
+		ImgUrl string `json:"img_url"`
+		SubUrl string `json:"sub_url"`
+	} `json:"wbi_img"`
+}
+
+type following struct {
+	TotalPage int `json:"totalPage"`
+	List      []struct {
+		RoomId     int64  `json:"roomid"`
+		Uid        int64  `json:"uid"`
+		Uname      string `json:"uname"`
+		Title      string `json:"title"`
+		Face       string `json:"face"`
+		LiveStatus int8   `json:"live_status"`
+		RoomCover  string `json:"room_cover"`
+	} `json:"list"`
+}
@@ -0,0 +1,73 @@
+package bili
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// tagRe matches the html tags, e.g. `<em class="keyword">`, used to highlight the keyword in the search result.
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+func (b Bili) SearchRooms(ctx context.Context, keyword string, page int) ([]platform.Room, error) {
+	bc := biliClient[liveRoomSearch]{b.pc, b.log, b.st, b.wbi}
+	u := url.URL{
+		Scheme: "https",
+		Host:   "api.bilibili.com",
+		Path:   "/x/web-interface/wbi/search/type",
+	}
+	if page < 1 {
+		page = 1
+	}
+	q := u.Query()
+	q.Set("search_type", "live_room")
+	q.Set("keyword", keyword)
+	q.Set("page", strconv.Itoa(page))
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, ErrSearchRooms(err)
+	}
+	req.Header.Set("Referer", "https://search.bilibili.com/")
+	rs, err := bc.getJson(req)
+	if err != nil {
+		return nil, ErrSearchRooms(err)
+	}
+	rooms := make([]platform.Room, 0, len(rs.Result))
+	for _, r := range rs.Result {
+		cu, err := parseSchemelessUrl(r.UserCover)
+		if err != nil {
+			return nil, ErrSearchRooms(fmt.Errorf("failed to parse cover url: %w", err))
+		}
+		au, err := parseSchemelessUrl(r.Uface)
+		if err != nil {
+			return nil, ErrSearchRooms(fmt.Errorf("failed to parse avatar url: %w", err))
+		}
+		rooms = append(rooms, platform.Room{
+			Id:       strconv.FormatInt(r.RoomId, 10),
+			Title:    html.UnescapeString(tagRe.ReplaceAllString(r.Title, "")),
+			IsOnline: r.LiveStatus == 1,
+			CoverUrl: b.srv.GetCorsProxyUrl(*cu),
+			Owner: platform.Owner{
+				Id:        strconv.FormatInt(r.Uid, 10),
+				Name:      html.UnescapeString(tagRe.ReplaceAllString(r.Uname, "")),
+				AvatarUrl: b.srv.GetCorsProxyUrl(*au),
+			},
+		})
+	}
+	return rooms, nil
+}
+
+// parseSchemelessUrl parses the url like `//i0.hdslb.com/bfs/face/xxx.jpg` with https scheme.
+func parseSchemelessUrl(s string) (*url.URL, error) {
+	if strings.HasPrefix(s, "//") {
+		s = "https:" + s
+	}
+	return url.Parse(s)
+}
@@ -0,0 +1,97 @@
+package bili
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"log/slog"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBili_SearchRooms(t *testing.T) {
+	type fields struct {
+		pc platform.Client
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		want    []platform.Room
+		wantErr string
+	}{
+		{
+			name: "should return rooms without highlight tags",
+			fields: fields{
+				pc: func() platform.Client {
+					data, _ := os.ReadFile("testData/searchLiveRoom.json")
+					return mc{res: data}
+				}(),
+			},
+			want: []platform.Room{
+				{
+					Id:       "5441",
+					Title:    "【二台】俺也玩鸣潮",
+					IsOnline: true,
+					CoverUrl: url.URL{
+						Scheme: "https",
+						Host:   "i0.hdslb.com",
+						Path:   "/bfs/live/new_room_cover/ad393b7cbe9afbd97404a3deacadba5a8dafae35.jpg",
+					},
+					Owner: platform.Owner{
+						Id:   "322892",
+						Name: "痒局长",
+						AvatarUrl: url.URL{
+							Scheme: "https",
+							Host:   "i2.hdslb.com",
+							Path:   "/bfs/face/2de1011b30ad531b64a40ca5788e1dcf2509874f.jpg",
+						},
+					},
+				},
+				{
+					Id:       "21452505",
+					Title:    "鸣潮 & 原神",
+					IsOnline: false,
+					CoverUrl: url.URL{
+						Scheme: "https",
+						Host:   "i0.hdslb.com",
+						Path:   "/bfs/live/user_cover/cover.jpg",
+					},
+					Owner: platform.Owner{
+						Id:   "1437582453",
+						Name: "测试主播",
+						AvatarUrl: url.URL{
+							Scheme: "https",
+							Host:   "i1.hdslb.com",
+							Path:   "/bfs/face/member.jpg",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "should return code error",
+			fields: fields{
+				pc: mc{res: []byte(`{"code":-412,"message":"请求被拦截","ttl":1,"data":null}`)},
+			},
+			wantErr: "unexpected response code: -412, message: 请求被拦截",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Bili{
+				log: slog.Default(),
+				pc:  tt.fields.pc,
+				srv: &mockServer{},
+			}
+			got, err := b.SearchRooms(context.TODO(), "鸣潮", 1)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package bili
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// wbiRejectedCode is the response code if the signature is invalid, e.g. the keys are rotated.
+const wbiRejectedCode = -352
+
+// wbiKeyTtl is the max age of the cached keys, the keys are rotated daily.
+const wbiKeyTtl = time.Hour
+
+// mixinKeyEncTab is the order of the characters of the img key and the sub key in the mixin key.
+var mixinKeyEncTab = []int{
+	46, 47, 18, 2, 53, 8, 23, 32, 15, 50, 10, 31, 58, 3, 45, 35, 27, 43, 5, 49,
+	33, 9, 42, 19, 29, 28, 14, 39, 12, 38, 41, 13, 37, 48, 7, 16, 24, 55, 40,
+	61, 26, 17, 0, 1, 60, 51, 30, 4, 22, 25, 54, 21, 56, 59, 6, 63, 57, 62, 11,
+	36, 20, 34, 44, 52,
+}
+
+// wbi signs the queries of the wbi endpoints with `wts` and `w_rid`, the keys are fetched from the nav
+// endpoint and cached, it is safe for concurrent use.
+type wbi struct {
+	pc  platform.Client
+	log *slog.Logger
+	now func() time.Time
+
+	mtx       sync.Mutex
+	mixinKey  string
+	fetchedAt time.Time
+}
+
+func newWbi(log *slog.Logger, pc platform.Client) *wbi {
+	return &wbi{
+		pc:  pc,
+		log: log,
+		now: time.Now,
+	}
+}
+
+// isWbiPath reports whether the endpoint requires the signature, e.g. `/x/web-interface/wbi/search/type`.
+func isWbiPath(u *url.URL) bool {
+	return strings.Contains(u.Path, "/wbi/")
+}
+
+// sign replaces the query of the request with the signed one.
+func (w *wbi) sign(req *http.Request) error {
+	key, err := w.key(req.Context())
+	if err != nil {
+		return errSignWbi(err)
+	}
+	req.URL.RawQuery = signQuery(req.URL.Query(), key, w.now().Unix())
+	return nil
+}
+
+// invalidate drops the cached keys, they are fetched again on the next signing.
+func (w *wbi) invalidate() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.mixinKey = ""
+}
+
+func (w *wbi) key(ctx context.Context) (string, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.mixinKey != "" && w.now().Sub(w.fetchedAt) < wbiKeyTtl {
+		return w.mixinKey, nil
+	}
+	img, sub, err := w.fetchKeys(ctx)
+	if err != nil {
+		return "", err
+	}
+	w.mixinKey = mixinKey(img, sub)
+	w.fetchedAt = w.now()
+	w.log.Info("fetched wbi keys")
+	return w.mixinKey, nil
+}
+
+// fetchKeys gets the keys from the nav endpoint, which returns them even if not logged in, so that the
+// response code is ignored.
+func (w *wbi) fetchKeys(ctx context.Context) (string, string, error) {
+	u := url.URL{
+		Scheme: "https",
+		Host:   "api.bilibili.com",
+		Path:   "/x/web-interface/nav",
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", "", err
+	}
+	for k, v := range commonHeaders {
+		req.Header.Set(k, v)
+	}
+	res, err := w.pc.Do(req)
+	if err != nil {
+		return "", "", platform.ErrRequest(err)
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			w.log.Warn("failed to close response body", "error", err)
+		}
+	}()
+	var rb response[nav]
+	if err := json.NewDecoder(res.Body).Decode(&rb); err != nil {
+		return "", "", platform.ErrRequest(fmt.Errorf("failed to decode response body: %w", err))
+	}
+	img := keyOf(rb.Data.WbiImg.ImgUrl)
+	sub := keyOf(rb.Data.WbiImg.SubUrl)
+	if len(img)+len(sub) < len(mixinKeyEncTab) {
+		return "", "", errors.New("invalid wbi keys")
+	}
+	return img, sub, nil
+}
+
+// keyOf returns the file name without extension, e.g. `7cd084941338484aae1ad9425b84077c` of
+// `https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png`.
+func keyOf(u string) string {
+	b := path.Base(u)
+	return strings.TrimSuffix(b, path.Ext(b))
+}
+
+func mixinKey(img string, sub string) string {
+	raw := img + sub
+	var sb strings.Builder
+	for _, i := range mixinKeyEncTab {
+		if i < len(raw) {
+			sb.WriteByte(raw[i])
+		}
+	}
+	return sb.String()[:32]
+}
+
+// signQuery returns the encoded query with `wts` and `w_rid`, the characters `!'()*` are removed from
+// the values and the spaces are encoded as `%20` as encodeURIComponent of the web client does.
+func signQuery(q url.Values, mixinKey string, wts int64) string {
+	sq := make(url.Values, len(q)+1)
+	for k, vs := range q {
+		if k == "w_rid" || k == "wts" {
+			continue
+		}
+		for _, v := range vs {
+			sq.Add(k, strings.Map(func(r rune) rune {
+				if strings.ContainsRune("!'()*", r) {
+					return -1
+				}
+				return r
+			}, v))
+		}
+	}
+	sq.Set("wts", strconv.FormatInt(wts, 10))
+	// Encode sorts the keys, the `+` of the spaces is replaced since a literal `+` is encoded as `%2B`
+	encoded := strings.ReplaceAll(sq.Encode(), "+", "%20")
+	sum := md5.Sum([]byte(encoded + mixinKey))
+	return encoded + "&w_rid=" + hex.EncodeToString(sum[:])
+}
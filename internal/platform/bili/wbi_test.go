@@ -0,0 +1,138 @@
+package bili
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the vectors are from https://github.com/SocialSisterYi/bilibili-API-collect/blob/master/docs/misc/sign/wbi.md
+const (
+	testImgKey   = "7cd084941338484aae1ad9425b84077c"
+	testSubKey   = "4932caff0ff746eab6f01bf08b70ac45"
+	testMixinKey = "ea1db124af3c7062474693fa704f4ff8"
+)
+
+func Test_mixinKey(t *testing.T) {
+	assert.Equal(t, testMixinKey, mixinKey(testImgKey, testSubKey))
+}
+
+func Test_signQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		q    url.Values
+		want string
+	}{
+		{
+			name: "should sign the sorted query",
+			q:    url.Values{"foo": {"114"}, "bar": {"514"}, "zab": {"1919810"}},
+			want: "bar=514&foo=114&wts=1702204169&zab=1919810&w_rid=8f6f2b5b3d485fe1886cec6a0be8c5d4",
+		},
+		{
+			name: "should replace the old signature",
+			q:    url.Values{"foo": {"114"}, "bar": {"514"}, "zab": {"1919810"}, "wts": {"1"}, "w_rid": {"old"}},
+			want: "bar=514&foo=114&wts=1702204169&zab=1919810&w_rid=8f6f2b5b3d485fe1886cec6a0be8c5d4",
+		},
+		{
+			name: "should remove the reserved characters from the values",
+			q:    url.Values{"foo": {"(114)!"}, "bar": {"5'1*4"}, "zab": {"1919810"}},
+			want: "bar=514&foo=114&wts=1702204169&zab=1919810&w_rid=8f6f2b5b3d485fe1886cec6a0be8c5d4",
+		},
+		{
+			name: "should encode the spaces as %20",
+			q:    url.Values{"foo": {"one two"}, "bar": {"514"}, "zab": {"1919810"}},
+			want: "bar=514&foo=one%20two&wts=1702204169&zab=1919810&w_rid=9b2aa6167e14127a8d047764b67c6fd9",
+		},
+		{
+			name: "should keep the plus sign encoded",
+			q:    url.Values{"foo": {"a+b c"}},
+			want: "foo=a%2Bb%20c&wts=1702204169&w_rid=ae8fc67d5fd6994e77df90c8626fcc9f",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, signQuery(tt.q, testMixinKey, 1702204169))
+		})
+	}
+}
+
+func Test_keyOf(t *testing.T) {
+	assert.Equal(t, testImgKey, keyOf("https://i0.hdslb.com/bfs/wbi/"+testImgKey+".png"))
+}
+
+// wbiClient serves the nav endpoint with the keys, and the wbi endpoint which rejects the first signed request.
+type wbiClient struct {
+	mtx      sync.Mutex
+	navs     int
+	rejected bool
+	queries  []url.Values
+}
+
+func (c *wbiClient) Do(req *http.Request) (*http.Response, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	var body string
+	switch req.URL.Path {
+	case "/x/web-interface/nav":
+		c.navs++
+		body = `{"code":-101,"message":"账号未登录","data":{"isLogin":false,"wbi_img":{` +
+			`"img_url":"https://i0.hdslb.com/bfs/wbi/` + testImgKey + `.png",` +
+			`"sub_url":"https://i0.hdslb.com/bfs/wbi/` + testSubKey + `.png"}}}`
+	default:
+		c.queries = append(c.queries, req.URL.Query())
+		if c.rejected {
+			body = `{"code":0,"message":"0","data":{"a":1,"b":"2"}}`
+		} else {
+			c.rejected = true
+			body = `{"code":-352,"message":"风控校验失败","data":null}`
+		}
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+func Test_biliClient_getJson_wbi(t *testing.T) {
+	pc := &wbiClient{}
+	w := newWbi(slog.Default(), pc)
+	w.now = func() time.Time {
+		return time.Unix(1702204169, 0)
+	}
+	c := biliClient[testData]{Client: pc, log: slog.Default(), wbi: w}
+
+	t.Run("should sign again with the refreshed keys since rejected", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "https://api.bilibili.com/x/wbi/test?foo=114&bar=514&zab=1919810", nil)
+		got, err := c.getJson(req)
+		assert.NoError(t, err)
+		assert.Equal(t, &testData{A: 1, B: "2"}, got)
+		assert.Equal(t, 2, pc.navs)
+		assert.Len(t, pc.queries, 2)
+		for _, q := range pc.queries {
+			assert.Equal(t, "1702204169", q.Get("wts"))
+			assert.Equal(t, "8f6f2b5b3d485fe1886cec6a0be8c5d4", q.Get("w_rid"))
+		}
+	})
+
+	t.Run("should use the cached keys", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "https://api.bilibili.com/x/wbi/test?foo=114", nil)
+		_, err := c.getJson(req)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, pc.navs)
+	})
+
+	t.Run("should not sign the other endpoints", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "https://api.bilibili.com/x/test?foo=114", nil)
+		_, err := c.getJson(req)
+		assert.NoError(t, err)
+		assert.Empty(t, pc.queries[len(pc.queries)-1].Get("w_rid"))
+	})
+}
@@ -0,0 +1,274 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultRoomTtl       = 30 * time.Second
+	defaultQualityTtl    = 10 * time.Minute
+	defaultLiveUrlTtl    = 5 * time.Minute
+	defaultMaxCacheItems = 1000
+)
+
+// liveUrlExpiryMargin is how long before the expiry of the live urls they are dropped from the cache, so
+// that the player does not get the urls which expire soon.
+const liveUrlExpiryMargin = 30 * time.Second
+
+// CacheOptions is the ttl of the cached results, the zero values are replaced by the defaults and the
+// negative ones disable the cache.
+type CacheOptions struct {
+	// RoomTtl is 30 seconds by default, it is short since the online status changes.
+	RoomTtl time.Duration
+	// QualityTtl is 10 minutes by default.
+	QualityTtl time.Duration
+	// LiveUrlTtl is 5 minutes by default, it is shortened to the expiry of the urls.
+	LiveUrlTtl time.Duration
+	// MaxItems caps the number of the cached results of each kind, 1000 by default.
+	MaxItems int
+}
+
+// CacheCounter is the number of the cache hits and misses.
+type CacheCounter struct {
+	Hits   int64
+	Misses int64
+}
+
+// CacheStats is the counters of each kind of the cached results.
+type CacheStats struct {
+	Rooms     CacheCounter
+	Qualities CacheCounter
+	LiveUrls  CacheCounter
+}
+
+// CachedPlatform caches the rooms, the qualities and the live urls of the platform, the errors are not
+// cached. It is safe for concurrent use.
+type CachedPlatform struct {
+	Platform
+	opts      CacheOptions
+	now       func() time.Time
+	rooms     *ttlCache[Room]
+	qualities *ttlCache[[]Quality]
+	liveUrls  *ttlCache[[]LiveStream]
+}
+
+// Cached returns the platform with the cache of the results of GetRoom, GetQualities, GetLiveUrls, and
+// GetRooms and GetLiveStreams if the platform implements BatchRoomGetter or StreamGetter.
+func Cached(p Platform, opts CacheOptions) *CachedPlatform {
+	if opts.RoomTtl == 0 {
+		opts.RoomTtl = defaultRoomTtl
+	}
+	if opts.QualityTtl == 0 {
+		opts.QualityTtl = defaultQualityTtl
+	}
+	if opts.LiveUrlTtl == 0 {
+		opts.LiveUrlTtl = defaultLiveUrlTtl
+	}
+	if opts.MaxItems <= 0 {
+		opts.MaxItems = defaultMaxCacheItems
+	}
+	return &CachedPlatform{
+		Platform:  p,
+		opts:      opts,
+		now:       time.Now,
+		rooms:     newTtlCache[Room](opts.MaxItems),
+		qualities: newTtlCache[[]Quality](opts.MaxItems),
+		liveUrls:  newTtlCache[[]LiveStream](opts.MaxItems),
+	}
+}
+
+func (c *CachedPlatform) GetRoom(ctx context.Context, roomId string) (Room, error) {
+	if r, ok := c.rooms.get(roomId, c.now()); ok {
+		return r, nil
+	}
+	r, err := c.Platform.GetRoom(ctx, roomId)
+	if err != nil {
+		return Room{}, err
+	}
+	now := c.now()
+	c.rooms.set(roomId, r, now, now.Add(c.opts.RoomTtl))
+	return r, nil
+}
+
+// GetRooms returns the cached rooms and gets the others in batch, it returns an error if the platform
+// does not implement BatchRoomGetter.
+func (c *CachedPlatform) GetRooms(ctx context.Context, roomIds []string) (map[string]Room, error) {
+	bp, ok := c.Platform.(BatchRoomGetter)
+	if !ok {
+		return nil, errors.New("batch getting rooms is not supported")
+	}
+	rs := make(map[string]Room, len(roomIds))
+	missing := make([]string, 0, len(roomIds))
+	for _, id := range roomIds {
+		if r, ok := c.rooms.get(id, c.now()); ok {
+			rs[id] = r
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return rs, nil
+	}
+	mrs, err := bp.GetRooms(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	now := c.now()
+	for id, r := range mrs {
+		c.rooms.set(id, r, now, now.Add(c.opts.RoomTtl))
+		rs[id] = r
+	}
+	return rs, nil
+}
+
+func (c *CachedPlatform) GetQualities(ctx context.Context, roomId string) ([]Quality, error) {
+	if qs, ok := c.qualities.get(roomId, c.now()); ok {
+		return append([]Quality(nil), qs...), nil
+	}
+	qs, err := c.Platform.GetQualities(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	now := c.now()
+	c.qualities.set(roomId, append([]Quality(nil), qs...), now, now.Add(c.opts.QualityTtl))
+	return qs, nil
+}
+
+func (c *CachedPlatform) GetLiveUrls(ctx context.Context, roomId string, qualityId string) ([]url.URL, error) {
+	lss, err := c.GetLiveStreams(ctx, roomId, qualityId)
+	if err != nil {
+		return nil, err
+	}
+	us := make([]url.URL, len(lss))
+	for i, ls := range lss {
+		us[i] = ls.Url
+	}
+	return us, nil
+}
+
+// GetLiveStreams returns the live streams, which are inferred from the live urls if the platform does
+// not implement StreamGetter. The cached streams expire before the earliest expiry of the urls.
+func (c *CachedPlatform) GetLiveStreams(ctx context.Context, roomId string, qualityId string) ([]LiveStream, error) {
+	key := roomId + "\x00" + qualityId
+	if lss, ok := c.liveUrls.get(key, c.now()); ok {
+		return append([]LiveStream(nil), lss...), nil
+	}
+	lss, err := GetLiveStreams(ctx, c.Platform, roomId, qualityId)
+	if err != nil {
+		return nil, err
+	}
+	now := c.now()
+	expiresAt := now.Add(c.opts.LiveUrlTtl)
+	for _, ls := range lss {
+		if ls.ExpiresAt.IsZero() {
+			continue
+		}
+		if e := ls.ExpiresAt.Add(-liveUrlExpiryMargin); e.Before(expiresAt) {
+			expiresAt = e
+		}
+	}
+	c.liveUrls.set(key, append([]LiveStream(nil), lss...), now, expiresAt)
+	return lss, nil
+}
+
+// Invalidate drops the cached room, qualities and live urls of the room.
+func (c *CachedPlatform) Invalidate(roomId string) {
+	c.rooms.delete(func(k string) bool { return k == roomId })
+	c.qualities.delete(func(k string) bool { return k == roomId })
+	c.liveUrls.delete(func(k string) bool { return strings.HasPrefix(k, roomId+"\x00") })
+}
+
+// InvalidateAll drops all the cached results.
+func (c *CachedPlatform) InvalidateAll() {
+	all := func(string) bool { return true }
+	c.rooms.delete(all)
+	c.qualities.delete(all)
+	c.liveUrls.delete(all)
+}
+
+func (c *CachedPlatform) Stats() CacheStats {
+	return CacheStats{
+		Rooms:     c.rooms.counter(),
+		Qualities: c.qualities.counter(),
+		LiveUrls:  c.liveUrls.counter(),
+	}
+}
+
+type ttlCache[V any] struct {
+	maxItems int
+	hits     atomic.Int64
+	misses   atomic.Int64
+
+	mtx   sync.Mutex
+	items map[string]ttlItem[V]
+}
+
+type ttlItem[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+func newTtlCache[V any](maxItems int) *ttlCache[V] {
+	return &ttlCache[V]{maxItems: maxItems, items: make(map[string]ttlItem[V])}
+}
+
+func (c *ttlCache[V]) get(key string, now time.Time) (V, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	it, ok := c.items[key]
+	if ok && now.Before(it.expiresAt) {
+		c.hits.Add(1)
+		return it.value, true
+	}
+	if ok {
+		delete(c.items, key)
+	}
+	c.misses.Add(1)
+	var zero V
+	return zero, false
+}
+
+// set caches the value until expiresAt, nothing is cached if it is not after now, i.e. the ttl is
+// negative or the live urls expire soon. The expired items are dropped when the cache is full, and an
+// arbitrary item is dropped if none is expired.
+func (c *ttlCache[V]) set(key string, value V, now time.Time, expiresAt time.Time) {
+	if !expiresAt.After(now) {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.items[key]; !ok && len(c.items) >= c.maxItems {
+		for k, it := range c.items {
+			if !now.Before(it.expiresAt) {
+				delete(c.items, k)
+			}
+		}
+		for k := range c.items {
+			if len(c.items) < c.maxItems {
+				break
+			}
+			delete(c.items, k)
+		}
+	}
+	c.items[key] = ttlItem[V]{value: value, expiresAt: expiresAt}
+}
+
+func (c *ttlCache[V]) delete(match func(key string) bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for k := range c.items {
+		if match(k) {
+			delete(c.items, k)
+		}
+	}
+}
+
+func (c *ttlCache[V]) counter() CacheCounter {
+	return CacheCounter{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
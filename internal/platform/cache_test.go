@@ -0,0 +1,271 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingPlatform counts the calls of each method, it returns the error if set.
+type countingPlatform struct {
+	err     error
+	streams []LiveStream
+	calls   map[string]int
+}
+
+func (p *countingPlatform) Id() string {
+	return "counting"
+}
+
+func (p *countingPlatform) Name() string {
+	return "Counting"
+}
+
+func (p *countingPlatform) IconUrl() url.URL {
+	return url.URL{}
+}
+
+func (p *countingPlatform) GetRoom(ctx context.Context, roomId string) (Room, error) {
+	p.calls["GetRoom"]++
+	return Room{Id: roomId}, p.err
+}
+
+func (p *countingPlatform) GetQualities(ctx context.Context, roomId string) ([]Quality, error) {
+	p.calls["GetQualities"]++
+	return []Quality{{Id: "hd"}}, p.err
+}
+
+func (p *countingPlatform) GetLiveUrls(ctx context.Context, roomId string, qualityId string) ([]url.URL, error) {
+	p.calls["GetLiveUrls"]++
+	us := make([]url.URL, len(p.streams))
+	for i, ls := range p.streams {
+		us[i] = ls.Url
+	}
+	return us, p.err
+}
+
+type countingBatchPlatform struct {
+	*countingPlatform
+}
+
+func (p countingBatchPlatform) GetRooms(ctx context.Context, roomIds []string) (map[string]Room, error) {
+	p.calls["GetRooms"]++
+	rs := make(map[string]Room, len(roomIds))
+	for _, id := range roomIds {
+		rs[id] = Room{Id: id}
+	}
+	return rs, p.err
+}
+
+type countingStreamPlatform struct {
+	*countingPlatform
+}
+
+func (p countingStreamPlatform) GetLiveStreams(ctx context.Context, roomId string, qualityId string) ([]LiveStream, error) {
+	p.calls["GetLiveStreams"]++
+	return p.streams, p.err
+}
+
+func newCountingPlatform() *countingPlatform {
+	return &countingPlatform{calls: make(map[string]int)}
+}
+
+func TestCachedPlatform_GetRoom(t *testing.T) {
+	now := time.Date(2024, 7, 24, 12, 0, 0, 0, time.UTC)
+	p := newCountingPlatform()
+	c := Cached(p, CacheOptions{})
+	c.now = func() time.Time { return now }
+
+	r, err := c.GetRoom(context.Background(), "6")
+	assert.NoError(t, err)
+	assert.Equal(t, "6", r.Id)
+	_, _ = c.GetRoom(context.Background(), "6")
+	assert.Equal(t, 1, p.calls["GetRoom"])
+
+	now = now.Add(defaultRoomTtl)
+	_, _ = c.GetRoom(context.Background(), "6")
+	assert.Equal(t, 2, p.calls["GetRoom"], "should get again since expired")
+
+	c.Invalidate("6")
+	_, _ = c.GetRoom(context.Background(), "6")
+	assert.Equal(t, 3, p.calls["GetRoom"], "should get again since invalidated")
+	assert.Equal(t, CacheCounter{Hits: 1, Misses: 3}, c.Stats().Rooms)
+
+	t.Run("should not cache errors", func(t *testing.T) {
+		p := newCountingPlatform()
+		p.err = errors.New("no room")
+		c := Cached(p, CacheOptions{})
+		_, err := c.GetRoom(context.Background(), "6")
+		assert.Error(t, err)
+		_, err = c.GetRoom(context.Background(), "6")
+		assert.Error(t, err)
+		assert.Equal(t, 2, p.calls["GetRoom"])
+	})
+
+	t.Run("should not cache since disabled", func(t *testing.T) {
+		p := newCountingPlatform()
+		c := Cached(p, CacheOptions{RoomTtl: -1})
+		_, _ = c.GetRoom(context.Background(), "6")
+		_, _ = c.GetRoom(context.Background(), "6")
+		assert.Equal(t, 2, p.calls["GetRoom"])
+	})
+
+	t.Run("should drop items since full", func(t *testing.T) {
+		p := newCountingPlatform()
+		c := Cached(p, CacheOptions{MaxItems: 2})
+		for _, id := range []string{"1", "2", "3"} {
+			_, _ = c.GetRoom(context.Background(), id)
+		}
+		assert.Len(t, c.rooms.items, 2)
+		assert.Contains(t, c.rooms.items, "3")
+	})
+}
+
+func TestCachedPlatform_GetRooms(t *testing.T) {
+	p := countingBatchPlatform{newCountingPlatform()}
+	c := Cached(p, CacheOptions{})
+	_, _ = c.GetRoom(context.Background(), "1")
+
+	rs, err := c.GetRooms(context.Background(), []string{"1", "2", "3"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Room{"1": {Id: "1"}, "2": {Id: "2"}, "3": {Id: "3"}}, rs)
+	assert.Equal(t, 1, p.calls["GetRooms"])
+	_, _ = c.GetRooms(context.Background(), []string{"2", "3"})
+	assert.Equal(t, 1, p.calls["GetRooms"], "should not get since all cached")
+	assert.Equal(t, CacheCounter{Hits: 3, Misses: 3}, c.Stats().Rooms)
+
+	t.Run("should return error since batch is not supported", func(t *testing.T) {
+		c := Cached(newCountingPlatform(), CacheOptions{})
+		_, err := c.GetRooms(context.Background(), []string{"1"})
+		assert.Error(t, err)
+	})
+}
+
+func TestCachedPlatform_GetQualities(t *testing.T) {
+	p := newCountingPlatform()
+	c := Cached(p, CacheOptions{})
+	qs, err := c.GetQualities(context.Background(), "6")
+	assert.NoError(t, err)
+	// the cached qualities are not affected by the caller
+	qs[0].Id = "changed"
+	qs, err = c.GetQualities(context.Background(), "6")
+	assert.NoError(t, err)
+	assert.Equal(t, []Quality{{Id: "hd"}}, qs)
+	assert.Equal(t, 1, p.calls["GetQualities"])
+	assert.Equal(t, CacheCounter{Hits: 1, Misses: 1}, c.Stats().Qualities)
+}
+
+func TestCachedPlatform_GetLiveStreams(t *testing.T) {
+	now := time.Date(2024, 7, 24, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		// after is the duration since the first call
+		after      time.Duration
+		expiresAt  time.Time
+		wantCalled int
+	}{
+		{
+			name:       "should hit since the urls never expire",
+			after:      defaultLiveUrlTtl - time.Second,
+			wantCalled: 1,
+		},
+		{
+			name:       "should miss since the ttl is passed",
+			after:      defaultLiveUrlTtl,
+			wantCalled: 2,
+		},
+		{
+			name:       "should hit before the urls expire",
+			after:      time.Minute,
+			expiresAt:  now.Add(2 * time.Minute),
+			wantCalled: 1,
+		},
+		{
+			name:       "should miss since the urls expire soon",
+			after:      2*time.Minute - liveUrlExpiryMargin,
+			expiresAt:  now.Add(2 * time.Minute),
+			wantCalled: 2,
+		},
+		{
+			name:       "should not cache since the urls expire too soon",
+			after:      0,
+			expiresAt:  now.Add(liveUrlExpiryMargin),
+			wantCalled: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := url.URL{Scheme: "https", Host: "example.com", Path: "/live.flv"}
+			p := countingStreamPlatform{newCountingPlatform()}
+			p.streams = []LiveStream{{Url: u, ExpiresAt: tt.expiresAt}}
+			c := Cached(p, CacheOptions{})
+			current := now
+			c.now = func() time.Time { return current }
+			_, err := c.GetLiveStreams(context.Background(), "6", "hd")
+			assert.NoError(t, err)
+			current = now.Add(tt.after)
+			lss, err := c.GetLiveStreams(context.Background(), "6", "hd")
+			assert.NoError(t, err)
+			assert.Equal(t, p.streams, lss)
+			assert.Equal(t, tt.wantCalled, p.calls["GetLiveStreams"])
+		})
+	}
+
+	t.Run("should expire the relay urls with the signature of the upstream urls", func(t *testing.T) {
+		upstream := url.URL{
+			Scheme:   "https",
+			Host:     "tx.hls.huya.com",
+			Path:     "/src/live.m3u8",
+			RawQuery: "wsTime=" + strconv.FormatInt(now.Add(2*time.Minute).Unix(), 16),
+		}
+		// the platforms infer the streams before wrapping the urls, as the relay urls carry no expiry
+		ls := InferLiveStream(upstream)
+		ls.Url = url.URL{Scheme: "http", Host: "127.0.0.1:8080", Path: "/stream", RawQuery: url.Values{"origin": {upstream.String()}}.Encode()}
+		p := countingStreamPlatform{newCountingPlatform()}
+		p.streams = []LiveStream{ls}
+		c := Cached(p, CacheOptions{})
+		current := now
+		c.now = func() time.Time { return current }
+		_, _ = c.GetLiveStreams(context.Background(), "6", "hd")
+		current = now.Add(time.Minute)
+		_, _ = c.GetLiveStreams(context.Background(), "6", "hd")
+		assert.Equal(t, 1, p.calls["GetLiveStreams"])
+		current = now.Add(2*time.Minute - liveUrlExpiryMargin)
+		_, _ = c.GetLiveStreams(context.Background(), "6", "hd")
+		assert.Equal(t, 2, p.calls["GetLiveStreams"])
+	})
+
+	t.Run("should infer streams since the platform is not StreamGetter", func(t *testing.T) {
+		u := url.URL{Scheme: "https", Host: "example.com", Path: "/live.flv"}
+		p := newCountingPlatform()
+		p.streams = []LiveStream{{Url: u}}
+		c := Cached(p, CacheOptions{})
+		lss, err := c.GetLiveStreams(context.Background(), "6", "hd")
+		assert.NoError(t, err)
+		assert.Equal(t, []LiveStream{InferLiveStream(u)}, lss)
+		us, err := c.GetLiveUrls(context.Background(), "6", "hd")
+		assert.NoError(t, err)
+		assert.Equal(t, []url.URL{u}, us)
+		assert.Equal(t, 1, p.calls["GetLiveUrls"])
+		assert.Equal(t, CacheCounter{Hits: 1, Misses: 1}, c.Stats().LiveUrls)
+	})
+
+	t.Run("should keep the urls of other rooms since invalidated", func(t *testing.T) {
+		p := countingStreamPlatform{newCountingPlatform()}
+		c := Cached(p, CacheOptions{})
+		_, _ = c.GetLiveStreams(context.Background(), "6", "hd")
+		_, _ = c.GetLiveStreams(context.Background(), "66", "hd")
+		c.Invalidate("6")
+		_, _ = c.GetLiveStreams(context.Background(), "6", "hd")
+		_, _ = c.GetLiveStreams(context.Background(), "66", "hd")
+		assert.Equal(t, 3, p.calls["GetLiveStreams"])
+		c.InvalidateAll()
+		_, _ = c.GetLiveStreams(context.Background(), "66", "hd")
+		assert.Equal(t, 4, p.calls["GetLiveStreams"])
+	})
+}
@@ -1,46 +1,236 @@
 package platform
 
 import (
+	"asmblive/internal/proxy"
+	"context"
 	"errors"
+	"io"
 	"log/slog"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 )
 
 type Headers = map[string]string
 
+const (
+	defaultTimeout     = 15 * time.Second
+	defaultMaxAttempts = 3
+	defaultBaseDelay   = 200 * time.Millisecond
+	defaultMaxDelay    = 5 * time.Second
+)
+
 type Client interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// ClientOptions is the policy of the requests, the zero values are replaced by the defaults.
+type ClientOptions struct {
+	// Timeout is the timeout of each attempt until the response headers are received, 15 seconds by
+	// default. Reading the body is not limited, so that the streams and the large bodies are not cut off.
+	Timeout time.Duration
+	// MaxAttempts is the max number of attempts of a request, 3 by default, 1 disables retrying.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it doubles on each retry, 200 milliseconds by default.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay before a retry, 5 seconds by default. The request is not retried if the
+	// server asks for a longer delay with Retry-After.
+	MaxDelay time.Duration
+	// RateLimit returns the limit of the requests to each host, it is called on each request so that the
+	// changes take effect immediately. Nil means unlimited.
+	RateLimit func() RateLimit
+	// Proxy returns the proxy of the request as http.Transport.Proxy does, nil means direct connections.
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
 type client struct {
-	log *slog.Logger
-	hc  *http.Client
+	log  *slog.Logger
+	hc   *http.Client
+	opts ClientOptions
+	// lim is nil if the requests are unlimited.
+	lim *limiter
+	co  *coalescer
+	// sleep waits for the delay before a retry, it returns false if the context is done.
+	sleep func(ctx context.Context, d time.Duration) bool
 }
 
 // NewClient returns a new instance of the Client.
 // The parameter `h` is the default headers to be used for all requests.
-func NewClient(l *slog.Logger, h Headers) Client {
+// The idempotent requests are retried with exponential backoff on network errors, 429 and 5xx, and the
+// concurrent identical ones share one upstream response.
+func NewClient(l *slog.Logger, h Headers, opts ClientOptions) Client {
 	l = l.With("module", "platform/client")
-	tsp := roundTripper{h: h}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = defaultBaseDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = defaultMaxDelay
+	}
+	var t http.RoundTripper = http.DefaultTransport
+	if opts.Proxy != nil {
+		t = proxy.Transport(opts.Proxy)
+	}
+	tsp := roundTripper{h: h, t: t}
 	hc := &http.Client{Transport: tsp}
-	return &client{log: l, hc: hc}
+	c := &client{log: l, hc: hc, opts: opts, co: newCoalescer(), sleep: sleep}
+	if opts.RateLimit != nil {
+		c.lim = newLimiter(opts.RateLimit)
+	}
+	return c
 }
 
 func (c client) Do(req *http.Request) (*http.Response, error) {
-	res, err := c.hc.Do(req)
-	if err != nil {
-		c.log.Error("request failed", "error", err)
-		return nil, ErrRequest(err)
+	if !isIdempotent(req) {
+		return c.send(req, false)
+	}
+	res, shared, err := c.co.do(req, func(req *http.Request) (*http.Response, error) {
+		return c.send(req, true)
+	})
+	if shared {
+		c.log.Debug("request coalesced", "method", req.Method, "host", req.URL.Host, "path", req.URL.Path)
+	}
+	return res, err
+}
+
+// send sends the request, which is retried if it is retryable.
+func (c client) send(req *http.Request, retryable bool) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := c.do(req)
+		last := !retryable || attempt >= c.opts.MaxAttempts
+		if err == nil && res.StatusCode < 400 {
+			return res, nil
+		}
+		delay := c.backoff(attempt)
+		if err != nil {
+			// the timeout of an attempt is retried, but the canceled request is not
+			if req.Context().Err() != nil {
+				last = true
+			}
+		} else {
+			if !isRetryableStatus(res.StatusCode) {
+				last = true
+			}
+			if ra, ok := retryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+				if ra > c.opts.MaxDelay {
+					last = true
+				}
+				delay = max(delay, ra)
+			}
+			closeBody(c.log, res)
+			err = errors.New("status code: " + res.Status)
+		}
+		log := c.log.With("method", req.Method, "host", req.URL.Host, "path", req.URL.Path,
+			"attempt", attempt, "maxAttempts", c.opts.MaxAttempts, "error", err)
+		if res != nil {
+			log = log.With("status", res.StatusCode)
+		}
+		if last {
+			log.Error("request failed")
+			return nil, ErrRequest(err)
+		}
+		log.Warn("request failed, retry later", "delay", delay)
+		if !c.sleep(req.Context(), delay) {
+			return nil, ErrRequest(req.Context().Err())
+		}
+	}
+}
+
+// do sends the request with the timeout, which is stopped once the response headers are received. The
+// request is canceled when the body is closed. The waiting for the rate limit is not counted in the timeout.
+func (c client) do(req *http.Request) (*http.Response, error) {
+	if c.lim != nil {
+		d, err := c.lim.wait(req.Context(), req.URL.Host)
+		if err != nil {
+			return nil, err
+		}
+		if d > 0 {
+			c.log.Debug("request delayed by rate limit", "host", req.URL.Host, "path", req.URL.Path, "delay", d)
+		}
 	}
-	if res.StatusCode >= 400 {
-		c.log.Error("request failed", "status", res.StatusCode)
-		return nil, ErrRequest(errors.New("status code: " + res.Status))
+	ctx, cancel := context.WithCancelCause(req.Context())
+	timer := time.AfterFunc(c.opts.Timeout, func() { cancel(context.DeadlineExceeded) })
+	res, err := c.hc.Do(req.Clone(ctx))
+	timer.Stop()
+	if err != nil {
+		cancel(nil)
+		return nil, err
 	}
+	res.Body = cancelBody{ReadCloser: res.Body, cancel: cancel}
 	return res, nil
 }
 
+// backoff returns the delay before the next attempt, it is a random duration between the half and the
+// whole of the exponential delay, so that the clients do not retry at the same time.
+func (c client) backoff(attempt int) time.Duration {
+	d := c.opts.BaseDelay << (attempt - 1)
+	if d > c.opts.MaxDelay || d <= 0 {
+		d = c.opts.MaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelCauseFunc
+}
+
+func (b cancelBody) Close() error {
+	defer b.cancel(nil)
+	return b.ReadCloser.Close()
+}
+
+func isIdempotent(req *http.Request) bool {
+	return (req.Method == http.MethodGet || req.Method == http.MethodHead) && req.Body == nil
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// retryAfter parses the Retry-After header, which is in seconds or an http date.
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func closeBody(log *slog.Logger, res *http.Response) {
+	// drain the body so that the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+	if err := res.Body.Close(); err != nil {
+		log.Warn("failed to close response body", "error", err)
+	}
+}
+
 type roundTripper struct {
 	h Headers
+	t http.RoundTripper
 }
 
 func (r roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -49,5 +239,5 @@ func (r roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 			req.Header.Set(k, v)
 		}
 	}
-	return http.DefaultTransport.RoundTrip(req)
+	return r.t.RoundTrip(req)
 }
@@ -1,12 +1,17 @@
 package platform
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func Test_client_Do(t *testing.T) {
@@ -54,7 +59,7 @@ func Test_client_Do(t *testing.T) {
 				_, _ = w.Write(tt.fields.body)
 			}))
 			defer ts.Close()
-			c := NewClient(slog.Default(), tt.fields.headers)
+			c := NewClient(slog.Default(), tt.fields.headers, ClientOptions{BaseDelay: time.Millisecond})
 			req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
 			assert.NoError(t, err)
 			got, err := c.Do(req)
@@ -72,3 +77,206 @@ func Test_client_Do(t *testing.T) {
 		})
 	}
 }
+
+func Test_client_Do_retry(t *testing.T) {
+	tests := []struct {
+		name string
+		// failures is the number of the failed responses before the success
+		failures     int32
+		fail         func(w http.ResponseWriter, r *http.Request)
+		method       string
+		wantAttempts int32
+		wantErr      string
+	}{
+		{
+			name:     "should succeed after retrying 5xx",
+			failures: 2,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantAttempts: 3,
+		},
+		{
+			name:     "should fail since attempts are exhausted",
+			failures: 3,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			wantAttempts: 3,
+			wantErr:      "status code: 502",
+		},
+		{
+			name:     "should not retry 4xx",
+			failures: 1,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			wantAttempts: 1,
+			wantErr:      "status code: 404",
+		},
+		{
+			name:     "should retry 429 after Retry-After",
+			failures: 1,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantAttempts: 2,
+		},
+		{
+			name:     "should not retry since Retry-After is too long",
+			failures: 1,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "60")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantAttempts: 1,
+			wantErr:      "status code: 429",
+		},
+		{
+			name:     "should not retry non-idempotent requests",
+			failures: 1,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			method:       http.MethodPost,
+			wantAttempts: 1,
+			wantErr:      "status code: 503",
+		},
+		{
+			name:     "should retry since the attempt times out",
+			failures: 1,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+			wantAttempts: 2,
+		},
+		{
+			name:     "should retry since the connection is broken",
+			failures: 1,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				_ = conn.Close()
+			},
+			wantAttempts: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) <= tt.failures {
+					tt.fail(w, r)
+					return
+				}
+				_, _ = w.Write([]byte("ok"))
+			}))
+			defer ts.Close()
+			c := NewClient(slog.Default(), nil, ClientOptions{
+				Timeout:   200 * time.Millisecond,
+				BaseDelay: time.Millisecond,
+				MaxDelay:  time.Second,
+			})
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			var body io.Reader
+			if method == http.MethodPost {
+				body = strings.NewReader("body")
+			}
+			req, _ := http.NewRequest(method, ts.URL, body)
+			res, err := c.Do(req)
+			assert.Equal(t, tt.wantAttempts, attempts.Load())
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			data, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, "ok", string(data))
+			assert.NoError(t, res.Body.Close())
+		})
+	}
+
+	t.Run("should stop retrying since the request is canceled", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+		c := NewClient(slog.Default(), nil, ClientOptions{BaseDelay: time.Hour, MaxDelay: time.Hour})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+		_, err := c.Do(req)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("should not time out while reading the body", func(t *testing.T) {
+		var attempts atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			_, _ = w.Write([]byte("o"))
+			w.(http.Flusher).Flush()
+			time.Sleep(300 * time.Millisecond)
+			_, _ = w.Write([]byte("k"))
+		}))
+		defer ts.Close()
+		c := NewClient(slog.Default(), nil, ClientOptions{Timeout: 100 * time.Millisecond, BaseDelay: time.Millisecond})
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		res, err := c.Do(req)
+		if !assert.NoError(t, err) {
+			return
+		}
+		data, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", string(data))
+		assert.NoError(t, res.Body.Close())
+		assert.Equal(t, int32(1), attempts.Load())
+	})
+}
+
+func Test_retryAfter(t *testing.T) {
+	now := time.Date(2024, 7, 24, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{name: "should parse seconds", value: "3", want: 3 * time.Second, wantOk: true},
+		{name: "should parse http date", value: "Wed, 24 Jul 2024 12:00:10 GMT", want: 10 * time.Second, wantOk: true},
+		{name: "should be zero since the date is passed", value: "Wed, 24 Jul 2024 11:00:00 GMT", want: 0, wantOk: true},
+		{name: "should not parse empty value", value: ""},
+		{name: "should not parse invalid value", value: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(tt.value, now)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_client_Do_proxy(t *testing.T) {
+	var proxied atomic.Int32
+	// the http proxy answers the requests in absolute form by itself
+	px := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		_, _ = w.Write([]byte("proxied " + r.URL.String()))
+	}))
+	defer px.Close()
+	pu, _ := url.Parse(px.URL)
+	c := NewClient(slog.Default(), nil, ClientOptions{Proxy: http.ProxyURL(pu)})
+	req, _ := http.NewRequest(http.MethodGet, "http://live.example.com/room", nil)
+	res, err := c.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, _ := io.ReadAll(res.Body)
+	assert.NoError(t, res.Body.Close())
+	assert.Equal(t, "proxied http://live.example.com/room", string(data))
+	assert.Equal(t, int32(1), proxied.Load())
+}
@@ -0,0 +1,153 @@
+package platform
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxCoalescedBodySize caps the body shared by the coalesced requests, the larger response is returned to
+// the first request only.
+const maxCoalescedBodySize = 1 << 20
+
+// coalescer lets the concurrent identical requests share one upstream response, it is safe for
+// concurrent use.
+type coalescer struct {
+	mtx   sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done chan struct{}
+	res  *http.Response
+	body []byte
+	err  error
+	// shared is false if the result cannot be shared, e.g. the response is a stream, then the waiting
+	// requests are sent by themselves.
+	shared bool
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{calls: make(map[string]*call)}
+}
+
+// do sends the request by `send` unless an identical one is in flight, whose result is shared instead.
+// It reports whether the result is shared from another request.
+func (g *coalescer) do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, bool, error) {
+	key := coalesceKey(req)
+	g.mtx.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mtx.Unlock()
+		select {
+		case <-req.Context().Done():
+			return nil, false, req.Context().Err()
+		case <-c.done:
+		}
+		if !c.shared {
+			res, err := send(req)
+			return res, false, err
+		}
+		if c.err != nil {
+			return nil, true, c.err
+		}
+		return c.response(), true, nil
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mtx.Unlock()
+	defer func() {
+		g.mtx.Lock()
+		delete(g.calls, key)
+		g.mtx.Unlock()
+		close(c.done)
+	}()
+
+	res, err := send(req)
+	if err != nil {
+		// the error caused by the canceled request should not fail the others
+		c.err = err
+		c.shared = req.Context().Err() == nil
+		return nil, false, err
+	}
+	if !isShareable(res) {
+		return res, false, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxCoalescedBodySize+1))
+	if err != nil {
+		_ = res.Body.Close()
+		c.err = err
+		c.shared = req.Context().Err() == nil
+		return nil, false, err
+	}
+	if len(data) > maxCoalescedBodySize {
+		res.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), res.Body), res.Body}
+		return res, false, nil
+	}
+	_ = res.Body.Close()
+	c.res = res
+	c.body = data
+	c.shared = true
+	return c.response(), false, nil
+}
+
+// response returns a copy of the shared response with its own body.
+func (c *call) response() *http.Response {
+	res := *c.res
+	res.Header = c.res.Header.Clone()
+	res.Body = io.NopCloser(bytes.NewReader(c.body))
+	return &res
+}
+
+// shareableTypes are the media types of the api responses which can be shared, the other types are not
+// since they may be streams which never end.
+var shareableTypes = map[string]bool{
+	"application/json":              true,
+	"text/html":                     true,
+	"application/xml":               true,
+	"text/xml":                      true,
+	"application/vnd.apple.mpegurl": true,
+	"application/x-mpegurl":         true,
+	"audio/mpegurl":                 true,
+	"audio/x-mpegurl":               true,
+}
+
+// isShareable reports whether the response can be read into memory, only the small responses of the
+// shareable types are.
+func isShareable(res *http.Response) bool {
+	if res.ContentLength > maxCoalescedBodySize {
+		return false
+	}
+	mt, _, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return shareableTypes[mt] || strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml")
+}
+
+// coalesceKey identifies the identical requests by the method, the url and the headers, so that the
+// requests with different cookies are not coalesced.
+func coalesceKey(req *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(req.Method)
+	sb.WriteByte(' ')
+	sb.WriteString(req.URL.String())
+	ks := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	for _, k := range ks {
+		sb.WriteByte('\n')
+		sb.WriteString(k)
+		sb.WriteByte(':')
+		sb.WriteString(strings.Join(req.Header[k], ","))
+	}
+	return sb.String()
+}
@@ -0,0 +1,151 @@
+package platform
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_client_Do_coalesce(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		// header returns the header of the i-th request
+		header        func(i int) http.Header
+		method        string
+		wantUpstreams int32
+	}{
+		{
+			name:          "should share one upstream response",
+			contentType:   "application/json",
+			body:          `{"code":0}`,
+			wantUpstreams: 1,
+		},
+		{
+			name:        "should not coalesce since the cookies are different",
+			contentType: "application/json",
+			body:        `{"code":0}`,
+			header: func(i int) http.Header {
+				h := http.Header{}
+				h.Set("Cookie", "uid="+string(rune('a'+i)))
+				return h
+			},
+			wantUpstreams: 4,
+		},
+		{
+			name:          "should not coalesce since the response is a stream",
+			contentType:   "video/x-flv",
+			body:          "FLV",
+			wantUpstreams: 4,
+		},
+		{
+			name:          "should not coalesce since the response is too large",
+			contentType:   "text/html",
+			body:          strings.Repeat("a", maxCoalescedBodySize+1),
+			wantUpstreams: 4,
+		},
+		{
+			name:          "should share the playlist",
+			contentType:   "application/vnd.apple.mpegurl",
+			body:          "#EXTM3U",
+			wantUpstreams: 1,
+		},
+		{
+			name:          "should not coalesce since the response type is plain text",
+			contentType:   "text/plain",
+			body:          "a",
+			wantUpstreams: 4,
+		},
+		{
+			name:          "should not coalesce since the response type is unknown",
+			contentType:   "application/x-unknown",
+			body:          "a",
+			wantUpstreams: 4,
+		},
+		{
+			name:          "should not coalesce since the response type is missing",
+			body:          "a",
+			wantUpstreams: 4,
+		},
+		{
+			name:          "should not coalesce non-idempotent requests",
+			contentType:   "application/json",
+			body:          `{"code":0}`,
+			method:        http.MethodPost,
+			wantUpstreams: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var upstreams atomic.Int32
+			release := make(chan struct{})
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstreams.Add(1)
+				<-release
+				w.Header().Set("Content-Type", tt.contentType)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+			c := NewClient(slog.Default(), nil, ClientOptions{})
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			var wg sync.WaitGroup
+			bodies := make([]string, 4)
+			for i := range bodies {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					var body io.Reader
+					if method == http.MethodPost {
+						body = strings.NewReader("body")
+					}
+					req, _ := http.NewRequest(method, ts.URL+"/room?id=6", body)
+					if tt.header != nil {
+						req.Header = tt.header(i)
+					}
+					res, err := c.Do(req)
+					if !assert.NoError(t, err) {
+						return
+					}
+					defer func() { _ = res.Body.Close() }()
+					data, err := io.ReadAll(res.Body)
+					assert.NoError(t, err)
+					bodies[i] = string(data)
+				}(i)
+			}
+			// let the requests be in flight together
+			time.Sleep(100 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			assert.Equal(t, tt.wantUpstreams, upstreams.Load())
+			for _, b := range bodies {
+				assert.Equal(t, tt.body, b)
+			}
+		})
+	}
+}
+
+func Test_coalesceKey(t *testing.T) {
+	newReq := func(u string, h map[string]string) *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, u, nil)
+		for k, v := range h {
+			req.Header.Set(k, v)
+		}
+		return req
+	}
+	a := coalesceKey(newReq("https://example.com/a?b=1", map[string]string{"Referer": "r", "Cookie": "c"}))
+	assert.Equal(t, a, coalesceKey(newReq("https://example.com/a?b=1", map[string]string{"Cookie": "c", "Referer": "r"})))
+	assert.NotEqual(t, a, coalesceKey(newReq("https://example.com/a?b=2", map[string]string{"Referer": "r", "Cookie": "c"})))
+	assert.NotEqual(t, a, coalesceKey(newReq("https://example.com/a?b=1", map[string]string{"Referer": "r", "Cookie": "d"})))
+}
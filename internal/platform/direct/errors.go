@@ -0,0 +1,23 @@
+package direct
+
+import "fmt"
+
+func ErrGetRoom(err error) error {
+	return fmt.Errorf("failed to get room: %w", err)
+}
+
+func ErrGetQualities(err error) error {
+	return fmt.Errorf("failed to get qualities: %w", err)
+}
+
+func ErrGetLiveUrls(err error) error {
+	return fmt.Errorf("failed to get live urls: %w", err)
+}
+
+func errInvalidUrl(err error) error {
+	return fmt.Errorf("invalid stream url: %w", err)
+}
+
+func ErrResolveRoomId(err error) error {
+	return fmt.Errorf("failed to resolve room id: %w", err)
+}
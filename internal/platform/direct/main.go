@@ -0,0 +1,176 @@
+package direct
+
+import (
+	"asmblive/internal/platform"
+	"asmblive/internal/platform/hls"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+)
+
+// sourceQualityId is the quality of the url itself, it is the only quality of a non-master stream.
+const sourceQualityId = "source"
+
+// maxPlaylistSize limits the size of a master playlist to be read.
+const maxPlaylistSize = 1024 * 1024 // 1 MB
+
+const icon = "image/svg+xml;base64,PHN2ZyB4bWxucz0iaHR0cDovL3d3dy53My5vcmcvMjAwMC9zdmciIHZpZXdCb3g9IjAgMCAyNCAyNCIgZmlsbD0ibm9uZSIgc3Ryb2tlPSIjNjY2IiBzdHJva2Utd2lkdGg9IjIiPjxwYXRoIGQ9Ik0xMCAxM2E1IDUgMCAwIDAgNy41NC41NGwzLTNhNSA1IDAgMCAwLTcuMDctNy4wN2wtMS43MiAxLjcxIi8+PHBhdGggZD0iTTE0IDExYTUgNSAwIDAgMC03LjU0LS41NGwtMyAzYTUgNSAwIDAgMCA3LjA3IDcuMDdsMS43MS0xLjcxIi8+PC9zdmc+"
+
+// Direct is a platform whose room id is the url of a HLS or FLV stream.
+type Direct struct {
+	log *slog.Logger
+	pc  platform.Client
+}
+
+func NewDirect(log *slog.Logger, pc platform.Client) *Direct {
+	log = log.With("module", "platform/direct")
+	return &Direct{
+		log: log,
+		pc:  pc,
+	}
+}
+
+func (d Direct) Id() string {
+	return "url"
+}
+
+func (d Direct) Name() string {
+	return "直播链接"
+}
+
+func (d Direct) IconUrl() url.URL {
+	return url.URL{Scheme: "data", Opaque: icon}
+}
+
+// GetRoom probes the stream url, the room is offline if the url is unreachable.
+func (d Direct) GetRoom(ctx context.Context, roomId string) (platform.Room, error) {
+	u, err := parseUrl(roomId)
+	if err != nil {
+		return platform.Room{}, ErrGetRoom(err)
+	}
+	isOnline := true
+	res, err := d.get(ctx, u)
+	if err != nil {
+		d.log.Info("stream is unreachable", "url", roomId, "err", err)
+		isOnline = false
+	} else {
+		d.closeBody(res)
+	}
+	title := path.Base(u.Path)
+	if title == "." || title == "/" {
+		title = u.Host
+	}
+	return platform.Room{
+		Id:       u.String(),
+		Title:    title,
+		IsOnline: isOnline,
+		Owner: platform.Owner{
+			Id:   u.Host,
+			Name: u.Hostname(),
+		},
+	}, nil
+}
+
+// GetQualities returns the variants of a HLS master playlist, or the source quality for other streams.
+func (d Direct) GetQualities(ctx context.Context, roomId string) ([]platform.Quality, error) {
+	u, err := parseUrl(roomId)
+	if err != nil {
+		return []platform.Quality{}, ErrGetQualities(err)
+	}
+	vs, err := d.getVariants(ctx, u)
+	if err != nil {
+		return []platform.Quality{}, ErrGetQualities(err)
+	}
+	if len(vs) == 0 {
+		return []platform.Quality{{Id: sourceQualityId, Name: "原画", Priority: 0}}, nil
+	}
+	qualities := make([]platform.Quality, len(vs))
+	for idx, v := range vs {
+		qualities[idx] = platform.Quality{
+			Id:       v.Id,
+			Name:     v.Name,
+			Priority: int8(-idx),
+		}
+	}
+	return qualities, nil
+}
+
+// GetLiveUrls returns the url itself, or the variant playlist of the quality.
+func (d Direct) GetLiveUrls(ctx context.Context, roomId string, qualityId string) ([]url.URL, error) {
+	u, err := parseUrl(roomId)
+	if err != nil {
+		return []url.URL{}, ErrGetLiveUrls(err)
+	}
+	if qualityId == "" || qualityId == sourceQualityId {
+		return []url.URL{*u}, nil
+	}
+	vs, err := d.getVariants(ctx, u)
+	if err != nil {
+		return []url.URL{}, ErrGetLiveUrls(err)
+	}
+	for _, v := range vs {
+		if v.Id == qualityId {
+			return []url.URL{v.Url}, nil
+		}
+	}
+	return []url.URL{}, ErrGetLiveUrls(fmt.Errorf("quality not found: %s", qualityId))
+}
+
+// getVariants returns the variants sorted from the best to the worst,
+// it returns a zero-length slice if the stream is not a HLS master playlist.
+func (d Direct) getVariants(ctx context.Context, u *url.URL) ([]hls.Variant, error) {
+	res, err := d.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer d.closeBody(res)
+	// only peek the beginning since a FLV stream never ends
+	br := bufio.NewReader(io.LimitReader(res.Body, maxPlaylistSize))
+	if h, err := br.Peek(len("#EXTM3U")); err != nil || string(h) != "#EXTM3U" {
+		return make([]hls.Variant, 0), nil
+	}
+	vs, err := hls.ParseMaster(br, *res.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(vs, func(i, j int) bool {
+		return vs[i].Bandwidth > vs[j].Bandwidth
+	})
+	return vs, nil
+}
+
+func (d Direct) get(ctx context.Context, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, platform.ErrRequest(err)
+	}
+	res, err := d.pc.Do(req)
+	if err != nil {
+		return nil, platform.ErrRequest(err)
+	}
+	return res, nil
+}
+
+func (d Direct) closeBody(res *http.Response) {
+	if err := res.Body.Close(); err != nil {
+		d.log.Warn("failed to close response body", "error", err)
+	}
+}
+
+func parseUrl(roomId string) (*url.URL, error) {
+	u, err := url.Parse(roomId)
+	if err != nil {
+		return nil, errInvalidUrl(err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errInvalidUrl(errors.New("only absolute http(s) url is supported"))
+	}
+	return u, nil
+}
@@ -0,0 +1,212 @@
+package direct
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const master = `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,RESOLUTION=854x480
+480.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=3000000,RESOLUTION=1280x720
+720.m3u8
+`
+
+const media = `#EXTM3U
+#EXT-X-TARGETDURATION:2
+#EXTINF:2.000,
+seg1.ts
+`
+
+func newTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/live/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		_, _ = w.Write([]byte(master))
+	})
+	mux.HandleFunc("/live/media.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		_, _ = w.Write([]byte(media))
+	})
+	mux.HandleFunc("/live/stream.flv", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/x-flv")
+		_, _ = w.Write([]byte{'F', 'L', 'V', 0x01, 0x05, 0x00, 0x00, 0x00, 0x09})
+	})
+	return httptest.NewServer(mux)
+}
+
+func newTestDirect() Direct {
+	return Direct{
+		log: slog.Default(),
+		pc:  platform.NewClient(slog.Default(), platform.Headers{}, platform.ClientOptions{}),
+	}
+}
+
+func TestNewDirect(t *testing.T) {
+	t.Run("should return an id", func(t *testing.T) {
+		d := NewDirect(slog.Default(), nil)
+		assert.Equal(t, "url", d.Id())
+	})
+
+	t.Run("should return a name", func(t *testing.T) {
+		d := NewDirect(slog.Default(), nil)
+		assert.Equal(t, "直播链接", d.Name())
+	})
+
+	t.Run("should return a data icon", func(t *testing.T) {
+		d := NewDirect(slog.Default(), nil)
+		u := d.IconUrl()
+		assert.Equal(t, "data", u.Scheme)
+	})
+}
+
+func TestDirect_GetRoom(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Close()
+	host, _ := url.Parse(ts.URL)
+	tests := []struct {
+		name    string
+		roomId  string
+		want    platform.Room
+		wantErr string
+	}{
+		{
+			name:   "should return online room",
+			roomId: ts.URL + "/live/stream.flv",
+			want: platform.Room{
+				Id:       ts.URL + "/live/stream.flv",
+				Title:    "stream.flv",
+				IsOnline: true,
+				Owner:    platform.Owner{Id: host.Host, Name: "127.0.0.1"},
+			},
+		},
+		{
+			name:   "should return offline room since not found",
+			roomId: ts.URL + "/live/missing.m3u8",
+			want: platform.Room{
+				Id:       ts.URL + "/live/missing.m3u8",
+				Title:    "missing.m3u8",
+				IsOnline: false,
+				Owner:    platform.Owner{Id: host.Host, Name: "127.0.0.1"},
+			},
+		},
+		{
+			name:    "should return error since not a http url",
+			roomId:  "rtmp://example.com/live",
+			wantErr: "only absolute http(s) url is supported",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDirect()
+			got, err := d.GetRoom(context.TODO(), tt.roomId)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDirect_GetQualities(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Close()
+	tests := []struct {
+		name    string
+		roomId  string
+		want    []platform.Quality
+		wantErr string
+	}{
+		{
+			name:   "should return variants of master playlist",
+			roomId: ts.URL + "/live/master.m3u8",
+			want: []platform.Quality{
+				{Id: "720p", Name: "720p", Priority: 0},
+				{Id: "480p", Name: "480p", Priority: -1},
+			},
+		},
+		{
+			name:   "should return source quality for media playlist",
+			roomId: ts.URL + "/live/media.m3u8",
+			want:   []platform.Quality{{Id: sourceQualityId, Name: "原画", Priority: 0}},
+		},
+		{
+			name:   "should return source quality for flv",
+			roomId: ts.URL + "/live/stream.flv",
+			want:   []platform.Quality{{Id: sourceQualityId, Name: "原画", Priority: 0}},
+		},
+		{
+			name:    "should return error since not found",
+			roomId:  ts.URL + "/live/missing.m3u8",
+			wantErr: "status code: 404",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDirect()
+			got, err := d.GetQualities(context.TODO(), tt.roomId)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDirect_GetLiveUrls(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Close()
+	tests := []struct {
+		name      string
+		roomId    string
+		qualityId string
+		want      []string
+		wantErr   string
+	}{
+		{
+			name:      "should return the variant playlist",
+			roomId:    ts.URL + "/live/master.m3u8",
+			qualityId: "480p",
+			want:      []string{ts.URL + "/live/480.m3u8"},
+		},
+		{
+			name:      "should return the url itself",
+			roomId:    ts.URL + "/live/stream.flv",
+			qualityId: sourceQualityId,
+			want:      []string{ts.URL + "/live/stream.flv"},
+		},
+		{
+			name:      "should return error since unknown quality",
+			roomId:    ts.URL + "/live/master.m3u8",
+			qualityId: "1080p",
+			wantErr:   "quality not found: 1080p",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDirect()
+			got, err := d.GetLiveUrls(context.TODO(), tt.roomId, tt.qualityId)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			us := make([]string, len(got))
+			for i, u := range got {
+				us[i] = u.String()
+			}
+			assert.Equal(t, tt.want, us)
+		})
+	}
+}
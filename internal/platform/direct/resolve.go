@@ -0,0 +1,22 @@
+package direct
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"path"
+	"strings"
+)
+
+// ResolveRoomId accepts the links of `.m3u8` and `.flv` files, the room id is the link itself.
+func (d Direct) ResolveRoomId(_ context.Context, input string) (string, error) {
+	u, err := parseUrl(strings.TrimSpace(input))
+	if err != nil {
+		return "", ErrResolveRoomId(platform.ErrUnsupportedInput)
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".m3u8", ".flv":
+		return u.String(), nil
+	default:
+		return "", ErrResolveRoomId(platform.ErrUnsupportedInput)
+	}
+}
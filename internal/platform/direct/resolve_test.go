@@ -0,0 +1,47 @@
+package direct
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirect_ResolveRoomId(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "should resolve hls link",
+			input: "https://example.com/live/master.m3u8?token=1",
+			want:  "https://example.com/live/master.m3u8?token=1",
+		},
+		{
+			name:  "should resolve flv link",
+			input: "http://example.com/live/stream.FLV",
+			want:  "http://example.com/live/stream.FLV",
+		},
+		{
+			name:    "should return unsupported since not a stream link",
+			input:   "https://live.bilibili.com/6",
+			wantErr: platform.ErrUnsupportedInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDirect(slog.Default(), nil)
+			got, err := d.ResolveRoomId(context.TODO(), tt.input)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
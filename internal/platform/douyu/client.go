@@ -0,0 +1,74 @@
+package douyu
+
+import (
+	"asmblive/internal/platform"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+var commonHeaders = map[string]string{
+	"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/93.0.4577.82 Safari/537.36 OPR/79.0.4143.50",
+	"Referer":    "https://www.douyu.com/",
+}
+
+// streamHeaders returns the headers of the live stream requests.
+func streamHeaders() http.Header {
+	h := http.Header{}
+	for k, v := range commonHeaders {
+		h.Set(k, v)
+	}
+	return h
+}
+
+type douyuClient[T any] struct {
+	platform.Client
+	log *slog.Logger
+}
+
+// getRaw decodes the response body into T directly, it is used by the
+// endpoints which do not wrap their data into the common envelope.
+func (c douyuClient[T]) getRaw(req *http.Request) (*T, error) {
+	var d T
+	if err := c.decode(req, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (c douyuClient[T]) getJson(req *http.Request) (*T, error) {
+	var rb response
+	if err := c.decode(req, &rb); err != nil {
+		return nil, err
+	}
+	if rb.Error != 0 {
+		return nil, platform.ErrRequest(fmt.Errorf("unexpected response error: %d, message: %s", rb.Error, rb.Msg))
+	}
+	var d T
+	if err := json.Unmarshal(rb.Data, &d); err != nil {
+		c.log.Error("failed to decode response data", "error", err)
+		return nil, platform.ErrRequest(fmt.Errorf("failed to decode response data: %w", err))
+	}
+	return &d, nil
+}
+
+func (c douyuClient[T]) decode(req *http.Request, v any) error {
+	for k, v := range commonHeaders {
+		req.Header.Set(k, v)
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		return platform.ErrRequest(err)
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			c.log.Warn("failed to close response body", "error", err)
+		}
+	}()
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		c.log.Error("failed to decode response body", "error", err)
+		return platform.ErrRequest(fmt.Errorf("failed to decode response body: %w", err))
+	}
+	return nil
+}
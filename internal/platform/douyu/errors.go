@@ -0,0 +1,27 @@
+package douyu
+
+import "fmt"
+
+func ErrGetRoom(err error) error {
+	return fmt.Errorf("failed to get room: %w", err)
+}
+
+func ErrGetQualities(err error) error {
+	return fmt.Errorf("failed to get qualities: %w", err)
+}
+
+func ErrGetLiveUrls(err error) error {
+	return fmt.Errorf("failed to get live urls: %w", err)
+}
+
+func errGetEncryption(err error) error {
+	return fmt.Errorf("failed to get encryption: %w", err)
+}
+
+func errGetH5Play(err error) error {
+	return fmt.Errorf("failed to get h5 play info: %w", err)
+}
+
+func ErrResolveRoomId(err error) error {
+	return fmt.Errorf("failed to resolve room id: %w", err)
+}
@@ -0,0 +1,235 @@
+package douyu
+
+import (
+	"asmblive/internal/platform"
+	"asmblive/internal/server"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// did is the device id used for the play url signature, douyu accepts this fixed value for guests.
+const did = "10000000000000000000000000001501"
+
+type Douyu struct {
+	log *slog.Logger
+	srv server.Server
+	pc  platform.Client
+}
+
+// proxyHosts is the hosts of the icon, covers and avatars, which are fetched through the cors proxy.
+var proxyHosts = []string{"www.douyu.com", ".douyucdn.cn"}
+
+// streamHosts is the cdn hosts of the live streams and their segments, which are fetched through the stream
+// relay.
+var streamHosts = []string{".douyucdn.cn", ".douyucdn2.cn"}
+
+func NewDouyu(log *slog.Logger, pc platform.Client, srv server.Server) *Douyu {
+	log = log.With("module", "platform/douyu")
+	if srv != nil {
+		srv.AllowProxyHost("douyu", proxyHosts...)
+		srv.AllowProxyHost("douyu", streamHosts...)
+	}
+	return &Douyu{
+		log: log,
+		pc:  pc,
+		srv: srv,
+	}
+}
+
+func (d Douyu) Id() string {
+	return "douyu"
+}
+
+func (d Douyu) Name() string {
+	return "斗鱼直播"
+}
+
+func (d Douyu) IconUrl() url.URL {
+	u := url.URL{
+		Scheme: "https",
+		Host:   "www.douyu.com",
+		Path:   "/favicon.ico",
+	}
+	return d.srv.GetCorsProxyUrl(u)
+}
+
+func (d Douyu) GetRoom(ctx context.Context, roomId string) (platform.Room, error) {
+	dc := douyuClient[betard]{d.pc, d.log}
+	u := url.URL{
+		Scheme: "https",
+		Host:   "www.douyu.com",
+		Path:   "/betard/" + url.PathEscape(roomId),
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return platform.Room{}, ErrGetRoom(err)
+	}
+	bd, err := dc.getRaw(req)
+	if err != nil {
+		return platform.Room{}, ErrGetRoom(err)
+	}
+	if bd.Room.RoomId == 0 {
+		return platform.Room{}, ErrGetRoom(fmt.Errorf("room not found: %s", roomId))
+	}
+	cu, err := url.Parse(bd.Room.RoomPic)
+	if err != nil {
+		return platform.Room{}, ErrGetRoom(fmt.Errorf("failed to parse cover url: %w", err))
+	}
+	pCu := d.srv.GetCorsProxyUrl(*cu)
+	au, err := url.Parse(bd.Room.OwnerAvatar)
+	if err != nil {
+		return platform.Room{}, ErrGetRoom(fmt.Errorf("failed to parse avatar url: %w", err))
+	}
+	pAu := d.srv.GetCorsProxyUrl(*au)
+	return platform.Room{
+		Id:    strconv.FormatInt(bd.Room.RoomId, 10),
+		Title: bd.Room.RoomName,
+		// a looping replay is not a live stream
+		IsOnline: bd.Room.ShowStatus == 1 && bd.Room.VideoLoop == 0,
+		CoverUrl: pCu,
+		Owner: platform.Owner{
+			Id:        strconv.FormatInt(bd.Room.OwnerUid, 10),
+			Name:      bd.Room.OwnerName,
+			AvatarUrl: pAu,
+		},
+	}, nil
+}
+
+func (d Douyu) GetQualities(ctx context.Context, roomId string) ([]platform.Quality, error) {
+	hp, err := d.getH5Play(ctx, roomId, "")
+	if err != nil {
+		return []platform.Quality{}, ErrGetQualities(err)
+	}
+	qualities := make([]platform.Quality, 0, len(hp.MultiRates))
+	for idx, r := range hp.MultiRates {
+		qualities = append(qualities, platform.Quality{
+			Id:       strconv.Itoa(r.Rate),
+			Name:     r.Name,
+			Priority: int8(-idx),
+		})
+	}
+	return qualities, nil
+}
+
+func (d Douyu) GetLiveUrls(ctx context.Context, roomId string, qualityId string) ([]url.URL, error) {
+	lss, err := d.GetLiveStreams(ctx, roomId, qualityId)
+	if err != nil {
+		return []url.URL{}, err
+	}
+	urls := make([]url.URL, len(lss))
+	for i, ls := range lss {
+		urls[i] = ls.Url
+	}
+	return urls, nil
+}
+
+func (d Douyu) GetLiveStreams(ctx context.Context, roomId string, qualityId string) ([]platform.LiveStream, error) {
+	hp, err := d.getH5Play(ctx, roomId, qualityId)
+	if err != nil {
+		return []platform.LiveStream{}, ErrGetLiveUrls(err)
+	}
+	if hp.RtmpUrl == "" || hp.RtmpLive == "" {
+		return make([]platform.LiveStream, 0), nil
+	}
+	u, err := url.Parse(hp.RtmpUrl + "/" + hp.RtmpLive)
+	if err != nil {
+		return []platform.LiveStream{}, ErrGetLiveUrls(fmt.Errorf("failed to parse live url: %w", err))
+	}
+	// the metadata is taken from the upstream url, the relay url carries neither the extension nor the expiry
+	ls := platform.InferLiveStream(*u)
+	ls.Origin = *u
+	ls.Header = streamHeaders()
+	// the player cannot play flv, which is remuxed into hls
+	if ls.Format == platform.FormatFlv {
+		ls.Url = d.srv.GetRemuxUrl(*u, ls.Header)
+	} else {
+		ls.Url = d.srv.GetStreamUrl(*u, ls.Header)
+	}
+	return []platform.LiveStream{ls}, nil
+}
+
+func (d Douyu) getEncryption(ctx context.Context) (*encryption, error) {
+	dc := douyuClient[encryption]{d.pc, d.log}
+	u := url.URL{
+		Scheme: "https",
+		Host:   "www.douyu.com",
+		Path:   "/wgapi/livenc/liveweb/websec/getEncryption",
+	}
+	q := u.Query()
+	q.Set("did", did)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errGetEncryption(err)
+	}
+	enc, err := dc.getJson(req)
+	if err != nil {
+		return nil, errGetEncryption(err)
+	}
+	return enc, nil
+}
+
+// getH5Play requests the signed play info, the rate is the quality id, empty means the default one.
+func (d Douyu) getH5Play(ctx context.Context, roomId string, rate string) (*h5Play, error) {
+	enc, err := d.getEncryption(ctx)
+	if err != nil {
+		return nil, errGetH5Play(err)
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	if rate == "" {
+		rate = "-1"
+	}
+	f := url.Values{}
+	f.Set("enc_data", enc.EncData)
+	f.Set("tt", ts)
+	f.Set("did", did)
+	f.Set("auth", sign(*enc, roomId, ts))
+	f.Set("cdn", "")
+	f.Set("rate", rate)
+	f.Set("hevc", "0")
+	f.Set("fa", "0")
+	f.Set("ive", "0")
+	u := url.URL{
+		Scheme: "https",
+		Host:   "www.douyu.com",
+		Path:   "/lapi/live/getH5PlayV1/" + url.PathEscape(roomId),
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(f.Encode()))
+	if err != nil {
+		return nil, errGetH5Play(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	dc := douyuClient[h5Play]{d.pc, d.log}
+	hp, err := dc.getJson(req)
+	if err != nil {
+		return nil, errGetH5Play(err)
+	}
+	return hp, nil
+}
+
+// sign computes the auth parameter of the play info request:
+// the random string is hashed with the key `enc_time` times, and then hashed again with the room id and timestamp.
+func sign(enc encryption, roomId string, ts string) string {
+	s := enc.RandStr
+	for i := 0; i < enc.EncTime; i++ {
+		s = md5Hex(s + enc.Key)
+	}
+	salt := roomId + ts
+	if enc.IsSpecial == 1 {
+		salt = ""
+	}
+	return md5Hex(s + enc.Key + salt)
+}
+
+func md5Hex(s string) string {
+	h := md5.Sum([]byte(s))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,285 @@
+package douyu
+
+import (
+	"asmblive/internal/health"
+	"asmblive/internal/platform"
+	"asmblive/internal/proxy"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockServer struct{}
+
+func (m mockServer) Start() error {
+	panic("should not call")
+}
+
+func (m mockServer) Stop(_ context.Context) error {
+	panic("should not call")
+}
+
+func (m mockServer) AddHandler(string, http.HandlerFunc) {
+	panic("should not call")
+}
+
+func (m mockServer) BaseUrl() url.URL {
+	panic("should not call")
+}
+
+func (m mockServer) AllowProxyHost(string, ...string) {}
+
+func (m mockServer) GetCorsProxyUrl(origin url.URL) url.URL {
+	return origin
+}
+
+func (m mockServer) GetStreamUrl(origin url.URL, _ http.Header) url.URL {
+	return origin
+}
+
+func (m mockServer) GetRemuxUrl(origin url.URL, _ http.Header) url.URL {
+	return origin
+}
+
+func (m mockServer) CheckStreams(_ context.Context, _ string, urls []url.URL) []health.Result {
+	rs := make([]health.Result, len(urls))
+	for i, u := range urls {
+		rs[i] = health.Result{Url: u}
+	}
+	return rs
+}
+
+func (m mockServer) GetFailoverUrl(_ string, candidates []url.URL) url.URL {
+	return candidates[0]
+}
+
+// relayServer wraps the live urls like the real server, so that nothing is inferred from the wrapped urls.
+type relayServer struct {
+	mockServer
+}
+
+func (m relayServer) GetStreamUrl(origin url.URL, _ http.Header) url.URL {
+	return url.URL{Scheme: "http", Host: "127.0.0.1:8080", Path: "/stream", RawQuery: url.Values{"origin": {origin.String()}}.Encode()}
+}
+
+func (m relayServer) GetRemuxUrl(url.URL, http.Header) url.URL {
+	return url.URL{Scheme: "http", Host: "127.0.0.1:8080", Path: "/remux/1/index.m3u8"}
+}
+
+// tsClient redirects all requests to the test server.
+type tsClient struct {
+	platform.Client
+	ts *httptest.Server
+}
+
+func (c tsClient) Do(req *http.Request) (*http.Response, error) {
+	u, _ := url.Parse(c.ts.URL)
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	return c.Client.Do(req)
+}
+
+func newTestServer(t *testing.T, routes map[string]string) *httptest.Server {
+	mux := http.NewServeMux()
+	for p, f := range routes {
+		data, err := os.ReadFile(f)
+		assert.NoError(t, err)
+		mux.HandleFunc(p, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(data)
+		})
+	}
+	return httptest.NewServer(mux)
+}
+
+func newTestDouyu(ts *httptest.Server) Douyu {
+	return Douyu{
+		log: slog.Default(),
+		pc:  tsClient{Client: platform.NewClient(slog.Default(), platform.Headers{}, platform.ClientOptions{}), ts: ts},
+		srv: &mockServer{},
+	}
+}
+
+func TestNewDouyu(t *testing.T) {
+	t.Run("should return an id", func(t *testing.T) {
+		d := NewDouyu(slog.Default(), nil, nil)
+		assert.Equal(t, "douyu", d.Id())
+	})
+
+	t.Run("should return a name", func(t *testing.T) {
+		d := NewDouyu(slog.Default(), nil, nil)
+		assert.Equal(t, "斗鱼直播", d.Name())
+	})
+
+	t.Run("should return an icon", func(t *testing.T) {
+		d := NewDouyu(slog.Default(), nil, &mockServer{})
+		u := d.IconUrl()
+		assert.Equal(t, "https://www.douyu.com/favicon.ico", u.String())
+	})
+}
+
+func TestDouyu_GetRoom(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  map[string]string
+		roomId  string
+		want    platform.Room
+		wantErr string
+	}{
+		{
+			name:   "should return room",
+			routes: map[string]string{"/betard/288016": "testData/betard.json"},
+			roomId: "288016",
+			want: platform.Room{
+				Id:       "288016",
+				Title:    "一起来看看新版本",
+				IsOnline: true,
+				CoverUrl: url.URL{
+					Scheme: "https",
+					Host:   "rpic.douyucdn.cn",
+					Path:   "/asrpic/240724/288016_1412.png",
+				},
+				Owner: platform.Owner{
+					Id:   "7654321",
+					Name: "斗鱼主播",
+					AvatarUrl: url.URL{
+						Scheme: "https",
+						Host:   "apic.douyucdn.cn",
+						Path:   "/upload/avatar_v3/202301/abcdef_big.jpg",
+					},
+				},
+			},
+		},
+		{
+			name:    "should return error since room not found",
+			routes:  map[string]string{},
+			roomId:  "1",
+			wantErr: "status code: 404",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, tt.routes)
+			defer ts.Close()
+			d := newTestDouyu(ts)
+			got, err := d.GetRoom(context.TODO(), tt.roomId)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDouyu_GetQualities(t *testing.T) {
+	t.Run("should return qualities", func(t *testing.T) {
+		ts := newTestServer(t, map[string]string{
+			"/wgapi/livenc/liveweb/websec/getEncryption": "testData/getEncryption.json",
+			"/lapi/live/getH5PlayV1/288016":              "testData/getH5PlayV1.json",
+		})
+		defer ts.Close()
+		d := newTestDouyu(ts)
+		got, err := d.GetQualities(context.TODO(), "288016")
+		assert.NoError(t, err)
+		assert.Equal(t, []platform.Quality{
+			{Id: "0", Name: "原画", Priority: 0},
+			{Id: "4", Name: "蓝光4M", Priority: -1},
+			{Id: "3", Name: "超清", Priority: -2},
+			{Id: "2", Name: "高清", Priority: -3},
+		}, got)
+	})
+
+	t.Run("should return error since response error", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"error":-5,"msg":"房间未开播","data":""}`))
+		}))
+		defer ts.Close()
+		d := newTestDouyu(ts)
+		_, err := d.GetQualities(context.TODO(), "288016")
+		assert.ErrorContains(t, err, "unexpected response error: -5, message: 房间未开播")
+	})
+}
+
+// newPlayServer serves the signed play info of the room 288016.
+func newPlayServer(t *testing.T) *httptest.Server {
+	encData, _ := os.ReadFile("testData/getEncryption.json")
+	playData, _ := os.ReadFile("testData/getH5PlayV1.json")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wgapi/livenc/liveweb/websec/getEncryption", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, did, r.URL.Query().Get("did"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(encData)
+	})
+	mux.HandleFunc("/lapi/live/getH5PlayV1/288016", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, r.ParseForm())
+		enc := encryption{RandStr: "fc5e12b5a0ca33f6", EncTime: 2, Key: "a44b3f0e9b0b4f3c"}
+		assert.Equal(t, sign(enc, "288016", r.PostForm.Get("tt")), r.PostForm.Get("auth"))
+		assert.Equal(t, "eyJ0b2tlbiI6InRlc3QifQ==", r.PostForm.Get("enc_data"))
+		assert.Equal(t, "4", r.PostForm.Get("rate"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(playData)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestDouyu_GetLiveUrls(t *testing.T) {
+	t.Run("should return signed live urls", func(t *testing.T) {
+		ts := newPlayServer(t)
+		defer ts.Close()
+		d := newTestDouyu(ts)
+		got, err := d.GetLiveUrls(context.TODO(), "288016", "4")
+		assert.NoError(t, err)
+		assert.Len(t, got, 1)
+		assert.Equal(t, "hw-tct.douyucdn.cn", got[0].Host)
+		assert.Equal(t, "/live/288016rEDNYaiNc.flv", got[0].Path)
+		assert.Equal(t, "7a7c4f2e", got[0].Query().Get("wsAuth"))
+	})
+}
+
+func TestDouyu_GetLiveStreams(t *testing.T) {
+	t.Run("should infer the stream from the upstream url", func(t *testing.T) {
+		ts := newPlayServer(t)
+		defer ts.Close()
+		d := newTestDouyu(ts)
+		d.srv = relayServer{}
+		got, err := d.GetLiveStreams(context.TODO(), "288016", "4")
+		assert.NoError(t, err)
+		if !assert.Len(t, got, 1) {
+			return
+		}
+		assert.Equal(t, "/remux/1/index.m3u8", got[0].Url.Path)
+		assert.Equal(t, "/live/288016rEDNYaiNc.flv", got[0].Origin.Path)
+		assert.True(t, proxy.Bypassed(got[0].Origin.Host, streamHosts))
+		assert.Equal(t, streamHeaders(), got[0].Header)
+		assert.Equal(t, platform.ProtocolHttpStream, got[0].Protocol)
+		assert.Equal(t, platform.FormatFlv, got[0].Format)
+		assert.Equal(t, time.Unix(0x66a1c2f0, 0), got[0].ExpiresAt)
+	})
+}
+
+func Test_sign(t *testing.T) {
+	enc := encryption{
+		RandStr: "fc5e12b5a0ca33f6",
+		EncTime: 2,
+		Key:     "a44b3f0e9b0b4f3c",
+	}
+	t.Run("should sign with room id and timestamp", func(t *testing.T) {
+		assert.Equal(t, "afce4f5b6086b2a63fcf4f3886f19440", sign(enc, "288016", "1721800000"))
+	})
+
+	t.Run("should sign without salt for special encryption", func(t *testing.T) {
+		enc.IsSpecial = 1
+		assert.Equal(t, "5496cc121f5d3a4a1e32cc752001c7e6", sign(enc, "288016", "1721800000"))
+	})
+}
@@ -0,0 +1,29 @@
+package douyu
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ResolveRoomId accepts the links like `https://www.douyu.com/288016` and `https://www.douyu.com/topic/xxx?rid=288016`,
+// the vanity room id is normalised to the numeric one.
+func (d Douyu) ResolveRoomId(ctx context.Context, input string) (string, error) {
+	u, ok := platform.ParseInputUrl(input)
+	if !ok || !platform.MatchHost(u, "www.douyu.com", "douyu.com", "m.douyu.com") {
+		return "", ErrResolveRoomId(platform.ErrUnsupportedInput)
+	}
+	id := u.Query().Get("rid")
+	if id == "" {
+		id, _, _ = strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	}
+	if id == "" {
+		return "", ErrResolveRoomId(fmt.Errorf("no room id in link: %s", u.String()))
+	}
+	r, err := d.GetRoom(ctx, id)
+	if err != nil {
+		return "", ErrResolveRoomId(err)
+	}
+	return r.Id, nil
+}
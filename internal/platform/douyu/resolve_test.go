@@ -0,0 +1,48 @@
+package douyu
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDouyu_ResolveRoomId(t *testing.T) {
+	ts := newTestServer(t, map[string]string{"/betard/288016": "testData/betard.json"})
+	defer ts.Close()
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "should resolve room link",
+			input: "https://www.douyu.com/288016?dyshid=0",
+			want:  "288016",
+		},
+		{
+			name:  "should resolve topic link",
+			input: "https://www.douyu.com/topic/summer?rid=288016",
+			want:  "288016",
+		},
+		{
+			name:    "should return unsupported since other platform",
+			input:   "https://www.huya.com/660000",
+			wantErr: platform.ErrUnsupportedInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDouyu(ts)
+			got, err := d.ResolveRoomId(context.TODO(), tt.input)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package douyu
+
+import "encoding/json"
+
+// response is the common envelope, the data is kept raw since it is not an object when the error is not 0.
+type response struct {
+	Error int             `json:"error"`
+	Msg   string          `json:"msg"`
+	Data  json.RawMessage `json:"data"`
+}
+
+type betard struct {
+	Room struct {
+		RoomId      int64  `json:"room_id"`
+		RoomName    string `json:"room_name"`
+		OwnerName   string `json:"owner_name"`
+		OwnerAvatar string `json:"owner_avatar"`
+		OwnerUid    int64  `json:"owner_uid"`
+		ShowStatus  int8   `json:"show_status"`
+		VideoLoop   int8   `json:"videoLoop"`
+		RoomPic     string `json:"room_pic"`
+	} `json:"room"`
+}
+
+type encryption struct {
+	RandStr   string `json:"rand_str"`
+	EncTime   int    `json:"enc_time"`
+	Key       string `json:"key"`
+	IsSpecial int    `json:"is_special"`
+	EncData   string `json:"enc_data"`
+}
+
+type h5Play struct {
+	RoomId     int64  `json:"room_id"`
+	RtmpUrl    string `json:"rtmp_url"`
+	RtmpLive   string `json:"rtmp_live"`
+	Rate       int    `json:"rate"`
+	MultiRates []struct {
+		Name string `json:"name"`
+		Rate int    `json:"rate"`
+	} `json:"multirates"`
+}
@@ -1,6 +1,12 @@
 package platform
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedInput is returned by Resolver if the input does not belong to the platform.
+var ErrUnsupportedInput = errors.New("unsupported input")
 
 func ErrRequest(err error) error {
 	return fmt.Errorf("request failed: %w", err)
@@ -0,0 +1,7 @@
+package hls
+
+import "fmt"
+
+func ErrParse(err error) error {
+	return fmt.Errorf("failed to parse playlist: %w", err)
+}
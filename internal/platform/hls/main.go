@@ -0,0 +1,253 @@
+package hls
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tagHeader         = "#EXTM3U"
+	tagMedia          = "#EXT-X-MEDIA:"
+	tagStreamInf      = "#EXT-X-STREAM-INF:"
+	tagTargetDuration = "#EXT-X-TARGETDURATION:"
+	tagMediaSequence  = "#EXT-X-MEDIA-SEQUENCE:"
+	tagInf            = "#EXTINF:"
+	tagEndList        = "#EXT-X-ENDLIST"
+	tagMap            = "#EXT-X-MAP:"
+)
+
+// Variant is a stream declared by `#EXT-X-STREAM-INF` in a master playlist.
+type Variant struct {
+	// Id is the GROUP-ID of the video rendition, or derived from the resolution when there is no rendition.
+	Id string
+	// Name is the NAME of the video rendition, or the Id when there is no rendition.
+	Name       string
+	Bandwidth  int64
+	Resolution string
+	FrameRate  float64
+	Codecs     string
+	Url        url.URL
+}
+
+// IsAudioOnly reports whether the variant has no video.
+func (v Variant) IsAudioOnly() bool {
+	return v.Resolution == "" && !strings.Contains(v.Codecs, "avc") && !strings.Contains(v.Codecs, "hvc") &&
+		!strings.Contains(v.Codecs, "hev")
+}
+
+// ParseMaster parses the variants of a master playlist, relative URIs are resolved against base.
+// It returns a zero-length slice if the playlist is a media playlist.
+func ParseMaster(r io.Reader, base url.URL) ([]Variant, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	names := make(map[string]string)
+	variants := make([]Variant, 0)
+	ids := make(map[string]bool)
+	var header bool
+	var pending *Variant
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if !header {
+			if line != tagHeader {
+				return nil, ErrParse(errors.New("missing #EXTM3U header"))
+			}
+			header = true
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, tagMedia):
+			attrs := ParseAttributes(strings.TrimPrefix(line, tagMedia))
+			if attrs["TYPE"] == "VIDEO" && attrs["GROUP-ID"] != "" {
+				names[attrs["GROUP-ID"]] = attrs["NAME"]
+			}
+		case strings.HasPrefix(line, tagStreamInf):
+			attrs := ParseAttributes(strings.TrimPrefix(line, tagStreamInf))
+			v := Variant{
+				Id:         attrs["VIDEO"],
+				Resolution: attrs["RESOLUTION"],
+				Codecs:     attrs["CODECS"],
+			}
+			v.Bandwidth, _ = strconv.ParseInt(attrs["BANDWIDTH"], 10, 64)
+			v.FrameRate, _ = strconv.ParseFloat(attrs["FRAME-RATE"], 64)
+			pending = &v
+		case strings.HasPrefix(line, "#"):
+			// other tags are not interesting
+		default:
+			if pending == nil {
+				continue
+			}
+			u, err := base.Parse(line)
+			if err != nil {
+				return nil, ErrParse(fmt.Errorf("invalid variant uri %q: %w", line, err))
+			}
+			v := *pending
+			pending = nil
+			v.Url = *u
+			if v.Id == "" {
+				v.Id = defaultId(v)
+			}
+			if ids[v.Id] {
+				v.Id = v.Id + "_" + strconv.FormatInt(v.Bandwidth, 10)
+			}
+			ids[v.Id] = true
+			v.Name = names[v.Id]
+			if v.Name == "" {
+				v.Name = v.Id
+			}
+			variants = append(variants, v)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, ErrParse(err)
+	}
+	if !header {
+		return nil, ErrParse(errors.New("missing #EXTM3U header"))
+	}
+	return variants, nil
+}
+
+// Segment is a media segment declared by `#EXTINF` in a media playlist.
+type Segment struct {
+	// Seq is the media sequence number of the segment.
+	Seq      int64
+	Duration time.Duration
+	Url      url.URL
+}
+
+// MediaPlaylist is the parsed media playlist.
+type MediaPlaylist struct {
+	TargetDuration time.Duration
+	// InitUrl is the URI of `#EXT-X-MAP`, the init section of the fmp4 segments, it is nil for the ts segments.
+	InitUrl  *url.URL
+	Segments []Segment
+	// Ended reports whether the playlist has `#EXT-X-ENDLIST`, which means no more segments will be added.
+	Ended bool
+}
+
+// ParseMedia parses the segments of a media playlist, relative URIs are resolved against base.
+func ParseMedia(r io.Reader, base url.URL) (MediaPlaylist, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var (
+		mp      MediaPlaylist
+		header  bool
+		seq     int64
+		pending *Segment
+	)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if !header {
+			if line != tagHeader {
+				return mp, ErrParse(errors.New("missing #EXTM3U header"))
+			}
+			header = true
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, tagTargetDuration):
+			d, err := strconv.ParseFloat(strings.TrimPrefix(line, tagTargetDuration), 64)
+			if err != nil {
+				return mp, ErrParse(fmt.Errorf("invalid target duration %q: %w", line, err))
+			}
+			mp.TargetDuration = time.Duration(d * float64(time.Second))
+		case strings.HasPrefix(line, tagMediaSequence):
+			n, err := strconv.ParseInt(strings.TrimPrefix(line, tagMediaSequence), 10, 64)
+			if err != nil {
+				return mp, ErrParse(fmt.Errorf("invalid media sequence %q: %w", line, err))
+			}
+			seq = n
+		case strings.HasPrefix(line, tagInf):
+			d, _, _ := strings.Cut(strings.TrimPrefix(line, tagInf), ",")
+			f, err := strconv.ParseFloat(d, 64)
+			if err != nil {
+				return mp, ErrParse(fmt.Errorf("invalid segment duration %q: %w", line, err))
+			}
+			pending = &Segment{Duration: time.Duration(f * float64(time.Second))}
+		case strings.HasPrefix(line, tagMap):
+			attrs := ParseAttributes(strings.TrimPrefix(line, tagMap))
+			u, err := base.Parse(attrs["URI"])
+			if err != nil {
+				return mp, ErrParse(fmt.Errorf("invalid map uri %q: %w", line, err))
+			}
+			mp.InitUrl = u
+		case line == tagEndList:
+			mp.Ended = true
+		case strings.HasPrefix(line, "#"):
+			// other tags are not interesting
+		default:
+			if pending == nil {
+				continue
+			}
+			u, err := base.Parse(line)
+			if err != nil {
+				return mp, ErrParse(fmt.Errorf("invalid segment uri %q: %w", line, err))
+			}
+			sg := *pending
+			pending = nil
+			sg.Seq = seq
+			sg.Url = *u
+			seq++
+			mp.Segments = append(mp.Segments, sg)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return mp, ErrParse(err)
+	}
+	if !header {
+		return mp, ErrParse(errors.New("missing #EXTM3U header"))
+	}
+	return mp, nil
+}
+
+func defaultId(v Variant) string {
+	if _, h, ok := strings.Cut(v.Resolution, "x"); ok {
+		id := h + "p"
+		if v.FrameRate > 30 {
+			id += strconv.Itoa(int(v.FrameRate + 0.5))
+		}
+		return id
+	}
+	if v.IsAudioOnly() {
+		return "audio_only"
+	}
+	return strconv.FormatInt(v.Bandwidth, 10)
+}
+
+// ParseAttributes parses an attribute list like `BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2"`,
+// the quotes of quoted values are removed.
+func ParseAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for len(s) > 0 {
+		k, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		var v string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				v, rest = rest[1:], ""
+			} else {
+				v, rest = rest[1:end+1], rest[end+2:]
+			}
+			rest = strings.TrimPrefix(rest, ",")
+		} else {
+			v, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.TrimSpace(k)] = v
+		s = rest
+	}
+	return attrs
+}
@@ -0,0 +1,181 @@
+package hls
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMaster(t *testing.T) {
+	base := url.URL{Scheme: "https", Host: "example.com", Path: "/live/master.m3u8"}
+	tests := []struct {
+		name    string
+		content string
+		want    []Variant
+		wantErr string
+	}{
+		{
+			name: "should return variants with rendition names",
+			content: `#EXTM3U
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="chunked",NAME="1080p60 (source)",AUTOSELECT=YES,DEFAULT=YES
+#EXT-X-STREAM-INF:BANDWIDTH=8534030,RESOLUTION=1920x1080,CODECS="avc1.64002A,mp4a.40.2",VIDEO="chunked",FRAME-RATE=60.000
+https://cdn.example.com/chunked.m3u8
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="audio_only",NAME="audio_only",AUTOSELECT=NO,DEFAULT=NO
+#EXT-X-STREAM-INF:BANDWIDTH=160000,CODECS="mp4a.40.2",VIDEO="audio_only"
+audio_only.m3u8
+`,
+			want: []Variant{
+				{
+					Id:         "chunked",
+					Name:       "1080p60 (source)",
+					Bandwidth:  8534030,
+					Resolution: "1920x1080",
+					FrameRate:  60,
+					Codecs:     "avc1.64002A,mp4a.40.2",
+					Url:        url.URL{Scheme: "https", Host: "cdn.example.com", Path: "/chunked.m3u8"},
+				},
+				{
+					Id:        "audio_only",
+					Name:      "audio_only",
+					Bandwidth: 160000,
+					Codecs:    "mp4a.40.2",
+					Url:       url.URL{Scheme: "https", Host: "example.com", Path: "/live/audio_only.m3u8"},
+				},
+			},
+		},
+		{
+			name: "should derive ids from resolution",
+			content: `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=2000000,RESOLUTION=1280x720,FRAME-RATE=60
+720.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,RESOLUTION=1280x720
+720_low.m3u8
+`,
+			want: []Variant{
+				{
+					Id:         "720p60",
+					Name:       "720p60",
+					Bandwidth:  2000000,
+					Resolution: "1280x720",
+					FrameRate:  60,
+					Url:        url.URL{Scheme: "https", Host: "example.com", Path: "/live/720.m3u8"},
+				},
+				{
+					Id:         "720p",
+					Name:       "720p",
+					Bandwidth:  1000000,
+					Resolution: "1280x720",
+					Url:        url.URL{Scheme: "https", Host: "example.com", Path: "/live/720_low.m3u8"},
+				},
+			},
+		},
+		{
+			name: "should return no variant for media playlist",
+			content: `#EXTM3U
+#EXT-X-TARGETDURATION:2
+#EXTINF:2.000,
+seg1.ts
+`,
+			want: []Variant{},
+		},
+		{
+			name:    "should return error since not a playlist",
+			content: "<html></html>",
+			wantErr: "missing #EXTM3U header",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMaster(strings.NewReader(tt.content), base)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseMedia(t *testing.T) {
+	base := url.URL{Scheme: "https", Host: "example.com", Path: "/live/index.m3u8"}
+	tests := []struct {
+		name    string
+		content string
+		want    MediaPlaylist
+		wantErr string
+	}{
+		{
+			name: "should return segments with sequence numbers",
+			content: `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:41
+#EXTINF:2.000,
+41.ts
+#EXT-X-PROGRAM-DATE-TIME:2024-07-24T12:00:00Z
+#EXTINF:1.5,live
+https://cdn.example.com/42.ts?t=1
+`,
+			want: MediaPlaylist{
+				TargetDuration: 2 * time.Second,
+				Segments: []Segment{
+					{Seq: 41, Duration: 2 * time.Second, Url: url.URL{Scheme: "https", Host: "example.com", Path: "/live/41.ts"}},
+					{Seq: 42, Duration: 1500 * time.Millisecond, Url: url.URL{Scheme: "https", Host: "cdn.example.com", Path: "/42.ts", RawQuery: "t=1"}},
+				},
+			},
+		},
+		{
+			name:    "should return ended playlist",
+			content: "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\n0.ts\n#EXT-X-ENDLIST\n",
+			want: MediaPlaylist{
+				TargetDuration: 6 * time.Second,
+				Segments:       []Segment{{Seq: 0, Duration: 6 * time.Second, Url: url.URL{Scheme: "https", Host: "example.com", Path: "/live/0.ts"}}},
+				Ended:          true,
+			},
+		},
+		{
+			name:    "should return init url of fmp4 segments",
+			content: "#EXTM3U\n#EXT-X-MAP:URI=\"h1.m4s\"\n#EXTINF:1,\n2.m4s\n",
+			want: MediaPlaylist{
+				InitUrl:  &url.URL{Scheme: "https", Host: "example.com", Path: "/live/h1.m4s"},
+				Segments: []Segment{{Seq: 0, Duration: time.Second, Url: url.URL{Scheme: "https", Host: "example.com", Path: "/live/2.m4s"}}},
+			},
+		},
+		{
+			name:    "should return error if header is missing",
+			content: "#EXTINF:6,\n0.ts\n",
+			wantErr: "failed to parse playlist: missing #EXTM3U header",
+		},
+		{
+			name:    "should return error if duration is invalid",
+			content: "#EXTM3U\n#EXTINF:abc,\n0.ts\n",
+			wantErr: `failed to parse playlist: invalid segment duration "#EXTINF:abc,": strconv.ParseFloat: parsing "abc": invalid syntax`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMedia(strings.NewReader(tt.content), base)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	t.Run("should parse quoted and plain values", func(t *testing.T) {
+		got := ParseAttributes(`BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2",VIDEO="720p"`)
+		assert.Equal(t, map[string]string{
+			"BANDWIDTH": "1280000",
+			"CODECS":    "avc1.4d401f,mp4a.40.2",
+			"VIDEO":     "720p",
+		}, got)
+	})
+}
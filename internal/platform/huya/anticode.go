@@ -0,0 +1,74 @@
+package huya
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sdkVersion is the web player version reported to the cdn.
+const sdkVersion = "2401310321"
+
+// antiCode builds the query string of a stream url from the anti code returned by huya.
+//
+// The anti code carries a `fm` template which is base64 encoded, e.g. `DWq8BcJ3h6DJt6TY_$0_$1_$2_$3`,
+// the placeholders are the uid, stream name, a hash of the sequence id and the `wsTime`,
+// and `wsSecret` is the md5 of the filled template.
+func antiCode(ac string, streamName string, uid int64, now time.Time) (string, error) {
+	q, err := url.ParseQuery(strings.ReplaceAll(ac, "&amp;", "&"))
+	if err != nil {
+		return "", errAntiCode(err)
+	}
+	fm, err := base64.StdEncoding.DecodeString(q.Get("fm"))
+	if err != nil {
+		return "", errAntiCode(err)
+	}
+	wsTime := q.Get("wsTime")
+	if wsTime == "" {
+		return "", errAntiCode(errors.New("wsTime is required"))
+	}
+	ctype := q.Get("ctype")
+	t := q.Get("t")
+	if t == "" {
+		t = "100"
+	}
+	u := strconv.FormatInt(uid, 10)
+	seqId := strconv.FormatInt(uid+now.UnixMilli(), 10)
+	ss := md5Hex(seqId + "|" + ctype + "|" + t)
+	r := strings.NewReplacer("$0", u, "$1", streamName, "$2", ss, "$3", wsTime)
+	wsSecret := md5Hex(r.Replace(string(fm)))
+
+	// keep the order stable since some cdn nodes are picky about it
+	ps := [][2]string{
+		{"wsSecret", wsSecret},
+		{"wsTime", wsTime},
+		{"seqid", seqId},
+		{"ctype", ctype},
+		{"ver", "1"},
+		{"fs", q.Get("fs")},
+		{"u", u},
+		{"t", t},
+		{"sv", sdkVersion},
+		{"uuid", strconv.FormatInt(now.UnixMilli()%1e10*1e3%0xffffffff, 10)},
+	}
+	var sb strings.Builder
+	for i, p := range ps {
+		if i > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(p[0])
+		sb.WriteByte('=')
+		sb.WriteString(url.QueryEscape(p[1]))
+	}
+	return sb.String(), nil
+}
+
+func md5Hex(s string) string {
+	h := md5.Sum([]byte(s))
+	return hex.EncodeToString(h[:])
+}
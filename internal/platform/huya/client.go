@@ -0,0 +1,57 @@
+package huya
+
+import (
+	"asmblive/internal/platform"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+var commonHeaders = map[string]string{
+	"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/93.0.4577.82 Safari/537.36 OPR/79.0.4143.50",
+	"Referer":    "https://www.huya.com/",
+}
+
+// streamHeaders returns the headers of the live stream requests.
+func streamHeaders() http.Header {
+	h := http.Header{}
+	for k, v := range commonHeaders {
+		h.Set(k, v)
+	}
+	return h
+}
+
+type huyaClient[T any] struct {
+	platform.Client
+	log *slog.Logger
+}
+
+func (c huyaClient[T]) getJson(req *http.Request) (*T, error) {
+	for k, v := range commonHeaders {
+		req.Header.Set(k, v)
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, platform.ErrRequest(err)
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			c.log.Warn("failed to close response body", "error", err)
+		}
+	}()
+	var rb response
+	if err := json.NewDecoder(res.Body).Decode(&rb); err != nil {
+		c.log.Error("failed to decode response body", "error", err)
+		return nil, platform.ErrRequest(fmt.Errorf("failed to decode response body: %w", err))
+	}
+	if rb.Status != http.StatusOK {
+		return nil, platform.ErrRequest(fmt.Errorf("unexpected response status: %d, message: %s", rb.Status, rb.Message))
+	}
+	var d T
+	if err := json.Unmarshal(rb.Data, &d); err != nil {
+		c.log.Error("failed to decode response data", "error", err)
+		return nil, platform.ErrRequest(fmt.Errorf("failed to decode response data: %w", err))
+	}
+	return &d, nil
+}
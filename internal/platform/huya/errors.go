@@ -0,0 +1,27 @@
+package huya
+
+import "fmt"
+
+func ErrGetRoom(err error) error {
+	return fmt.Errorf("failed to get room: %w", err)
+}
+
+func ErrGetQualities(err error) error {
+	return fmt.Errorf("failed to get qualities: %w", err)
+}
+
+func ErrGetLiveUrls(err error) error {
+	return fmt.Errorf("failed to get live urls: %w", err)
+}
+
+func errGetProfileRoom(err error) error {
+	return fmt.Errorf("failed to get profile room: %w", err)
+}
+
+func errAntiCode(err error) error {
+	return fmt.Errorf("failed to build anti code: %w", err)
+}
+
+func ErrResolveRoomId(err error) error {
+	return fmt.Errorf("failed to resolve room id: %w", err)
+}
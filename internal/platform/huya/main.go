@@ -0,0 +1,222 @@
+package huya
+
+import (
+	"asmblive/internal/platform"
+	"asmblive/internal/server"
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type Huya struct {
+	log *slog.Logger
+	srv server.Server
+	pc  platform.Client
+
+	// uid is the anonymous viewer id used to sign stream urls.
+	uid int64
+	now func() time.Time
+}
+
+// proxyHosts is the hosts of the icon, covers and avatars, which are fetched through the cors proxy.
+var proxyHosts = []string{"www.huya.com", ".msstatic.com"}
+
+// streamHosts is the cdn hosts of the live streams and their segments, which are fetched through the stream
+// relay.
+var streamHosts = []string{".huya.com"}
+
+func NewHuya(log *slog.Logger, pc platform.Client, srv server.Server) *Huya {
+	log = log.With("module", "platform/huya")
+	if srv != nil {
+		srv.AllowProxyHost("huya", proxyHosts...)
+		srv.AllowProxyHost("huya", streamHosts...)
+	}
+	return &Huya{
+		log: log,
+		pc:  pc,
+		srv: srv,
+		uid: 1_400_000_000_000 + rand.Int63n(100_000_000_000),
+		now: time.Now,
+	}
+}
+
+func (h Huya) Id() string {
+	return "huya"
+}
+
+func (h Huya) Name() string {
+	return "虎牙直播"
+}
+
+func (h Huya) IconUrl() url.URL {
+	u := url.URL{
+		Scheme: "https",
+		Host:   "www.huya.com",
+		Path:   "/favicon.ico",
+	}
+	return h.srv.GetCorsProxyUrl(u)
+}
+
+func (h Huya) GetRoom(ctx context.Context, roomId string) (platform.Room, error) {
+	pr, err := h.getProfileRoom(ctx, roomId)
+	if err != nil {
+		return platform.Room{}, ErrGetRoom(err)
+	}
+	cu, err := url.Parse(pr.LiveData.Screenshot)
+	if err != nil {
+		return platform.Room{}, ErrGetRoom(fmt.Errorf("failed to parse cover url: %w", err))
+	}
+	pCu := h.srv.GetCorsProxyUrl(*cu)
+	au, err := url.Parse(pr.ProfileInfo.Avatar180)
+	if err != nil {
+		return platform.Room{}, ErrGetRoom(fmt.Errorf("failed to parse avatar url: %w", err))
+	}
+	pAu := h.srv.GetCorsProxyUrl(*au)
+	id := roomId
+	if pr.ProfileInfo.ProfileRoom != 0 {
+		id = strconv.FormatInt(pr.ProfileInfo.ProfileRoom, 10)
+	}
+	return platform.Room{
+		Id:       id,
+		Title:    pr.LiveData.Introduction,
+		IsOnline: pr.RealLiveStatus == "ON",
+		CoverUrl: pCu,
+		Owner: platform.Owner{
+			Id:        strconv.FormatInt(pr.ProfileInfo.Uid, 10),
+			Name:      pr.ProfileInfo.Nick,
+			AvatarUrl: pAu,
+		},
+	}, nil
+}
+
+func (h Huya) GetQualities(ctx context.Context, roomId string) ([]platform.Quality, error) {
+	pr, err := h.getProfileRoom(ctx, roomId)
+	if err != nil {
+		return []platform.Quality{}, ErrGetQualities(err)
+	}
+	rs := pr.Stream.Flv.RateArray
+	if len(rs) == 0 {
+		return []platform.Quality{{Id: "0", Name: "原画", Priority: 0}}, nil
+	}
+	// bit rate 0 means the original stream, it is always the best one
+	sort.SliceStable(rs, func(i, j int) bool {
+		if rs[i].IBitRate == 0 || rs[j].IBitRate == 0 {
+			return rs[i].IBitRate == 0 && rs[j].IBitRate != 0
+		}
+		return rs[i].IBitRate > rs[j].IBitRate
+	})
+	qualities := make([]platform.Quality, 0, len(rs))
+	for idx, r := range rs {
+		qualities = append(qualities, platform.Quality{
+			Id:       strconv.Itoa(r.IBitRate),
+			Name:     r.SDisplayName,
+			Priority: int8(-idx),
+		})
+	}
+	return qualities, nil
+}
+
+// GetLiveUrls returns the hls urls before the flv ones, the master cdn goes first in each group.
+func (h Huya) GetLiveUrls(ctx context.Context, roomId string, qualityId string) ([]url.URL, error) {
+	lss, err := h.GetLiveStreams(ctx, roomId, qualityId)
+	if err != nil {
+		return []url.URL{}, err
+	}
+	urls := make([]url.URL, len(lss))
+	for i, ls := range lss {
+		urls[i] = ls.Url
+	}
+	return urls, nil
+}
+
+func (h Huya) GetLiveStreams(ctx context.Context, roomId string, qualityId string) ([]platform.LiveStream, error) {
+	pr, err := h.getProfileRoom(ctx, roomId)
+	if err != nil {
+		return []platform.LiveStream{}, ErrGetLiveUrls(err)
+	}
+	sis := pr.Stream.BaseSteamInfoList
+	sort.SliceStable(sis, func(i, j int) bool {
+		return sis[i].IIsMaster > sis[j].IIsMaster
+	})
+	now := h.now()
+	hls := make([]platform.LiveStream, 0, len(sis))
+	flv := make([]platform.LiveStream, 0, len(sis))
+	for _, si := range sis {
+		if si.SHlsUrl != "" && si.SHlsAntiCode != "" {
+			if u, err := h.buildUrl(si.SHlsUrl, si.SStreamName, si.SHlsUrlSuffix, si.SHlsAntiCode, qualityId, now); err != nil {
+				h.log.Warn("failed to build hls url", "cdn", si.SCdnType, "err", err)
+			} else {
+				ls := platform.InferLiveStream(u)
+				ls.Format = platform.FormatTs
+				hls = append(hls, ls)
+			}
+		}
+		if si.SFlvUrl != "" && si.SFlvAntiCode != "" {
+			if u, err := h.buildUrl(si.SFlvUrl, si.SStreamName, si.SFlvUrlSuffix, si.SFlvAntiCode, qualityId, now); err != nil {
+				h.log.Warn("failed to build flv url", "cdn", si.SCdnType, "err", err)
+			} else {
+				flv = append(flv, platform.InferLiveStream(u))
+			}
+		}
+	}
+	lss := append(hls, flv...)
+	// the metadata is taken from the upstream urls, the relay urls carry neither the extension nor the expiry
+	sh := streamHeaders()
+	for i, ls := range lss {
+		lss[i].Origin = ls.Url
+		lss[i].Header = sh
+		// the player cannot play flv, which is remuxed into hls
+		if ls.Format == platform.FormatFlv {
+			lss[i].Url = h.srv.GetRemuxUrl(ls.Url, sh)
+		} else {
+			lss[i].Url = h.srv.GetStreamUrl(ls.Url, sh)
+		}
+	}
+	return lss, nil
+}
+
+func (h Huya) buildUrl(base, streamName, suffix, ac, qualityId string, now time.Time) (url.URL, error) {
+	u, err := url.Parse(base + "/" + streamName + "." + suffix)
+	if err != nil {
+		return url.URL{}, err
+	}
+	q, err := antiCode(ac, streamName, h.uid, now)
+	if err != nil {
+		return url.URL{}, err
+	}
+	if qualityId != "" && qualityId != "0" {
+		q += "&ratio=" + url.QueryEscape(qualityId)
+	}
+	u.Scheme = "https"
+	u.RawQuery = q
+	return *u, nil
+}
+
+func (h Huya) getProfileRoom(ctx context.Context, roomId string) (*profileRoom, error) {
+	hc := huyaClient[profileRoom]{h.pc, h.log}
+	u := url.URL{
+		Scheme: "https",
+		Host:   "mp.huya.com",
+		Path:   "/cache.php",
+	}
+	q := u.Query()
+	q.Set("m", "Live")
+	q.Set("do", "profileRoom")
+	q.Set("roomid", roomId)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errGetProfileRoom(err)
+	}
+	pr, err := hc.getJson(req)
+	if err != nil {
+		return nil, errGetProfileRoom(err)
+	}
+	return pr, nil
+}
@@ -0,0 +1,316 @@
+package huya
+
+import (
+	"asmblive/internal/health"
+	"asmblive/internal/platform"
+	"asmblive/internal/proxy"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockServer struct{}
+
+func (m mockServer) Start() error {
+	panic("should not call")
+}
+
+func (m mockServer) Stop(_ context.Context) error {
+	panic("should not call")
+}
+
+func (m mockServer) AddHandler(string, http.HandlerFunc) {
+	panic("should not call")
+}
+
+func (m mockServer) BaseUrl() url.URL {
+	panic("should not call")
+}
+
+func (m mockServer) AllowProxyHost(string, ...string) {}
+
+func (m mockServer) GetCorsProxyUrl(origin url.URL) url.URL {
+	return origin
+}
+
+func (m mockServer) GetStreamUrl(origin url.URL, _ http.Header) url.URL {
+	return origin
+}
+
+func (m mockServer) GetRemuxUrl(origin url.URL, _ http.Header) url.URL {
+	return origin
+}
+
+func (m mockServer) CheckStreams(_ context.Context, _ string, urls []url.URL) []health.Result {
+	rs := make([]health.Result, len(urls))
+	for i, u := range urls {
+		rs[i] = health.Result{Url: u}
+	}
+	return rs
+}
+
+func (m mockServer) GetFailoverUrl(_ string, candidates []url.URL) url.URL {
+	return candidates[0]
+}
+
+// relayServer wraps the live urls like the real server, so that nothing is inferred from the wrapped urls.
+type relayServer struct {
+	mockServer
+}
+
+func (m relayServer) GetStreamUrl(origin url.URL, _ http.Header) url.URL {
+	return url.URL{Scheme: "http", Host: "127.0.0.1:8080", Path: "/stream", RawQuery: url.Values{"origin": {origin.String()}}.Encode()}
+}
+
+func (m relayServer) GetRemuxUrl(url.URL, http.Header) url.URL {
+	return url.URL{Scheme: "http", Host: "127.0.0.1:8080", Path: "/remux/1/index.m3u8"}
+}
+
+// tsClient redirects all requests to the test server.
+type tsClient struct {
+	platform.Client
+	ts *httptest.Server
+}
+
+func (c tsClient) Do(req *http.Request) (*http.Response, error) {
+	u, _ := url.Parse(c.ts.URL)
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	return c.Client.Do(req)
+}
+
+func newTestHuya(t *testing.T, body []byte) (Huya, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/cache.php", r.URL.Path)
+		assert.Equal(t, "profileRoom", r.URL.Query().Get("do"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	h := Huya{
+		log: slog.Default(),
+		pc:  tsClient{Client: platform.NewClient(slog.Default(), platform.Headers{}, platform.ClientOptions{}), ts: ts},
+		srv: &mockServer{},
+		uid: 1450000000000,
+		now: func() time.Time { return time.UnixMilli(1721800000000) },
+	}
+	return h, ts.Close
+}
+
+func TestNewHuya(t *testing.T) {
+	t.Run("should return an id", func(t *testing.T) {
+		h := NewHuya(slog.Default(), nil, nil)
+		assert.Equal(t, "huya", h.Id())
+	})
+
+	t.Run("should return a name", func(t *testing.T) {
+		h := NewHuya(slog.Default(), nil, nil)
+		assert.Equal(t, "虎牙直播", h.Name())
+	})
+
+	t.Run("should return an icon", func(t *testing.T) {
+		h := NewHuya(slog.Default(), nil, &mockServer{})
+		u := h.IconUrl()
+		assert.Equal(t, "https://www.huya.com/favicon.ico", u.String())
+	})
+}
+
+func TestHuya_GetRoom(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		body    []byte
+		want    platform.Room
+		wantErr string
+	}{
+		{
+			name: "should return room",
+			file: "testData/profileRoom.json",
+			want: platform.Room{
+				Id:       "660000",
+				Title:    "晚上好，开黑",
+				IsOnline: true,
+				CoverUrl: url.URL{
+					Scheme: "https",
+					Host:   "live-cover.msstatic.com",
+					Path:   "/huyalive/1199512345/cover.jpg",
+				},
+				Owner: platform.Owner{
+					Id:   "1199512345",
+					Name: "虎牙主播",
+					AvatarUrl: url.URL{
+						Scheme: "https",
+						Host:   "huyaimg.msstatic.com",
+						Path:   "/avatar/1026/0b/abcdef_180_135.jpg",
+					},
+				},
+			},
+		},
+		{
+			name:    "should return status error since invalid room id",
+			body:    []byte(`{"status":422,"message":"该主播不存在！","data":""}`),
+			wantErr: "unexpected response status: 422, message: 该主播不存在！",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.body
+			if tt.file != "" {
+				body, _ = os.ReadFile(tt.file)
+			}
+			h, done := newTestHuya(t, body)
+			defer done()
+			got, err := h.GetRoom(context.TODO(), "660000")
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHuya_GetQualities(t *testing.T) {
+	t.Run("should return qualities with the original one first", func(t *testing.T) {
+		body, _ := os.ReadFile("testData/profileRoom.json")
+		h, done := newTestHuya(t, body)
+		defer done()
+		got, err := h.GetQualities(context.TODO(), "660000")
+		assert.NoError(t, err)
+		assert.Equal(t, []platform.Quality{
+			{Id: "0", Name: "原画", Priority: 0},
+			{Id: "4000", Name: "蓝光4M", Priority: -1},
+			{Id: "2000", Name: "高清", Priority: -2},
+		}, got)
+	})
+}
+
+func TestHuya_GetLiveUrls(t *testing.T) {
+	t.Run("should return signed live urls", func(t *testing.T) {
+		body, _ := os.ReadFile("testData/profileRoom.json")
+		h, done := newTestHuya(t, body)
+		defer done()
+		got, err := h.GetLiveUrls(context.TODO(), "660000", "4000")
+		assert.NoError(t, err)
+		assert.Len(t, got, 4)
+		hosts := make([]string, len(got))
+		for i, u := range got {
+			hosts[i] = u.Host
+			assert.Equal(t, "https", u.Scheme)
+			q := u.Query()
+			assert.Len(t, q.Get("wsSecret"), 32)
+			assert.Equal(t, "66a1c2f0", q.Get("wsTime"))
+			assert.Equal(t, "3171800000000", q.Get("seqid"))
+			assert.Equal(t, "1450000000000", q.Get("u"))
+			assert.Equal(t, "4000", q.Get("ratio"))
+		}
+		assert.Equal(t, []string{"al.hls.huya.com", "tx.hls.huya.com", "al.flv.huya.com", "tx.flv.huya.com"}, hosts)
+		assert.Equal(t, "/src/1199512345-1199512345-5152010587-2399024690-10057-A-0-1.m3u8", got[0].Path)
+	})
+}
+
+func TestHuya_GetLiveStreams(t *testing.T) {
+	t.Run("should infer the streams from the upstream urls", func(t *testing.T) {
+		body, _ := os.ReadFile("testData/profileRoom.json")
+		h, done := newTestHuya(t, body)
+		defer done()
+		h.srv = relayServer{}
+		got, err := h.GetLiveStreams(context.TODO(), "660000", "4000")
+		assert.NoError(t, err)
+		if !assert.Len(t, got, 4) {
+			return
+		}
+		formats := make([]string, len(got))
+		for i, ls := range got {
+			formats[i] = ls.Format
+			assert.Equal(t, "127.0.0.1:8080", ls.Url.Host)
+			assert.True(t, proxy.Bypassed(ls.Origin.Host, streamHosts), ls.Origin.Host)
+			assert.Equal(t, streamHeaders(), ls.Header)
+			assert.Equal(t, time.Unix(0x66a1c2f0, 0), ls.ExpiresAt)
+		}
+		assert.Equal(t, []string{platform.FormatTs, platform.FormatTs, platform.FormatFlv, platform.FormatFlv}, formats)
+		assert.Equal(t, platform.ProtocolHls, got[0].Protocol)
+		assert.Equal(t, platform.ProtocolHttpStream, got[2].Protocol)
+	})
+
+	t.Run("should not cache the urls since the signature is expired", func(t *testing.T) {
+		body, _ := os.ReadFile("testData/profileRoom.json")
+		h, done := newTestHuya(t, body)
+		defer done()
+		h.srv = relayServer{}
+		c := platform.Cached(h, platform.CacheOptions{})
+		_, err := c.GetLiveUrls(context.TODO(), "660000", "4000")
+		assert.NoError(t, err)
+		_, err = c.GetLiveUrls(context.TODO(), "660000", "4000")
+		assert.NoError(t, err)
+		// the wsTime of the test data is in the past
+		assert.Equal(t, platform.CacheCounter{Misses: 2}, c.Stats().LiveUrls)
+	})
+}
+
+func Test_antiCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		ac      string
+		want    url.Values
+		wantErr string
+	}{
+		{
+			name: "should compute ws secret",
+			ac:   "wsSecret=0d3d4cd1c4f2d3f9&wsTime=66a1c2f0&fm=RFdxOEJjSjNoNkRKdDZUWV8kMF8kMV8kMl8kMw%3D%3D&ctype=huya_live&fs=bgct&t=100",
+			want: url.Values{
+				"wsSecret": {"f37b023355a0835606704a2c06e22160"},
+				"wsTime":   {"66a1c2f0"},
+				"seqid":    {"3171800000000"},
+				"ctype":    {"huya_live"},
+				"ver":      {"1"},
+				"fs":       {"bgct"},
+				"u":        {"1450000000000"},
+				"t":        {"100"},
+				"sv":       {sdkVersion},
+				"uuid":     {"408703395"},
+			},
+		},
+		{
+			name: "should accept html escaped anti code",
+			ac:   "wsSecret=0d3d4cd1c4f2d3f9&amp;wsTime=66a1c2f0&amp;fm=RFdxOEJjSjNoNkRKdDZUWV8kMF8kMV8kMl8kMw%3D%3D&amp;ctype=huya_live&amp;fs=bgct",
+			want: url.Values{
+				"wsSecret": {"f37b023355a0835606704a2c06e22160"},
+				"wsTime":   {"66a1c2f0"},
+				"seqid":    {"3171800000000"},
+				"ctype":    {"huya_live"},
+				"ver":      {"1"},
+				"fs":       {"bgct"},
+				"u":        {"1450000000000"},
+				"t":        {"100"},
+				"sv":       {sdkVersion},
+				"uuid":     {"408703395"},
+			},
+		},
+		{
+			name:    "should return error since no ws time",
+			ac:      "fm=RFdxOEJjSjNoNkRKdDZUWV8kMF8kMV8kMl8kMw%3D%3D&ctype=huya_live",
+			wantErr: "wsTime is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := antiCode(tt.ac, "name", 1450000000000, time.UnixMilli(1721800000000))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			q, err := url.ParseQuery(got)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, q)
+		})
+	}
+}
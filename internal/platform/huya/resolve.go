@@ -0,0 +1,25 @@
+package huya
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ResolveRoomId accepts the links like `https://www.huya.com/660000`, the vanity room id is normalised to the numeric one.
+func (h Huya) ResolveRoomId(ctx context.Context, input string) (string, error) {
+	u, ok := platform.ParseInputUrl(input)
+	if !ok || !platform.MatchHost(u, "www.huya.com", "huya.com", "m.huya.com") {
+		return "", ErrResolveRoomId(platform.ErrUnsupportedInput)
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if id == "" {
+		return "", ErrResolveRoomId(fmt.Errorf("no room id in link: %s", u.String()))
+	}
+	r, err := h.GetRoom(ctx, id)
+	if err != nil {
+		return "", ErrResolveRoomId(err)
+	}
+	return r.Id, nil
+}
@@ -0,0 +1,44 @@
+package huya
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHuya_ResolveRoomId(t *testing.T) {
+	body, _ := os.ReadFile("testData/profileRoom.json")
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "should resolve vanity room link",
+			input: "https://www.huya.com/lpl",
+			want:  "660000",
+		},
+		{
+			name:    "should return unsupported since other platform",
+			input:   "https://www.douyu.com/288016",
+			wantErr: platform.ErrUnsupportedInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, done := newTestHuya(t, body)
+			defer done()
+			got, err := h.ResolveRoomId(context.TODO(), tt.input)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package huya
+
+import "encoding/json"
+
+// response is the common envelope, the data is kept raw since it is not an object when the status is not 200.
+type response struct {
+	Status  int             `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type profileRoom struct {
+	ProfileInfo struct {
+		Uid         int64  `json:"uid"`
+		Nick        string `json:"nick"`
+		Avatar180   string `json:"avatar180"`
+		ProfileRoom int64  `json:"profileRoom"`
+	} `json:"profileInfo"`
+
+	LiveData struct {
+		Introduction string `json:"introduction"`
+		Screenshot   string `json:"screenshot"`
+	} `json:"liveData"`
+
+	// RealLiveStatus is `ON` when the room is streaming, replays are excluded.
+	RealLiveStatus string `json:"realLiveStatus"`
+
+	Stream struct {
+		BaseSteamInfoList []streamInfo `json:"baseSteamInfoList"`
+
+		Flv struct {
+			RateArray []struct {
+				SDisplayName string `json:"sDisplayName"`
+				IBitRate     int    `json:"iBitRate"`
+			} `json:"rateArray"`
+		} `json:"flv"`
+	} `json:"stream"`
+}
+
+type streamInfo struct {
+	SCdnType      string `json:"sCdnType"`
+	SStreamName   string `json:"sStreamName"`
+	SFlvUrl       string `json:"sFlvUrl"`
+	SFlvUrlSuffix string `json:"sFlvUrlSuffix"`
+	SFlvAntiCode  string `json:"sFlvAntiCode"`
+	SHlsUrl       string `json:"sHlsUrl"`
+	SHlsUrlSuffix string `json:"sHlsUrlSuffix"`
+	SHlsAntiCode  string `json:"sHlsAntiCode"`
+	IIsMaster     int    `json:"iIsMaster"`
+}
@@ -0,0 +1,90 @@
+package platform
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit is the token bucket of the requests to a host.
+type RateLimit struct {
+	// Rate is the number of requests per second, 0 means unlimited.
+	Rate float64
+	// Burst is the max number of requests sent at once, it is at least 1.
+	Burst int
+}
+
+// limiter holds a token bucket for each host, the limit is got on each request so that the changes of
+// the settings take effect immediately.
+type limiter struct {
+	limit func() RateLimit
+	now   func() time.Time
+
+	mtx     sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newLimiter(limit func() RateLimit) *limiter {
+	return &limiter{
+		limit:   limit,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// wait blocks until the request to the host is allowed, it returns the waited duration, or the error of
+// the context if it is done before.
+func (l *limiter) wait(ctx context.Context, host string) (time.Duration, error) {
+	rl := l.limit()
+	if rl.Rate <= 0 {
+		return 0, nil
+	}
+	l.mtx.Lock()
+	b, ok := l.buckets[host]
+	if !ok {
+		b = &bucket{}
+		l.buckets[host] = b
+	}
+	l.mtx.Unlock()
+	d := b.reserve(l.now(), rl)
+	if d <= 0 {
+		return 0, nil
+	}
+	if !sleep(ctx, d) {
+		b.cancel(rl)
+		return 0, ctx.Err()
+	}
+	return d, nil
+}
+
+type bucket struct {
+	mtx    sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token and returns the delay until the token is available. The tokens go negative when
+// the bucket is empty, so that the waiting requests are sent in order at the rate.
+func (b *bucket) reserve(now time.Time, rl RateLimit) time.Duration {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	burst := float64(max(rl.Burst, 1))
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(burst, b.tokens+elapsed.Seconds()*rl.Rate)
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rl.Rate * float64(time.Second))
+}
+
+// cancel gives back the token of a request which is not sent.
+func (b *bucket) cancel(rl RateLimit) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.tokens = min(float64(max(rl.Burst, 1)), b.tokens+1)
+}
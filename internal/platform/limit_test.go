@@ -0,0 +1,71 @@
+package platform
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_bucket_reserve(t *testing.T) {
+	start := time.Date(2024, 7, 24, 12, 0, 0, 0, time.UTC)
+	rl := RateLimit{Rate: 2, Burst: 2}
+	tests := []struct {
+		name  string
+		after time.Duration
+		want  time.Duration
+	}{
+		{name: "should take the first token of the burst", after: 0, want: 0},
+		{name: "should take the second token of the burst", after: 0, want: 0},
+		{name: "should wait since the bucket is empty", after: 0, want: 500 * time.Millisecond},
+		{name: "should wait after the queued request", after: 0, want: time.Second},
+		{name: "should not wait since the tokens are refilled", after: 3 * time.Second, want: 0},
+	}
+	b := &bucket{}
+	now := start
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.after)
+			assert.Equal(t, tt.want, b.reserve(now, rl))
+		})
+	}
+}
+
+func Test_limiter_wait(t *testing.T) {
+	t.Run("should not wait since unlimited", func(t *testing.T) {
+		l := newLimiter(func() RateLimit { return RateLimit{} })
+		for i := 0; i < 100; i++ {
+			d, err := l.wait(context.Background(), "example.com")
+			assert.NoError(t, err)
+			assert.Zero(t, d)
+		}
+	})
+
+	t.Run("should limit each host separately", func(t *testing.T) {
+		l := newLimiter(func() RateLimit { return RateLimit{Rate: 1000, Burst: 1} })
+		d, err := l.wait(context.Background(), "a.example.com")
+		assert.NoError(t, err)
+		assert.Zero(t, d)
+		d, err = l.wait(context.Background(), "b.example.com")
+		assert.NoError(t, err)
+		assert.Zero(t, d)
+		d, err = l.wait(context.Background(), "a.example.com")
+		assert.NoError(t, err)
+		assert.Positive(t, d)
+	})
+
+	t.Run("should give back the token since the context is done", func(t *testing.T) {
+		now := time.Date(2024, 7, 24, 12, 0, 0, 0, time.UTC)
+		l := newLimiter(func() RateLimit { return RateLimit{Rate: 1, Burst: 1} })
+		l.now = func() time.Time { return now }
+		_, err := l.wait(context.Background(), "example.com")
+		assert.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = l.wait(ctx, "example.com")
+		assert.ErrorIs(t, err, context.Canceled)
+		// the canceled request does not delay the next one
+		assert.Equal(t, time.Second, l.buckets["example.com"].reserve(now, RateLimit{Rate: 1, Burst: 1}))
+	})
+}
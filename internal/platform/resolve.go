@@ -0,0 +1,50 @@
+package platform
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ParseInputUrl parses the pasted link, the scheme can be omitted, e.g. `live.bilibili.com/6`.
+// It returns false if the input does not look like a link.
+func ParseInputUrl(input string) (*url.URL, bool) {
+	input = strings.TrimSpace(input)
+	if !strings.Contains(input, "://") {
+		input = "https://" + input
+	}
+	u, err := url.Parse(input)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.Contains(u.Hostname(), ".") {
+		return nil, false
+	}
+	return u, true
+}
+
+// MatchHost reports whether the host of the url is one of the hosts, it ignores the case and the port.
+func MatchHost(u *url.URL, hosts ...string) bool {
+	h := strings.ToLower(u.Hostname())
+	for _, host := range hosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// FollowRedirects requests the short link and returns the final url after redirects.
+func FollowRedirects(ctx context.Context, c Client, u *url.URL) (*url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, ErrRequest(err)
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, ErrRequest(err)
+	}
+	_ = res.Body.Close()
+	if res.Request == nil {
+		return u, nil
+	}
+	return res.Request.URL, nil
+}
@@ -0,0 +1,76 @@
+package platform
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInputUrl(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		wantOk bool
+	}{
+		{
+			name:   "should parse full link",
+			input:  " https://live.bilibili.com/21452505?broadcast_type=0 ",
+			want:   "https://live.bilibili.com/21452505?broadcast_type=0",
+			wantOk: true,
+		},
+		{
+			name:   "should parse link without scheme",
+			input:  "live.bilibili.com/6",
+			want:   "https://live.bilibili.com/6",
+			wantOk: true,
+		},
+		{
+			name:  "should not parse bare room id",
+			input: "21452505",
+		},
+		{
+			name:  "should not parse non http link",
+			input: "rtmp://example.com/live",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseInputUrl(tt.input)
+			assert.Equal(t, tt.wantOk, ok)
+			if tt.wantOk {
+				assert.Equal(t, tt.want, got.String())
+			}
+		})
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	t.Run("should match host ignoring case and port", func(t *testing.T) {
+		u, _ := url.Parse("https://Live.Bilibili.com:443/6")
+		assert.True(t, MatchHost(u, "b23.tv", "live.bilibili.com"))
+		assert.False(t, MatchHost(u, "www.bilibili.com"))
+	})
+}
+
+func TestFollowRedirects(t *testing.T) {
+	t.Run("should return the final url", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/short" {
+				http.Redirect(w, r, "/6?broadcast_type=0", http.StatusFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+		u, _ := url.Parse(ts.URL + "/short")
+		got, err := FollowRedirects(context.TODO(), NewClient(slog.Default(), Headers{}, ClientOptions{}), u)
+		assert.NoError(t, err)
+		assert.Equal(t, ts.URL+"/6?broadcast_type=0", got.String())
+	})
+}
@@ -0,0 +1,61 @@
+package platform
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetLiveStreams returns the live streams of the platform, they are inferred from the live urls if the
+// platform does not implement StreamGetter.
+func GetLiveStreams(ctx context.Context, p Platform, roomId string, qualityId string) ([]LiveStream, error) {
+	if sg, ok := p.(StreamGetter); ok {
+		return sg.GetLiveStreams(ctx, roomId, qualityId)
+	}
+	us, err := p.GetLiveUrls(ctx, roomId, qualityId)
+	if err != nil {
+		return nil, err
+	}
+	lss := make([]LiveStream, len(us))
+	for i, u := range us {
+		lss[i] = InferLiveStream(u)
+	}
+	return lss, nil
+}
+
+// InferLiveStream guesses the protocol and the format of the url by its extension, it is used for the
+// platforms which do not implement StreamGetter.
+func InferLiveStream(u url.URL) LiveStream {
+	ls := LiveStream{
+		Url:       u,
+		ExpiresAt: ParseExpiry(u),
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".flv":
+		ls.Protocol = ProtocolHttpStream
+		ls.Format = FormatFlv
+	case ".m3u8":
+		ls.Protocol = ProtocolHls
+	}
+	return ls
+}
+
+// ParseExpiry returns the expiry time carried by the query of the signed url, i.e. `expires` in unix
+// seconds, or `wsTime` in hex unix seconds. It returns zero time if the url has no expiry.
+func ParseExpiry(u url.URL) time.Time {
+	q := u.Query()
+	if v := q.Get("expires"); v != "" {
+		if sec, err := strconv.ParseInt(v, 10, 64); err == nil && sec > 0 {
+			return time.Unix(sec, 0)
+		}
+	}
+	if v := q.Get("wsTime"); v != "" {
+		if sec, err := strconv.ParseInt(v, 16, 64); err == nil && sec > 0 {
+			return time.Unix(sec, 0)
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,40 @@
+package platform
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInferLiveStream(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want LiveStream
+	}{
+		{
+			name: "should infer flv stream with expiry",
+			url:  "https://d1--cn-gotcha09.bilivideo.com/live-bvc/live_1.flv?expires=1721816094&len=0",
+			want: LiveStream{Protocol: ProtocolHttpStream, Format: FormatFlv, ExpiresAt: time.Unix(1721816094, 0)},
+		},
+		{
+			name: "should infer hls stream with hex expiry",
+			url:  "https://al.hls.huya.com/src/1.m3u8?wsTime=66a0b21e",
+			want: LiveStream{Protocol: ProtocolHls, ExpiresAt: time.Unix(0x66a0b21e, 0)},
+		},
+		{
+			name: "should leave unknown fields empty",
+			url:  "http://localhost:8080/stream/abc?expires=invalid",
+			want: LiveStream{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse(tt.url)
+			tt.want.Url = *u
+			assert.Equal(t, tt.want, InferLiveStream(*u))
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package twitch
+
+import (
+	"asmblive/internal/platform"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+// clientId is the public client id of the twitch web player.
+const clientId = "kimne78kx3ncx6brgo4mv6wki5h1ko"
+
+var commonHeaders = map[string]string{
+	"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/93.0.4577.82 Safari/537.36 OPR/79.0.4143.50",
+	"Client-ID":  clientId,
+}
+
+var gqlUrl = url.URL{
+	Scheme: "https",
+	Host:   "gql.twitch.tv",
+	Path:   "/gql",
+}
+
+type twitchClient[T any] struct {
+	platform.Client
+	log *slog.Logger
+}
+
+func (c twitchClient[T]) query(ctx context.Context, q string, vars map[string]any) (*T, error) {
+	body, err := json.Marshal(request{Query: q, Variables: vars})
+	if err != nil {
+		return nil, platform.ErrRequest(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, gqlUrl.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, platform.ErrRequest(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range commonHeaders {
+		req.Header.Set(k, v)
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, platform.ErrRequest(err)
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			c.log.Warn("failed to close response body", "error", err)
+		}
+	}()
+	var rb response[T]
+	if err := json.NewDecoder(res.Body).Decode(&rb); err != nil {
+		c.log.Error("failed to decode response body", "error", err)
+		return nil, platform.ErrRequest(fmt.Errorf("failed to decode response body: %w", err))
+	}
+	if len(rb.Errors) > 0 {
+		return nil, platform.ErrRequest(fmt.Errorf("unexpected response error: %s", rb.Errors[0].Message))
+	}
+	return &rb.Data, nil
+}
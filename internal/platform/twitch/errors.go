@@ -0,0 +1,27 @@
+package twitch
+
+import "fmt"
+
+func ErrGetRoom(err error) error {
+	return fmt.Errorf("failed to get room: %w", err)
+}
+
+func ErrGetQualities(err error) error {
+	return fmt.Errorf("failed to get qualities: %w", err)
+}
+
+func ErrGetLiveUrls(err error) error {
+	return fmt.Errorf("failed to get live urls: %w", err)
+}
+
+func errGetAccessToken(err error) error {
+	return fmt.Errorf("failed to get playback access token: %w", err)
+}
+
+func errGetVariants(err error) error {
+	return fmt.Errorf("failed to get variants: %w", err)
+}
+
+func ErrResolveRoomId(err error) error {
+	return fmt.Errorf("failed to resolve room id: %w", err)
+}
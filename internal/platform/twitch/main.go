@@ -0,0 +1,207 @@
+package twitch
+
+import (
+	"asmblive/internal/platform"
+	"asmblive/internal/platform/hls"
+	"asmblive/internal/server"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const channelQuery = `query Channel($login: String!) {
+  user(login: $login) {
+    id
+    login
+    displayName
+    profileImageURL(width: 300)
+    offlineImageURL
+    broadcastSettings { title }
+    stream { type previewImageURL(width: 640, height: 360) }
+  }
+}`
+
+const accessTokenQuery = `query PlaybackAccessToken($login: String!) {
+  streamPlaybackAccessToken(channelName: $login, params: {platform: "web", playerBackend: "mediaplayer", playerType: "site"}) {
+    value
+    signature
+  }
+}`
+
+type Twitch struct {
+	log *slog.Logger
+	srv server.Server
+	pc  platform.Client
+}
+
+// proxyHosts is the hosts of the icon, thumbnails and avatars, which are fetched through the cors proxy.
+var proxyHosts = []string{"www.twitch.tv", ".jtvnw.net"}
+
+func NewTwitch(log *slog.Logger, pc platform.Client, srv server.Server) *Twitch {
+	log = log.With("module", "platform/twitch")
+	if srv != nil {
+		srv.AllowProxyHost("twitch", proxyHosts...)
+	}
+	return &Twitch{
+		log: log,
+		pc:  pc,
+		srv: srv,
+	}
+}
+
+func (t Twitch) Id() string {
+	return "twitch"
+}
+
+func (t Twitch) Name() string {
+	return "Twitch"
+}
+
+func (t Twitch) IconUrl() url.URL {
+	u := url.URL{
+		Scheme: "https",
+		Host:   "www.twitch.tv",
+		Path:   "/favicon.ico",
+	}
+	return t.srv.GetCorsProxyUrl(u)
+}
+
+// GetRoom returns the channel of the login, the room id of twitch is the login of the channel owner.
+func (t Twitch) GetRoom(ctx context.Context, roomId string) (platform.Room, error) {
+	tc := twitchClient[channel]{t.pc, t.log}
+	ch, err := tc.query(ctx, channelQuery, map[string]any{"login": normalizeLogin(roomId)})
+	if err != nil {
+		return platform.Room{}, ErrGetRoom(err)
+	}
+	u := ch.User
+	if u == nil {
+		return platform.Room{}, ErrGetRoom(fmt.Errorf("channel not found: %s", roomId))
+	}
+	isOnline := u.Stream != nil && u.Stream.Type == "live"
+	cover := u.OfflineImageURL
+	if u.Stream != nil {
+		cover = u.Stream.PreviewImageURL
+	}
+	cu, err := url.Parse(cover)
+	if err != nil {
+		return platform.Room{}, ErrGetRoom(fmt.Errorf("failed to parse cover url: %w", err))
+	}
+	au, err := url.Parse(u.ProfileImageURL)
+	if err != nil {
+		return platform.Room{}, ErrGetRoom(fmt.Errorf("failed to parse avatar url: %w", err))
+	}
+	return platform.Room{
+		Id:       u.Login,
+		Title:    u.BroadcastSettings.Title,
+		IsOnline: isOnline,
+		CoverUrl: t.srv.GetCorsProxyUrl(*cu),
+		Owner: platform.Owner{
+			Id:        u.Id,
+			Name:      u.DisplayName,
+			AvatarUrl: t.srv.GetCorsProxyUrl(*au),
+		},
+	}, nil
+}
+
+func (t Twitch) GetQualities(ctx context.Context, roomId string) ([]platform.Quality, error) {
+	vs, err := t.getVariants(ctx, roomId)
+	if err != nil {
+		return []platform.Quality{}, ErrGetQualities(err)
+	}
+	qualities := make([]platform.Quality, len(vs))
+	for idx, v := range vs {
+		qualities[idx] = platform.Quality{
+			Id:       v.Id,
+			Name:     v.Name,
+			Priority: int8(-idx),
+		}
+	}
+	return qualities, nil
+}
+
+// GetLiveUrls returns the variant playlist of the quality, the best one is used if the quality id is empty.
+func (t Twitch) GetLiveUrls(ctx context.Context, roomId string, qualityId string) ([]url.URL, error) {
+	vs, err := t.getVariants(ctx, roomId)
+	if err != nil {
+		return []url.URL{}, ErrGetLiveUrls(err)
+	}
+	if len(vs) == 0 {
+		return make([]url.URL, 0), nil
+	}
+	if qualityId == "" {
+		return []url.URL{vs[0].Url}, nil
+	}
+	for _, v := range vs {
+		if v.Id == qualityId {
+			return []url.URL{v.Url}, nil
+		}
+	}
+	return []url.URL{}, ErrGetLiveUrls(fmt.Errorf("quality not found: %s", qualityId))
+}
+
+// getVariants returns the variants of the usher master playlist, sorted from the best to the worst,
+// audio only variant is always the last one.
+func (t Twitch) getVariants(ctx context.Context, roomId string) ([]hls.Variant, error) {
+	login := normalizeLogin(roomId)
+	tc := twitchClient[accessToken]{t.pc, t.log}
+	at, err := tc.query(ctx, accessTokenQuery, map[string]any{"login": login})
+	if err != nil {
+		return nil, errGetAccessToken(err)
+	}
+	if at.StreamPlaybackAccessToken == nil {
+		return nil, errGetAccessToken(errors.New("empty token"))
+	}
+	u := url.URL{
+		Scheme: "https",
+		Host:   "usher.ttvnw.net",
+		Path:   "/api/channel/hls/" + url.PathEscape(login) + ".m3u8",
+	}
+	q := u.Query()
+	q.Set("sig", at.StreamPlaybackAccessToken.Signature)
+	q.Set("token", at.StreamPlaybackAccessToken.Value)
+	q.Set("allow_source", "true")
+	q.Set("allow_audio_only", "true")
+	q.Set("fast_bread", "true")
+	q.Set("playlist_include_framerate", "true")
+	q.Set("player_backend", "mediaplayer")
+	q.Set("p", strconv.Itoa(rand.Intn(10_000_000)))
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errGetVariants(err)
+	}
+	for k, v := range commonHeaders {
+		req.Header.Set(k, v)
+	}
+	res, err := t.pc.Do(req)
+	if err != nil {
+		return nil, errGetVariants(platform.ErrRequest(err))
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			t.log.Warn("failed to close response body", "error", err)
+		}
+	}()
+	vs, err := hls.ParseMaster(res.Body, u)
+	if err != nil {
+		return nil, errGetVariants(err)
+	}
+	sort.SliceStable(vs, func(i, j int) bool {
+		if vs[i].IsAudioOnly() != vs[j].IsAudioOnly() {
+			return vs[j].IsAudioOnly()
+		}
+		return vs[i].Bandwidth > vs[j].Bandwidth
+	})
+	return vs, nil
+}
+
+func normalizeLogin(roomId string) string {
+	return strings.ToLower(strings.TrimSpace(roomId))
+}
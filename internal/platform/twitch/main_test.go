@@ -0,0 +1,224 @@
+package twitch
+
+import (
+	"asmblive/internal/health"
+	"asmblive/internal/platform"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockServer struct{}
+
+func (m mockServer) Start() error {
+	panic("should not call")
+}
+
+func (m mockServer) Stop(_ context.Context) error {
+	panic("should not call")
+}
+
+func (m mockServer) AddHandler(string, http.HandlerFunc) {
+	panic("should not call")
+}
+
+func (m mockServer) BaseUrl() url.URL {
+	panic("should not call")
+}
+
+func (m mockServer) AllowProxyHost(string, ...string) {}
+
+func (m mockServer) GetCorsProxyUrl(origin url.URL) url.URL {
+	return origin
+}
+
+func (m mockServer) GetStreamUrl(origin url.URL, _ http.Header) url.URL {
+	return origin
+}
+
+func (m mockServer) GetRemuxUrl(origin url.URL, _ http.Header) url.URL {
+	return origin
+}
+
+func (m mockServer) CheckStreams(_ context.Context, _ string, urls []url.URL) []health.Result {
+	rs := make([]health.Result, len(urls))
+	for i, u := range urls {
+		rs[i] = health.Result{Url: u}
+	}
+	return rs
+}
+
+func (m mockServer) GetFailoverUrl(_ string, candidates []url.URL) url.URL {
+	return candidates[0]
+}
+
+// tsClient redirects all requests to the test server.
+type tsClient struct {
+	platform.Client
+	ts *httptest.Server
+}
+
+func (c tsClient) Do(req *http.Request) (*http.Response, error) {
+	u, _ := url.Parse(c.ts.URL)
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	return c.Client.Do(req)
+}
+
+// newTestTwitch serves the gql queries by the operation name and the usher playlist.
+func newTestTwitch(t *testing.T, gql map[string]string, usher string) (Twitch, func()) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gql", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, clientId, r.Header.Get("Client-ID"))
+		var req request
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		for op, f := range gql {
+			if strings.HasPrefix(req.Query, "query "+op+"(") {
+				data, _ := os.ReadFile(f)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write(data)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"errors":[{"message":"service error"}]}`))
+	})
+	mux.HandleFunc("/api/channel/hls/xqc.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		if usher == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		assert.Equal(t, "4b1b2c3d4e5f60718293a4b5c6d7e8f901234567", r.URL.Query().Get("sig"))
+		data, _ := os.ReadFile(usher)
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		_, _ = w.Write(data)
+	})
+	ts := httptest.NewServer(mux)
+	tw := Twitch{
+		log: slog.Default(),
+		pc:  tsClient{Client: platform.NewClient(slog.Default(), platform.Headers{}, platform.ClientOptions{}), ts: ts},
+		srv: &mockServer{},
+	}
+	return tw, ts.Close
+}
+
+func TestNewTwitch(t *testing.T) {
+	t.Run("should return an id", func(t *testing.T) {
+		tw := NewTwitch(slog.Default(), nil, nil)
+		assert.Equal(t, "twitch", tw.Id())
+	})
+
+	t.Run("should return a name", func(t *testing.T) {
+		tw := NewTwitch(slog.Default(), nil, nil)
+		assert.Equal(t, "Twitch", tw.Name())
+	})
+
+	t.Run("should return an icon", func(t *testing.T) {
+		tw := NewTwitch(slog.Default(), nil, &mockServer{})
+		u := tw.IconUrl()
+		assert.Equal(t, "https://www.twitch.tv/favicon.ico", u.String())
+	})
+}
+
+func TestTwitch_GetRoom(t *testing.T) {
+	t.Run("should return room by login", func(t *testing.T) {
+		tw, done := newTestTwitch(t, map[string]string{"Channel": "testData/channel.json"}, "")
+		defer done()
+		got, err := tw.GetRoom(context.TODO(), "xQc")
+		assert.NoError(t, err)
+		assert.Equal(t, platform.Room{
+			Id:       "xqc",
+			Title:    "JUST CHATTING",
+			IsOnline: true,
+			CoverUrl: url.URL{
+				Scheme: "https",
+				Host:   "static-cdn.jtvnw.net",
+				Path:   "/previews-ttv/live_user_xqc-640x360.jpg",
+			},
+			Owner: platform.Owner{
+				Id:   "71092938",
+				Name: "xQc",
+				AvatarUrl: url.URL{
+					Scheme: "https",
+					Host:   "static-cdn.jtvnw.net",
+					Path:   "/jtv_user_pictures/xqc-profile_image-9298dca608632101-300x300.jpeg",
+				},
+			},
+		}, got)
+	})
+
+	t.Run("should return error since gql error", func(t *testing.T) {
+		tw, done := newTestTwitch(t, map[string]string{}, "")
+		defer done()
+		_, err := tw.GetRoom(context.TODO(), "xqc")
+		assert.ErrorContains(t, err, "unexpected response error: service error")
+	})
+}
+
+func TestTwitch_GetQualities(t *testing.T) {
+	t.Run("should return qualities sorted by bandwidth", func(t *testing.T) {
+		tw, done := newTestTwitch(t, map[string]string{"PlaybackAccessToken": "testData/accessToken.json"}, "testData/master.m3u8")
+		defer done()
+		got, err := tw.GetQualities(context.TODO(), "xqc")
+		assert.NoError(t, err)
+		assert.Equal(t, []platform.Quality{
+			{Id: "chunked", Name: "1080p60 (source)", Priority: 0},
+			{Id: "720p60", Name: "720p60", Priority: -1},
+			{Id: "480p30", Name: "480p", Priority: -2},
+			{Id: "audio_only", Name: "audio_only", Priority: -3},
+		}, got)
+	})
+
+	t.Run("should return error since offline", func(t *testing.T) {
+		tw, done := newTestTwitch(t, map[string]string{"PlaybackAccessToken": "testData/accessToken.json"}, "")
+		defer done()
+		_, err := tw.GetQualities(context.TODO(), "xqc")
+		assert.ErrorContains(t, err, "status code: 404")
+	})
+}
+
+func TestTwitch_GetLiveUrls(t *testing.T) {
+	tests := []struct {
+		name      string
+		qualityId string
+		want      string
+		wantErr   string
+	}{
+		{
+			name:      "should return the variant playlist",
+			qualityId: "720p60",
+			want:      "https://video-weaver.sea01.hls.ttvnw.net/v1/playlist/720p60.m3u8",
+		},
+		{
+			name: "should return the best variant since no quality",
+			want: "https://video-weaver.sea01.hls.ttvnw.net/v1/playlist/chunked.m3u8",
+		},
+		{
+			name:      "should return error since unknown quality",
+			qualityId: "160p",
+			wantErr:   "quality not found: 160p",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tw, done := newTestTwitch(t, map[string]string{"PlaybackAccessToken": "testData/accessToken.json"}, "testData/master.m3u8")
+			defer done()
+			got, err := tw.GetLiveUrls(context.TODO(), "xqc", tt.qualityId)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, got, 1)
+			assert.Equal(t, tt.want, got[0].String())
+		})
+	}
+}
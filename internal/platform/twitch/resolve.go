@@ -0,0 +1,21 @@
+package twitch
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ResolveRoomId accepts the links like `https://www.twitch.tv/xqc`, the room id is the login of the channel.
+func (t Twitch) ResolveRoomId(_ context.Context, input string) (string, error) {
+	u, ok := platform.ParseInputUrl(input)
+	if !ok || !platform.MatchHost(u, "www.twitch.tv", "twitch.tv", "m.twitch.tv") {
+		return "", ErrResolveRoomId(platform.ErrUnsupportedInput)
+	}
+	login, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if login == "" {
+		return "", ErrResolveRoomId(fmt.Errorf("no channel in link: %s", u.String()))
+	}
+	return normalizeLogin(login), nil
+}
@@ -0,0 +1,42 @@
+package twitch
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTwitch_ResolveRoomId(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "should resolve channel link",
+			input: "https://www.twitch.tv/xQc?sr=a",
+			want:  "xqc",
+		},
+		{
+			name:    "should return unsupported since other platform",
+			input:   "https://live.bilibili.com/6",
+			wantErr: platform.ErrUnsupportedInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tw := NewTwitch(slog.Default(), nil, nil)
+			got, err := tw.ResolveRoomId(context.TODO(), tt.input)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
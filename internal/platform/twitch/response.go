@@ -0,0 +1,37 @@
+package twitch
+
+type request struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+type response[T any] struct {
+	Data   T `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type channel struct {
+	User *struct {
+		Id                string `json:"id"`
+		Login             string `json:"login"`
+		DisplayName       string `json:"displayName"`
+		ProfileImageURL   string `json:"profileImageURL"`
+		OfflineImageURL   string `json:"offlineImageURL"`
+		BroadcastSettings struct {
+			Title string `json:"title"`
+		} `json:"broadcastSettings"`
+		Stream *struct {
+			Type            string `json:"type"`
+			PreviewImageURL string `json:"previewImageURL"`
+		} `json:"stream"`
+	} `json:"user"`
+}
+
+type accessToken struct {
+	StreamPlaybackAccessToken *struct {
+		Value     string `json:"value"`
+		Signature string `json:"signature"`
+	} `json:"streamPlaybackAccessToken"`
+}
@@ -2,7 +2,10 @@ package platform
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
 	"net/url"
+	"time"
 )
 
 type Platform interface {
@@ -34,3 +37,132 @@ type Quality struct {
 	Name     string
 	Priority int8
 }
+
+const (
+	ProtocolHttpStream = "http_stream"
+	ProtocolHls        = "http_hls"
+
+	FormatFlv  = "flv"
+	FormatTs   = "ts"
+	FormatFmp4 = "fmp4"
+
+	CodecAvc  = "avc"
+	CodecHevc = "hevc"
+)
+
+// LiveStream is a live url with its metadata, the empty fields are unknown.
+type LiveStream struct {
+	Url url.URL
+	// Origin is the upstream url if Url is relayed or remuxed by the server, the recorder downloads it
+	// with Header instead of the player-facing Url. It is zero if Url is the upstream url.
+	Origin url.URL
+	// Header is sent with the upstream requests of the stream, e.g. the referer required by the cdn.
+	Header http.Header
+	// Protocol is ProtocolHttpStream or ProtocolHls of the origin stream, the flv stream may be
+	// remuxed into hls before playing.
+	Protocol string
+	// Format is FormatFlv, FormatTs or FormatFmp4.
+	Format string
+	// Codec is CodecAvc or CodecHevc.
+	Codec string
+	// ExpiresAt is zero if the url never expires or the expiry is unknown.
+	ExpiresAt time.Time
+}
+
+// Upstream returns Origin if Url is relayed, otherwise Url.
+func (ls LiveStream) Upstream() url.URL {
+	if ls.Origin.Host != "" {
+		return ls.Origin
+	}
+	return ls.Url
+}
+
+// StreamGetter is implemented by the platforms which provide the metadata of the live urls.
+type StreamGetter interface {
+	// GetLiveStreams returns the same urls as GetLiveUrls with their metadata.
+	GetLiveStreams(ctx context.Context, roomId string, qualityId string) ([]LiveStream, error)
+}
+
+// Searcher is implemented by the platforms which support searching rooms by keyword.
+type Searcher interface {
+	// SearchRooms returns the rooms matching the keyword, the page starts from 1.
+	SearchRooms(ctx context.Context, keyword string, page int) ([]Room, error)
+}
+
+// BatchRoomGetter is implemented by the platforms which can get many rooms in a few requests.
+type BatchRoomGetter interface {
+	// GetRooms returns the rooms keyed by the requested room ids, the missing rooms are omitted.
+	GetRooms(ctx context.Context, roomIds []string) (map[string]Room, error)
+}
+
+// Resolver is implemented by the platforms which can extract room ids from pasted links.
+type Resolver interface {
+	// ResolveRoomId returns the canonical room id of the input, it returns an error wrapping
+	// ErrUnsupportedInput if the input is not a link of the platform.
+	ResolveRoomId(ctx context.Context, input string) (string, error)
+}
+
+// ChatSource is implemented by the platforms which support live chat.
+type ChatSource interface {
+	// Subscribe returns the chat events of the room, the channel is closed when the context is done
+	// or the connection is broken.
+	Subscribe(ctx context.Context, roomId string) (<-chan ChatEvent, error)
+}
+
+// FollowLister is implemented by the platforms which can list the rooms followed by the logged-in user.
+type FollowLister interface {
+	// GetFollowedRooms returns the followed rooms, the online ones go first.
+	GetFollowedRooms(ctx context.Context) ([]Room, error)
+}
+
+// QrLoginProvider is implemented by the platforms which support logging in by scanning a qr code with
+// the mobile app.
+type QrLoginProvider interface {
+	// GetLoginQrCode returns a new qr code, the key is used to poll the login state.
+	GetLoginQrCode(ctx context.Context) (LoginQrCode, error)
+	// PollLogin returns the state of the qr code, the credentials are stored once it is LoginConfirmed.
+	PollLogin(ctx context.Context, key string) (LoginState, error)
+	// Logout clears the stored credentials.
+	Logout(ctx context.Context) error
+}
+
+type LoginQrCode struct {
+	Key string
+	// Url is the content of the qr code.
+	Url url.URL
+}
+
+type LoginState string
+
+const (
+	LoginWaiting   LoginState = "waiting"
+	LoginScanned   LoginState = "scanned"
+	LoginConfirmed LoginState = "confirmed"
+	LoginExpired   LoginState = "expired"
+)
+
+type ChatEventKind string
+
+const (
+	ChatEventMessage     ChatEventKind = "message"
+	ChatEventGift        ChatEventKind = "gift"
+	ChatEventSuperChat   ChatEventKind = "superChat"
+	ChatEventMembership  ChatEventKind = "membership"
+	ChatEventOnlineCount ChatEventKind = "onlineCount"
+)
+
+type ChatEvent struct {
+	Kind ChatEventKind
+	User ChatUser
+	Text string
+	// Amount is the paid value in 1/1000 of the platform currency, or the number of viewers for ChatEventOnlineCount.
+	Amount int64
+	Time   time.Time
+	// Raw is the original message of the platform.
+	Raw json.RawMessage
+}
+
+type ChatUser struct {
+	Id   string
+	Name string
+}
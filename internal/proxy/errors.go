@@ -0,0 +1,20 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnsupportedScheme = errors.New("unsupported proxy scheme, only http, https and socks5 are supported")
+
+func ErrInvalidUrl(err error) error {
+	return fmt.Errorf("invalid proxy url: %w", err)
+}
+
+func errInvalidBypass(pattern string) error {
+	return fmt.Errorf("invalid bypass pattern: %s", pattern)
+}
+
+func errInvalidHost(raw string) error {
+	return fmt.Errorf("no host in %s", raw)
+}
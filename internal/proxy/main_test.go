@@ -0,0 +1,386 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// targetHost cannot be resolved, so that the requests to it succeed only through the proxies.
+const targetHost = "target.test"
+
+// resolver maps targetHost to the address of the test server.
+type resolver map[string]string
+
+func (r resolver) resolve(hostport string) string {
+	host, port, _ := net.SplitHostPort(hostport)
+	if addr, ok := r[host+":"+port]; ok {
+		return addr
+	}
+	return hostport
+}
+
+func pipe(a net.Conn, b net.Conn) {
+	go func() {
+		_, _ = io.Copy(a, b)
+		_ = a.Close()
+	}()
+	_, _ = io.Copy(b, a)
+	_ = b.Close()
+}
+
+// newHttpProxy returns an http proxy, which forwards the plain requests and tunnels the CONNECT requests.
+func newHttpProxy(r resolver, user string, pass string) (*httptest.Server, *atomic.Int32) {
+	var count atomic.Int32
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		count.Add(1)
+		if user != "" && req.Header.Get("Proxy-Authorization") != wantAuth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		if req.Method == http.MethodConnect {
+			conn, err := net.Dial("tcp", r.resolve(req.Host))
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			cc, _, _ := w.(http.Hijacker).Hijack()
+			_, _ = cc.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+			pipe(cc, conn)
+			return
+		}
+		out := req.Clone(req.Context())
+		out.RequestURI = ""
+		out.URL.Host = r.resolve(req.URL.Host + ":80")
+		out.Header.Del("Proxy-Authorization")
+		res, err := http.DefaultTransport.RoundTrip(out)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer func() { _ = res.Body.Close() }()
+		w.WriteHeader(res.StatusCode)
+		_, _ = io.Copy(w, res.Body)
+	}))
+	return ts, &count
+}
+
+// newSocks5Proxy returns the address of a socks5 proxy, which supports the CONNECT command only.
+func newSocks5Proxy(t *testing.T, r resolver, user string, pass string) (string, *atomic.Int32) {
+	var count atomic.Int32
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	serve := func(conn net.Conn) error {
+		br := bufio.NewReader(conn)
+		head := make([]byte, 2)
+		if _, err := io.ReadFull(br, head); err != nil || head[0] != 5 {
+			return errors.New("invalid greeting")
+		}
+		if _, err := io.ReadFull(br, make([]byte, head[1])); err != nil {
+			return err
+		}
+		if user == "" {
+			_, _ = conn.Write([]byte{5, 0})
+		} else {
+			_, _ = conn.Write([]byte{5, 2})
+			var ver, l byte
+			ver, _ = br.ReadByte()
+			l, _ = br.ReadByte()
+			u := make([]byte, l)
+			_, _ = io.ReadFull(br, u)
+			l, _ = br.ReadByte()
+			p := make([]byte, l)
+			_, _ = io.ReadFull(br, p)
+			if ver != 1 || string(u) != user || string(p) != pass {
+				_, _ = conn.Write([]byte{1, 1})
+				return errors.New("invalid credentials")
+			}
+			_, _ = conn.Write([]byte{1, 0})
+		}
+		req := make([]byte, 4)
+		if _, err := io.ReadFull(br, req); err != nil || req[1] != 1 {
+			return errors.New("invalid request")
+		}
+		var host string
+		switch req[3] {
+		case 1, 4:
+			ip := make([]byte, 4)
+			if req[3] == 4 {
+				ip = make([]byte, 16)
+			}
+			_, _ = io.ReadFull(br, ip)
+			host = net.IP(ip).String()
+		case 3:
+			l, _ := br.ReadByte()
+			d := make([]byte, l)
+			_, _ = io.ReadFull(br, d)
+			host = string(d)
+		}
+		port := make([]byte, 2)
+		_, _ = io.ReadFull(br, port)
+		addr := r.resolve(net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+		up, err := net.Dial("tcp", addr)
+		if err != nil {
+			_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return err
+		}
+		_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		count.Add(1)
+		pipe(conn, up)
+		return nil
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				if err := serve(conn); err != nil {
+					_ = conn.Close()
+				}
+			}()
+		}
+	}()
+	return l.Addr().String(), &count
+}
+
+func newTarget(tls bool) *httptest.Server {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello " + r.Host))
+	})
+	if tls {
+		return httptest.NewTLSServer(h)
+	}
+	return httptest.NewServer(h)
+}
+
+func TestTransport(t *testing.T) {
+	target := newTarget(false)
+	defer target.Close()
+	tlsTarget := newTarget(true)
+	defer tlsTarget.Close()
+	r := resolver{
+		targetHost + ":80":  target.Listener.Addr().String(),
+		targetHost + ":443": tlsTarget.Listener.Addr().String(),
+	}
+	hp, hpCount := newHttpProxy(r, "", "")
+	defer hp.Close()
+	ahp, _ := newHttpProxy(r, "user", "pass")
+	defer ahp.Close()
+	sp, spCount := newSocks5Proxy(t, r, "user", "pass")
+	mustParse := func(raw string) *url.URL {
+		u, err := Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	tests := []struct {
+		name    string
+		config  Config
+		url     string
+		counter *atomic.Int32
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "should forward through http proxy",
+			config:  Config{Url: mustParse(hp.URL)},
+			url:     "http://" + targetHost + "/",
+			counter: hpCount,
+			want:    "hello " + targetHost,
+		},
+		{
+			name:    "should tunnel https through http proxy",
+			config:  Config{Url: mustParse(hp.URL)},
+			url:     "https://" + targetHost + "/",
+			counter: hpCount,
+			want:    "hello " + targetHost,
+		},
+		{
+			name:   "should forward through http proxy with auth",
+			config: Config{Url: mustParse("http://user:pass@" + ahp.Listener.Addr().String())},
+			url:    "http://" + targetHost + "/",
+			want:   "hello " + targetHost,
+		},
+		{
+			name:    "should fail since the auth of http proxy is wrong",
+			config:  Config{Url: mustParse("http://user:wrong@" + ahp.Listener.Addr().String())},
+			url:     "https://" + targetHost + "/",
+			wantErr: true,
+		},
+		{
+			name:    "should connect through socks5 proxy with auth",
+			config:  Config{Url: mustParse("socks5://user:pass@" + sp)},
+			url:     "https://" + targetHost + "/",
+			counter: spCount,
+			want:    "hello " + targetHost,
+		},
+		{
+			name:    "should fail since the auth of socks5 proxy is wrong",
+			config:  Config{Url: mustParse("socks5://user:wrong@" + sp)},
+			url:     "http://" + targetHost + "/",
+			wantErr: true,
+		},
+		{
+			name:   "should connect directly since bypassed",
+			config: Config{Url: mustParse(hp.URL), Bypass: []string{"127.0.0.0/8"}},
+			url:    target.URL,
+			want:   "hello " + target.Listener.Addr().String(),
+		},
+		{
+			name:   "should connect directly since the target is loopback",
+			config: Config{Url: mustParse(hp.URL)},
+			url:    target.URL,
+			want:   "hello " + target.Listener.Addr().String(),
+		},
+		{
+			name:    "should fail since the target is connected directly without proxy",
+			config:  Config{},
+			url:     "http://" + targetHost + "/",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before int32
+			if tt.counter != nil {
+				before = tt.counter.Load()
+			}
+			hpBefore := hpCount.Load()
+			tr := Transport(Func(func() (Config, error) { return tt.config, nil }))
+			tlsConfig := tlsTarget.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+			// the certificate of the test server is issued to example.com
+			tlsConfig.ServerName = "example.com"
+			tr.TLSClientConfig = tlsConfig
+			c := &http.Client{Transport: tr}
+			defer c.CloseIdleConnections()
+			res, err := c.Get(tt.url)
+			if err == nil && res.StatusCode != http.StatusOK {
+				err = errors.New(res.Status)
+			}
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() { _ = res.Body.Close() }()
+			data, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+			if tt.counter != nil {
+				assert.Greater(t, tt.counter.Load(), before)
+			}
+			if tt.config.Bypass != nil || tt.url == target.URL {
+				assert.Equal(t, hpBefore, hpCount.Load())
+			}
+		})
+	}
+
+	t.Run("should fail since the config cannot be got", func(t *testing.T) {
+		tr := Transport(Func(func() (Config, error) { return Config{}, errors.New("invalid setting") }))
+		_, err := (&http.Client{Transport: tr}).Get("http://" + targetHost + "/")
+		assert.ErrorContains(t, err, "invalid setting")
+	})
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{name: "should return nil since empty", raw: " "},
+		{name: "should parse http", raw: "http://127.0.0.1:8080", want: "http://127.0.0.1:8080"},
+		{name: "should parse https", raw: "https://proxy.example.com", want: "https://proxy.example.com"},
+		{name: "should parse socks5 with auth", raw: "socks5://u:p@127.0.0.1:1080", want: "socks5://u:p@127.0.0.1:1080"},
+		{name: "should fail since unsupported scheme", raw: "ftp://127.0.0.1", wantErr: true},
+		{name: "should fail since no scheme", raw: "127.0.0.1:8080", wantErr: true},
+		{name: "should fail since no host", raw: "http://", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := Parse(tt.raw)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.want == "" {
+				assert.Nil(t, u)
+				return
+			}
+			assert.Equal(t, tt.want, u.String())
+		})
+	}
+}
+
+func TestBypassed(t *testing.T) {
+	patterns := []string{"localhost", ".example.com", "*.bilibili.com", "10.0.0.0/8", "::1"}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "localhost:8080", want: true},
+		{host: "example.com", want: true},
+		{host: "api.example.com", want: true},
+		{host: "notexample.com", want: false},
+		{host: "live.BILIBILI.com", want: true},
+		{host: "10.1.2.3:443", want: true},
+		{host: "11.1.2.3", want: false},
+		{host: "[::1]:80", want: true},
+		{host: "huya.com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.want, Bypassed(tt.host, patterns))
+		})
+	}
+	assert.True(t, Bypassed("huya.com", []string{"*"}))
+}
+
+func Test_isLoopback(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "127.0.0.1:34567", want: true},
+		{host: "[::1]:80", want: true},
+		{host: "LocalHost", want: true},
+		{host: "app.localhost:80", want: true},
+		{host: "127.1.2.3", want: true},
+		{host: "10.0.0.1", want: false},
+		{host: "example.com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.want, isLoopback(tt.host))
+		})
+	}
+}
+
+func TestCheckBypass(t *testing.T) {
+	assert.NoError(t, CheckBypass([]string{"localhost", ".example.com", "10.0.0.0/8", "::1"}))
+	assert.Error(t, CheckBypass([]string{"10.0.0.0/33"}))
+	assert.Error(t, CheckBypass([]string{"a b"}))
+	assert.Error(t, CheckBypass([]string{""}))
+}
@@ -0,0 +1,12 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrTooLong = errors.New("content too long")
+
+func ErrEncode(err error) error {
+	return fmt.Errorf("failed to encode qr code: %w", err)
+}
@@ -0,0 +1,187 @@
+// Package qrcode encodes short texts, e.g. the login links, into qr codes. Only the byte mode and the
+// error correction level M are supported, which is enough for the links of up to 213 bytes.
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// quietZone is the width of the blank border in modules.
+const quietZone = 4
+
+// block is the error correction layout of a version at level M.
+type block struct {
+	// ecLen is the number of error correction codewords per block.
+	ecLen int
+	// groups are the numbers of blocks, the blocks of the second group have one more data codeword.
+	g1Blocks, g1DataLen, g2Blocks int
+}
+
+// blocks is indexed by version, from 1 to 10.
+var blocks = []block{
+	{},
+	{ecLen: 10, g1Blocks: 1, g1DataLen: 16},
+	{ecLen: 16, g1Blocks: 1, g1DataLen: 28},
+	{ecLen: 26, g1Blocks: 1, g1DataLen: 44},
+	{ecLen: 18, g1Blocks: 2, g1DataLen: 32},
+	{ecLen: 24, g1Blocks: 2, g1DataLen: 43},
+	{ecLen: 16, g1Blocks: 4, g1DataLen: 27},
+	{ecLen: 18, g1Blocks: 4, g1DataLen: 31},
+	{ecLen: 22, g1Blocks: 2, g1DataLen: 38, g2Blocks: 2},
+	{ecLen: 22, g1Blocks: 3, g1DataLen: 36, g2Blocks: 2},
+	{ecLen: 26, g1Blocks: 4, g1DataLen: 43, g2Blocks: 1},
+}
+
+// alignments are the center coordinates of the alignment patterns, indexed by version.
+var alignments = [][]int{
+	{}, {}, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+func (b block) dataLen() int {
+	return b.g1Blocks*b.g1DataLen + b.g2Blocks*(b.g1DataLen+1)
+}
+
+// Encode returns the modules of the qr code without the quiet zone, indexed by row and column, true is dark.
+func Encode(content string) ([][]bool, error) {
+	data := []byte(content)
+	version := 0
+	for v := 1; v < len(blocks); v++ {
+		if len(data)+overhead(v) <= blocks[v].dataLen() {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrEncode(ErrTooLong)
+	}
+	q := newQr(version)
+	q.drawFunctions()
+	q.drawCodewords(interleave(blocks[version], encodeData(data, version)))
+	best, bestPenalty := -1, 0
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if p := q.penalty(); best < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		// the mask is its own inverse
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+	return q.modules, nil
+}
+
+// PNG renders the qr code of the content with the quiet zone, each module is scale pixels.
+func PNG(content string, scale int) ([]byte, error) {
+	ms, err := Encode(content)
+	if err != nil {
+		return nil, err
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	size := (len(ms) + 2*quietZone) * scale
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			r, c := y/scale-quietZone, x/scale-quietZone
+			dark := r >= 0 && c >= 0 && r < len(ms) && c < len(ms) && ms[r][c]
+			if dark {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, ErrEncode(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// overhead is the number of bytes of the mode indicator, the count and the terminator.
+func overhead(version int) int {
+	if version < 10 {
+		// 4 + 8 bits, and the terminator is truncated if there is no room
+		return 2
+	}
+	// 4 + 16 bits
+	return 3
+}
+
+// encodeData returns the data codewords in byte mode with the padding.
+func encodeData(data []byte, version int) []byte {
+	var bb bitBuffer
+	bb.append(0b0100, 4)
+	if version < 10 {
+		bb.append(len(data), 8)
+	} else {
+		bb.append(len(data), 16)
+	}
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	capacity := blocks[version].dataLen() * 8
+	bb.append(0, min(4, capacity-len(bb)))
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+	return bb.bytes()
+}
+
+// interleave splits the data into blocks, appends the error correction codewords of each block, and
+// interleaves the codewords of the blocks.
+func interleave(b block, data []byte) []byte {
+	n := b.g1Blocks + b.g2Blocks
+	ds := make([][]byte, n)
+	ecs := make([][]byte, n)
+	gen := generator(b.ecLen)
+	for i, off := 0, 0; i < n; i++ {
+		l := b.g1DataLen
+		if i >= b.g1Blocks {
+			l++
+		}
+		ds[i] = data[off : off+l]
+		ecs[i] = remainder(ds[i], gen)
+		off += l
+	}
+	r := make([]byte, 0, len(data)+n*b.ecLen)
+	for i := 0; i <= b.g1DataLen; i++ {
+		for _, d := range ds {
+			if i < len(d) {
+				r = append(r, d[i])
+			}
+		}
+	}
+	for i := 0; i < b.ecLen; i++ {
+		for _, ec := range ecs {
+			r = append(r, ec[i])
+		}
+	}
+	return r
+}
+
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(v int, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*bb = append(*bb, v>>i&1 == 1)
+	}
+}
+
+func (bb bitBuffer) bytes() []byte {
+	r := make([]byte, (len(bb)+7)/8)
+	for i, b := range bb {
+		if b {
+			r[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return r
+}
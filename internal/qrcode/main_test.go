@@ -0,0 +1,137 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemainder(t *testing.T) {
+	// HELLO WORLD in alphanumeric mode, version 1-M
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, remainder(data, generator(10)))
+}
+
+func TestFormatBits(t *testing.T) {
+	want := []int{
+		0b101010000010010, 0b101000100100101, 0b101111001111100, 0b101101101001011,
+		0b100010111111001, 0b100000011001110, 0b100111110010111, 0b100101010100000,
+	}
+	for mask, w := range want {
+		assert.Equal(t, w, formatBits(mask), "mask %d", mask)
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+	assert.Equal(t, 0b000111110010010100, versionBits(7))
+	assert.Equal(t, 0b001010010011010011, versionBits(10))
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		size    int
+		wantErr string
+	}{
+		{
+			name:    "should encode in version 1",
+			content: "hello",
+			size:    21,
+		},
+		{
+			name:    "should encode the login link in version 8",
+			content: "https://account.bilibili.com/h5/account-passport-login/mobile/scan-login?qrcode_key=8a2f7dbb59e6c8e1d4bb8d4b3c1e0f2a&navigation=native",
+			size:    49,
+		},
+		{
+			name:    "should encode in version 10",
+			content: strings.Repeat("a", 213),
+			size:    57,
+		},
+		{
+			name:    "should fail since too long",
+			content: strings.Repeat("a", 214),
+			wantErr: "failed to encode qr code: content too long",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms, err := Encode(tt.content)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, ms, tt.size)
+			version := (len(ms) - 17) / 4
+			// read the format information back
+			format := 0
+			for i := 14; i >= 9; i-- {
+				format = format<<1 | bit(ms[8][14-i])
+			}
+			format = format<<1 | bit(ms[8][7])
+			format = format<<1 | bit(ms[8][8])
+			format = format<<1 | bit(ms[7][8])
+			for i := 5; i >= 0; i-- {
+				format = format<<1 | bit(ms[i][8])
+			}
+			mask := -1
+			for m := 0; m < 8; m++ {
+				if formatBits(m) == format {
+					mask = m
+				}
+			}
+			assert.NotEqual(t, -1, mask)
+			// unmask and read the data codewords back
+			q := newQr(version)
+			q.drawFunctions()
+			q.modules = ms
+			q.applyMask(mask)
+			var bb bitBuffer
+			for right := q.size - 1; right >= 1; right -= 2 {
+				if right == 6 {
+					right = 5
+				}
+				upward := (right+1)&2 == 0
+				for vert := 0; vert < q.size; vert++ {
+					y := vert
+					if upward {
+						y = q.size - 1 - vert
+					}
+					for j := 0; j < 2; j++ {
+						if !q.function[y][right-j] {
+							bb = append(bb, q.modules[y][right-j])
+						}
+					}
+				}
+			}
+			b := blocks[version]
+			cws := bb.bytes()[:b.dataLen()+(b.g1Blocks+b.g2Blocks)*b.ecLen]
+			assert.Equal(t, interleave(b, encodeData([]byte(tt.content), version)), cws)
+		})
+	}
+}
+
+func bit(dark bool) int {
+	if dark {
+		return 1
+	}
+	return 0
+}
+
+func TestPNG(t *testing.T) {
+	data, err := PNG("hello", 4)
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, (21+2*quietZone)*4, img.Bounds().Dx())
+	// the quiet zone is light and the finder is dark
+	r, _, _, _ := img.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+	r, _, _, _ = img.At(quietZone*4, quietZone*4).RGBA()
+	assert.Equal(t, uint32(0), r)
+}
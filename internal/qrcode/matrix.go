@@ -0,0 +1,277 @@
+package qrcode
+
+// qr is the matrix under construction, the coordinates are (x, y), i.e. (column, row).
+type qr struct {
+	version int
+	size    int
+	modules [][]bool
+	// function marks the modules of the patterns, which are not masked.
+	function [][]bool
+}
+
+func newQr(version int) *qr {
+	size := version*4 + 17
+	q := &qr{
+		version:  version,
+		size:     size,
+		modules:  make([][]bool, size),
+		function: make([][]bool, size),
+	}
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.function[i] = make([]bool, size)
+	}
+	return q
+}
+
+func (q *qr) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+// drawFunctions draws the finder, timing, alignment and version patterns, and reserves the format area.
+func (q *qr) drawFunctions() {
+	for i := 0; i < q.size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	q.drawFinder(3, 3)
+	q.drawFinder(q.size-4, 3)
+	q.drawFinder(3, q.size-4)
+	as := alignments[q.version]
+	last := len(as) - 1
+	for i, x := range as {
+		for j, y := range as {
+			// the corners overlap the finders
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			q.drawAlignment(x, y)
+		}
+	}
+	q.drawFormat(0)
+	q.drawVersion()
+}
+
+// drawFinder draws the finder pattern centered at (x, y) with its separator.
+func (q *qr) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= q.size || yy >= q.size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			q.set(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+func (q *qr) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			q.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormat draws both copies of the format information of level M and the mask.
+func (q *qr) drawFormat(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool {
+		return bits>>i&1 == 1
+	}
+	for i := 0; i < 6; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	// the dark module
+	q.set(8, q.size-8, true)
+}
+
+// formatBits returns the 15-bit format information, the level M is 0b00.
+func formatBits(mask int) int {
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// drawVersion draws both copies of the version information, which exists since version 7.
+func (q *qr) drawVersion() {
+	if q.version < 7 {
+		return
+	}
+	bits := versionBits(q.version)
+	for i := 0; i < 18; i++ {
+		dark := bits>>i&1 == 1
+		a, b := q.size-11+i%3, i/3
+		q.set(a, b, dark)
+		q.set(b, a, dark)
+	}
+}
+
+// versionBits returns the 18-bit version information.
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	return version<<12 | rem
+}
+
+// drawCodewords places the codewords in the zigzag order from the bottom right corner, the remainder
+// bits are left light.
+func (q *qr) drawCodewords(cws []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		// skip the vertical timing pattern
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.size; vert++ {
+			y := vert
+			if upward {
+				y = q.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if q.function[y][x] || i >= len(cws)*8 {
+					continue
+				}
+				q.modules[y][x] = cws[i/8]>>(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules selected by the mask pattern.
+func (q *qr) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if !q.function[y][x] && masked(mask, x, y) {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+func masked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// penalty scores the matrix by the rules of the specification, the mask of the lowest score is used.
+func (q *qr) penalty() int {
+	p := 0
+	at := func(x, y int, transposed bool) bool {
+		if transposed {
+			return q.modules[x][y]
+		}
+		return q.modules[y][x]
+	}
+	for _, transposed := range []bool{false, true} {
+		for y := 0; y < q.size; y++ {
+			// the runs of 5 or more modules of the same color
+			run := 1
+			for x := 1; x < q.size; x++ {
+				if at(x, y, transposed) == at(x-1, y, transposed) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				p += run - 2
+			}
+			// the finder-like patterns, 1011101 with 4 light modules on either side
+			for x := 0; x+7 <= q.size; x++ {
+				if !finderLike(func(i int) bool { return at(x+i, y, transposed) }) {
+					continue
+				}
+				if lightRun(func(i int) bool { return at(i, y, transposed) }, x-4, x, q.size) ||
+					lightRun(func(i int) bool { return at(i, y, transposed) }, x+7, x+11, q.size) {
+					p += 40
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			c := q.modules[y][x]
+			if c {
+				dark++
+			}
+			// the 2x2 blocks of the same color
+			if x+1 < q.size && y+1 < q.size &&
+				c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+				p += 3
+			}
+		}
+	}
+	// the deviation of the dark ratio from 50% in steps of 5%
+	total := q.size * q.size
+	p += abs(dark*20-total*10) / total * 10
+	return p
+}
+
+func finderLike(at func(int) bool) bool {
+	for i, dark := range []bool{true, false, true, true, true, false, true} {
+		if at(i) != dark {
+			return false
+		}
+	}
+	return true
+}
+
+// lightRun reports whether the modules in [from, to) are light, the modules out of the matrix are light.
+func lightRun(at func(int) bool, from, to, size int) bool {
+	for i := from; i < to; i++ {
+		if i >= 0 && i < size && at(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
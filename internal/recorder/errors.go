@@ -0,0 +1,21 @@
+package recorder
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoStream is returned if the room has no live urls, e.g. it is offline.
+var ErrNoStream = errors.New("no stream")
+
+func ErrStart(err error) error {
+	return fmt.Errorf("failed to start recording: %w", err)
+}
+
+func ErrRecord(err error) error {
+	return fmt.Errorf("failed to record: %w", err)
+}
+
+func errDownload(err error) error {
+	return fmt.Errorf("failed to download: %w", err)
+}
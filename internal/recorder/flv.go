@@ -0,0 +1,42 @@
+package recorder
+
+import "asmblive/internal/flv"
+
+// flvHeaders keeps the file header, the metadata and the latest sequence headers of a stream, they are
+// written at the beginning of every split file so that each file can be played on its own.
+type flvHeaders struct {
+	header flv.Header
+	meta   flv.Tag
+	video  flv.Tag
+	audio  flv.Tag
+	// keyframed is set after the first split point is written.
+	keyframed bool
+}
+
+func (f *flvHeaders) keep(tag flv.Tag) {
+	switch {
+	case tag.Type() == flv.TagScript:
+		f.meta = tag
+	case tag.Type() == flv.TagVideo && tag.IsSequenceHeader():
+		f.video = tag
+	case tag.Type() == flv.TagAudio && tag.IsSequenceHeader():
+		f.audio = tag
+	}
+}
+
+func (f *flvHeaders) bytes() []byte {
+	b := make([]byte, 0, len(f.header)+len(f.meta)+len(f.video)+len(f.audio))
+	b = append(b, f.header...)
+	b = append(b, f.meta...)
+	b = append(b, f.video...)
+	return append(b, f.audio...)
+}
+
+// isSplitPoint reports whether a new file can start with the tag, it is a video keyframe or any audio
+// tag if the stream has no video.
+func (f *flvHeaders) isSplitPoint(tag flv.Tag) bool {
+	if !f.header.HasVideo() {
+		return tag.Type() == flv.TagAudio && len(tag.Data()) > 0
+	}
+	return tag.IsKeyframe()
+}
@@ -0,0 +1,615 @@
+// Package recorder saves a live stream to disk, the flv streams are passed through and the hls streams are
+// saved by concatenating the segments.
+package recorder
+
+import (
+	"asmblive/internal/flv"
+	"asmblive/internal/platform/hls"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type State string
+
+const (
+	StateRecording State = "recording"
+	StateFinished  State = "finished"
+	StateFailed    State = "failed"
+)
+
+const (
+	// maxRetries is the max number of the consecutive failures before giving up.
+	maxRetries = 5
+	// progressInterval is the interval of reporting the progress.
+	progressInterval = time.Second
+)
+
+// retryDelay is the delay before re-resolving the urls, it is a variable for testing.
+var retryDelay = 3 * time.Second
+
+type Progress struct {
+	Bytes    int64
+	Duration time.Duration
+	State    State
+	// Err is the reason of StateFailed.
+	Err error
+}
+
+// Stream is an upstream url of the live stream, the relay urls of the server must not be used since the
+// format of the stream cannot be told from them.
+type Stream struct {
+	Url url.URL
+	// Header is sent with the requests of the stream and its segments.
+	Header http.Header
+	// Hls reports whether Url is an hls playlist, otherwise it is an flv stream unless the response is a
+	// playlist, since the protocol of the urls without the extension is unknown.
+	Hls bool
+}
+
+type Options struct {
+	// Resolve returns the live streams, it is called on start and whenever the stream breaks, since the urls
+	// expire.
+	Resolve func(ctx context.Context) ([]Stream, error)
+	// Path is the file path without extension, the extension is chosen by the format of the stream.
+	Path string
+	// Client is used to download the stream, http.DefaultClient is used if it is nil.
+	Client *http.Client
+	// OnProgress is called periodically and when the recording ends.
+	OnProgress func(Progress)
+	// MaxBytes and MaxDuration split the recording into files, the new file starts at a keyframe of flv
+	// streams or a segment of hls streams, 0 means no limit.
+	MaxBytes    int64
+	MaxDuration time.Duration
+}
+
+// Recorder records a stream until it ends or is stopped.
+type Recorder struct {
+	log  *slog.Logger
+	opts Options
+
+	startedAt time.Time
+	bytes     atomic.Int64
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	mtx  sync.Mutex
+	file *os.File
+	// ext is the extension of the current file, the formats are not mixed in a file.
+	ext   string
+	paths []string
+	state State
+	err   error
+	endAt time.Time
+
+	// fileBytes and fileOpenedAt are the total bytes and the time when the current file starts.
+	fileBytes    int64
+	fileOpenedAt time.Time
+
+	// flvStarted is set after the flv header of the file is written, the headers of the reconnections are
+	// skipped.
+	flvStarted bool
+	flv        flvHeaders
+	// hls is kept across the reconnections of the hls stream.
+	hls hlsState
+}
+
+// hlsState is the state of the hls stream written to the current file, so that the segments still in the
+// live window are not appended again after reconnecting.
+type hlsState struct {
+	// key is the media playlist url without the query, which changes with the signature on re-resolving.
+	key string
+	// lastSeq is the media sequence of the last written segment, it is -1 before any segment.
+	lastSeq  int64
+	initUrl  string
+	initData []byte
+}
+
+// Start resolves the urls and starts recording in background.
+func Start(log *slog.Logger, opts Options) (*Recorder, error) {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	streams, err := opts.Resolve(ctx)
+	if err == nil && len(streams) == 0 {
+		err = ErrNoStream
+	}
+	if err != nil {
+		cancel()
+		return nil, ErrStart(err)
+	}
+	r := &Recorder{
+		log:       log.With("module", "recorder"),
+		opts:      opts,
+		startedAt: time.Now(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		state:     StateRecording,
+		hls:       hlsState{lastSeq: -1},
+	}
+	go r.run(ctx, streams)
+	go r.report()
+	return r, nil
+}
+
+// Stop stops recording, it blocks until the file is closed.
+func (r *Recorder) Stop() {
+	r.cancel()
+	<-r.done
+}
+
+// Done is closed when the recording ends.
+func (r *Recorder) Done() <-chan struct{} {
+	return r.done
+}
+
+// Path returns the path of the current file, it is empty before any data is received.
+func (r *Recorder) Path() string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if len(r.paths) == 0 {
+		return ""
+	}
+	return r.paths[len(r.paths)-1]
+}
+
+// Paths returns the paths of all files, there are more than one if the recording is split.
+func (r *Recorder) Paths() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]string(nil), r.paths...)
+}
+
+func (r *Recorder) StartedAt() time.Time {
+	return r.startedAt
+}
+
+func (r *Recorder) Progress() Progress {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	end := r.endAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return Progress{
+		Bytes:    r.bytes.Load(),
+		Duration: end.Sub(r.startedAt),
+		State:    r.state,
+		Err:      r.err,
+	}
+}
+
+func (r *Recorder) report() {
+	t := time.NewTicker(progressInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-t.C:
+			if r.opts.OnProgress != nil {
+				r.opts.OnProgress(r.Progress())
+			}
+		}
+	}
+}
+
+func (r *Recorder) run(ctx context.Context, streams []Stream) {
+	err := r.record(ctx, streams)
+	r.mtx.Lock()
+	if r.file != nil {
+		if cErr := r.file.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	r.endAt = time.Now()
+	r.state = StateFinished
+	if err != nil {
+		r.state = StateFailed
+		r.err = ErrRecord(err)
+		r.log.Error("recording failed", "paths", r.paths, "err", err)
+	} else {
+		r.log.Info("recording finished", "paths", r.paths, "bytes", r.bytes.Load())
+	}
+	r.mtx.Unlock()
+	// the final progress is reported before Stop returns
+	if r.opts.OnProgress != nil {
+		r.opts.OnProgress(r.Progress())
+	}
+	close(r.done)
+}
+
+// record downloads the first working stream, the streams are re-resolved when the stream breaks, it returns
+// nil when the stream ends or the recording is stopped.
+func (r *Recorder) record(ctx context.Context, streams []Stream) error {
+	failures := 0
+	for {
+		before := r.bytes.Load()
+		var err error
+		for _, s := range streams {
+			if s.Hls {
+				err = r.downloadHls(ctx, s)
+			} else {
+				err = r.downloadFlv(ctx, s)
+			}
+			if err == nil || ctx.Err() != nil {
+				return nil
+			}
+			r.log.Warn("stream broken", "host", s.Url.Host, "err", err)
+			if r.bytes.Load() > before {
+				// the url has worked, it is probably expired
+				break
+			}
+		}
+		if r.bytes.Load() > before {
+			failures = 0
+		} else {
+			failures++
+		}
+		if failures >= maxRetries {
+			if r.bytes.Load() > 0 {
+				// the stream has ended
+				return nil
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retryDelay):
+		}
+		ns, rErr := r.opts.Resolve(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if rErr == nil && len(ns) == 0 {
+			// the room is offline
+			return nil
+		}
+		if rErr != nil {
+			r.log.Warn("failed to re-resolve urls", "err", rErr)
+			continue
+		}
+		streams = ns
+	}
+}
+
+// downloadFlv returns an error if the stream breaks, a live flv stream never ends normally. The stream is
+// downloaded by downloadHls if the response is a playlist.
+func (r *Recorder) downloadFlv(ctx context.Context, s Stream) error {
+	res, err := r.get(ctx, s.Url, s.Header)
+	if err != nil {
+		return errDownload(err)
+	}
+	body := bufio.NewReader(res.Body)
+	if isPlaylist(res.Header.Get("Content-Type"), body) {
+		r.close(res.Body)
+		return r.downloadHls(ctx, s)
+	}
+	defer r.close(res.Body)
+	header, err := flv.ReadHeader(body)
+	if err != nil {
+		return errDownload(err)
+	}
+	if r.ext != ".flv" {
+		// the flv stream is not appended to the file of an hls stream
+		if err := r.closeFile(); err != nil {
+			return err
+		}
+	}
+	w, err := r.writer(".flv")
+	if err != nil {
+		return err
+	}
+	// the header of the reconnection is skipped
+	if !r.flvStarted {
+		r.flv = flvHeaders{header: header}
+		if _, err := w.Write(r.flv.header); err != nil {
+			return errDownload(err)
+		}
+		r.excludeHeaders()
+		r.flvStarted = true
+	}
+	for {
+		tag, err := flv.ReadTag(body)
+		if errors.Is(err, io.EOF) {
+			// a live stream never ends normally
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return errDownload(err)
+		}
+		if r.flv.isSplitPoint(tag) {
+			// the first file is not split before its first keyframe
+			if r.flv.keyframed && r.needSplit() {
+				if w, err = r.split(".flv", r.flv.bytes()); err != nil {
+					return err
+				}
+			}
+			r.flv.keyframed = true
+		}
+		r.flv.keep(tag)
+		if _, err := w.Write(tag); err != nil {
+			return errDownload(err)
+		}
+	}
+}
+
+// downloadHls polls the playlist and appends the new segments, it returns nil when the playlist ends. The
+// segments of the same playlist are appended to the current file after reconnecting, another playlist or
+// format starts a new file since its media sequences are not comparable.
+func (r *Recorder) downloadHls(ctx context.Context, s Stream) error {
+	u := s.Url
+	for {
+		mp, pu, err := r.getMediaPlaylist(ctx, u, s.Header)
+		if err != nil {
+			return errDownload(err)
+		}
+		u = pu
+		ext := ".ts"
+		if mp.InitUrl != nil {
+			ext = ".mp4"
+		}
+		key := pu.Scheme + "://" + pu.Host + pu.Path
+		if key != r.hls.key || ext != r.ext {
+			if err := r.closeFile(); err != nil {
+				return err
+			}
+			r.hls.key = key
+		}
+		if mp.InitUrl != nil && mp.InitUrl.String() != r.hls.initUrl {
+			r.hls.initData, err = r.getInit(ctx, *mp.InitUrl, s.Header, ext)
+			if err != nil {
+				return errDownload(err)
+			}
+			r.hls.initUrl = mp.InitUrl.String()
+		}
+		if n := len(mp.Segments); n > 0 && mp.Segments[n-1].Seq < r.hls.lastSeq {
+			// the media sequence is restarted
+			r.hls.lastSeq = mp.Segments[0].Seq - 1
+		}
+		for _, seg := range mp.Segments {
+			if seg.Seq <= r.hls.lastSeq {
+				continue
+			}
+			if r.needSplit() {
+				// the init segment is required by every fmp4 file
+				if _, err := r.split(ext, r.hls.initData); err != nil {
+					return err
+				}
+			}
+			if err := r.downloadSegment(ctx, seg.Url, s.Header, ext); err != nil {
+				return errDownload(err)
+			}
+			r.hls.lastSeq = seg.Seq
+		}
+		if mp.Ended {
+			return nil
+		}
+		wait := mp.TargetDuration / 2
+		if wait <= 0 {
+			wait = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// isPlaylist reports whether the response is an hls playlist by its content type or the beginning of its
+// body.
+func isPlaylist(contentType string, body *bufio.Reader) bool {
+	if strings.Contains(strings.ToLower(contentType), "mpegurl") {
+		return true
+	}
+	b, _ := body.Peek(len("#EXTM3U"))
+	return string(b) == "#EXTM3U"
+}
+
+// getMediaPlaylist follows the first variant if u is a master playlist.
+func (r *Recorder) getMediaPlaylist(ctx context.Context, u url.URL, h http.Header) (hls.MediaPlaylist, url.URL, error) {
+	for i := 0; i < 2; i++ {
+		res, err := r.get(ctx, u, h)
+		if err != nil {
+			return hls.MediaPlaylist{}, u, err
+		}
+		body, err := io.ReadAll(io.LimitReader(res.Body, 4*1024*1024))
+		r.close(res.Body)
+		if err != nil {
+			return hls.MediaPlaylist{}, u, err
+		}
+		base := *res.Request.URL
+		vs, err := hls.ParseMaster(bytes.NewReader(body), base)
+		if err != nil {
+			return hls.MediaPlaylist{}, u, err
+		}
+		if len(vs) > 0 {
+			u = vs[0].Url
+			continue
+		}
+		mp, err := hls.ParseMedia(bytes.NewReader(body), base)
+		return mp, u, err
+	}
+	return hls.MediaPlaylist{}, u, errors.New("too many nested playlists")
+}
+
+// getInit writes the init segment and returns it.
+func (r *Recorder) getInit(ctx context.Context, u url.URL, h http.Header, ext string) ([]byte, error) {
+	res, err := r.get(ctx, u, h)
+	if err != nil {
+		return nil, err
+	}
+	defer r.close(res.Body)
+	data, err := io.ReadAll(io.LimitReader(res.Body, 4*1024*1024))
+	if err != nil {
+		return nil, err
+	}
+	w, err := r.writer(ext)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	r.excludeHeaders()
+	return data, nil
+}
+
+func (r *Recorder) downloadSegment(ctx context.Context, u url.URL, h http.Header, ext string) error {
+	res, err := r.get(ctx, u, h)
+	if err != nil {
+		return err
+	}
+	defer r.close(res.Body)
+	w, err := r.writer(ext)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
+func (r *Recorder) get(ctx context.Context, u url.URL, h http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range h {
+		req.Header[k] = vs
+	}
+	res, err := r.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		r.close(res.Body)
+		return nil, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+	return res, nil
+}
+
+func (r *Recorder) close(c io.Closer) {
+	if err := c.Close(); err != nil {
+		r.log.Warn("failed to close response body", "err", err)
+	}
+}
+
+// writer opens a file with the extension if no file is open, the file is written until it is closed by
+// split or closeFile.
+func (r *Recorder) writer(ext string) (io.Writer, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.file == nil {
+		if err := os.MkdirAll(filepath.Dir(r.opts.Path), 0755); err != nil {
+			return nil, err
+		}
+		f, p, err := createFile(r.opts.Path, ext)
+		if err != nil {
+			return nil, err
+		}
+		r.file = f
+		r.ext = ext
+		r.paths = append(r.paths, p)
+		r.fileBytes = r.bytes.Load()
+		r.fileOpenedAt = time.Now()
+		r.log.Info("recording file created", "path", p)
+	}
+	return countingWriter{w: r.file, n: &r.bytes}, nil
+}
+
+// needSplit reports whether the current file reaches MaxBytes or MaxDuration.
+func (r *Recorder) needSplit() bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.file == nil || r.bytes.Load() == r.fileBytes {
+		return false
+	}
+	return r.opts.MaxBytes > 0 && r.bytes.Load()-r.fileBytes >= r.opts.MaxBytes ||
+		r.opts.MaxDuration > 0 && time.Since(r.fileOpenedAt) >= r.opts.MaxDuration
+}
+
+// split closes the current file and opens a new one starting with the headers.
+func (r *Recorder) split(ext string, headers []byte) (io.Writer, error) {
+	r.mtx.Lock()
+	f := r.file
+	r.file = nil
+	r.mtx.Unlock()
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	w, err := r.writer(ext)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(headers); err != nil {
+		return nil, err
+	}
+	r.excludeHeaders()
+	return w, nil
+}
+
+// closeFile closes the current file so that the next write starts a new one, the states of the streams
+// written to it are reset.
+func (r *Recorder) closeFile() error {
+	r.mtx.Lock()
+	f := r.file
+	r.file = nil
+	r.ext = ""
+	r.mtx.Unlock()
+	r.flvStarted = false
+	r.hls = hlsState{lastSeq: -1}
+	if f == nil {
+		return nil
+	}
+	return f.Close()
+}
+
+// excludeHeaders excludes the written bytes of the current file from MaxBytes, a file is never split
+// before any media data is written.
+func (r *Recorder) excludeHeaders() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.fileBytes = r.bytes.Load()
+}
+
+// createFile adds a number suffix if the file exists.
+func createFile(path string, ext string) (*os.File, string, error) {
+	p := path + ext
+	for i := 2; ; i++ {
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return f, p, nil
+		}
+		if !errors.Is(err, os.ErrExist) || i > 100 {
+			return nil, "", err
+		}
+		p = path + "-" + strconv.Itoa(i) + ext
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,353 @@
+package recorder
+
+import (
+	"asmblive/internal/flv"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	retryDelay = 10 * time.Millisecond
+}
+
+// resolver returns the stream for the first n calls, then no stream like an offline room.
+func resolver(u string, n int) func(ctx context.Context) ([]Stream, error) {
+	var mtx sync.Mutex
+	return func(ctx context.Context) ([]Stream, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if n <= 0 {
+			return []Stream{}, nil
+		}
+		n--
+		pu, err := url.Parse(u)
+		if err != nil {
+			return nil, err
+		}
+		return []Stream{{Url: *pu, Hls: strings.HasSuffix(pu.Path, ".m3u8")}}, nil
+	}
+}
+
+func TestStart(t *testing.T) {
+	flv, err := os.ReadFile("../remux/testData/sample.flv")
+	assert.NoError(t, err)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/live.flv":
+			_, _ = w.Write(flv)
+		case "/slow.flv":
+			_, _ = w.Write(flv[:13])
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		resolve func(ctx context.Context) ([]Stream, error)
+		want    []byte
+		state   State
+		wantErr string
+	}{
+		{
+			name:    "should save the flv stream until offline",
+			resolve: resolver(ts.URL+"/live.flv", 1),
+			want:    flv,
+			state:   StateFinished,
+		},
+		{
+			name:    "should skip the header of the reconnection",
+			resolve: resolver(ts.URL+"/live.flv", 2),
+			want:    append(append([]byte{}, flv...), flv[13:]...),
+			state:   StateFinished,
+		},
+		{
+			name:    "should fail since the stream is unavailable",
+			resolve: resolver(ts.URL+"/404.flv", 10),
+			state:   StateFailed,
+			wantErr: "failed to record: failed to download: unexpected status code: 404",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "a", "record")
+			var mtx sync.Mutex
+			var last Progress
+			r, err := Start(slog.Default(), Options{
+				Resolve: tt.resolve,
+				Path:    path,
+				OnProgress: func(p Progress) {
+					mtx.Lock()
+					last = p
+					mtx.Unlock()
+				},
+			})
+			assert.NoError(t, err)
+			select {
+			case <-r.Done():
+			case <-time.After(5 * time.Second):
+				t.Fatal("recording not ended")
+			}
+			p := r.Progress()
+			assert.Equal(t, tt.state, p.State)
+			if tt.wantErr != "" {
+				assert.EqualError(t, p.Err, tt.wantErr)
+				assert.Equal(t, "", r.Path())
+				return
+			}
+			assert.NoError(t, p.Err)
+			assert.Equal(t, path+".flv", r.Path())
+			got, err := os.ReadFile(r.Path())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, int64(len(tt.want)), p.Bytes)
+			mtx.Lock()
+			assert.Equal(t, StateFinished, last.State)
+			mtx.Unlock()
+		})
+	}
+
+	t.Run("should return error since offline", func(t *testing.T) {
+		_, err := Start(slog.Default(), Options{Resolve: resolver("", 0)})
+		assert.ErrorIs(t, err, ErrNoStream)
+		_, err = Start(slog.Default(), Options{Resolve: func(ctx context.Context) ([]Stream, error) {
+			return nil, errors.New("no room")
+		}})
+		assert.EqualError(t, err, "failed to start recording: no room")
+	})
+
+	t.Run("should stop recording", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "record")
+		r, err := Start(slog.Default(), Options{Resolve: resolver(ts.URL+"/slow.flv", 1), Path: path})
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool { return r.Progress().Bytes == 13 }, 5*time.Second, 10*time.Millisecond)
+		r.Stop()
+		assert.Equal(t, StateFinished, r.Progress().State)
+		got, _ := os.ReadFile(path + ".flv")
+		assert.Equal(t, flv[:13], got)
+	})
+
+	t.Run("should add a suffix since the file exists", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "record")
+		assert.NoError(t, os.WriteFile(path+".flv", []byte("old"), 0644))
+		r, err := Start(slog.Default(), Options{Resolve: resolver(ts.URL+"/live.flv", 1), Path: path})
+		assert.NoError(t, err)
+		<-r.Done()
+		assert.Equal(t, path+"-2.flv", r.Path())
+	})
+}
+
+func TestStart_hls(t *testing.T) {
+	var mtx sync.Mutex
+	polls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Referer") != "https://example.com/" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/play":
+			_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000\nlive/index.m3u8\n"))
+		case "/live/index.m3u8":
+			mtx.Lock()
+			polls++
+			p := polls
+			mtx.Unlock()
+			if p == 1 {
+				_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:0.1\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:0.1,\n0.m4s\n#EXTINF:0.1,\n1.m4s\n"))
+				return
+			}
+			_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:0.1\n#EXT-X-MEDIA-SEQUENCE:1\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:0.1,\n1.m4s\n#EXTINF:0.1,\n2.m4s\n#EXT-X-ENDLIST\n"))
+		default:
+			_, _ = fmt.Fprintf(w, "[%s]", filepath.Base(r.URL.Path))
+		}
+	}))
+	defer ts.Close()
+	path := filepath.Join(t.TempDir(), "record")
+	// the format is not told by the url, and the header is required by all requests
+	u, _ := url.Parse(ts.URL + "/play")
+	s := Stream{Url: *u, Header: http.Header{"Referer": {"https://example.com/"}}, Hls: true}
+	r, err := Start(slog.Default(), Options{
+		Resolve: func(ctx context.Context) ([]Stream, error) { return []Stream{s}, nil },
+		Path:    path,
+	})
+	assert.NoError(t, err)
+	select {
+	case <-r.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording not ended")
+	}
+	assert.Equal(t, StateFinished, r.Progress().State)
+	assert.Equal(t, path+".mp4", r.Path())
+	got, _ := os.ReadFile(r.Path())
+	assert.Equal(t, "[init.mp4][0.m4s][1.m4s][2.m4s]", string(got))
+}
+
+func TestStart_split(t *testing.T) {
+	sample, err := os.ReadFile("../remux/testData/sample.flv")
+	assert.NoError(t, err)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/live.flv":
+			_, _ = w.Write(sample)
+		case "/index.m3u8":
+			_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:1\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:1,\n0.m4s\n#EXTINF:1,\n1.m4s\n#EXT-X-ENDLIST\n"))
+		default:
+			_, _ = fmt.Fprintf(w, "[%s]", filepath.Base(r.URL.Path))
+		}
+	}))
+	defer ts.Close()
+
+	t.Run("should split the flv stream at the keyframes", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "record")
+		r, err := Start(slog.Default(), Options{Resolve: resolver(ts.URL+"/live.flv", 1), Path: path, MaxBytes: 1})
+		assert.NoError(t, err)
+		<-r.Done()
+		assert.Equal(t, StateFinished, r.Progress().State)
+		// the sample has a keyframe every second
+		assert.Equal(t, []string{path + ".flv", path + "-2.flv", path + "-3.flv"}, r.Paths())
+
+		hs := flvHeaders{header: sample[:13]}
+		body := bytes.NewReader(sample[13:])
+		for body.Len() > 0 {
+			tag, err := flv.ReadTag(body)
+			assert.NoError(t, err)
+			hs.keep(tag)
+		}
+		var joined []byte
+		for i, p := range r.Paths() {
+			got, err := os.ReadFile(p)
+			assert.NoError(t, err)
+			if i > 0 {
+				assert.True(t, bytes.HasPrefix(got, hs.bytes()))
+				got = got[len(hs.bytes()):]
+			}
+			joined = append(joined, got...)
+		}
+		assert.Equal(t, sample, joined)
+	})
+
+	t.Run("should split the hls stream with the init segment", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "record")
+		r, err := Start(slog.Default(), Options{Resolve: resolver(ts.URL+"/index.m3u8", 1), Path: path, MaxBytes: 1})
+		assert.NoError(t, err)
+		<-r.Done()
+		assert.Equal(t, []string{path + ".mp4", path + "-2.mp4"}, r.Paths())
+		got, _ := os.ReadFile(path + ".mp4")
+		assert.Equal(t, "[init.mp4][0.m4s]", string(got))
+		got, _ = os.ReadFile(path + "-2.mp4")
+		assert.Equal(t, "[init.mp4][1.m4s]", string(got))
+	})
+}
+
+func TestStart_reconnect(t *testing.T) {
+	sample, err := os.ReadFile("../remux/testData/sample.flv")
+	assert.NoError(t, err)
+	const (
+		live  = "#EXTM3U\n#EXT-X-TARGETDURATION:0.1\n#EXT-X-MEDIA-SEQUENCE:0\n#EXTINF:0.1,\na0.ts\n#EXTINF:0.1,\na1.ts\n#EXTINF:0.1,\na2.ts\n"
+		ended = "#EXTM3U\n#EXT-X-TARGETDURATION:0.1\n#EXT-X-MEDIA-SEQUENCE:1\n#EXTINF:0.1,\na1.ts\n#EXTINF:0.1,\na2.ts\n#EXTINF:0.1,\na3.ts\n#EXT-X-ENDLIST\n"
+		other = "#EXTM3U\n#EXT-X-TARGETDURATION:0.1\n#EXTINF:0.1,\nc0.ts\n#EXT-X-ENDLIST\n"
+	)
+	// record serves the successive responses of the playlists keyed by the request uri, a playlist is not
+	// found after its responses, and records the streams of the uris in turn until offline.
+	record := func(t *testing.T, playlists map[string][]string, uris ...string) (string, *Recorder) {
+		var mtx sync.Mutex
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			switch {
+			case r.URL.Path == "/live.flv":
+				_, _ = w.Write(sample)
+			case playlists[r.RequestURI] != nil:
+				ps := playlists[r.RequestURI]
+				if len(ps) == 0 {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write([]byte(ps[0]))
+				playlists[r.RequestURI] = ps[1:]
+			default:
+				_, _ = fmt.Fprintf(w, "[%s]", filepath.Base(r.URL.Path))
+			}
+		}))
+		t.Cleanup(ts.Close)
+		resolve := func(ctx context.Context) ([]Stream, error) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			if len(uris) == 0 {
+				return []Stream{}, nil
+			}
+			pu, _ := url.Parse(ts.URL + uris[0])
+			uris = uris[1:]
+			return []Stream{{Url: *pu, Hls: strings.HasSuffix(pu.Path, ".m3u8")}}, nil
+		}
+		path := filepath.Join(t.TempDir(), "record")
+		r, err := Start(slog.Default(), Options{Resolve: resolve, Path: path})
+		assert.NoError(t, err)
+		select {
+		case <-r.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("recording not ended")
+		}
+		assert.Equal(t, StateFinished, r.Progress().State)
+		return path, r
+	}
+
+	t.Run("should not append the segments again after reconnecting", func(t *testing.T) {
+		// the signature of the playlist changes on re-resolving
+		path, r := record(t, map[string][]string{
+			"/a/index.m3u8?sign=1": {live},
+			"/a/index.m3u8?sign=2": {ended},
+		}, "/a/index.m3u8?sign=1", "/a/index.m3u8?sign=2")
+		assert.Equal(t, []string{path + ".ts"}, r.Paths())
+		got, _ := os.ReadFile(path + ".ts")
+		assert.Equal(t, "[a0.ts][a1.ts][a2.ts][a3.ts]", string(got))
+	})
+
+	t.Run("should start a new file for another playlist", func(t *testing.T) {
+		path, r := record(t, map[string][]string{
+			"/a/index.m3u8": {live},
+			"/c/index.m3u8": {other},
+		}, "/a/index.m3u8", "/c/index.m3u8")
+		assert.Equal(t, []string{path + ".ts", path + "-2.ts"}, r.Paths())
+		got, _ := os.ReadFile(path + ".ts")
+		assert.Equal(t, "[a0.ts][a1.ts][a2.ts]", string(got))
+		got, _ = os.ReadFile(path + "-2.ts")
+		assert.Equal(t, "[c0.ts]", string(got))
+	})
+
+	t.Run("should start a new file since the format changes", func(t *testing.T) {
+		path, r := record(t, map[string][]string{
+			"/c/index.m3u8": {other},
+		}, "/live.flv", "/c/index.m3u8")
+		assert.Equal(t, []string{path + ".flv", path + ".ts"}, r.Paths())
+		got, _ := os.ReadFile(path + ".flv")
+		assert.Equal(t, sample, got)
+		got, _ = os.ReadFile(path + ".ts")
+		assert.Equal(t, "[c0.ts]", string(got))
+	})
+	t.Run("should record the playlist without the extension as hls", func(t *testing.T) {
+		// the playlist is requested again after it is told by the response
+		path, r := record(t, map[string][]string{"/play?id=1": {other, other}}, "/play?id=1")
+		assert.Equal(t, []string{path + ".ts"}, r.Paths())
+		got, _ := os.ReadFile(path + ".ts")
+		assert.Equal(t, "[c0.ts]", string(got))
+	})
+}
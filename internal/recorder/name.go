@@ -0,0 +1,45 @@
+package recorder
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxNameLength is the max number of the runes of a file name without extension.
+const maxNameLength = 180
+
+// NameFields are the values of the file name template.
+type NameFields struct {
+	Platform string
+	RoomId   string
+	Owner    string
+	Title    string
+	Start    time.Time
+}
+
+// FileName renders the template with the fields `{platform}`, `{room}`, `{owner}`, `{title}` and `{start}`,
+// the characters which are invalid in file names are replaced with `_`.
+func FileName(template string, f NameFields) string {
+	r := strings.NewReplacer(
+		"{platform}", f.Platform,
+		"{room}", f.RoomId,
+		"{owner}", f.Owner,
+		"{title}", f.Title,
+		"{start}", f.Start.Format("20060102-150405"),
+	)
+	name := strings.Map(func(c rune) rune {
+		if unicode.IsControl(c) || strings.ContainsRune(`/\:*?"<>|`, c) {
+			return '_'
+		}
+		return c
+	}, r.Replace(template))
+	if rs := []rune(name); len(rs) > maxNameLength {
+		name = string(rs[:maxNameLength])
+	}
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return f.Start.Format("20060102-150405")
+	}
+	return name
+}
@@ -0,0 +1,50 @@
+package recorder
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileName(t *testing.T) {
+	f := NameFields{
+		Platform: "bili",
+		RoomId:   "21452505",
+		Owner:    "七海Nana7mi",
+		Title:    "鸣潮 / 原神: 今天玩什么?",
+		Start:    time.Date(2024, 7, 24, 12, 3, 4, 0, time.Local),
+	}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			name:     "should render all fields",
+			template: "{platform}-{room}-{owner}-{title}-{start}",
+			want:     "bili-21452505-七海Nana7mi-鸣潮 _ 原神_ 今天玩什么_-20240724-120304",
+		},
+		{
+			name:     "should not allow directories",
+			template: "../{owner}",
+			want:     "_七海Nana7mi",
+		},
+		{
+			name:     "should fallback to start time since empty",
+			template: " . ",
+			want:     "20240724-120304",
+		},
+		{
+			name:     "should limit the length",
+			template: strings.Repeat("a", 300),
+			want:     strings.Repeat("a", maxNameLength),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FileName(tt.template, f))
+		})
+	}
+}
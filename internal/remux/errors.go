@@ -0,0 +1,15 @@
+package remux
+
+import "fmt"
+
+func ErrDemux(err error) error {
+	return fmt.Errorf("failed to demux flv: %w", err)
+}
+
+func errParseVideo(err error) error {
+	return fmt.Errorf("failed to parse video tag: %w", err)
+}
+
+func errParseAudio(err error) error {
+	return fmt.Errorf("failed to parse audio tag: %w", err)
+}
@@ -0,0 +1,265 @@
+package remux
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+type videoCodec int
+
+const (
+	codecUnknown videoCodec = iota
+	codecAvc
+	codecHevc
+)
+
+// videoPacket is a parsed video tag, either a sequence header with the parameter sets or a frame.
+type videoPacket struct {
+	Codec    videoCodec
+	Keyframe bool
+	// Config is the decoder configuration record of the sequence header.
+	Config []byte
+	// Cts is the composition time offset in milliseconds.
+	Cts int32
+	// Data is the length prefixed nalus of the frame.
+	Data []byte
+}
+
+// parseVideo supports the legacy avc tags, the hevc tags with codec id 12 used by the chinese platforms,
+// and the enhanced rtmp tags with avc1 and hvc1 fourcc.
+func parseVideo(data []byte) (videoPacket, bool, error) {
+	if len(data) < 1 {
+		return videoPacket{}, false, errParseVideo(errors.New("empty tag"))
+	}
+	var p videoPacket
+	if data[0]&0x80 != 0 {
+		// enhanced rtmp
+		if len(data) < 5 {
+			return p, false, errParseVideo(errors.New("tag is too short"))
+		}
+		p.Keyframe = (data[0]>>4)&0x07 == 1
+		pt := data[0] & 0x0f
+		switch string(data[1:5]) {
+		case "avc1":
+			p.Codec = codecAvc
+		case "hvc1":
+			p.Codec = codecHevc
+		default:
+			return p, false, nil
+		}
+		body := data[5:]
+		switch pt {
+		case 0:
+			p.Config = body
+			return p, true, nil
+		case 1:
+			if len(body) < 3 {
+				return p, false, errParseVideo(errors.New("tag is too short"))
+			}
+			p.Cts = si24(body)
+			p.Data = body[3:]
+			return p, true, nil
+		case 3:
+			p.Data = body
+			return p, true, nil
+		default:
+			// sequence end, metadata, etc.
+			return p, false, nil
+		}
+	}
+	p.Keyframe = data[0]>>4 == 1
+	switch data[0] & 0x0f {
+	case 7:
+		p.Codec = codecAvc
+	case 12:
+		p.Codec = codecHevc
+	default:
+		return p, false, nil
+	}
+	if len(data) < 5 {
+		return p, false, errParseVideo(errors.New("tag is too short"))
+	}
+	switch data[1] {
+	case 0:
+		p.Config = data[5:]
+	case 1:
+		p.Cts = si24(data[2:5])
+		p.Data = data[5:]
+	default:
+		return p, false, nil
+	}
+	return p, true, nil
+}
+
+func si24(b []byte) int32 {
+	v := int32(b[0])<<16 | int32(b[1])<<8 | int32(b[2])
+	if v&0x800000 != 0 {
+		v -= 0x1000000
+	}
+	return v
+}
+
+// videoConfig is the decoder configuration of the video.
+type videoConfig struct {
+	Codec videoCodec
+	// LengthSize is the size of the nalu length prefix.
+	LengthSize int
+	// ParamSets are the vps, sps and pps nalus, inserted before every keyframe.
+	ParamSets [][]byte
+}
+
+func parseVideoConfig(codec videoCodec, rec []byte) (videoConfig, error) {
+	c := videoConfig{Codec: codec}
+	switch codec {
+	case codecAvc:
+		// AVCDecoderConfigurationRecord
+		if len(rec) < 7 {
+			return c, errParseVideo(errors.New("avc config is too short"))
+		}
+		c.LengthSize = int(rec[4]&0x03) + 1
+		pos := 5
+		for _, mask := range []byte{0x1f, 0xff} {
+			if pos >= len(rec) {
+				return c, errParseVideo(errors.New("avc config is too short"))
+			}
+			n := int(rec[pos] & mask)
+			pos++
+			for i := 0; i < n; i++ {
+				ps, next, err := readParamSet(rec, pos)
+				if err != nil {
+					return c, errParseVideo(err)
+				}
+				c.ParamSets = append(c.ParamSets, ps)
+				pos = next
+			}
+		}
+	case codecHevc:
+		// HEVCDecoderConfigurationRecord
+		if len(rec) < 23 {
+			return c, errParseVideo(errors.New("hevc config is too short"))
+		}
+		c.LengthSize = int(rec[21]&0x03) + 1
+		arrays := int(rec[22])
+		pos := 23
+		for i := 0; i < arrays; i++ {
+			if pos+3 > len(rec) {
+				return c, errParseVideo(errors.New("hevc config is too short"))
+			}
+			n := int(binary.BigEndian.Uint16(rec[pos+1:]))
+			pos += 3
+			for j := 0; j < n; j++ {
+				ps, next, err := readParamSet(rec, pos)
+				if err != nil {
+					return c, errParseVideo(err)
+				}
+				c.ParamSets = append(c.ParamSets, ps)
+				pos = next
+			}
+		}
+	default:
+		return c, errParseVideo(errors.New("unsupported codec"))
+	}
+	return c, nil
+}
+
+func readParamSet(rec []byte, pos int) ([]byte, int, error) {
+	if pos+2 > len(rec) {
+		return nil, 0, errors.New("parameter set is truncated")
+	}
+	n := int(binary.BigEndian.Uint16(rec[pos:]))
+	pos += 2
+	if pos+n > len(rec) {
+		return nil, 0, errors.New("parameter set is truncated")
+	}
+	return rec[pos : pos+n], pos + n, nil
+}
+
+// splitNalus splits the length prefixed nalus.
+func splitNalus(data []byte, lengthSize int) ([][]byte, error) {
+	nalus := make([][]byte, 0, 4)
+	for len(data) > 0 {
+		if len(data) < lengthSize {
+			return nil, errors.New("nalu length is truncated")
+		}
+		n := 0
+		for _, b := range data[:lengthSize] {
+			n = n<<8 | int(b)
+		}
+		data = data[lengthSize:]
+		if n > len(data) {
+			return nil, errors.New("nalu is truncated")
+		}
+		nalus = append(nalus, data[:n])
+		data = data[n:]
+	}
+	return nalus, nil
+}
+
+// audioPacket is a parsed aac tag.
+type audioPacket struct {
+	// Config is the AudioSpecificConfig of the sequence header.
+	Config []byte
+	// Data is a raw aac frame.
+	Data []byte
+}
+
+// parseAudio returns false for the codecs other than aac.
+func parseAudio(data []byte) (audioPacket, bool, error) {
+	if len(data) < 2 {
+		return audioPacket{}, false, errParseAudio(errors.New("tag is too short"))
+	}
+	if data[0]>>4 != 10 {
+		return audioPacket{}, false, nil
+	}
+	if data[1] == 0 {
+		return audioPacket{Config: data[2:]}, true, nil
+	}
+	return audioPacket{Data: data[2:]}, true, nil
+}
+
+// audioConfig is used to build the adts headers.
+type audioConfig struct {
+	ObjectType  uint8
+	FreqIndex   uint8
+	ChannelConf uint8
+}
+
+func parseAudioConfig(asc []byte) (audioConfig, error) {
+	if len(asc) < 2 {
+		return audioConfig{}, errParseAudio(errors.New("aac config is too short"))
+	}
+	return audioConfig{
+		ObjectType:  asc[0] >> 3,
+		FreqIndex:   (asc[0]&0x07)<<1 | asc[1]>>7,
+		ChannelConf: (asc[1] >> 3) & 0x0f,
+	}, nil
+}
+
+var sampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// sampleRate returns 0 if the index is not a standard one.
+func (c audioConfig) sampleRate() int {
+	if int(c.FreqIndex) >= len(sampleRates) {
+		return 0
+	}
+	return sampleRates[c.FreqIndex]
+}
+
+// adts returns the raw frame with the adts header.
+func (c audioConfig) adts(raw []byte) []byte {
+	n := len(raw) + 7
+	profile := c.ObjectType
+	if profile > 0 {
+		profile--
+	}
+	h := []byte{
+		0xff,
+		0xf1,
+		profile<<6 | (c.FreqIndex&0x0f)<<2 | (c.ChannelConf>>2)&0x01,
+		(c.ChannelConf&0x03)<<6 | byte(n>>11)&0x03,
+		byte(n >> 3),
+		byte(n&0x07)<<5 | 0x1f,
+		0xfc,
+	}
+	return append(h, raw...)
+}
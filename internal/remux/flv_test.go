@@ -0,0 +1,91 @@
+package remux
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseVideo(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		want   videoPacket
+		wantOk bool
+	}{
+		{
+			name:   "should parse avc sequence header",
+			data:   []byte{0x17, 0x00, 0x00, 0x00, 0x00, 0x01},
+			want:   videoPacket{Codec: codecAvc, Keyframe: true, Config: []byte{0x01}},
+			wantOk: true,
+		},
+		{
+			name:   "should parse hevc frame with negative composition time",
+			data:   []byte{0x2c, 0x01, 0xff, 0xff, 0xd8, 0x00},
+			want:   videoPacket{Codec: codecHevc, Cts: -40, Data: []byte{0x00}},
+			wantOk: true,
+		},
+		{
+			name:   "should parse enhanced hevc frame without composition time",
+			data:   []byte{0x93, 'h', 'v', 'c', '1', 0x00},
+			want:   videoPacket{Codec: codecHevc, Keyframe: true, Data: []byte{0x00}},
+			wantOk: true,
+		},
+		{
+			name: "should skip other codecs",
+			data: []byte{0x12, 0x00},
+			want: videoPacket{Keyframe: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := parseVideo(tt.data)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_parseVideoConfig(t *testing.T) {
+	t.Run("should parse avc parameter sets", func(t *testing.T) {
+		rec := []byte{0x01, 0x42, 0xc0, 0x1e, 0xff, 0xe1, 0x00, 0x02, 0x67, 0x42, 0x01, 0x00, 0x01, 0x68}
+		got, err := parseVideoConfig(codecAvc, rec)
+		assert.NoError(t, err)
+		assert.Equal(t, videoConfig{Codec: codecAvc, LengthSize: 4, ParamSets: [][]byte{{0x67, 0x42}, {0x68}}}, got)
+	})
+
+	t.Run("should parse hevc parameter sets", func(t *testing.T) {
+		rec := make([]byte, 23)
+		rec[21] = 0x0f
+		rec[22] = 2
+		rec = append(rec, 0xa0, 0x00, 0x01, 0x00, 0x01, 0x40)
+		rec = append(rec, 0xa1, 0x00, 0x01, 0x00, 0x02, 0x42, 0x01)
+		got, err := parseVideoConfig(codecHevc, rec)
+		assert.NoError(t, err)
+		assert.Equal(t, videoConfig{Codec: codecHevc, LengthSize: 4, ParamSets: [][]byte{{0x40}, {0x42, 0x01}}}, got)
+	})
+
+	t.Run("should return error for truncated config", func(t *testing.T) {
+		_, err := parseVideoConfig(codecAvc, []byte{0x01, 0x42, 0xc0, 0x1e, 0xff, 0xe1, 0x00, 0x09, 0x67})
+		assert.EqualError(t, err, "failed to parse video tag: parameter set is truncated")
+	})
+}
+
+func Test_splitNalus(t *testing.T) {
+	got, err := splitNalus([]byte{0x00, 0x00, 0x00, 0x01, 0x65, 0x00, 0x00, 0x00, 0x02, 0x41, 0x9a}, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{{0x65}, {0x41, 0x9a}}, got)
+
+	_, err = splitNalus([]byte{0x00, 0x00, 0x00, 0x05, 0x65}, 4)
+	assert.EqualError(t, err, "nalu is truncated")
+}
+
+func Test_audioConfig_adts(t *testing.T) {
+	ac, err := parseAudioConfig([]byte{0x12, 0x10})
+	assert.NoError(t, err)
+	assert.Equal(t, audioConfig{ObjectType: 2, FreqIndex: 4, ChannelConf: 2}, ac)
+	assert.Equal(t, 44100, ac.sampleRate())
+	got := ac.adts([]byte{0x21, 0x00})
+	assert.Equal(t, []byte{0xff, 0xf1, 0x50, 0x80, 0x01, 0x3f, 0xfc, 0x21, 0x00}, got)
+}
@@ -0,0 +1,336 @@
+// Package remux converts a live flv stream into a rolling hls playlist of mpeg-ts segments, so that the
+// flv only rooms can be played by hls.js.
+package remux
+
+import (
+	"asmblive/internal/flv"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTargetDuration = 2 * time.Second
+	DefaultWindowSize     = 6
+)
+
+// tsOffset is added to the timestamps in milliseconds, so that the pts of the b-frames are positive.
+const tsOffset = 1000
+
+type Options struct {
+	// TargetDuration is the min duration of a segment, the segments are cut at the keyframes.
+	TargetDuration time.Duration
+	// WindowSize is the number of the segments in the playlist.
+	WindowSize int
+}
+
+type segment struct {
+	seq      int
+	duration time.Duration
+	data     []byte
+}
+
+// Remuxer keeps the latest segments in memory, it is safe to read the playlist and the segments while running.
+type Remuxer struct {
+	log  *slog.Logger
+	opts Options
+
+	mtx       sync.RWMutex
+	segments  []segment
+	ended     bool
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	// the states below are only accessed by Run
+	vc       *videoConfig
+	ac       *audioConfig
+	hasVideo bool
+	mux      *tsMuxer
+	cur      *bytes.Buffer
+	curStart int64
+	lastTs   int64
+	baseTs   int64
+	based    bool
+	seq      int
+}
+
+func New(log *slog.Logger, opts Options) *Remuxer {
+	if opts.TargetDuration <= 0 {
+		opts.TargetDuration = DefaultTargetDuration
+	}
+	if opts.WindowSize <= 0 {
+		opts.WindowSize = DefaultWindowSize
+	}
+	return &Remuxer{
+		log:   log.With("module", "remux"),
+		opts:  opts,
+		ready: make(chan struct{}),
+		mux:   newTsMuxer(0, false),
+	}
+}
+
+// Ready is closed when the first segment is available or the stream ends.
+func (r *Remuxer) Ready() <-chan struct{} {
+	return r.ready
+}
+
+// End ends the playlist, it is called by Run, or instead of Run if the stream cannot be opened.
+func (r *Remuxer) End() {
+	r.mtx.Lock()
+	r.ended = true
+	r.mtx.Unlock()
+	r.readyOnce.Do(func() { close(r.ready) })
+}
+
+// Run remuxes the flv stream until it ends, the playlist is ended when Run returns.
+func (r *Remuxer) Run(src io.Reader) error {
+	defer func() {
+		r.finishSegment()
+		r.End()
+	}()
+	if _, err := flv.ReadHeader(src); err != nil {
+		return ErrDemux(err)
+	}
+	for {
+		t, err := flv.ReadTag(src)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return ErrDemux(err)
+		}
+		switch t.Type() {
+		case flv.TagVideo:
+			err = r.handleVideo(t)
+		case flv.TagAudio:
+			err = r.handleAudio(t)
+		}
+		if err != nil {
+			return ErrDemux(err)
+		}
+	}
+}
+
+func (r *Remuxer) handleVideo(t flv.Tag) error {
+	p, ok, err := parseVideo(t.Data())
+	if err != nil || !ok {
+		return err
+	}
+	r.hasVideo = true
+	if p.Config != nil {
+		vc, err := parseVideoConfig(p.Codec, p.Config)
+		if err != nil {
+			return err
+		}
+		r.vc = &vc
+		return nil
+	}
+	if r.vc == nil || r.vc.Codec != p.Codec {
+		return nil
+	}
+	ts := r.timestamp(t.Timestamp())
+	if p.Keyframe {
+		r.cut(ts)
+	}
+	if r.cur == nil {
+		// wait for the first keyframe
+		return nil
+	}
+	nalus, err := splitNalus(p.Data, r.vc.LengthSize)
+	if err != nil {
+		return errParseVideo(err)
+	}
+	dts := uint64(ts) * 90
+	pts := uint64(max(ts+int64(p.Cts), 0)) * 90
+	r.mux.writePes(r.cur, pidVideo, streamIdVideo, pts, dts, true, annexB(r.vc, nalus, p.Keyframe))
+	r.lastTs = ts
+	return nil
+}
+
+func (r *Remuxer) handleAudio(t flv.Tag) error {
+	p, ok, err := parseAudio(t.Data())
+	if err != nil || !ok {
+		return err
+	}
+	if p.Config != nil {
+		ac, err := parseAudioConfig(p.Config)
+		if err != nil {
+			return err
+		}
+		r.ac = &ac
+		return nil
+	}
+	if r.ac == nil {
+		return nil
+	}
+	ts := r.timestamp(t.Timestamp())
+	if !r.hasVideo {
+		r.cut(ts)
+	}
+	if r.cur == nil {
+		return nil
+	}
+	dts := uint64(ts) * 90
+	r.mux.writePes(r.cur, pidAudio, streamIdAudio, dts, dts, !r.hasVideo, r.ac.adts(p.Data))
+	r.lastTs = max(r.lastTs, ts)
+	return nil
+}
+
+// timestamp returns the timestamp relative to the first tag.
+func (r *Remuxer) timestamp(ts uint32) int64 {
+	if !r.based {
+		r.baseTs = int64(ts)
+		r.based = true
+	}
+	return max(int64(ts)-r.baseTs+tsOffset, 0)
+}
+
+// cut starts a new segment if the current one is long enough.
+func (r *Remuxer) cut(ts int64) {
+	if r.cur != nil && time.Duration(ts-r.curStart)*time.Millisecond < r.opts.TargetDuration {
+		return
+	}
+	if r.cur != nil {
+		r.lastTs = ts
+		r.finishSegment()
+	}
+	r.cur = &bytes.Buffer{}
+	r.curStart = ts
+	r.mux.videoType = 0
+	if r.vc != nil {
+		r.mux.videoType = streamTypeAvc
+		if r.vc.Codec == codecHevc {
+			r.mux.videoType = streamTypeHevc
+		}
+	}
+	r.mux.hasAudio = r.ac != nil
+	r.mux.writeTables(r.cur)
+}
+
+func (r *Remuxer) finishSegment() {
+	if r.cur == nil {
+		return
+	}
+	s := segment{
+		seq:      r.seq,
+		duration: time.Duration(max(r.lastTs-r.curStart, 1)) * time.Millisecond,
+		data:     r.cur.Bytes(),
+	}
+	r.cur = nil
+	r.seq++
+	r.mtx.Lock()
+	r.segments = append(r.segments, s)
+	// keep more segments than the playlist for the slow players
+	if len(r.segments) > r.opts.WindowSize*2 {
+		r.segments = r.segments[len(r.segments)-r.opts.WindowSize*2:]
+	}
+	r.mtx.Unlock()
+	r.readyOnce.Do(func() { close(r.ready) })
+}
+
+// Playlist returns the live media playlist, the segment uris are `<seq>.ts`.
+func (r *Remuxer) Playlist() []byte {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	ss := r.segments
+	if len(ss) > r.opts.WindowSize {
+		ss = ss[len(ss)-r.opts.WindowSize:]
+	}
+	target := r.opts.TargetDuration
+	for _, s := range ss {
+		target = max(target, s.duration)
+	}
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	_, _ = fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int((target+time.Second-1)/time.Second))
+	seq := 0
+	if len(ss) > 0 {
+		seq = ss[0].seq
+	}
+	_, _ = fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", seq)
+	for _, s := range ss {
+		_, _ = fmt.Fprintf(&b, "#EXTINF:%.3f,\n%d.ts\n", s.duration.Seconds(), s.seq)
+	}
+	if r.ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return []byte(b.String())
+}
+
+// Segment returns false if the segment is not available.
+func (r *Remuxer) Segment(name string) ([]byte, bool) {
+	seq, err := strconv.Atoi(strings.TrimSuffix(name, ".ts"))
+	if err != nil || !strings.HasSuffix(name, ".ts") {
+		return nil, false
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	for _, s := range r.segments {
+		if s.seq == seq {
+			return s.data, true
+		}
+	}
+	return nil, false
+}
+
+var (
+	avcAud  = []byte{0x09, 0xf0}
+	hevcAud = []byte{0x46, 0x01, 0x50}
+)
+
+// annexB converts the nalus into the annex b byte stream with an access unit delimiter, and inserts the
+// parameter sets before the keyframe if the frame does not have them.
+func annexB(vc *videoConfig, nalus [][]byte, keyframe bool) []byte {
+	var hasAud, hasParams bool
+	for _, n := range nalus {
+		if len(n) == 0 {
+			continue
+		}
+		if vc.Codec == codecAvc {
+			t := n[0] & 0x1f
+			hasAud = hasAud || t == 9
+			hasParams = hasParams || t == 7
+		} else {
+			t := (n[0] >> 1) & 0x3f
+			hasAud = hasAud || t == 35
+			hasParams = hasParams || t == 33
+		}
+	}
+	var b bytes.Buffer
+	startCode := []byte{0x00, 0x00, 0x00, 0x01}
+	writeParams := func() {
+		for _, ps := range vc.ParamSets {
+			b.Write(startCode)
+			b.Write(ps)
+		}
+	}
+	// the parameter sets must follow the delimiter
+	pending := keyframe && !hasParams
+	if !hasAud {
+		b.Write(startCode)
+		if vc.Codec == codecAvc {
+			b.Write(avcAud)
+		} else {
+			b.Write(hevcAud)
+		}
+		if pending {
+			writeParams()
+			pending = false
+		}
+	}
+	for _, n := range nalus {
+		b.Write(startCode)
+		b.Write(n)
+		if pending {
+			writeParams()
+			pending = false
+		}
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,71 @@
+package remux
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemuxer_Run(t *testing.T) {
+	t.Run("should remux the fixture into segments", func(t *testing.T) {
+		data, err := os.ReadFile("testData/sample.flv")
+		assert.NoError(t, err)
+		r := New(slog.Default(), Options{TargetDuration: time.Second})
+		assert.NoError(t, r.Run(bytes.NewReader(data)))
+		select {
+		case <-r.Ready():
+		default:
+			t.Fatal("should be ready")
+		}
+		assert.Equal(t, "#EXTM3U\n"+
+			"#EXT-X-VERSION:3\n"+
+			"#EXT-X-TARGETDURATION:1\n"+
+			"#EXT-X-MEDIA-SEQUENCE:0\n"+
+			"#EXTINF:1.000,\n0.ts\n"+
+			"#EXTINF:1.000,\n1.ts\n"+
+			"#EXTINF:0.995,\n2.ts\n"+
+			"#EXT-X-ENDLIST\n", string(r.Playlist()))
+		for _, name := range []string{"0.ts", "1.ts", "2.ts"} {
+			seg, ok := r.Segment(name)
+			assert.True(t, ok)
+			assert.Equal(t, 0, len(seg)%tsPacketSize)
+			// every segment starts with the pat
+			assert.Equal(t, []byte{0x47, 0x40, 0x00}, seg[:3])
+		}
+		// the first video pes starts with the delimiter and the parameter sets
+		seg, _ := r.Segment("0.ts")
+		assert.True(t, bytes.Contains(seg, []byte{0x00, 0x00, 0x00, 0x01, 0x09, 0xf0, 0x00, 0x00, 0x00, 0x01, 0x67}))
+		_, ok := r.Segment("3.ts")
+		assert.False(t, ok)
+		_, ok = r.Segment("index.m3u8")
+		assert.False(t, ok)
+	})
+
+	t.Run("should keep the window of segments", func(t *testing.T) {
+		data, err := os.ReadFile("testData/sample.flv")
+		assert.NoError(t, err)
+		r := New(slog.Default(), Options{TargetDuration: time.Millisecond, WindowSize: 1})
+		assert.NoError(t, r.Run(bytes.NewReader(data)))
+		assert.Equal(t, "#EXTM3U\n"+
+			"#EXT-X-VERSION:3\n"+
+			"#EXT-X-TARGETDURATION:1\n"+
+			"#EXT-X-MEDIA-SEQUENCE:2\n"+
+			"#EXTINF:0.995,\n2.ts\n"+
+			"#EXT-X-ENDLIST\n", string(r.Playlist()))
+		_, ok := r.Segment("0.ts")
+		assert.False(t, ok)
+		_, ok = r.Segment("1.ts")
+		assert.True(t, ok)
+	})
+
+	t.Run("should return error for broken stream", func(t *testing.T) {
+		r := New(slog.Default(), Options{})
+		err := r.Run(bytes.NewReader([]byte("not a flv stream")))
+		assert.EqualError(t, err, "failed to demux flv: failed to read flv header: invalid flv signature")
+		assert.Equal(t, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-ENDLIST\n", string(r.Playlist()))
+	})
+}
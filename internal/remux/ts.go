@@ -0,0 +1,215 @@
+package remux
+
+import (
+	"bytes"
+)
+
+const (
+	tsPacketSize = 188
+
+	pidPat   = 0x0000
+	pidPmt   = 0x1000
+	pidVideo = 0x0100
+	pidAudio = 0x0101
+
+	streamTypeAvc  = 0x1b
+	streamTypeHevc = 0x24
+	streamTypeAac  = 0x0f
+
+	streamIdVideo = 0xe0
+	streamIdAudio = 0xc0
+)
+
+var crcTable = func() [256]uint32 {
+	var t [256]uint32
+	for i := range t {
+		c := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04c11db7
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return t
+}()
+
+// crc32Mpeg is the crc of the psi sections.
+func crc32Mpeg(b []byte) uint32 {
+	c := uint32(0xffffffff)
+	for _, v := range b {
+		c = c<<8 ^ crcTable[byte(c>>24)^v]
+	}
+	return c
+}
+
+// tsMuxer writes the elementary streams into mpeg-ts packets.
+type tsMuxer struct {
+	videoType uint8
+	hasAudio  bool
+	cc        map[uint16]uint8
+}
+
+// newTsMuxer creates a muxer of the stream types, videoType is 0 if there is no video.
+func newTsMuxer(videoType uint8, hasAudio bool) *tsMuxer {
+	return &tsMuxer{
+		videoType: videoType,
+		hasAudio:  hasAudio,
+		cc:        make(map[uint16]uint8),
+	}
+}
+
+func (m *tsMuxer) pcrPid() uint16 {
+	if m.videoType != 0 {
+		return pidVideo
+	}
+	return pidAudio
+}
+
+// writeTables writes the pat and the pmt, every segment starts with them.
+func (m *tsMuxer) writeTables(w *bytes.Buffer) {
+	pat := []byte{
+		0x00,       // table id
+		0xb0, 0x0d, // section syntax, length
+		0x00, 0x01, // transport stream id
+		0xc1,       // version, current
+		0x00, 0x00, // section number, last section number
+		0x00, 0x01, // program number
+		0xe0 | pidPmt>>8, pidPmt & 0xff,
+	}
+	m.writeSection(w, pidPat, pat)
+
+	streams := make([]byte, 0, 10)
+	if m.videoType != 0 {
+		streams = append(streams, m.videoType, 0xe0|pidVideo>>8, pidVideo&0xff, 0xf0, 0x00)
+	}
+	if m.hasAudio {
+		streams = append(streams, streamTypeAac, 0xe0|pidAudio>>8, pidAudio&0xff, 0xf0, 0x00)
+	}
+	// section length counts the bytes after it, including the crc
+	sl := 9 + len(streams) + 4
+	pcr := m.pcrPid()
+	pmt := []byte{
+		0x02, // table id
+		0xb0 | byte(sl>>8), byte(sl),
+		0x00, 0x01, // program number
+		0xc1,
+		0x00, 0x00,
+		0xe0 | byte(pcr>>8), byte(pcr),
+		0xf0, 0x00, // program info length
+	}
+	pmt = append(pmt, streams...)
+	m.writeSection(w, pidPmt, pmt)
+}
+
+func (m *tsMuxer) writeSection(w *bytes.Buffer, pid uint16, section []byte) {
+	crc := crc32Mpeg(section)
+	section = append(section, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+	pkt := make([]byte, tsPacketSize)
+	pkt[0] = 0x47
+	pkt[1] = 0x40 | byte(pid>>8)
+	pkt[2] = byte(pid)
+	pkt[3] = 0x10 | m.nextCc(pid)
+	pkt[4] = 0x00 // pointer field
+	n := copy(pkt[5:], section)
+	for i := 5 + n; i < tsPacketSize; i++ {
+		pkt[i] = 0xff
+	}
+	w.Write(pkt)
+}
+
+func (m *tsMuxer) nextCc(pid uint16) uint8 {
+	c := m.cc[pid]
+	m.cc[pid] = (c + 1) & 0x0f
+	return c
+}
+
+// writePes writes an access unit, the timestamps are in 90 kHz, the pcr is written if withPcr.
+func (m *tsMuxer) writePes(w *bytes.Buffer, pid uint16, streamId uint8, pts, dts uint64, withPcr bool, data []byte) {
+	header := []byte{0x00, 0x00, 0x01, streamId, 0x00, 0x00, 0x80}
+	if pts != dts {
+		header = append(header, 0xc0, 10)
+		header = append(header, encodeTimestamp(0x3, pts)...)
+		header = append(header, encodeTimestamp(0x1, dts)...)
+	} else {
+		header = append(header, 0x80, 5)
+		header = append(header, encodeTimestamp(0x2, pts)...)
+	}
+	// the length of the video pes may be 0, which means unbounded
+	if l := len(header) - 6 + len(data); l <= 0xffff && streamId != streamIdVideo {
+		header[4] = byte(l >> 8)
+		header[5] = byte(l)
+	}
+	payload := append(header, data...)
+
+	first := true
+	for len(payload) > 0 {
+		pkt := make([]byte, tsPacketSize)
+		pkt[0] = 0x47
+		pkt[1] = byte(pid >> 8)
+		if first {
+			pkt[1] |= 0x40
+		}
+		pkt[2] = byte(pid)
+		var af []byte
+		if first && withPcr {
+			af = []byte{0x10}
+			af = append(af, encodePcr(dts)...)
+		}
+		// the adaptation field includes its length byte
+		space := tsPacketSize - 4
+		if af != nil {
+			space -= 1 + len(af)
+		}
+		if len(payload) < space {
+			// stuff the last packet with the adaptation field
+			need := space - len(payload)
+			if af == nil {
+				af = []byte{}
+				if need > 1 {
+					af = append(af, 0x00)
+					af = append(af, bytes.Repeat([]byte{0xff}, need-2)...)
+				}
+			} else {
+				af = append(af, bytes.Repeat([]byte{0xff}, need)...)
+			}
+			space = len(payload)
+		}
+		pos := 4
+		if af != nil {
+			pkt[3] = 0x30 | m.nextCc(pid)
+			pkt[4] = byte(len(af))
+			copy(pkt[5:], af)
+			pos = 5 + len(af)
+		} else {
+			pkt[3] = 0x10 | m.nextCc(pid)
+		}
+		copy(pkt[pos:], payload[:space])
+		payload = payload[space:]
+		first = false
+		w.Write(pkt)
+	}
+}
+
+func encodeTimestamp(marker uint8, ts uint64) []byte {
+	return []byte{
+		marker<<4 | byte(ts>>29)&0x0e | 0x01,
+		byte(ts >> 22),
+		byte(ts>>14)&0xfe | 0x01,
+		byte(ts >> 7),
+		byte(ts<<1) | 0x01,
+	}
+}
+
+func encodePcr(base uint64) []byte {
+	return []byte{
+		byte(base >> 25),
+		byte(base >> 17),
+		byte(base >> 9),
+		byte(base >> 1),
+		byte(base<<7) | 0x7e,
+		0x00,
+	}
+}
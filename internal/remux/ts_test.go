@@ -0,0 +1,68 @@
+package remux
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_crc32Mpeg(t *testing.T) {
+	assert.Equal(t, uint32(0x0376e6e7), crc32Mpeg([]byte("123456789")))
+}
+
+func Test_encodeTimestamp(t *testing.T) {
+	// 0x1_2345_6789 has all bits of the 33-bit timestamp in use
+	got := encodeTimestamp(0x2, 0x123456789)
+	assert.Equal(t, []byte{0x29, 0x8d, 0x15, 0xcf, 0x13}, got)
+}
+
+func Test_tsMuxer(t *testing.T) {
+	t.Run("should write tables with crc", func(t *testing.T) {
+		m := newTsMuxer(streamTypeAvc, true)
+		var b bytes.Buffer
+		m.writeTables(&b)
+		assert.Equal(t, 2*tsPacketSize, b.Len())
+		pat := b.Bytes()[:tsPacketSize]
+		assert.Equal(t, []byte{0x47, 0x40, 0x00, 0x10, 0x00}, pat[:5])
+		// the crc of a section with its crc is 0
+		assert.Equal(t, uint32(0), crc32Mpeg(pat[5:5+3+13]))
+		pmt := b.Bytes()[tsPacketSize:]
+		assert.Equal(t, []byte{0x47, 0x50, 0x00, 0x10, 0x00, 0x02}, pmt[:6])
+		assert.Equal(t, uint32(0), crc32Mpeg(pmt[5:5+3+23]))
+	})
+
+	tests := []struct {
+		name    string
+		size    int
+		withPcr bool
+		packets int
+	}{
+		{name: "should write a pes in one packet", size: 100, packets: 1},
+		{name: "should stuff a single byte", size: 184 - 19 - 1, packets: 1},
+		{name: "should write a pes with pcr across packets", size: 1000, withPcr: true, packets: 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTsMuxer(streamTypeAvc, true)
+			var b bytes.Buffer
+			data := bytes.Repeat([]byte{0xab}, tt.size)
+			m.writePes(&b, pidVideo, streamIdVideo, 180000, 90000, tt.withPcr, data)
+			assert.Equal(t, tt.packets*tsPacketSize, b.Len())
+			var payload []byte
+			for i := 0; i < tt.packets; i++ {
+				pkt := b.Bytes()[i*tsPacketSize : (i+1)*tsPacketSize]
+				assert.Equal(t, byte(0x47), pkt[0])
+				assert.Equal(t, byte(i), pkt[3]&0x0f)
+				pos := 4
+				if pkt[3]&0x20 != 0 {
+					pos += 1 + int(pkt[4])
+				}
+				payload = append(payload, pkt[pos:]...)
+			}
+			// pes header with pts and dts
+			assert.Equal(t, []byte{0x00, 0x00, 0x01, streamIdVideo, 0x00, 0x00, 0x80, 0xc0, 10}, payload[:9])
+			assert.Equal(t, data, payload[19:])
+		})
+	}
+}
@@ -1,10 +1,11 @@
 package server
 
 import (
-	"fmt"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 )
 
 const (
@@ -14,32 +15,55 @@ const (
 
 const bufferSize = 32 * 1024 // 32 KB
 
+// maxCorsResponseSize caps the response of the cors proxy, which serves the icons and the covers only.
+const maxCorsResponseSize = 16 * 1024 * 1024 // 16 MB
+
+// corsProxy fetches the resources of the hosts allowed by the platforms.
 type corsProxy struct {
-	log *slog.Logger
+	log     *slog.Logger
+	guard   *hostGuard
+	maxSize int64
+}
+
+func newCorsProxy(log *slog.Logger, g *hostGuard) *corsProxy {
+	return &corsProxy{
+		log:     log,
+		guard:   g,
+		maxSize: maxCorsResponseSize,
+	}
 }
 
-func (c corsProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+func (c *corsProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
 	origin := req.URL.Query().Get(originKey)
 	if origin == "" {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "origin is required"}`))
+		writeError(w, http.StatusBadRequest, errors.New("origin is required"))
 		c.log.Error("origin is required")
 		return
 	}
-	newReq, err := http.NewRequestWithContext(req.Context(), req.Method, origin, req.Body)
+	u, err := url.Parse(origin)
+	if err != nil || !c.guard.allowed(u) {
+		writeError(w, http.StatusForbidden, errors.New("origin is not allowed"))
+		c.log.Warn("origin is not allowed", "origin", origin)
+		return
+	}
+	newReq, err := http.NewRequestWithContext(req.Context(), req.Method, origin, nil)
 	if err != nil {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf(`{"error": "%s"}`, err.Error())))
+		writeError(w, http.StatusInternalServerError, err)
 		c.log.Error("cannot create new request", "error", err)
 		return
 	}
-	res, err := http.DefaultClient.Do(newReq)
+	res, err := c.guard.client.Do(newReq)
 	if err != nil {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf(`{"error": "%s"}`, err.Error())))
+		code := http.StatusInternalServerError
+		if errors.Is(err, ErrBlockedAddress) || errors.Is(err, ErrHostNotAllowed) {
+			code = http.StatusForbidden
+		}
+		writeError(w, code, err)
 		c.log.Error("cannot do request", "error", err)
 		return
 	}
@@ -49,6 +73,11 @@ func (c corsProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			c.log.Error("failed to close response body", "error", err)
 		}
 	}()
+	if res.ContentLength > c.maxSize {
+		writeError(w, http.StatusBadGateway, errors.New("response is too large"))
+		c.log.Error("response is too large", "origin", u.Host, "length", res.ContentLength)
+		return
+	}
 	for key, values := range res.Header {
 		// remove cors response headers
 		if len(key) > len(corsHeadersPrefix) && key[0:len(corsHeadersPrefix)] != corsHeadersPrefix {
@@ -59,9 +88,13 @@ func (c corsProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 	w.WriteHeader(res.StatusCode)
-	l, err := io.CopyBuffer(w, res.Body, make([]byte, bufferSize))
+	l, err := io.CopyBuffer(w, io.LimitReader(res.Body, c.maxSize+1), make([]byte, bufferSize))
 	if err != nil {
 		c.log.Error("failed to copy response body", "error", err)
+	} else if l > c.maxSize {
+		c.log.Error("response is too large", "origin", u.Host, "length", l)
+		// the status is sent, abort the response so that the truncated body is not taken as complete
+		panic(http.ErrAbortHandler)
 	} else {
 		c.log.Info("copied response body", "length", l)
 	}
@@ -1,33 +1,160 @@
 package server
 
 import (
+	"asmblive/internal/proxy"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
+func corsUrl(origin string) string {
+	u := url.URL{Scheme: "http", Host: "proxy.com", Path: corsPath}
+	q := u.Query()
+	q.Add(originKey, origin)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
 func Test_corsProxy_ServeHTTP(t *testing.T) {
-	t.Run("should return proxy response", func(t *testing.T) {
-		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "http://other.com/", http.StatusFound)
+		case "/large":
+			w.Header().Set("Content-Length", "1024")
+			_, _ = w.Write(make([]byte, 1024))
+		default:
 			w.Header().Set("Access-Control-Allow-Origin", "example.com")
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte("test response"))
+		}
+	}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	tests := []struct {
+		name     string
+		method   string
+		origin   string
+		hosts    []string
+		loopback bool
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "should return proxy response",
+			origin:   ts.URL,
+			hosts:    []string{"127.0.0.1"},
+			loopback: true,
+			wantCode: http.StatusOK,
+			wantBody: "test response",
+		},
+		{
+			name:     "should return 405 since method is not allowed",
+			method:   http.MethodPost,
+			origin:   ts.URL,
+			hosts:    []string{"127.0.0.1"},
+			loopback: true,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:     "should return 400 since origin is missing",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "should return 403 since host is not allowed",
+			origin:   ts.URL,
+			hosts:    []string{".example.com"},
+			loopback: true,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "should return 403 since scheme is not allowed",
+			origin:   "file:///etc/passwd",
+			hosts:    []string{"*"},
+			loopback: true,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "should return 403 since ip is loopback",
+			origin:   ts.URL,
+			hosts:    []string{"127.0.0.1"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "should return 403 since host resolves to loopback",
+			origin:   "http://" + net.JoinHostPort("localhost", port),
+			hosts:    []string{"localhost"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "should return 403 since redirected to host not allowed",
+			origin:   ts.URL + "/redirect",
+			hosts:    []string{"127.0.0.1"},
+			loopback: true,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "should return 502 since response is too large",
+			origin:   ts.URL + "/large",
+			hosts:    []string{"127.0.0.1"},
+			loopback: true,
+			wantCode: http.StatusBadGateway,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newHostGuard(proxy.Transport(nil))
+			g.allow("", tt.hosts...)
+			if tt.loopback {
+				g.blocked = func(ip net.IP) bool { return !ip.IsLoopback() && isPrivateIp(ip) }
+			}
+			cp := newCorsProxy(slog.Default(), g)
+			cp.maxSize = 512
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req, _ := http.NewRequest(method, corsUrl(tt.origin), nil)
+			w := httptest.NewRecorder()
+			cp.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, w.Body.String())
+				assert.Equal(t, "", w.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+
+	t.Run("should abort since streamed response is too large", func(t *testing.T) {
+		origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// without Content-Length, the response is chunked
+			for i := 0; i < 4; i++ {
+				_, _ = w.Write([]byte(strings.Repeat("a", 256)))
+				w.(http.Flusher).Flush()
+			}
 		}))
-		defer ts.Close()
-		u := url.URL{Scheme: "http", Host: "proxy.com", Path: corsPath}
-		q := u.Query()
-		q.Add(originKey, ts.URL)
-		u.RawQuery = q.Encode()
-		req, _ := http.NewRequest(http.MethodGet, u.String(), nil)
-		w := httptest.NewRecorder()
-		cp := corsProxy{log: slog.Default()}
-		cp.ServeHTTP(w, req)
-		assert.Equal(t, http.StatusOK, w.Code)
-		assert.Equal(t, "test response", w.Body.String())
-		assert.Equal(t, "", w.Header().Get("Access-Control-Allow-Origin"))
+		defer origin.Close()
+		g := newHostGuard(proxy.Transport(nil))
+		g.allow("", "127.0.0.1")
+		g.blocked = func(ip net.IP) bool { return false }
+		cp := newCorsProxy(slog.Default(), g)
+		cp.maxSize = 512
+		ps := httptest.NewServer(cp)
+		defer ps.Close()
+		res, err := http.Get(ps.URL + corsPath + "?" + url.Values{originKey: {origin.URL}}.Encode())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() { _ = res.Body.Close() }()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		_, err = io.ReadAll(res.Body)
+		assert.Error(t, err)
 	})
 }
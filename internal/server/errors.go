@@ -1,6 +1,16 @@
 package server
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ErrHostNotAllowed is returned if the cors proxy is redirected to a host not allowed by the platforms.
+var ErrHostNotAllowed = errors.New("host is not allowed")
+
+// ErrBlockedAddress is returned if the host of the cors proxy request resolves to a private address.
+var ErrBlockedAddress = errors.New("address is blocked")
 
 func ErrServerStart(err error) error {
 	return fmt.Errorf("failed to start server: %w", err)
@@ -9,3 +19,11 @@ func ErrServerStart(err error) error {
 func ErrServerStop(err error) error {
 	return fmt.Errorf("failed to stop server: %w", err)
 }
+
+func errHostNotAllowed(host string) error {
+	return fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+}
+
+func errBlockedAddress(host string, ip net.IP) error {
+	return fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, ip)
+}
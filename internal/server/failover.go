@@ -0,0 +1,482 @@
+package server
+
+import (
+	"asmblive/internal/flv"
+	"asmblive/internal/platform/hls"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	failoverPath     = "/failover/"
+	failoverPlaylist = "index.m3u8"
+	failoverStream   = "live.flv"
+)
+
+const (
+	// failoverStallTimeout switches the flv stream if no data is received for a while, the hls playlist
+	// is switched if it is not updated for 3 target durations but at least this timeout.
+	failoverStallTimeout = 5 * time.Second
+	// failoverWindowSize is the number of the segments in the served playlist.
+	failoverWindowSize = 10
+	// failoverEdgeSize is the number of the segments taken from a new candidate, so that the player
+	// keeps close to the live edge.
+	failoverEdgeSize = 3
+	// failoverIdleTimeout removes the candidates which are not requested for a while.
+	failoverIdleTimeout = 10 * time.Minute
+	// failoverFetchTimeout is the max time of fetching the playlist of a candidate, so that a hung
+	// candidate is switched.
+	failoverFetchTimeout = 10 * time.Second
+)
+
+type failoverSegment struct {
+	seq           int64
+	duration      time.Duration
+	url           url.URL
+	initUrl       *url.URL
+	discontinuity bool
+}
+
+// failoverGroup is the state of a failover url, it is shared by all players of the url.
+type failoverGroup struct {
+	candidates []url.URL
+	hls        bool
+
+	// lastAccess is the unix nanoseconds of the last request, it is read without mtx so that the idle
+	// groups can be found while a request is holding mtx.
+	lastAccess atomic.Int64
+
+	mtx sync.Mutex
+	cur int
+
+	// segs is the window of the served playlist, the segments are renumbered with nextSeq so that the
+	// media sequence keeps increasing across the candidates.
+	segs           []failoverSegment
+	nextSeq        int64
+	discSeq        int64
+	targetDuration time.Duration
+	ended          bool
+	// srcSeq is the last appended media sequence of the current candidate, it is -1 after switching.
+	srcSeq  int64
+	lastNew time.Time
+}
+
+func (g *failoverGroup) touch() {
+	g.lastAccess.Store(time.Now().UnixNano())
+}
+
+func (g *failoverGroup) idle() bool {
+	return time.Since(time.Unix(0, g.lastAccess.Load())) > failoverIdleTimeout
+}
+
+func (g *failoverGroup) current() int {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.cur
+}
+
+// switchFrom switches to the next candidate if the current one is still i, the players of the group may
+// find the failure at the same time.
+func (g *failoverGroup) switchFrom(i int) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	if g.cur == i {
+		g.next()
+	}
+}
+
+func (g *failoverGroup) next() {
+	g.cur = (g.cur + 1) % len(g.candidates)
+	g.srcSeq = -1
+	g.lastNew = time.Now()
+}
+
+// stalled reports whether the playlist of the current candidate is not updated for a while.
+func (g *failoverGroup) stalled(mp hls.MediaPlaylist) bool {
+	if g.srcSeq < 0 {
+		return false
+	}
+	if n := len(mp.Segments); n > 0 && mp.Segments[n-1].Seq != g.srcSeq {
+		return false
+	}
+	timeout := 3 * mp.TargetDuration
+	if timeout < failoverStallTimeout {
+		timeout = failoverStallTimeout
+	}
+	return time.Since(g.lastNew) > timeout
+}
+
+// append adds the new segments of the current candidate to the window.
+func (g *failoverGroup) append(mp hls.MediaPlaylist) {
+	segs := mp.Segments
+	n := len(segs)
+	fresh := g.srcSeq < 0 || (n > 0 && segs[n-1].Seq < g.srcSeq)
+	if fresh && n > failoverEdgeSize {
+		segs = segs[n-failoverEdgeSize:]
+	}
+	for i, s := range segs {
+		if !fresh && s.Seq <= g.srcSeq {
+			continue
+		}
+		g.segs = append(g.segs, failoverSegment{
+			seq:      g.nextSeq,
+			duration: s.Duration,
+			url:      s.Url,
+			initUrl:  mp.InitUrl,
+			// the first segment of another candidate or a restarted sequence
+			discontinuity: fresh && i == 0 && g.nextSeq > 0,
+		})
+		g.nextSeq++
+		g.srcSeq = s.Seq
+		g.lastNew = time.Now()
+	}
+	for len(g.segs) > failoverWindowSize {
+		if g.segs[0].discontinuity {
+			g.discSeq++
+		}
+		g.segs = g.segs[1:]
+	}
+	if mp.TargetDuration > g.targetDuration {
+		g.targetDuration = mp.TargetDuration
+	}
+}
+
+func (g *failoverGroup) playlist() []byte {
+	version := 3
+	target := g.targetDuration
+	for _, s := range g.segs {
+		if s.initUrl != nil {
+			version = 6
+		}
+		if s.duration > target {
+			target = s.duration
+		}
+	}
+	first := g.nextSeq - int64(len(g.segs))
+	var b bytes.Buffer
+	_, _ = fmt.Fprintf(&b, "#EXTM3U\n#EXT-X-VERSION:%d\n#EXT-X-TARGETDURATION:%d\n", version, int(math.Ceil(target.Seconds())))
+	_, _ = fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", first, g.discSeq)
+	var initUrl string
+	for _, s := range g.segs {
+		if s.discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		if s.initUrl != nil && (s.initUrl.String() != initUrl || s.discontinuity) {
+			initUrl = s.initUrl.String()
+			_, _ = fmt.Fprintf(&b, "#EXT-X-MAP:URI=%q\n", initUrl)
+		}
+		_, _ = fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", s.duration.Seconds(), s.url.String())
+	}
+	if g.ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return b.Bytes()
+}
+
+// failover serves a stream with several candidate urls, it plays the first one and switches to the next
+// one when the current one returns an error or stalls.
+type failover struct {
+	log *slog.Logger
+	// client fetches the upstream candidates, local fetches the relay and remux urls of the server, which
+	// are checked by their own handlers.
+	client *http.Client
+	local  *http.Client
+	// self is the host of the server, it is set on start.
+	self string
+	// fetchTimeout is failoverFetchTimeout, it is shortened by the tests.
+	fetchTimeout time.Duration
+
+	mtx    sync.Mutex
+	groups map[string]*failoverGroup
+}
+
+func newFailover(log *slog.Logger, client *http.Client, local *http.Client) *failover {
+	return &failover{
+		log:          log,
+		client:       client,
+		local:        local,
+		fetchTimeout: failoverFetchTimeout,
+		groups:       make(map[string]*failoverGroup),
+	}
+}
+
+// add returns the id of the candidates, the same candidates always have the same id.
+func (f *failover) add(candidates []url.URL, isHls bool) string {
+	hs := sha1.New()
+	for _, c := range candidates {
+		_, _ = fmt.Fprintln(hs, c.String())
+	}
+	id := hex.EncodeToString(hs.Sum(nil))[:16]
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	for gid, g := range f.groups {
+		if g.idle() {
+			delete(f.groups, gid)
+		}
+	}
+	if g, ok := f.groups[id]; ok {
+		g.touch()
+		return id
+	}
+	g := &failoverGroup{
+		candidates: append([]url.URL(nil), candidates...),
+		hls:        isHls,
+		srcSeq:     -1,
+	}
+	g.touch()
+	f.groups[id] = g
+	return id
+}
+
+func (f *failover) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	id, name, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, failoverPath), "/")
+	f.mtx.Lock()
+	g, ok := f.groups[id]
+	f.mtx.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		f.log.Error("unknown failover", "id", id)
+		return
+	}
+	g.touch()
+	switch {
+	case g.hls && name == failoverPlaylist:
+		f.servePlaylist(w, req, id, g)
+	case !g.hls && name == failoverStream:
+		f.serveStream(w, req, id, g)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+// servePlaylist refreshes the window with the playlist of the current candidate, the candidates are tried
+// in turn if it fails or stalls. The playlists are fetched without holding the lock of the group, so that
+// a hung candidate does not block the other players.
+func (f *failover) servePlaylist(w http.ResponseWriter, req *http.Request, id string, g *failoverGroup) {
+	g.mtx.Lock()
+	start, srcSeq, lastNew, ended := g.cur, g.srcSeq, g.lastNew, g.ended
+	g.mtx.Unlock()
+	n := len(g.candidates)
+	endedCount, ok := 0, false
+	for i := 0; i < n && !ended; i++ {
+		cur := (start + i) % n
+		ctx, cancel := context.WithTimeout(req.Context(), f.fetchTimeout)
+		mp, err := f.getMediaPlaylist(ctx, g.candidates[cur])
+		cancel()
+		if req.Context().Err() != nil {
+			return
+		}
+		if err == nil && mp.Ended {
+			endedCount++
+			err = errors.New("playlist ended")
+		}
+		g.mtx.Lock()
+		if g.cur != cur {
+			// another player has switched the candidate meanwhile, its window is served
+			g.mtx.Unlock()
+			ok = true
+			break
+		}
+		if err == nil && g.stalled(mp) {
+			err = errors.New("playlist stalled")
+		}
+		if err == nil {
+			g.append(mp)
+			g.mtx.Unlock()
+			ok = true
+			break
+		}
+		g.next()
+		g.mtx.Unlock()
+		f.log.Warn("candidate failed", "id", id, "host", g.candidates[cur].Host, "error", err)
+	}
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	if endedCount == n {
+		g.ended = true
+	}
+	if !ok && !g.ended {
+		if g.cur == start {
+			// all candidates fail, the current one is tried again by the next request
+			g.srcSeq, g.lastNew = srcSeq, lastNew
+		}
+		if len(g.segs) == 0 {
+			writeError(w, http.StatusBadGateway, errors.New("no available stream"))
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(g.playlist())
+}
+
+// getMediaPlaylist follows the first variant if u is a master playlist.
+func (f *failover) getMediaPlaylist(ctx context.Context, u url.URL) (hls.MediaPlaylist, error) {
+	for i := 0; i < 2; i++ {
+		res, err := f.get(ctx, u)
+		if err != nil {
+			return hls.MediaPlaylist{}, err
+		}
+		body, err := io.ReadAll(io.LimitReader(res.Body, maxPlaylistSize))
+		f.close(res.Body)
+		if err != nil {
+			return hls.MediaPlaylist{}, err
+		}
+		base := *res.Request.URL
+		vs, err := hls.ParseMaster(bytes.NewReader(body), base)
+		if err != nil {
+			return hls.MediaPlaylist{}, err
+		}
+		if len(vs) > 0 {
+			u = vs[0].Url
+			continue
+		}
+		return hls.ParseMedia(bytes.NewReader(body), base)
+	}
+	return hls.MediaPlaylist{}, errors.New("too many nested playlists")
+}
+
+// flvState is the state of a flv response across the candidates.
+type flvState struct {
+	// started is set after the header is written, the headers of the other candidates are skipped.
+	started bool
+	// lastTs is the timestamp of the last written tag, the timestamps of the next candidate are shifted
+	// to continue it.
+	lastTs int64
+	offset int64
+}
+
+// serveStream relays the flv stream of the current candidate, it switches to the next one when the stream
+// breaks, and ends when no candidate works.
+func (f *failover) serveStream(w http.ResponseWriter, req *http.Request, id string, g *failoverGroup) {
+	if req.Method == http.MethodHead {
+		w.Header().Set("Content-Type", "video/x-flv")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	fw := flushWriter{w: w, rc: http.NewResponseController(w)}
+	var st flvState
+	for fails := 0; fails < len(g.candidates); {
+		cur := g.current()
+		got, err := f.relayFlv(req.Context(), w, fw, g.candidates[cur], &st)
+		if req.Context().Err() != nil {
+			// the player goes away
+			return
+		}
+		if got {
+			fails = 0
+		} else {
+			fails++
+		}
+		f.log.Warn("candidate failed", "id", id, "host", g.candidates[cur].Host, "error", err)
+		g.switchFrom(cur)
+	}
+	if !st.started {
+		writeError(w, http.StatusBadGateway, errors.New("no available stream"))
+	}
+}
+
+// relayFlv returns true if any tag is written, the returned error is never nil since a live stream never
+// ends normally.
+func (f *failover) relayFlv(ctx context.Context, w http.ResponseWriter, fw io.Writer, u url.URL, st *flvState) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watchdog := time.AfterFunc(failoverStallTimeout, cancel)
+	defer watchdog.Stop()
+	res, err := f.get(ctx, u)
+	if err != nil {
+		return false, err
+	}
+	defer f.close(res.Body)
+	br := bufio.NewReaderSize(res.Body, bufferSize)
+	header, err := flv.ReadHeader(br)
+	if err != nil {
+		return false, err
+	}
+	if !st.started {
+		w.Header().Set("Content-Type", "video/x-flv")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if _, err := fw.Write(header); err != nil {
+			return false, err
+		}
+		st.started = true
+	}
+	shift := st.lastTs > 0
+	got := false
+	for {
+		watchdog.Reset(failoverStallTimeout)
+		tag, err := flv.ReadTag(br)
+		if err != nil {
+			return got, err
+		}
+		ts := int64(tag.Timestamp())
+		if shift && tag.IsSequenceHeader() {
+			// the sequence headers of the new candidate take the last timestamp
+			ts = st.lastTs
+		} else if shift && tag.Type() != flv.TagScript {
+			// the first frame of the new candidate continues the last one
+			st.offset = st.lastTs + 1 - ts
+			shift = false
+			ts += st.offset
+		} else {
+			ts += st.offset
+		}
+		if ts < 0 {
+			ts = 0
+		}
+		tag.SetTimestamp(uint32(ts))
+		if _, err := fw.Write(tag); err != nil {
+			return got, err
+		}
+		got = true
+		if tag.Type() != flv.TagScript {
+			st.lastTs = ts
+		}
+	}
+}
+
+func (f *failover) get(ctx context.Context, u url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	client := f.client
+	if u.Host == f.self {
+		client = f.local
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		f.close(res.Body)
+		return nil, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+	return res, nil
+}
+
+func (f *failover) close(rc io.Closer) {
+	if err := rc.Close(); err != nil {
+		f.log.Error("failed to close response body", "error", err)
+	}
+}
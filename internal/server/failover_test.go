@@ -0,0 +1,208 @@
+package server
+
+import (
+	"asmblive/internal/flv"
+	"asmblive/internal/platform/hls"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_failover_servePlaylist(t *testing.T) {
+	var (
+		mtx     sync.Mutex
+		bSeq    = 0
+		bStatus = http.StatusOK
+		hang    = make(chan struct{})
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		switch r.URL.Path {
+		case "/b.m3u8":
+			if bStatus != http.StatusOK {
+				w.WriteHeader(bStatus)
+				return
+			}
+			_, _ = fmt.Fprintf(w, "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:%d\n", bSeq)
+			for i := bSeq; i < bSeq+5; i++ {
+				_, _ = fmt.Fprintf(w, "#EXTINF:2,\nb%d.ts\n", i)
+			}
+		case "/c.m3u8":
+			_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:100\n#EXTINF:2,\nc100.ts\n#EXTINF:2,\nc101.ts\n"))
+		case "/hang.m3u8":
+			mtx.Unlock()
+			<-hang
+			mtx.Lock()
+		case "/ended.m3u8":
+			_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2,\nd0.ts\n#EXT-X-ENDLIST\n"))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+	defer close(hang)
+	u := func(p string) url.URL {
+		pu, _ := url.Parse(ts.URL + p)
+		return *pu
+	}
+	f := newFailover(slog.Default(), http.DefaultClient, http.DefaultClient)
+	f.fetchTimeout = 100 * time.Millisecond
+	get := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, failoverPath+id+"/"+failoverPlaylist, nil)
+		w := httptest.NewRecorder()
+		f.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("should switch to the next candidate", func(t *testing.T) {
+		id := f.add([]url.URL{u("/a.m3u8"), u("/b.m3u8"), u("/c.m3u8")}, true)
+		w := get(id)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/vnd.apple.mpegurl", w.Header().Get("Content-Type"))
+		// the new candidate starts from the live edge
+		assert.Equal(t, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-DISCONTINUITY-SEQUENCE:0\n"+
+			"#EXTINF:2.000,\n"+ts.URL+"/b2.ts\n#EXTINF:2.000,\n"+ts.URL+"/b3.ts\n#EXTINF:2.000,\n"+ts.URL+"/b4.ts\n", w.Body.String())
+
+		mtx.Lock()
+		bSeq = 1
+		mtx.Unlock()
+		w = get(id)
+		assert.True(t, strings.HasSuffix(w.Body.String(), "/b4.ts\n#EXTINF:2.000,\n"+ts.URL+"/b5.ts\n"))
+
+		mtx.Lock()
+		bStatus = http.StatusNotFound
+		mtx.Unlock()
+		w = get(id)
+		assert.Contains(t, w.Body.String(), "#EXT-X-MEDIA-SEQUENCE:0\n")
+		assert.True(t, strings.HasSuffix(w.Body.String(), "/b5.ts\n#EXT-X-DISCONTINUITY\n#EXTINF:2.000,\n"+ts.URL+"/c100.ts\n#EXTINF:2.000,\n"+ts.URL+"/c101.ts\n"))
+	})
+
+	t.Run("should switch the hung candidate without blocking others", func(t *testing.T) {
+		id := f.add([]url.URL{u("/hang.m3u8"), u("/c.m3u8")}, true)
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- get(id) }()
+		// adding other candidates is not blocked by the hung request
+		added := make(chan struct{})
+		go func() {
+			f.add([]url.URL{u("/c.m3u8")}, true)
+			close(added)
+		}()
+		select {
+		case <-added:
+		case <-time.After(50 * time.Millisecond):
+			t.Fatal("add is blocked")
+		}
+		select {
+		case w := <-done:
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), "/c101.ts\n")
+		case <-time.After(5 * time.Second):
+			t.Fatal("hung candidate is not switched")
+		}
+	})
+
+	t.Run("should end the playlist since all candidates end", func(t *testing.T) {
+		id := f.add([]url.URL{u("/ended.m3u8")}, true)
+		w := get(id)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, strings.HasSuffix(w.Body.String(), "#EXT-X-ENDLIST\n"))
+	})
+
+	t.Run("should fail since no candidate works", func(t *testing.T) {
+		id := f.add([]url.URL{u("/a.m3u8"), u("/x.m3u8")}, true)
+		w := get(id)
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+	})
+
+	t.Run("should return 404 since unknown id", func(t *testing.T) {
+		w := get("unknown")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func Test_failoverGroup_stalled(t *testing.T) {
+	mp := hls.MediaPlaylist{TargetDuration: 2 * time.Second, Segments: []hls.Segment{{Seq: 5}}}
+	g := &failoverGroup{srcSeq: -1}
+	assert.False(t, g.stalled(mp))
+	g.srcSeq = 5
+	g.lastNew = time.Now()
+	assert.False(t, g.stalled(mp))
+	g.lastNew = time.Now().Add(-failoverStallTimeout - time.Second)
+	assert.True(t, g.stalled(mp))
+	mp.Segments = append(mp.Segments, hls.Segment{Seq: 6})
+	assert.False(t, g.stalled(mp))
+}
+
+func flvTag(typ byte, ts int64, data ...byte) []byte {
+	size := len(data)
+	tag := []byte{typ, byte(size >> 16), byte(size >> 8), byte(size), 0, 0, 0, 0, 0, 0, 0}
+	flv.Tag(tag).SetTimestamp(uint32(ts))
+	tag = append(tag, data...)
+	n := len(tag)
+	return append(tag, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func Test_failover_serveStream(t *testing.T) {
+	header := []byte("FLV\x01\x05\x00\x00\x00\x09\x00\x00\x00\x00")
+	var mtx sync.Mutex
+	served := make(map[string]bool)
+	streams := map[string][][]byte{
+		"/y.flv": {flvTag(flv.TagVideo, 0, 0x17, 0), flvTag(flv.TagVideo, 0, 0x17, 1), flvTag(flv.TagVideo, 40, 0x27, 1)},
+		"/z.flv": {flvTag(flv.TagScript, 0, 2), flvTag(flv.TagVideo, 0, 0x17, 0), flvTag(flv.TagVideo, 1000, 0x17, 1), flvTag(flv.TagVideo, 1040, 0x27, 1)},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		tags, ok := streams[r.URL.Path]
+		// every stream breaks after it is served once
+		ok = ok && !served[r.URL.Path]
+		served[r.URL.Path] = true
+		mtx.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(header)
+		for _, tag := range tags {
+			_, _ = w.Write(tag)
+		}
+	}))
+	defer ts.Close()
+	var cs []url.URL
+	for _, p := range []string{"/x.flv", "/y.flv", "/z.flv"} {
+		u, _ := url.Parse(ts.URL + p)
+		cs = append(cs, *u)
+	}
+	f := newFailover(slog.Default(), http.DefaultClient, http.DefaultClient)
+	id := f.add(cs, false)
+
+	req := httptest.NewRequest(http.MethodGet, failoverPath+id+"/"+failoverStream, nil)
+	w := httptest.NewRecorder()
+	f.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "video/x-flv", w.Header().Get("Content-Type"))
+	// the header is written once and the timestamps of z continue the ones of y
+	want := bytes.Join([][]byte{
+		header,
+		flvTag(flv.TagVideo, 0, 0x17, 0), flvTag(flv.TagVideo, 0, 0x17, 1), flvTag(flv.TagVideo, 40, 0x27, 1),
+		flvTag(flv.TagScript, 0, 2), flvTag(flv.TagVideo, 40, 0x17, 0), flvTag(flv.TagVideo, 41, 0x17, 1), flvTag(flv.TagVideo, 81, 0x27, 1),
+	}, nil)
+	assert.Equal(t, want, w.Body.Bytes())
+
+	t.Run("should fail since no candidate works", func(t *testing.T) {
+		id := f.add(cs[:1], false)
+		req := httptest.NewRequest(http.MethodGet, failoverPath+id+"/"+failoverStream, nil)
+		w := httptest.NewRecorder()
+		f.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+	})
+}
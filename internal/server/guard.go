@@ -0,0 +1,177 @@
+package server
+
+import (
+	"asmblive/internal/proxy"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// hostGuard restricts the upstream requests of the server to the hosts allowed by the platforms, the hosts
+// resolving to the private addresses are refused, so that the server cannot be used to reach the local
+// network. It is shared by the cors proxy, the stream relay, the failover and the health checks.
+type hostGuard struct {
+	// client sends the requests with the checked connections, the redirects to the hosts not allowed are
+	// refused.
+	client *http.Client
+	// proxy is the proxy of the client transport, the connections to it are not checked.
+	proxy func(*http.Request) (*url.URL, error)
+	// blocked reports whether the connections to the ip are refused.
+	blocked func(ip net.IP) bool
+
+	mtx   sync.RWMutex
+	hosts []allowedHost
+}
+
+// allowedHost is a host pattern allowed by the platform.
+type allowedHost struct {
+	platformId string
+	pattern    string
+}
+
+// newHostGuard returns a guard whose client sends the requests with a copy of the transport.
+func newHostGuard(t *http.Transport) *hostGuard {
+	g := &hostGuard{
+		proxy:   t.Proxy,
+		blocked: isPrivateIp,
+		hosts:   make([]allowedHost, 0),
+	}
+	t = t.Clone()
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t.DialContext = g.dialContext(d)
+	g.client = &http.Client{
+		Transport: guardTransport{g: g, t: t},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if !g.allowed(req.URL) {
+				return errHostNotAllowed(req.URL.Host)
+			}
+			return nil
+		},
+	}
+	return g
+}
+
+// allow adds the host patterns of the platform, see proxy.Bypassed for the syntax.
+func (g *hostGuard) allow(platformId string, hosts ...string) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	for _, h := range hosts {
+		g.hosts = append(g.hosts, allowedHost{platformId: platformId, pattern: h})
+	}
+}
+
+// platform returns the id of the platform which allows the host first, ok is false if no platform allows it.
+func (g *hostGuard) platform(host string) (platformId string, ok bool) {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	for _, h := range g.hosts {
+		if proxy.Bypassed(host, []string{h.pattern}) {
+			return h.platformId, true
+		}
+	}
+	return "", false
+}
+
+// allowed reports whether the url is an http or https url of an allowed host, the ip hosts must not be
+// blocked even if they are allowed.
+func (g *hostGuard) allowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && g.blocked(ip) {
+		return false
+	}
+	_, ok := g.platform(u.Host)
+	return ok
+}
+
+// allowedFor reports whether the url is allowed as allowed does, and its host is allowed by the platform.
+func (g *hostGuard) allowedFor(platformId string, u *url.URL) bool {
+	if !g.allowed(u) {
+		return false
+	}
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	for _, h := range g.hosts {
+		if h.platformId == platformId && proxy.Bypassed(u.Host, []string{h.pattern}) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyAddrKey is the context key of the proxy address of the request.
+type proxyAddrKey struct{}
+
+// dialContext resolves the host and dials the resolved ips, so that the checked ips are connected even if
+// the dns record changes. The host is refused if any of its ips is blocked.
+func (g *hostGuard) dialContext(d *net.Dialer) func(ctx context.Context, network string, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		if addr == ctx.Value(proxyAddrKey{}) {
+			return d.DialContext(ctx, network, addr)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if g.blocked(ip.IP) {
+				return nil, errBlockedAddress(host, ip.IP)
+			}
+		}
+		for _, ip := range ips {
+			var conn net.Conn
+			conn, err = d.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+// guardTransport marks the proxy address of each request, the redirected requests included, so that the
+// connections to the proxy are not refused.
+type guardTransport struct {
+	g *hostGuard
+	t *http.Transport
+}
+
+func (gt guardTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if gt.g.proxy != nil {
+		pu, err := gt.g.proxy(req)
+		if err != nil {
+			return nil, err
+		}
+		if pu != nil {
+			req = req.WithContext(context.WithValue(req.Context(), proxyAddrKey{}, proxyAddr(pu)))
+		}
+	}
+	return gt.t.RoundTrip(req)
+}
+
+// isPrivateIp reports whether the ip is a loopback, private, link-local, unspecified or multicast address.
+func isPrivateIp(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// proxyAddr returns the address of the proxy url with the default port of its scheme.
+func proxyAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443", "socks5": "1080"}[u.Scheme]
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
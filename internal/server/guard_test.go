@@ -0,0 +1,87 @@
+package server
+
+import (
+	"asmblive/internal/proxy"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// loopbackGuard returns a guard which allows the test servers on the loopback address only.
+func loopbackGuard() *hostGuard {
+	g := newHostGuard(proxy.Transport(nil))
+	g.allow("", "127.0.0.1")
+	g.blocked = func(ip net.IP) bool { return !ip.IsLoopback() && isPrivateIp(ip) }
+	return g
+}
+
+func Test_isPrivateIp(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "127.0.0.1", want: true},
+		{ip: "10.1.2.3", want: true},
+		{ip: "192.168.1.1", want: true},
+		{ip: "169.254.169.254", want: true},
+		{ip: "0.0.0.0", want: true},
+		{ip: "::1", want: true},
+		{ip: "fd00::1", want: true},
+		{ip: "::ffff:127.0.0.1", want: true},
+		{ip: "8.8.8.8", want: false},
+		{ip: "2001:4860:4860::8888", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.want, isPrivateIp(net.ParseIP(tt.ip)))
+		})
+	}
+}
+
+func Test_hostGuard_platform(t *testing.T) {
+	g := newHostGuard(proxy.Transport(nil))
+	g.allow("bili", "www.bilibili.com", ".bilivideo.com")
+	g.allow("huya", ".huya.com")
+	tests := []struct {
+		host   string
+		wantId string
+		wantOk bool
+	}{
+		{host: "www.bilibili.com", wantId: "bili", wantOk: true},
+		{host: "d1--cn-gotcha09.bilivideo.com:443", wantId: "bili", wantOk: true},
+		{host: "tx.flv.huya.com", wantId: "huya", wantOk: true},
+		{host: "example.com", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			id, ok := g.platform(tt.host)
+			assert.Equal(t, tt.wantId, id)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+}
+
+func Test_hostGuard_allowedFor(t *testing.T) {
+	g := newHostGuard(proxy.Transport(nil))
+	g.allow("bili", ".bilivideo.com", "10.0.0.1")
+	g.allow("huya", ".huya.com")
+	tests := []struct {
+		name string
+		url  string
+		want bool
+	}{
+		{name: "should allow the host of the platform", url: "https://d1--cn-gotcha09.bilivideo.com/live.flv", want: true},
+		{name: "should refuse the host of another platform", url: "https://tx.flv.huya.com/live.flv", want: false},
+		{name: "should refuse the unknown host", url: "https://example.com/live.flv", want: false},
+		{name: "should refuse the private ip even if it is allowed", url: "http://10.0.0.1/live.flv", want: false},
+		{name: "should refuse the scheme other than http", url: "file://d1.bilivideo.com/live.flv", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse(tt.url)
+			assert.Equal(t, tt.want, g.allowedFor("bili", u))
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package server
 
 import (
+	"asmblive/internal/health"
+	"asmblive/internal/proxy"
 	"context"
 	"errors"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 )
 
 // Server is an HTTP server without any handler.
@@ -26,14 +30,59 @@ type Server interface {
 
 	// GetCorsProxyUrl returns the proxy URL for remove CORS limination.
 	GetCorsProxyUrl(origin url.URL) url.URL
+
+	// AllowProxyHost allows the cors proxy and the stream relay to request the hosts, which are host names
+	// or domains with the leading `.`, e.g. `.hdslb.com` matches the domain and its subdomains. The platforms
+	// call it on init with the hosts of their images and streams, the origins of other hosts are refused.
+	// The requests to the hosts are sent through the proxy of the platform.
+	AllowProxyHost(platformId string, hosts ...string)
+
+	// GetStreamUrl returns the relay URL of the stream, the upstream requests of the stream and its segments
+	// carry the header, which the player cannot set.
+	GetStreamUrl(origin url.URL, header http.Header) url.URL
+
+	// GetRemuxUrl returns the hls playlist URL of the flv stream, the stream is remuxed on demand.
+	GetRemuxUrl(origin url.URL, header http.Header) url.URL
+
+	// CheckStreams probes the stream URLs of the platform concurrently and returns them ranked by health,
+	// the relay and remux URLs are probed with their origins. The URLs whose hosts are not allowed by the
+	// platform are not probed and ranked last with ErrHostNotAllowed.
+	CheckStreams(ctx context.Context, platformId string, urls []url.URL) []health.Result
+
+	// GetFailoverUrl returns a stable URL of the stream of the platform, it plays the first candidate and
+	// switches to the next one when the current one stalls or returns an error. The candidates whose hosts
+	// are not allowed by the platform are dropped, an empty URL is returned if none is left.
+	GetFailoverUrl(platformId string, candidates []url.URL) url.URL
 }
 
-func New(log *slog.Logger) Server {
+// New returns a new instance of the Server, the upstream requests of the cors proxy, the streams and the
+// health checks are sent through the proxy of the platform which allows the host of the request. The
+// proxyFunc returns the proxy of the platform as http.Transport.Proxy does, the platform id is empty for
+// the hosts of no platform. A nil proxyFunc means http.ProxyFromEnvironment.
+func New(log *slog.Logger, proxyFunc func(platformId string) func(*http.Request) (*url.URL, error)) Server {
 	log = log.With("module", "server")
+	var g *hostGuard
+	t := proxy.Transport(nil)
+	if proxyFunc != nil {
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			id, _ := g.platform(req.URL.Host)
+			return proxyFunc(id)(req)
+		}
+	}
+	g = newHostGuard(t)
+	// the relay urls of the server are fetched directly, their handlers check the origins
+	lt := proxy.Transport(nil)
+	lt.Proxy = nil
+	sr := newStreamRelay(log.With("module", "server/stream"), g)
 	return &server{
-		log:              log,
-		hfs:              make(map[string]http.HandlerFunc),
-		corsProxyHandler: corsProxy{log: log.With("module", "server/cors")},
+		log:         log,
+		hfs:         make(map[string]http.HandlerFunc),
+		guard:       g,
+		corsProxy:   newCorsProxy(log.With("module", "server/cors"), g),
+		streamRelay: sr,
+		remuxer:     newRemuxer(log.With("module", "server/remux"), sr),
+		failover:    newFailover(log.With("module", "server/failover"), g.client, &http.Client{Transport: lt}),
+		checker:     health.New(log, g.client, health.Options{}),
 	}
 }
 
@@ -45,8 +94,13 @@ type server struct {
 	srv     *http.Server
 	baseUrl url.URL
 
-	hfs              map[string]http.HandlerFunc
-	corsProxyHandler http.Handler
+	hfs         map[string]http.HandlerFunc
+	guard       *hostGuard
+	corsProxy   *corsProxy
+	streamRelay *streamRelay
+	remuxer     *remuxer
+	failover    *failover
+	checker     *health.Checker
 }
 
 func (s *server) BaseUrl() url.URL {
@@ -61,12 +115,19 @@ func (s *server) Start() error {
 	}
 	s.baseUrl.Scheme = "http"
 	s.baseUrl.Host = l.Addr().String()
+	s.failover.self = s.baseUrl.Host
 	sm := http.NewServeMux()
 	for pattern, hf := range s.hfs {
 		sm.Handle(pattern, hf)
 	}
 	// add cors handler
-	sm.Handle(corsPath, s.corsProxyHandler)
+	sm.Handle(corsPath, s.corsProxy)
+	// add stream relay handler
+	sm.Handle(streamPath, s.streamRelay)
+	// add flv remuxing handler
+	sm.Handle(remuxPath, s.remuxer)
+	// add failover handler
+	sm.Handle(failoverPath, s.failover)
 
 	s.srv = &http.Server{Handler: sm}
 	s.log.Info("server started", "addr", l.Addr())
@@ -80,6 +141,7 @@ func (s *server) Start() error {
 
 func (s *server) Stop(ctx context.Context) error {
 	s.log.Info("server stopping")
+	s.remuxer.closeAll()
 	if err := s.srv.Shutdown(ctx); !errors.Is(http.ErrServerClosed, err) && err != nil {
 		s.log.Error("server stop error", "err", err)
 		return ErrServerStop(err)
@@ -103,3 +165,105 @@ func (s *server) GetCorsProxyUrl(origin url.URL) url.URL {
 	u.RawQuery = q.Encode()
 	return u
 }
+
+func (s *server) AllowProxyHost(platformId string, hosts ...string) {
+	s.guard.allow(platformId, hosts...)
+}
+
+func (s *server) GetStreamUrl(origin url.URL, header http.Header) url.URL {
+	u := relayPath(origin, s.streamRelay.addHeaders(origin, header))
+	u.Scheme = "http"
+	u.Host = s.baseUrl.Host
+	return u
+}
+
+func (s *server) GetRemuxUrl(origin url.URL, header http.Header) url.URL {
+	return url.URL{
+		Scheme: "http",
+		Host:   s.baseUrl.Host,
+		Path:   remuxPath + s.remuxer.addSource(origin, header) + "/" + remuxPlaylist,
+	}
+}
+
+func (s *server) CheckStreams(ctx context.Context, platformId string, urls []url.URL) []health.Result {
+	rs := make([]health.Result, 0, len(urls))
+	refused := make([]health.Result, 0)
+	var wg sync.WaitGroup
+	for _, u := range urls {
+		origin, h := s.origin(u)
+		if !s.allowedStream(platformId, origin) {
+			refused = append(refused, health.Result{Url: u, Err: errHostNotAllowed(origin.Host)})
+			s.log.Warn("stream host not allowed", "platformId", platformId, "host", origin.Host)
+			continue
+		}
+		// rs has the capacity of all urls, so that the pointers to its elements stay valid
+		rs = append(rs, health.Result{})
+		wg.Add(1)
+		go func(r *health.Result, u url.URL, origin url.URL, h http.Header) {
+			defer wg.Done()
+			*r = s.checker.Check(ctx, origin, h)
+			r.Url = u
+			if r.Err != nil {
+				s.log.Info("unhealthy stream", "host", origin.Host, "err", r.Err)
+			}
+		}(&rs[len(rs)-1], u, origin, h)
+	}
+	wg.Wait()
+	return append(health.Rank(rs), refused...)
+}
+
+func (s *server) GetFailoverUrl(platformId string, candidates []url.URL) url.URL {
+	allowed := make([]url.URL, 0, len(candidates))
+	for _, c := range candidates {
+		if origin, _ := s.origin(c); s.allowedStream(platformId, origin) {
+			allowed = append(allowed, c)
+		} else {
+			s.log.Warn("failover candidate not allowed", "platformId", platformId, "host", origin.Host)
+		}
+	}
+	candidates = allowed
+	if len(candidates) == 0 {
+		return url.URL{}
+	}
+	name := failoverStream
+	origin, _ := s.origin(candidates[0])
+	if strings.HasSuffix(candidates[0].Path, ".m3u8") || strings.HasSuffix(origin.Path, ".m3u8") {
+		name = failoverPlaylist
+	}
+	return url.URL{
+		Scheme: "http",
+		Host:   s.baseUrl.Host,
+		Path:   failoverPath + s.failover.add(candidates, name == failoverPlaylist) + "/" + name,
+	}
+}
+
+// allowedStream reports whether the origin of a stream is allowed by the platform, the local URLs which are
+// not relayed or remuxed streams are refused.
+func (s *server) allowedStream(platformId string, origin url.URL) bool {
+	if origin.Host == s.baseUrl.Host {
+		return false
+	}
+	return s.guard.allowedFor(platformId, &origin)
+}
+
+// origin returns the upstream URL and headers of the relay and remux URLs, other URLs are returned as is.
+func (s *server) origin(u url.URL) (url.URL, http.Header) {
+	if u.Host != s.baseUrl.Host {
+		return u, nil
+	}
+	switch {
+	case u.Path == streamPath:
+		o, err := url.Parse(u.Query().Get(originKey))
+		if err != nil {
+			return u, nil
+		}
+		h, _ := s.streamRelay.header(u.Query().Get(headersIdKey), *o)
+		return *o, h
+	case strings.HasPrefix(u.Path, remuxPath):
+		id, _, _ := strings.Cut(strings.TrimPrefix(u.Path, remuxPath), "/")
+		if src, ok := s.remuxer.source(id); ok {
+			return src.origin, src.header
+		}
+	}
+	return u, nil
+}
@@ -1,10 +1,14 @@
 package server
 
 import (
+	"asmblive/internal/health"
+	"asmblive/internal/proxy"
 	"context"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 
@@ -34,10 +38,13 @@ func Test_server_Start_Stop(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &server{
-				log:              slog.Default(),
-				hfs:              tt.fields.hfs,
-				corsProxyHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+				log:         slog.Default(),
+				hfs:         tt.fields.hfs,
+				corsProxy:   newCorsProxy(slog.Default(), newHostGuard(proxy.Transport(nil))),
+				streamRelay: newStreamRelay(slog.Default(), loopbackGuard()),
+				failover:    newFailover(slog.Default(), http.DefaultClient, http.DefaultClient),
 			}
+			s.remuxer = newRemuxer(slog.Default(), s.streamRelay)
 			err := s.Start()
 			if tt.wantErr != "" {
 				assert.ErrorContains(t, err, tt.wantErr)
@@ -81,12 +88,220 @@ func Test_server_GetCorsProxyUrl(t *testing.T) {
 	})
 }
 
+func Test_server_GetStreamUrl(t *testing.T) {
+	s := server{
+		baseUrl:     url.URL{Scheme: "http", Host: "localhost:8080"},
+		streamRelay: newStreamRelay(slog.Default(), loopbackGuard()),
+	}
+	t.Run("should return relay url with headers id", func(t *testing.T) {
+		h := http.Header{"Referer": {"https://example.com/"}}
+		u := s.GetStreamUrl(url.URL{Scheme: "https", Host: "example.com", Path: "/live.flv"}, h)
+		assert.Equal(t, "http://localhost:8080"+streamPath+"?"+headersIdKey+"="+s.streamRelay.addHeaders(url.URL{Host: "example.com"}, h)+"&"+originKey+"=https%3A%2F%2Fexample.com%2Flive.flv", u.String())
+	})
+
+	t.Run("should return relay url without headers id", func(t *testing.T) {
+		u := s.GetStreamUrl(url.URL{Scheme: "https", Host: "example.com", Path: "/live.flv"}, nil)
+		assert.Equal(t, "http://localhost:8080"+streamPath+"?"+originKey+"=https%3A%2F%2Fexample.com%2Flive.flv", u.String())
+	})
+}
+
+func Test_server_GetRemuxUrl(t *testing.T) {
+	t.Run("should return playlist url of the stream", func(t *testing.T) {
+		sr := newStreamRelay(slog.Default(), loopbackGuard())
+		s := server{
+			baseUrl:     url.URL{Scheme: "http", Host: "localhost:8080"},
+			streamRelay: sr,
+			remuxer:     newRemuxer(slog.Default(), sr),
+		}
+		origin := url.URL{Scheme: "https", Host: "example.com", Path: "/live.flv"}
+		u := s.GetRemuxUrl(origin, nil)
+		assert.Equal(t, "http://localhost:8080"+remuxPath+s.remuxer.addSource(origin, nil)+"/"+remuxPlaylist, u.String())
+	})
+}
+
+func Test_server_GetFailoverUrl(t *testing.T) {
+	g := newHostGuard(proxy.Transport(nil))
+	g.allow("test", "example.com")
+	g.allow("other", "example.org")
+	sr := newStreamRelay(slog.Default(), g)
+	s := server{
+		log:         slog.Default(),
+		baseUrl:     url.URL{Scheme: "http", Host: "localhost:8080"},
+		guard:       g,
+		streamRelay: sr,
+		remuxer:     newRemuxer(slog.Default(), sr),
+		failover:    newFailover(slog.Default(), http.DefaultClient, http.DefaultClient),
+	}
+	flv := url.URL{Scheme: "https", Host: "example.com", Path: "/live.flv"}
+	m3u8 := url.URL{Scheme: "https", Host: "example.com", Path: "/live.m3u8"}
+	tests := []struct {
+		name       string
+		candidates []url.URL
+		allowed    []url.URL
+		want       string
+	}{
+		{
+			name:       "should return the flv url",
+			candidates: []url.URL{flv, m3u8},
+			want:       failoverStream,
+		},
+		{
+			name:       "should return the playlist url",
+			candidates: []url.URL{m3u8, flv},
+			want:       failoverPlaylist,
+		},
+		{
+			name:       "should return the playlist url of the remuxed stream",
+			candidates: []url.URL{s.GetRemuxUrl(flv, nil)},
+			want:       failoverPlaylist,
+		},
+		{
+			name:       "should return the playlist url of the relayed playlist",
+			candidates: []url.URL{s.GetStreamUrl(m3u8, nil)},
+			want:       failoverPlaylist,
+		},
+		{
+			name: "should drop the candidates not allowed by the platform",
+			candidates: []url.URL{
+				{Scheme: "https", Host: "example.org", Path: "/live.m3u8"},
+				s.GetStreamUrl(url.URL{Scheme: "http", Host: "192.168.1.1", Path: "/live.m3u8"}, nil),
+				{Scheme: "http", Host: "localhost:8080", Path: failoverPath},
+				flv,
+			},
+			allowed: []url.URL{flv},
+			want:    failoverStream,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed := tt.allowed
+			if allowed == nil {
+				allowed = tt.candidates
+			}
+			u := s.GetFailoverUrl("test", tt.candidates)
+			assert.Equal(t, "http://localhost:8080"+failoverPath+s.failover.add(allowed, false)+"/"+tt.want, u.String())
+		})
+	}
+
+	t.Run("should return empty url since no candidate", func(t *testing.T) {
+		assert.Equal(t, url.URL{}, s.GetFailoverUrl("test", nil))
+	})
+
+	t.Run("should return empty url since no candidate is allowed by the platform", func(t *testing.T) {
+		assert.Equal(t, url.URL{}, s.GetFailoverUrl("other", []url.URL{flv, m3u8}))
+	})
+}
+
+func Test_server_CheckStreams(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/live.flv" && r.Header.Get("Referer") == "https://live.com/" {
+			_, _ = w.Write([]byte("FLV"))
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+	g := loopbackGuard()
+	sr := newStreamRelay(slog.Default(), g)
+	s := server{
+		log:         slog.Default(),
+		baseUrl:     url.URL{Scheme: "http", Host: "localhost:8080"},
+		guard:       g,
+		streamRelay: sr,
+		remuxer:     newRemuxer(slog.Default(), sr),
+		checker:     health.New(slog.Default(), http.DefaultClient, health.Options{}),
+	}
+	origin, _ := url.Parse(ts.URL + "/live.flv")
+	h := http.Header{"Referer": {"https://live.com/"}}
+	direct := *origin
+	relayed := s.GetStreamUrl(*origin, h)
+	remuxed := s.GetRemuxUrl(*origin, h)
+	other := url.URL{Scheme: "https", Host: "example.com", Path: "/live.flv"}
+	// the url not allowed by the platform goes first so that it is ranked last without probing
+	rs := s.CheckStreams(context.Background(), "", []url.URL{other, direct, relayed, remuxed})
+	assert.Len(t, rs, 4)
+	// the local urls are probed with their origins and headers
+	assert.True(t, rs[0].Healthy())
+	assert.True(t, rs[1].Healthy())
+	assert.ElementsMatch(t, []url.URL{relayed, remuxed}, []url.URL{rs[0].Url, rs[1].Url})
+	assert.Equal(t, direct, rs[2].Url)
+	assert.Equal(t, http.StatusForbidden, rs[2].StatusCode)
+	assert.Equal(t, other, rs[3].Url)
+	assert.ErrorIs(t, rs[3].Err, ErrHostNotAllowed)
+	assert.Equal(t, 0, rs[3].StatusCode)
+}
+
 func TestNew(t *testing.T) {
 	t.Run("should create a valid server", func(t *testing.T) {
-		s := New(slog.Default())
+		s := New(slog.Default(), nil)
 		assert.NotNil(t, s)
 		assert.NotPanics(t, func() {
 			s.AddHandler("/test", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
 		})
 	})
+
+	t.Run("should send upstream requests through the proxy of the platform", func(t *testing.T) {
+		origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("origin"))
+		}))
+		defer origin.Close()
+		var proxied []string
+		// the http proxy receives the requests in absolute form
+		px := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = append(proxied, r.URL.String())
+			out := r.Clone(r.Context())
+			out.RequestURI = ""
+			res, err := http.DefaultTransport.RoundTrip(out)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			defer func() { _ = res.Body.Close() }()
+			w.WriteHeader(res.StatusCode)
+			_, _ = io.Copy(w, res.Body)
+		}))
+		defer px.Close()
+		pu, _ := url.Parse(px.URL)
+		s := New(slog.Default(), func(platformId string) func(*http.Request) (*url.URL, error) {
+			if platformId == "test" {
+				return http.ProxyURL(pu)
+			}
+			// the loopback origin is refused if it is connected directly
+			return http.ProxyURL(nil)
+		})
+		s.AllowProxyHost("test", "localhost")
+		assert.NoError(t, s.Start())
+		defer func() { _ = s.Stop(context.Background()) }()
+		// the loopback origin is resolved by the proxy, only the connections to the proxy are made
+		_, port, _ := net.SplitHostPort(origin.Listener.Addr().String())
+		ou, _ := url.Parse("http://" + net.JoinHostPort("localhost", port) + "/data")
+		cu := s.GetCorsProxyUrl(*ou)
+		res, err := http.Get(cu.String())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() { _ = res.Body.Close() }()
+		data, _ := io.ReadAll(res.Body)
+		assert.Equal(t, "origin", string(data))
+		assert.Equal(t, []string{ou.String()}, proxied)
+	})
+	t.Run("should refuse the failover candidates resolving to the private addresses", func(t *testing.T) {
+		origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2,\n0.ts\n"))
+		}))
+		defer origin.Close()
+		s := New(slog.Default(), nil)
+		s.AllowProxyHost("test", "localhost")
+		assert.NoError(t, s.Start())
+		defer func() { _ = s.Stop(context.Background()) }()
+		_, port, _ := net.SplitHostPort(origin.Listener.Addr().String())
+		ou, _ := url.Parse("http://" + net.JoinHostPort("localhost", port) + "/live.m3u8")
+		fu := s.GetFailoverUrl("test", []url.URL{*ou})
+		res, err := http.Get(fu.String())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() { _ = res.Body.Close() }()
+		assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+	})
 }
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"asmblive/internal/remux"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	remuxPath     = "/remux/"
+	remuxPlaylist = "index.m3u8"
+)
+
+const (
+	// remuxIdleTimeout stops the remuxing if the player does not request it for a while.
+	remuxIdleTimeout = 30 * time.Second
+	// remuxReadyTimeout is the max wait of the first playlist request.
+	remuxReadyTimeout = 15 * time.Second
+	// remuxSourceIdleTimeout removes the sources which are not added or requested for a while, the signed
+	// urls get new ids so that the sources would pile up otherwise.
+	remuxSourceIdleTimeout = 10 * time.Minute
+)
+
+// remuxSource is the stream to be remuxed, the headers are sent to its origin only.
+type remuxSource struct {
+	origin     url.URL
+	header     http.Header
+	lastAccess time.Time
+}
+
+type remuxSession struct {
+	rm     *remux.Remuxer
+	cancel context.CancelFunc
+
+	mtx        sync.Mutex
+	lastAccess time.Time
+}
+
+func (s *remuxSession) touch() {
+	s.mtx.Lock()
+	s.lastAccess = time.Now()
+	s.mtx.Unlock()
+}
+
+func (s *remuxSession) idle() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return time.Since(s.lastAccess) > remuxIdleTimeout
+}
+
+// remuxer serves the flv streams as hls, the remuxing starts on the first request of the playlist and stops
+// when the player goes away.
+type remuxer struct {
+	log   *slog.Logger
+	relay *streamRelay
+
+	mtx      sync.Mutex
+	sources  map[string]remuxSource
+	sessions map[string]*remuxSession
+}
+
+func newRemuxer(log *slog.Logger, relay *streamRelay) *remuxer {
+	return &remuxer{
+		log:      log,
+		relay:    relay,
+		sources:  make(map[string]remuxSource),
+		sessions: make(map[string]*remuxSession),
+	}
+}
+
+// addSource returns the id of the stream, the same stream always has the same id.
+func (r *remuxer) addSource(origin url.URL, header http.Header) string {
+	hs := sha1.Sum([]byte(headersDigest(header) + "\n" + origin.String()))
+	id := hex.EncodeToString(hs[:])[:16]
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for sid, src := range r.sources {
+		if _, ok := r.sessions[sid]; !ok && time.Since(src.lastAccess) > remuxSourceIdleTimeout {
+			delete(r.sources, sid)
+		}
+	}
+	r.sources[id] = remuxSource{origin: origin, header: header.Clone(), lastAccess: time.Now()}
+	return id
+}
+
+func (r *remuxer) source(id string) (remuxSource, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	src, ok := r.sources[id]
+	return src, ok
+}
+
+func (r *remuxer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	id, name, ok := strings.Cut(strings.TrimPrefix(req.URL.Path, remuxPath), "/")
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	s, err := r.session(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		r.log.Error("cannot get session", "id", id, "error", err)
+		return
+	}
+	s.touch()
+	if name == remuxPlaylist {
+		select {
+		case <-s.rm.Ready():
+		case <-req.Context().Done():
+			return
+		case <-time.After(remuxReadyTimeout):
+			writeError(w, http.StatusGatewayTimeout, errors.New("stream is not ready"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(s.rm.Playlist())
+		return
+	}
+	seg, ok := s.rm.Segment(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("segment not found"))
+		return
+	}
+	w.Header().Set("Content-Type", "video/mp2t")
+	_, _ = w.Write(seg)
+}
+
+// session returns the running session of the stream, or starts a new one.
+func (r *remuxer) session(id string) (*remuxSession, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	src, ok := r.sources[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown stream: %s", id)
+	}
+	src.lastAccess = time.Now()
+	r.sources[id] = src
+	if s, ok := r.sessions[id]; ok {
+		return s, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &remuxSession{
+		rm:         remux.New(r.log, remux.Options{}),
+		cancel:     cancel,
+		lastAccess: time.Now(),
+	}
+	r.sessions[id] = s
+	go r.run(ctx, id, src, s)
+	go r.reap(id, s)
+	return s, nil
+}
+
+func (r *remuxer) run(ctx context.Context, id string, src remuxSource, s *remuxSession) {
+	log := r.log.With("id", id, "origin", src.origin.Host)
+	if !r.relay.guard.allowed(&src.origin) {
+		log.Error("origin is not allowed")
+		s.rm.End()
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.origin.String(), nil)
+	if err != nil {
+		log.Error("cannot create new request", "error", err)
+		s.rm.End()
+		return
+	}
+	for k, vs := range src.header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	res, err := r.relay.guard.client.Do(req)
+	if err != nil {
+		log.Error("cannot do request", "error", err)
+		s.rm.End()
+		return
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			log.Error("failed to close response body", "error", err)
+		}
+	}()
+	if res.StatusCode != http.StatusOK {
+		log.Error("unexpected status code", "code", res.StatusCode)
+		s.rm.End()
+		return
+	}
+	log.Info("remux started")
+	if err := s.rm.Run(res.Body); err != nil && ctx.Err() == nil {
+		log.Error("remux failed", "error", err)
+		return
+	}
+	log.Info("remux ended")
+}
+
+// reap stops the session when it is idle, the ended session is kept until idle so that the player can
+// finish the playlist.
+func (r *remuxer) reap(id string, s *remuxSession) {
+	t := time.NewTicker(remuxIdleTimeout / 3)
+	defer t.Stop()
+	for range t.C {
+		if s.idle() {
+			r.closeSession(id, s)
+			return
+		}
+	}
+}
+
+func (r *remuxer) closeSession(id string, s *remuxSession) {
+	s.cancel()
+	r.mtx.Lock()
+	if r.sessions[id] == s {
+		delete(r.sessions, id)
+	}
+	r.mtx.Unlock()
+	r.log.Info("remux session closed", "id", id)
+}
+
+// closeAll stops all sessions, it is called when the server stops.
+func (r *remuxer) closeAll() {
+	r.mtx.Lock()
+	ss := make(map[string]*remuxSession, len(r.sessions))
+	for id, s := range r.sessions {
+		ss[id] = s
+	}
+	r.mtx.Unlock()
+	for id, s := range ss {
+		r.closeSession(id, s)
+	}
+}
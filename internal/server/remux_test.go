@@ -0,0 +1,102 @@
+package server
+
+import (
+	"asmblive/internal/proxy"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_remuxer_ServeHTTP(t *testing.T) {
+	flv, err := os.ReadFile("../remux/testData/sample.flv")
+	assert.NoError(t, err)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Referer") != "https://live.com/" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "video/x-flv")
+		_, _ = w.Write(flv)
+	}))
+	defer ts.Close()
+	origin, _ := url.Parse(ts.URL + "/live.flv")
+	get := func(rm *remuxer, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		rm.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("should serve the remuxed playlist and segments", func(t *testing.T) {
+		rm := newRemuxer(slog.Default(), newStreamRelay(slog.Default(), loopbackGuard()))
+		defer rm.closeAll()
+		id := rm.addSource(*origin, http.Header{"Referer": {"https://live.com/"}})
+		w := get(rm, remuxPath+id+"/"+remuxPlaylist)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/vnd.apple.mpegurl", w.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(w.Body.String(), "#EXTM3U\n"))
+		assert.Contains(t, w.Body.String(), "\n0.ts\n")
+
+		w = get(rm, remuxPath+id+"/0.ts")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "video/mp2t", w.Header().Get("Content-Type"))
+		assert.Equal(t, 0, w.Body.Len()%188)
+
+		w = get(rm, remuxPath+id+"/99.ts")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("should end the playlist if the origin rejects", func(t *testing.T) {
+		rm := newRemuxer(slog.Default(), newStreamRelay(slog.Default(), loopbackGuard()))
+		defer rm.closeAll()
+		id := rm.addSource(*origin, nil)
+		w := get(rm, remuxPath+id+"/"+remuxPlaylist)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "#EXT-X-ENDLIST\n")
+	})
+
+	t.Run("should end the playlist if the origin is not allowed", func(t *testing.T) {
+		g := newHostGuard(proxy.Transport(nil))
+		g.allow("", "example.com")
+		rm := newRemuxer(slog.Default(), newStreamRelay(slog.Default(), g))
+		defer rm.closeAll()
+		id := rm.addSource(*origin, http.Header{"Referer": {"https://live.com/"}})
+		w := get(rm, remuxPath+id+"/"+remuxPlaylist)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "#EXT-X-ENDLIST\n")
+		assert.NotContains(t, w.Body.String(), ".ts\n")
+	})
+
+	t.Run("should return not found for unknown stream", func(t *testing.T) {
+		rm := newRemuxer(slog.Default(), newStreamRelay(slog.Default(), loopbackGuard()))
+		w := get(rm, remuxPath+"unknown/"+remuxPlaylist)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("should return the same id for the same stream", func(t *testing.T) {
+		rm := newRemuxer(slog.Default(), newStreamRelay(slog.Default(), loopbackGuard()))
+		h := http.Header{"Referer": {"https://live.com/"}}
+		assert.Equal(t, rm.addSource(*origin, h), rm.addSource(*origin, h))
+		assert.NotEqual(t, rm.addSource(*origin, h), rm.addSource(*origin, nil))
+	})
+
+	t.Run("should remove the idle sources", func(t *testing.T) {
+		rm := newRemuxer(slog.Default(), newStreamRelay(slog.Default(), loopbackGuard()))
+		id := rm.addSource(*origin, nil)
+		src := rm.sources[id]
+		src.lastAccess = time.Now().Add(-remuxSourceIdleTimeout - time.Second)
+		rm.sources[id] = src
+		other, _ := url.Parse(ts.URL + "/other.flv")
+		rm.addSource(*other, nil)
+		_, ok := rm.source(id)
+		assert.False(t, ok)
+		assert.Len(t, rm.sources, 1)
+	})
+}
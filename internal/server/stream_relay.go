@@ -0,0 +1,301 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	streamPath   = "/stream"
+	headersIdKey = "h"
+)
+
+// relayHeadersIdleTimeout removes the headers which are not added or used for a while, the headers of the
+// rooms which are not played any more would pile up otherwise.
+const relayHeadersIdleTimeout = 10 * time.Minute
+
+// maxPlaylistSize is the max size of a playlist to be rewritten.
+const maxPlaylistSize = 4 * 1024 * 1024 // 4 MB
+
+// uriAttrRe matches the URI attribute of the tags like EXT-X-KEY and EXT-X-MAP.
+var uriAttrRe = regexp.MustCompile(`URI="([^"]*)"`)
+
+// forwardedHeaders are copied from the player request to the upstream request.
+var forwardedHeaders = []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"}
+
+// relayedHeaders are copied from the upstream response to the player response.
+var relayedHeaders = []string{
+	"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges",
+	"Cache-Control", "Etag", "Last-Modified", "Expires",
+}
+
+// streamRelay fetches the streams with the registered request headers, so that the player can play the
+// streams which require Referer, User-Agent or Cookie. The playlists are rewritten to relay the segments too.
+// Only the hosts allowed by the platforms are relayed, as the cors proxy does.
+type streamRelay struct {
+	log   *slog.Logger
+	guard *hostGuard
+
+	mtx     sync.Mutex
+	headers map[string]relayHeaders
+}
+
+// relayHeaders are the registered headers, they are sent only to the hosts of the platform of the origin
+// which they are registered with, so that the cookies of a platform are not sent to the others.
+type relayHeaders struct {
+	header     http.Header
+	platformId string
+	lastAccess time.Time
+}
+
+func newStreamRelay(log *slog.Logger, g *hostGuard) *streamRelay {
+	return &streamRelay{
+		log:     log,
+		guard:   g,
+		headers: make(map[string]relayHeaders),
+	}
+}
+
+// addHeaders registers the headers for the platform of the origin and returns their id, the same headers of
+// the same platform always have the same id. The headers not used for a while are removed.
+func (s *streamRelay) addHeaders(origin url.URL, h http.Header) string {
+	if len(h) == 0 {
+		return ""
+	}
+	platformId, _ := s.guard.platform(origin.Host)
+	hs := sha1.Sum([]byte(platformId + "\n" + headersDigest(h)))
+	id := hex.EncodeToString(hs[:])[:16]
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for hid, rh := range s.headers {
+		if time.Since(rh.lastAccess) > relayHeadersIdleTimeout {
+			delete(s.headers, hid)
+		}
+	}
+	rh, ok := s.headers[id]
+	if !ok {
+		rh = relayHeaders{header: h.Clone(), platformId: platformId}
+	}
+	rh.lastAccess = time.Now()
+	s.headers[id] = rh
+	return id
+}
+
+// header returns the registered headers to be sent to the origin, ok is false if the id is unknown or the
+// origin is not allowed by the platform of the headers.
+func (s *streamRelay) header(id string, origin url.URL) (h http.Header, ok bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	rh, ok := s.headers[id]
+	if !ok || !s.guard.allowedFor(rh.platformId, &origin) {
+		return nil, false
+	}
+	rh.lastAccess = time.Now()
+	s.headers[id] = rh
+	return rh.header, true
+}
+
+// headersDigest returns the digest of the headers, which does not depend on the order of the keys.
+func headersDigest(h http.Header) string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, http.CanonicalHeaderKey(k))
+	}
+	sort.Strings(keys)
+	hs := sha1.New()
+	for _, k := range keys {
+		for _, v := range h.Values(k) {
+			_, _ = fmt.Fprintf(hs, "%s: %s\n", k, v)
+		}
+	}
+	return hex.EncodeToString(hs.Sum(nil))
+}
+
+// relayPath returns the relative relay url of the origin, it is used in the rewritten playlists.
+func relayPath(origin url.URL, headersId string) url.URL {
+	u := url.URL{Path: streamPath}
+	q := u.Query()
+	q.Set(originKey, origin.String())
+	if headersId != "" {
+		q.Set(headersIdKey, headersId)
+	}
+	u.RawQuery = q.Encode()
+	return u
+}
+
+func (s *streamRelay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if req.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(forwardedHeaders, ", "))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	origin, err := url.Parse(req.URL.Query().Get(originKey))
+	if err != nil || (origin.Scheme != "http" && origin.Scheme != "https") {
+		writeError(w, http.StatusBadRequest, errors.New("valid origin is required"))
+		s.log.Error("valid origin is required", "origin", req.URL.Query().Get(originKey))
+		return
+	}
+	if !s.guard.allowed(origin) {
+		writeError(w, http.StatusForbidden, errors.New("origin is not allowed"))
+		s.log.Warn("origin is not allowed", "origin", origin.Host)
+		return
+	}
+	hid := req.URL.Query().Get(headersIdKey)
+	var h http.Header
+	if hid != "" {
+		var ok bool
+		if h, ok = s.header(hid, *origin); !ok {
+			writeError(w, http.StatusBadRequest, errors.New("unknown headers"))
+			s.log.Error("unknown headers", "id", hid, "origin", origin.Host)
+			return
+		}
+	}
+	newReq, err := http.NewRequestWithContext(req.Context(), req.Method, origin.String(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		s.log.Error("cannot create new request", "error", err)
+		return
+	}
+	for k, vs := range h {
+		for _, v := range vs {
+			newReq.Header.Add(k, v)
+		}
+	}
+	for _, k := range forwardedHeaders {
+		if v := req.Header.Get(k); v != "" {
+			newReq.Header.Set(k, v)
+		}
+	}
+	res, err := s.guard.client.Do(newReq)
+	if err != nil {
+		code := http.StatusBadGateway
+		if errors.Is(err, ErrBlockedAddress) || errors.Is(err, ErrHostNotAllowed) {
+			code = http.StatusForbidden
+		}
+		writeError(w, code, err)
+		s.log.Error("cannot do request", "error", err)
+		return
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			s.log.Error("failed to close response body", "error", err)
+		}
+	}()
+	if res.StatusCode == http.StatusOK && isPlaylist(res) {
+		s.relayPlaylist(w, res, hid)
+		return
+	}
+	for _, k := range relayedHeaders {
+		if v := res.Header.Get(k); v != "" {
+			w.Header().Set(k, v)
+		}
+	}
+	w.WriteHeader(res.StatusCode)
+	fw := flushWriter{w: w, rc: http.NewResponseController(w)}
+	if _, err := io.CopyBuffer(fw, res.Body, make([]byte, bufferSize)); err != nil {
+		// the player closes the connection when switching streams
+		s.log.Info("stream relay ended", "origin", origin.Host, "error", err)
+	}
+}
+
+// relayPlaylist rewrites the uris of the playlist with the relay url.
+func (s *streamRelay) relayPlaylist(w http.ResponseWriter, res *http.Response, hid string) {
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxPlaylistSize+1))
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		s.log.Error("failed to read playlist", "error", err)
+		return
+	}
+	if len(body) > maxPlaylistSize {
+		writeError(w, http.StatusBadGateway, errors.New("playlist is too large"))
+		s.log.Error("playlist is too large")
+		return
+	}
+	// the uris are relative to the final url after redirects
+	base := res.Request.URL
+	out := rewritePlaylist(body, func(uri string) string {
+		ref, err := url.Parse(uri)
+		if err != nil {
+			return uri
+		}
+		u := relayPath(*base.ResolveReference(ref), hid)
+		return u.String()
+	})
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(out); err != nil {
+		s.log.Error("failed to write playlist", "error", err)
+	}
+}
+
+// rewritePlaylist replaces the uri lines and the URI attributes of the tags with rewrite.
+func rewritePlaylist(body []byte, rewrite func(uri string) string) []byte {
+	var out bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	sc.Buffer(make([]byte, bufferSize), maxPlaylistSize)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			line = uriAttrRe.ReplaceAllStringFunc(line, func(m string) string {
+				return `URI="` + rewrite(uriAttrRe.FindStringSubmatch(m)[1]) + `"`
+			})
+		default:
+			line = rewrite(line)
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+func isPlaylist(res *http.Response) bool {
+	ct := strings.ToLower(res.Header.Get("Content-Type"))
+	if strings.Contains(ct, "mpegurl") {
+		return true
+	}
+	return strings.HasSuffix(res.Request.URL.Path, ".m3u8")
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = fmt.Fprintf(w, `{"error": %q}`, err.Error())
+}
+
+// flushWriter flushes after every write, so that the live stream is not buffered.
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if err := f.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return n, err
+	}
+	return n, nil
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"asmblive/internal/proxy"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_rewritePlaylist(t *testing.T) {
+	in := "#EXTM3U\n" +
+		"#EXT-X-MAP:URI=\"init.mp4\"\n" +
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"https://key.com/k\",IV=0x1\n" +
+		"\n" +
+		"#EXTINF:1.0,\n" +
+		"seg1.m4s\r\n"
+	want := "#EXTM3U\n" +
+		"#EXT-X-MAP:URI=\"[init.mp4]\"\n" +
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"[https://key.com/k]\",IV=0x1\n" +
+		"\n" +
+		"#EXTINF:1.0,\n" +
+		"[seg1.m4s]\n"
+	got := rewritePlaylist([]byte(in), func(uri string) string { return "[" + uri + "]" })
+	assert.Equal(t, want, string(got))
+}
+
+func Test_streamRelay_addHeaders(t *testing.T) {
+	g := loopbackGuard()
+	g.allow("other", "example.com")
+	s := newStreamRelay(slog.Default(), g)
+	origin := url.URL{Scheme: "http", Host: "127.0.0.1"}
+	a := s.addHeaders(origin, http.Header{"Referer": {"a"}, "User-Agent": {"b"}})
+	b := s.addHeaders(origin, http.Header{"User-Agent": {"b"}, "Referer": {"a"}})
+	c := s.addHeaders(origin, http.Header{"Referer": {"c"}})
+	d := s.addHeaders(url.URL{Scheme: "http", Host: "example.com"}, http.Header{"Referer": {"a"}, "User-Agent": {"b"}})
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c)
+	assert.NotEqual(t, a, d)
+	assert.Equal(t, "", s.addHeaders(origin, nil))
+
+	t.Run("should return the headers for the hosts of the platform only", func(t *testing.T) {
+		h, ok := s.header(a, url.URL{Scheme: "http", Host: "127.0.0.1:8080"})
+		assert.True(t, ok)
+		assert.Equal(t, http.Header{"Referer": {"a"}, "User-Agent": {"b"}}, h)
+		_, ok = s.header(a, url.URL{Scheme: "http", Host: "example.com"})
+		assert.False(t, ok)
+		_, ok = s.header(d, url.URL{Scheme: "http", Host: "example.com"})
+		assert.True(t, ok)
+		_, ok = s.header("unknown", url.URL{Scheme: "http", Host: "127.0.0.1"})
+		assert.False(t, ok)
+	})
+
+	t.Run("should remove the idle headers", func(t *testing.T) {
+		s.mtx.Lock()
+		rh := s.headers[c]
+		rh.lastAccess = time.Now().Add(-relayHeadersIdleTimeout - time.Second)
+		s.headers[c] = rh
+		s.mtx.Unlock()
+		s.addHeaders(origin, http.Header{"Referer": {"a"}, "User-Agent": {"b"}})
+		_, ok := s.header(c, origin)
+		assert.False(t, ok)
+		_, ok = s.header(a, origin)
+		assert.True(t, ok)
+	})
+}
+
+func Test_streamRelay_ServeHTTP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Referer") != "https://live.com/" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/live/index.m3u8":
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			_, _ = w.Write([]byte("#EXTM3U\n#EXTINF:1.0,\nseg1.ts?t=1\n"))
+		case "/live/seg1.ts":
+			w.Header().Set("Content-Type", "video/mp2t")
+			if r.Header.Get("Range") != "" {
+				w.Header().Set("Content-Range", "bytes 0-3/8")
+				w.WriteHeader(http.StatusPartialContent)
+			}
+			_, _ = w.Write([]byte("data"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	g := loopbackGuard()
+	g.allow("other", "localhost")
+	s := newStreamRelay(slog.Default(), g)
+	tu, _ := url.Parse(ts.URL)
+	hid := s.addHeaders(*tu, http.Header{"Referer": {"https://live.com/"}})
+	relay := func(method string, origin string, hid string, header http.Header) *httptest.ResponseRecorder {
+		o, _ := url.Parse(origin)
+		u := relayPath(*o, hid)
+		req := httptest.NewRequest(method, u.String(), nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("should rewrite the playlist", func(t *testing.T) {
+		w := relay(http.MethodGet, ts.URL+"/live/index.m3u8", hid, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/vnd.apple.mpegurl", w.Header().Get("Content-Type"))
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		seg, _ := url.Parse(ts.URL + "/live/seg1.ts?t=1")
+		su := relayPath(*seg, hid)
+		assert.Equal(t, "#EXTM3U\n#EXTINF:1.0,\n"+su.String()+"\n", w.Body.String())
+	})
+
+	t.Run("should relay the segment with range", func(t *testing.T) {
+		w := relay(http.MethodGet, ts.URL+"/live/seg1.ts", hid, http.Header{"Range": {"bytes=0-3"}})
+		assert.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, "video/mp2t", w.Header().Get("Content-Type"))
+		assert.Equal(t, "bytes 0-3/8", w.Header().Get("Content-Range"))
+		assert.Equal(t, "data", w.Body.String())
+	})
+
+	t.Run("should relay the upstream error", func(t *testing.T) {
+		w := relay(http.MethodGet, ts.URL+"/live/seg1.ts", "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("should reject unknown headers", func(t *testing.T) {
+		w := relay(http.MethodGet, ts.URL+"/live/seg1.ts", "unknown", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should reject the headers of another platform", func(t *testing.T) {
+		host := strings.Replace(ts.Listener.Addr().String(), "127.0.0.1", "localhost", 1)
+		w := relay(http.MethodGet, "http://"+host+"/live/seg1.ts", hid, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should reject invalid origin", func(t *testing.T) {
+		w := relay(http.MethodGet, "file:///etc/passwd", hid, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should reject the host not allowed", func(t *testing.T) {
+		w := relay(http.MethodGet, "http://example.com/live/seg1.ts", hid, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("should reject the host resolving to a private address", func(t *testing.T) {
+		g := newHostGuard(proxy.Transport(nil))
+		g.allow("", "localhost")
+		s := newStreamRelay(slog.Default(), g)
+		host := strings.Replace(ts.Listener.Addr().String(), "127.0.0.1", "localhost", 1)
+		u := relayPath(url.URL{Scheme: "http", Host: host, Path: "/live/seg1.ts"}, "")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, u.String(), nil))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("should reject other methods", func(t *testing.T) {
+		w := relay(http.MethodPost, ts.URL+"/live/seg1.ts", hid, nil)
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
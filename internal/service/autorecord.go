@@ -0,0 +1,311 @@
+package service
+
+import (
+	"asmblive/internal/store"
+	"asmblive/internal/watcher"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// autoRecordInterval is the delay between two polls of the ruled rooms.
+	autoRecordInterval = 30 * time.Second
+	// maxRecordHistory is the max number of the kept history entries, the oldest ones are dropped.
+	maxRecordHistory = 500
+)
+
+// AutoRecordService polls the rooms of the enabled rules in background and records them when they are live,
+// the finished recordings are kept in the history.
+type AutoRecordService struct {
+	log     *slog.Logger
+	rs      *RecordingService
+	rules   store.Store[[]RecordRuleDTO]
+	history store.Store[[]RecordHistoryDTO]
+	w       *watcher.Watcher
+	now     func() time.Time
+
+	mtx sync.Mutex
+	// active is keyed by the rule id, the value is nil while the recording is starting.
+	active  map[string]*recording
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func NewAutoRecordService(log *slog.Logger, ps *PlatformService, rs *RecordingService) *AutoRecordService {
+	log = log.With("module", "service/autorecord")
+	s := &AutoRecordService{
+		log:     log,
+		rs:      rs,
+		rules:   store.New[[]RecordRuleDTO]("record_rules", make([]RecordRuleDTO, 0)),
+		history: store.New[[]RecordHistoryDTO]("record_history", make([]RecordHistoryDTO, 0)),
+		now:     time.Now,
+		active:  make(map[string]*recording),
+	}
+	s.w = watcher.New(log, ps.pm, watcher.Options{
+		Interval: func() time.Duration {
+			return autoRecordInterval
+		},
+		Targets:  s.targets,
+		Gaps:     watcherGaps,
+		OnStatus: s.onStatus,
+	})
+	return s
+}
+
+// Start starts polling in background, it must be called on startup. It does nothing if the polling is
+// running.
+func (s *AutoRecordService) Start() {
+	s.mtx.Lock()
+	s.stopped = false
+	s.mtx.Unlock()
+	s.w.Start()
+}
+
+// Stop stops polling and the running auto recordings, it must be called on shutdown so that the
+// history is saved.
+func (s *AutoRecordService) Stop() {
+	s.w.Stop()
+	s.mtx.Lock()
+	s.stopped = true
+	rs := make([]*recording, 0, len(s.active))
+	for _, r := range s.active {
+		if r != nil {
+			rs = append(rs, r)
+		}
+	}
+	s.mtx.Unlock()
+	for _, r := range rs {
+		r.rec.Stop()
+	}
+	s.wg.Wait()
+}
+
+func (s *AutoRecordService) GetRecordRules() []RecordRuleDTO {
+	rs, err := s.rules.Read()
+	if err != nil {
+		s.log.Error("error getting record rules", "err", err)
+		return make([]RecordRuleDTO, 0)
+	}
+	return rs
+}
+
+func (s *AutoRecordService) AddRecordRule(r RecordRuleDTO) *RecordRuleDTO {
+	if err := r.validate(); err != nil {
+		s.log.Error("error adding record rule", "err", err)
+		return nil
+	}
+	rs, err := s.rules.Read()
+	if err != nil {
+		s.log.Error("error getting record rules", "err", err)
+		return nil
+	}
+	for _, o := range rs {
+		if o.Id == r.Id {
+			s.log.Error("error adding record rule", "err", "rule exists")
+			return nil
+		}
+	}
+	if err := s.rules.Write(append(rs, r)); err != nil {
+		s.log.Error("error writing record rules", "err", err)
+		return nil
+	}
+	return &r
+}
+
+func (s *AutoRecordService) UpdateRecordRule(nr RecordRuleDTO) *RecordRuleDTO {
+	if err := nr.validate(); err != nil {
+		s.log.Error("error updating record rule", "err", err)
+		return nil
+	}
+	rs, err := s.rules.Read()
+	if err != nil {
+		s.log.Error("error getting record rules", "err", err)
+		return nil
+	}
+	var updated bool
+	for i, r := range rs {
+		if r.Id == nr.Id {
+			updated = true
+			rs[i] = nr
+			break
+		}
+	}
+	if !updated {
+		s.log.Error("error updating record rule", "err", "rule not found")
+		return nil
+	}
+	if err := s.rules.Write(rs); err != nil {
+		s.log.Error("error writing record rules", "err", err)
+		return nil
+	}
+	return &nr
+}
+
+// RemoveRecordRule does not stop the running recording of the rule.
+func (s *AutoRecordService) RemoveRecordRule(id string) *RecordRuleDTO {
+	rs, err := s.rules.Read()
+	if err != nil {
+		s.log.Error("error getting record rules", "err", err)
+		return nil
+	}
+	newRs := make([]RecordRuleDTO, 0, len(rs))
+	var removed RecordRuleDTO
+	for _, r := range rs {
+		if r.Id != id {
+			newRs = append(newRs, r)
+		} else {
+			removed = r
+		}
+	}
+	if removed.Id == "" {
+		s.log.Error("error removing record rule", "err", "rule not found")
+		return nil
+	}
+	if err := s.rules.Write(newRs); err != nil {
+		s.log.Error("error writing record rules", "err", err)
+		return nil
+	}
+	return &removed
+}
+
+// GetActiveRecordings returns the running recordings keyed by the rule id.
+func (s *AutoRecordService) GetActiveRecordings() map[string]*RecordingDto {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	ds := make(map[string]*RecordingDto, len(s.active))
+	for id, r := range s.active {
+		if r != nil {
+			d := newRecordingDto(r)
+			ds[id] = &d
+		}
+	}
+	return ds
+}
+
+// GetRecordHistory returns the finished auto recordings, the latest goes first.
+func (s *AutoRecordService) GetRecordHistory() []RecordHistoryDTO {
+	hs, err := s.history.Read()
+	if err != nil {
+		s.log.Error("error getting record history", "err", err)
+		return make([]RecordHistoryDTO, 0)
+	}
+	r := make([]RecordHistoryDTO, len(hs))
+	for i, h := range hs {
+		r[len(hs)-1-i] = h
+	}
+	return r
+}
+
+func (s *AutoRecordService) ClearRecordHistory() bool {
+	if err := s.history.Write(make([]RecordHistoryDTO, 0)); err != nil {
+		s.log.Error("error writing record history", "err", err)
+		return false
+	}
+	return true
+}
+
+// targets returns the distinct rooms of the enabled rules.
+func (s *AutoRecordService) targets() []watcher.Target {
+	seen := make(map[watcher.Target]bool)
+	ts := make([]watcher.Target, 0)
+	for _, r := range s.GetRecordRules() {
+		t := watcher.Target{PlatformId: r.PlatformId, RoomId: r.RoomId}
+		if !r.Enabled || seen[t] {
+			continue
+		}
+		seen[t] = true
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+func (s *AutoRecordService) onStatus(st watcher.Status) {
+	if !st.Room.IsOnline {
+		return
+	}
+	now := s.now()
+	for _, r := range s.GetRecordRules() {
+		if r.PlatformId != st.PlatformId || r.RoomId != st.RoomId || !r.matches(st.Room.Title, now) {
+			continue
+		}
+		s.mtx.Lock()
+		_, ok := s.active[r.Id]
+		if !ok {
+			s.active[r.Id] = nil
+		}
+		s.mtx.Unlock()
+		if ok {
+			continue
+		}
+		s.wg.Add(1)
+		// starting a recording resolves the urls, it must not block the watcher
+		go s.record(r)
+	}
+}
+
+// record starts a recording of the rule and saves the history when it ends, it does nothing if the room is
+// being recorded with the quality.
+func (s *AutoRecordService) record(rule RecordRuleDTO) {
+	defer s.wg.Done()
+	defer func() {
+		s.mtx.Lock()
+		delete(s.active, rule.Id)
+		s.mtx.Unlock()
+	}()
+	r, created := s.rs.start(rule.PlatformId, rule.RoomId, rule.QualityId, rule.MaxBytes, time.Duration(rule.MaxDuration)*time.Second)
+	if r == nil {
+		return
+	}
+	if !created {
+		// the recording is started by the user or another rule, it is neither stopped nor saved as the
+		// history of the rule, the rule starts its own one after it ends
+		s.log.Info("room is being recorded", "ruleId", rule.Id, "id", r.id)
+		return
+	}
+	s.log.Info("auto recording started", "ruleId", rule.Id, "id", r.id)
+	s.mtx.Lock()
+	s.active[rule.Id] = r
+	stopped := s.stopped
+	s.mtx.Unlock()
+	if stopped {
+		// the service is stopped while starting
+		r.rec.Stop()
+	}
+	<-r.rec.Done()
+	s.addHistory(newRecordHistoryDto(rule.Id, r))
+}
+
+func (s *AutoRecordService) addHistory(h RecordHistoryDTO) {
+	hs, err := s.history.Read()
+	if err != nil {
+		s.log.Error("error getting record history", "err", err)
+		return
+	}
+	hs = append(hs, h)
+	if len(hs) > maxRecordHistory {
+		hs = hs[len(hs)-maxRecordHistory:]
+	}
+	if err := s.history.Write(hs); err != nil {
+		s.log.Error("error writing record history", "err", err)
+	}
+}
+
+func newRecordHistoryDto(ruleId string, r *recording) RecordHistoryDTO {
+	d := newRecordingDto(r)
+	return RecordHistoryDTO{
+		Id:         d.Id,
+		RuleId:     ruleId,
+		PlatformId: d.PlatformId,
+		RoomId:     d.RoomId,
+		QualityId:  d.QualityId,
+		Title:      d.Title,
+		OwnerName:  d.OwnerName,
+		Paths:      d.Paths,
+		Bytes:      d.Bytes,
+		StartedAt:  d.StartedAt,
+		EndedAt:    d.StartedAt + d.Duration,
+		State:      d.State,
+		Error:      d.Error,
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type RecordRuleDTO struct {
+	Id         string `json:"id"`
+	PlatformId string `json:"platformId"`
+	RoomId     string `json:"roomId"`
+	QualityId  string `json:"qualityId"`
+	// Keywords filter the rooms by title, the room matches if its title contains any of them, all titles
+	// match if it is empty.
+	Keywords []string `json:"keywords"`
+	// TimeWindows are local time ranges like "20:00-02:00", a range may cross midnight, all times match
+	// if it is empty.
+	TimeWindows []string `json:"timeWindows"`
+	// MaxBytes and MaxDuration split the recording into files, 0 means no limit.
+	MaxBytes int64 `json:"maxBytes"`
+	// MaxDuration is in seconds.
+	MaxDuration int64 `json:"maxDuration"`
+	Enabled     bool  `json:"enabled"`
+}
+
+func (r RecordRuleDTO) validate() error {
+	if r.Id == "" || r.PlatformId == "" || r.RoomId == "" {
+		return fmt.Errorf("id, platform id and room id are required")
+	}
+	if r.MaxBytes < 0 || r.MaxDuration < 0 {
+		return fmt.Errorf("max bytes and max duration must not be negative")
+	}
+	for _, w := range r.TimeWindows {
+		if _, _, err := parseTimeWindow(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches reports whether a live room with the title should be recorded at now.
+func (r RecordRuleDTO) matches(title string, now time.Time) bool {
+	if !r.Enabled {
+		return false
+	}
+	if len(r.Keywords) > 0 {
+		found := false
+		lt := strings.ToLower(title)
+		for _, k := range r.Keywords {
+			if k != "" && strings.Contains(lt, strings.ToLower(k)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.TimeWindows) == 0 {
+		return true
+	}
+	m := now.Hour()*60 + now.Minute()
+	for _, w := range r.TimeWindows {
+		start, end, err := parseTimeWindow(w)
+		if err != nil {
+			continue
+		}
+		if start <= end && m >= start && m < end {
+			return true
+		}
+		// the window crosses midnight
+		if start > end && (m >= start || m < end) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeWindow returns the start and the end in minutes of the day.
+func parseTimeWindow(w string) (int, int, error) {
+	se := strings.Split(w, "-")
+	if len(se) != 2 {
+		return 0, 0, fmt.Errorf("invalid time window: %s", w)
+	}
+	var ms [2]int
+	for i, v := range se {
+		t, err := time.Parse("15:04", strings.TrimSpace(v))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid time window: %s", w)
+		}
+		ms[i] = t.Hour()*60 + t.Minute()
+	}
+	return ms[0], ms[1], nil
+}
+
+type RecordHistoryDTO struct {
+	// Id is the id of the recording.
+	Id         string   `json:"id"`
+	RuleId     string   `json:"ruleId"`
+	PlatformId string   `json:"platformId"`
+	RoomId     string   `json:"roomId"`
+	QualityId  string   `json:"qualityId"`
+	Title      string   `json:"title"`
+	OwnerName  string   `json:"ownerName"`
+	Paths      []string `json:"paths"`
+	Bytes      int64    `json:"bytes"`
+	// StartedAt and EndedAt are in milliseconds.
+	StartedAt int64 `json:"startedAt"`
+	EndedAt   int64 `json:"endedAt"`
+	// State is one of finished and failed.
+	State string `json:"state"`
+	Error string `json:"error"`
+}
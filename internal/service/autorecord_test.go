@@ -0,0 +1,218 @@
+package service
+
+import (
+	"asmblive/internal/platform"
+	"asmblive/internal/setting"
+	"asmblive/internal/watcher"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memStore[T any] struct {
+	mtx sync.Mutex
+	v   T
+}
+
+func (m *memStore[T]) Read() (T, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.v, nil
+}
+
+func (m *memStore[T]) Write(v T) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.v = v
+	return nil
+}
+
+func TestRecordRuleDTO_matches(t *testing.T) {
+	at := func(hm string) time.Time {
+		v, _ := time.Parse("15:04", hm)
+		return time.Date(2024, 7, 24, v.Hour(), v.Minute(), 0, 0, time.Local)
+	}
+	tests := []struct {
+		name  string
+		rule  RecordRuleDTO
+		title string
+		now   time.Time
+		want  bool
+	}{
+		{
+			name: "should match without filters",
+			rule: RecordRuleDTO{Enabled: true},
+			now:  at("12:00"),
+			want: true,
+		},
+		{
+			name: "should not match since disabled",
+			rule: RecordRuleDTO{},
+			now:  at("12:00"),
+			want: false,
+		},
+		{
+			name:  "should match the keyword ignoring case",
+			rule:  RecordRuleDTO{Enabled: true, Keywords: []string{"foo", "Game"}},
+			title: "playing games",
+			now:   at("12:00"),
+			want:  true,
+		},
+		{
+			name:  "should not match since no keyword in title",
+			rule:  RecordRuleDTO{Enabled: true, Keywords: []string{"foo"}},
+			title: "playing games",
+			now:   at("12:00"),
+			want:  false,
+		},
+		{
+			name: "should match the time window",
+			rule: RecordRuleDTO{Enabled: true, TimeWindows: []string{"08:00-09:00", "11:30-12:30"}},
+			now:  at("12:00"),
+			want: true,
+		},
+		{
+			name: "should not match since the end is exclusive",
+			rule: RecordRuleDTO{Enabled: true, TimeWindows: []string{"11:00-12:00"}},
+			now:  at("12:00"),
+			want: false,
+		},
+		{
+			name: "should match the time window crossing midnight",
+			rule: RecordRuleDTO{Enabled: true, TimeWindows: []string{"22:00-02:00"}},
+			now:  at("01:00"),
+			want: true,
+		},
+		{
+			name: "should not match since out of the time window crossing midnight",
+			rule: RecordRuleDTO{Enabled: true, TimeWindows: []string{"22:00-02:00"}},
+			now:  at("12:00"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rule.matches(tt.title, tt.now))
+		})
+	}
+}
+
+func TestRecordRuleDTO_validate(t *testing.T) {
+	valid := RecordRuleDTO{Id: "1", PlatformId: "bili", RoomId: "1", TimeWindows: []string{"20:00-02:00"}}
+	assert.NoError(t, valid.validate())
+	missing := valid
+	missing.RoomId = ""
+	assert.Error(t, missing.validate())
+	negative := valid
+	negative.MaxBytes = -1
+	assert.Error(t, negative.validate())
+	invalid := valid
+	invalid.TimeWindows = []string{"20:00"}
+	assert.EqualError(t, invalid.validate(), "invalid time window: 20:00")
+	invalid.TimeWindows = []string{"25:00-26:00"}
+	assert.EqualError(t, invalid.validate(), "invalid time window: 25:00-26:00")
+}
+
+func TestAutoRecordService_rules(t *testing.T) {
+	s := &AutoRecordService{log: slog.Default(), rules: &memStore[[]RecordRuleDTO]{v: make([]RecordRuleDTO, 0)}}
+	r := RecordRuleDTO{Id: "1", PlatformId: "bili", RoomId: "1", Enabled: true}
+	assert.Equal(t, &r, s.AddRecordRule(r))
+	assert.Nil(t, s.AddRecordRule(r))
+	assert.Nil(t, s.AddRecordRule(RecordRuleDTO{Id: "2"}))
+	assert.NotNil(t, s.AddRecordRule(RecordRuleDTO{Id: "2", PlatformId: "bili", RoomId: "1"}))
+	assert.NotNil(t, s.AddRecordRule(RecordRuleDTO{Id: "3", PlatformId: "huya", RoomId: "2", Enabled: true}))
+
+	// the disabled and duplicated rooms are not polled
+	assert.Equal(t, []watcher.Target{{PlatformId: "bili", RoomId: "1"}, {PlatformId: "huya", RoomId: "2"}}, s.targets())
+
+	r.QualityId = "q"
+	assert.Equal(t, &r, s.UpdateRecordRule(r))
+	assert.Nil(t, s.UpdateRecordRule(RecordRuleDTO{Id: "4", PlatformId: "bili", RoomId: "1"}))
+	assert.Equal(t, "q", s.GetRecordRules()[0].QualityId)
+
+	assert.Equal(t, "2", s.RemoveRecordRule("2").Id)
+	assert.Nil(t, s.RemoveRecordRule("2"))
+	assert.Len(t, s.GetRecordRules(), 2)
+}
+
+func TestAutoRecordService_onStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("FLV\x01\x05\x00\x00\x00\x09\x00\x00\x00\x00"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+	lu, _ := url.Parse(ts.URL + "/live.flv")
+	room := platform.Room{Title: "playing games", Owner: platform.Owner{Name: "owner"}, IsOnline: true}
+	rs := NewRecordingService(slog.Default(), &PlatformService{
+		log: slog.Default(),
+		pm: map[string]platform.Platform{
+			"testPlatform": mockPlatform{name: "test", room: room, liveUrls: []url.URL{*lu}},
+		},
+	}, &SettingService{
+		Recording: setting.Recording{
+			Store: mockSettingStore{"recording_dir": t.TempDir()},
+			Log:   slog.Default(),
+		},
+		Proxy: setting.Proxy{Store: mockSettingStore{}, Log: slog.Default()},
+	}, make(mockEmitter, 100))
+	s := &AutoRecordService{
+		log: slog.Default(),
+		rs:  rs,
+		rules: &memStore[[]RecordRuleDTO]{v: []RecordRuleDTO{
+			{Id: "games", PlatformId: "testPlatform", RoomId: "room", Keywords: []string{"game"}, Enabled: true},
+			{Id: "music", PlatformId: "testPlatform", RoomId: "room", Keywords: []string{"music"}, Enabled: true},
+			{Id: "manual", PlatformId: "testPlatform", RoomId: "manual", Enabled: true},
+		}},
+		history: &memStore[[]RecordHistoryDTO]{v: make([]RecordHistoryDTO, 0)},
+		w:       watcher.New(slog.Default(), nil, watcher.Options{}),
+		now:     time.Now,
+		active:  make(map[string]*recording),
+	}
+	st := watcher.Status{Target: watcher.Target{PlatformId: "testPlatform", RoomId: "room"}, Room: room}
+
+	s.onStatus(st)
+	assert.Eventually(t, func() bool {
+		return len(s.GetActiveRecordings()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, s.GetActiveRecordings(), "games")
+
+	// the room is being recorded
+	s.onStatus(st)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, rs.ListRecordings(), 1)
+
+	// the recording started by the user is not taken over by the rule
+	mid := rs.StartRecording("testPlatform", "manual", "")
+	assert.NotEmpty(t, mid)
+	s.onStatus(watcher.Status{Target: watcher.Target{PlatformId: "testPlatform", RoomId: "manual"}, Room: room})
+	time.Sleep(50 * time.Millisecond)
+	assert.NotContains(t, s.GetActiveRecordings(), "manual")
+	assert.Len(t, rs.ListRecordings(), 2)
+
+	s.Stop()
+	assert.Empty(t, s.GetActiveRecordings())
+	// the recording of the user is neither stopped nor saved as the history
+	for _, r := range rs.ListRecordings() {
+		if r.Id == mid {
+			assert.Equal(t, "recording", r.State)
+		}
+	}
+	rs.StopAll()
+	hs := s.GetRecordHistory()
+	assert.Len(t, hs, 1)
+	assert.Equal(t, "games", hs[0].RuleId)
+	assert.Equal(t, "playing games", hs[0].Title)
+	assert.Equal(t, "finished", hs[0].State)
+	assert.Len(t, hs[0].Paths, 1)
+	assert.GreaterOrEqual(t, hs[0].EndedAt, hs[0].StartedAt)
+
+	assert.True(t, s.ClearRecordHistory())
+	assert.Empty(t, s.GetRecordHistory())
+}
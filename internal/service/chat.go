@@ -0,0 +1,177 @@
+package service
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// chatRetryInterval is the delay before reconnecting a broken subscription.
+const chatRetryInterval = 5 * time.Second
+
+// ChatService subscribes the chat of the rooms on boards and emits the events to the frontend,
+// the event name is returned by ChatEventName.
+type ChatService struct {
+	log *slog.Logger
+	ps  *PlatformService
+	em  Emitter
+
+	mtx sync.Mutex
+	// subs is the chat event names subscribed by the boards, keyed by board id.
+	subs map[string]map[string]struct{}
+	// conns is the upstream connections keyed by chat event name, a room on several boards shares one
+	// connection, so that the events are emitted once.
+	conns map[string]*chatConn
+}
+
+// chatConn is an upstream chat connection, it is closed when no board subscribes it.
+type chatConn struct {
+	cancel context.CancelFunc
+	refs   int
+}
+
+func NewChatService(log *slog.Logger, ps *PlatformService, em Emitter) *ChatService {
+	log = log.With("module", "service/chat")
+	return &ChatService{
+		log:   log,
+		ps:    ps,
+		em:    em,
+		subs:  make(map[string]map[string]struct{}),
+		conns: make(map[string]*chatConn),
+	}
+}
+
+// ChatEventName returns the name of the events emitted for the room.
+func (s *ChatService) ChatEventName(platformId string, roomId string) string {
+	return "chat:" + platformId + ":" + roomId
+}
+
+// Subscribe starts emitting the chat events of the room on the board,
+// it returns false if the platform does not support chat.
+func (s *ChatService) Subscribe(boardId string, platformId string, roomId string) bool {
+	p, ok := s.ps.pm[platformId]
+	if !ok {
+		s.log.Warn("cannot find platform", "id", platformId)
+		return false
+	}
+	cs, ok := p.(platform.ChatSource)
+	if !ok {
+		s.log.Warn("platform does not support chat", "id", platformId)
+		return false
+	}
+	name := s.ChatEventName(platformId, roomId)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	bs, ok := s.subs[boardId]
+	if !ok {
+		bs = make(map[string]struct{})
+		s.subs[boardId] = bs
+	}
+	if _, ok := bs[name]; ok {
+		return true
+	}
+	bs[name] = struct{}{}
+	c, ok := s.conns[name]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		c = &chatConn{cancel: cancel}
+		s.conns[name] = c
+		go s.run(ctx, cs, roomId, name)
+	}
+	c.refs++
+	s.log.Info("subscribe chat", "boardId", boardId, "event", name, "refs", c.refs)
+	return true
+}
+
+// Unsubscribe stops emitting the chat events of the room on the board.
+func (s *ChatService) Unsubscribe(boardId string, platformId string, roomId string) {
+	name := s.ChatEventName(platformId, roomId)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	bs, ok := s.subs[boardId]
+	if !ok {
+		return
+	}
+	if _, ok := bs[name]; ok {
+		delete(bs, name)
+		s.release(name)
+		s.log.Info("unsubscribe chat", "boardId", boardId, "event", name)
+	}
+	if len(bs) == 0 {
+		delete(s.subs, boardId)
+	}
+}
+
+// CloseBoard stops all subscriptions of the board, it should be called when the board is closed.
+func (s *ChatService) CloseBoard(boardId string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for name := range s.subs[boardId] {
+		s.release(name)
+	}
+	delete(s.subs, boardId)
+	s.log.Info("close board chat", "boardId", boardId)
+}
+
+// CloseAll stops all subscriptions.
+func (s *ChatService) CloseAll() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, c := range s.conns {
+		c.cancel()
+	}
+	s.subs = make(map[string]map[string]struct{})
+	s.conns = make(map[string]*chatConn)
+	s.log.Info("close all chat")
+}
+
+// release drops a reference of the connection and closes it if it is not referenced, s.mtx must be
+// held.
+func (s *ChatService) release(name string) {
+	c, ok := s.conns[name]
+	if !ok {
+		return
+	}
+	c.refs--
+	if c.refs <= 0 {
+		c.cancel()
+		delete(s.conns, name)
+	}
+}
+
+// run emits the events until the context is done, it reconnects if the connection is broken.
+func (s *ChatService) run(ctx context.Context, cs platform.ChatSource, roomId string, name string) {
+	log := s.log.With("event", name)
+	for {
+		ch, err := cs.Subscribe(ctx, roomId)
+		if err != nil {
+			log.Warn("failed to subscribe chat", "err", err)
+		} else {
+			for e := range ch {
+				s.em.Emit(name, newChatEventDto(e))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(chatRetryInterval):
+			log.Info("resubscribe chat")
+		}
+	}
+}
+
+func newChatEventDto(e platform.ChatEvent) ChatEventDto {
+	return ChatEventDto{
+		Kind: string(e.Kind),
+		User: ChatUserDto{
+			Id:   e.User.Id,
+			Name: e.User.Name,
+		},
+		Text:      e.Text,
+		Amount:    e.Amount,
+		Timestamp: e.Time.UnixMilli(),
+		Raw:       string(e.Raw),
+	}
+}
@@ -0,0 +1,16 @@
+package service
+
+type ChatEventDto struct {
+	Kind   string      `json:"kind"`
+	User   ChatUserDto `json:"user"`
+	Text   string      `json:"text"`
+	Amount int64       `json:"amount"`
+	// Timestamp is in milliseconds.
+	Timestamp int64  `json:"timestamp"`
+	Raw       string `json:"raw"`
+}
+
+type ChatUserDto struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockChatSource struct {
+	mockPlatform
+
+	events []platform.ChatEvent
+	done   chan struct{}
+	// count is the number of the upstream connections, it is optional.
+	count *atomic.Int32
+}
+
+func (m mockChatSource) Subscribe(ctx context.Context, roomId string) (<-chan platform.ChatEvent, error) {
+	if m.count != nil {
+		m.count.Add(1)
+	}
+	ch := make(chan platform.ChatEvent)
+	go func() {
+		defer close(ch)
+		for _, e := range m.events {
+			ch <- e
+		}
+		<-ctx.Done()
+		close(m.done)
+	}()
+	return ch, nil
+}
+
+type emitted struct {
+	name string
+	data []any
+}
+
+type mockEmitter chan emitted
+
+func (m mockEmitter) Emit(name string, data ...any) {
+	m <- emitted{name: name, data: data}
+}
+
+func TestChatService_Subscribe(t *testing.T) {
+	t.Run("should emit events until the board is closed", func(t *testing.T) {
+		ts := time.UnixMilli(1721800000123)
+		cs := mockChatSource{
+			events: []platform.ChatEvent{{
+				Kind: platform.ChatEventMessage,
+				User: platform.ChatUser{Id: "1", Name: "a"},
+				Text: "hi",
+				Time: ts,
+				Raw:  json.RawMessage(`{"cmd":"DANMU_MSG"}`),
+			}},
+			done: make(chan struct{}),
+		}
+		em := make(mockEmitter, 1)
+		s := NewChatService(slog.Default(), &PlatformService{
+			log: slog.Default(),
+			pm:  map[string]platform.Platform{"testPlatform": cs},
+		}, em)
+		assert.True(t, s.Subscribe("board", "testPlatform", "room"))
+		// subscribing twice is a no-op
+		assert.True(t, s.Subscribe("board", "testPlatform", "room"))
+		select {
+		case e := <-em:
+			assert.Equal(t, "chat:testPlatform:room", e.name)
+			assert.Equal(t, []any{ChatEventDto{
+				Kind:      "message",
+				User:      ChatUserDto{Id: "1", Name: "a"},
+				Text:      "hi",
+				Timestamp: 1721800000123,
+				Raw:       `{"cmd":"DANMU_MSG"}`,
+			}}, e.data)
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not emitted")
+		}
+		s.CloseBoard("board")
+		select {
+		case <-cs.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("subscription not cancelled")
+		}
+		assert.Empty(t, s.subs)
+		assert.Empty(t, s.conns)
+	})
+
+	t.Run("should share the connection of the room on several boards", func(t *testing.T) {
+		var count atomic.Int32
+		cs := mockChatSource{
+			events: []platform.ChatEvent{{Kind: platform.ChatEventMessage, Text: "hi"}},
+			done:   make(chan struct{}),
+			count:  &count,
+		}
+		em := make(mockEmitter, 2)
+		s := NewChatService(slog.Default(), &PlatformService{
+			log: slog.Default(),
+			pm:  map[string]platform.Platform{"testPlatform": cs},
+		}, em)
+		assert.True(t, s.Subscribe("board1", "testPlatform", "room"))
+		assert.True(t, s.Subscribe("board2", "testPlatform", "room"))
+		select {
+		case <-em:
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not emitted")
+		}
+		select {
+		case <-em:
+			t.Fatal("event emitted twice")
+		case <-time.After(100 * time.Millisecond):
+		}
+		assert.Equal(t, int32(1), count.Load())
+		// the connection is kept until the last board is closed
+		s.CloseBoard("board1")
+		select {
+		case <-cs.done:
+			t.Fatal("connection closed while still subscribed")
+		case <-time.After(100 * time.Millisecond):
+		}
+		s.Unsubscribe("board2", "testPlatform", "room")
+		select {
+		case <-cs.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("connection not closed")
+		}
+		assert.Empty(t, s.conns)
+	})
+
+	t.Run("should return false since chat is not supported", func(t *testing.T) {
+		s := NewChatService(slog.Default(), &PlatformService{
+			log: slog.Default(),
+			pm:  map[string]platform.Platform{"testPlatform": mockPlatform{}},
+		}, make(mockEmitter))
+		assert.False(t, s.Subscribe("board", "testPlatform", "room"))
+		assert.False(t, s.Subscribe("board", "otherPlatform", "room"))
+	})
+}
+
+func TestChatService_Unsubscribe(t *testing.T) {
+	t.Run("should cancel the subscription", func(t *testing.T) {
+		cs := mockChatSource{done: make(chan struct{})}
+		s := NewChatService(slog.Default(), &PlatformService{
+			log: slog.Default(),
+			pm:  map[string]platform.Platform{"testPlatform": cs},
+		}, make(mockEmitter))
+		assert.True(t, s.Subscribe("board", "testPlatform", "room"))
+		s.Unsubscribe("board", "testPlatform", "room")
+		select {
+		case <-cs.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("subscription not cancelled")
+		}
+		assert.Empty(t, s.subs)
+	})
+}
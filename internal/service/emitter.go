@@ -0,0 +1,6 @@
+package service
+
+// Emitter emits events to the frontend.
+type Emitter interface {
+	Emit(name string, data ...any)
+}
@@ -1,30 +1,81 @@
 package service
 
 import (
+	"asmblive/internal/health"
 	"asmblive/internal/platform"
 	"asmblive/internal/platform/bili"
+	"asmblive/internal/platform/direct"
+	"asmblive/internal/platform/douyu"
+	"asmblive/internal/platform/huya"
+	"asmblive/internal/platform/twitch"
+	"asmblive/internal/qrcode"
 	"asmblive/internal/server"
 	"context"
+	"encoding/base64"
+	"errors"
 	"log/slog"
+	"net/url"
+	"sort"
+	"sync"
 )
 
+// maxConcurrentRoomRequests limits the concurrent requests of GetRooms for the platforms
+// which do not implement platform.BatchRoomGetter.
+const maxConcurrentRoomRequests = 4
+
+// loginQrCodeScale is the size of the qr code modules in pixels.
+const loginQrCodeScale = 6
+
 type PlatformService struct {
 	log *slog.Logger
 	pm  map[string]platform.Platform
+	// cm holds the cached platforms keyed by id, which are used to get rooms, qualities and live urls.
+	// The platforms not in it are not cached.
+	cm  map[string]*platform.CachedPlatform
+	srv server.Server
 }
 
 func NewPlatformService(log *slog.Logger, setting *SettingService, srv server.Server) *PlatformService {
 	log = log.With("module", "service/platform")
-	c := platform.NewClient(log, platform.Headers{})
+	// each platform has its own client, so that the requests are limited and proxied by the setting of the
+	// platform
+	newClient := func(platformId string) platform.Client {
+		return platform.NewClient(log, platform.Headers{}, platform.ClientOptions{
+			RateLimit: func() platform.RateLimit {
+				l := setting.GetPlatformRateLimit(platformId)
+				return platform.RateLimit{Rate: l.Rate, Burst: l.Burst}
+			},
+			Proxy: ProxyFunc(setting, platformId),
+		})
+	}
 
 	pm := make(map[string]platform.Platform)
 	// bilibili
-	bl := bili.NewBili(log, c, &setting.Bili, srv)
+	bl := bili.NewBili(log, newClient("bili"), ProxyFunc(setting, "bili"), &setting.Bili, srv)
 	pm[bl.Id()] = bl
+	// douyu
+	dy := douyu.NewDouyu(log, newClient("douyu"), srv)
+	pm[dy.Id()] = dy
+	// huya
+	hy := huya.NewHuya(log, newClient("huya"), srv)
+	pm[hy.Id()] = hy
+	// twitch
+	tw := twitch.NewTwitch(log, newClient("twitch"), srv)
+	pm[tw.Id()] = tw
+	// direct stream url
+	dr := direct.NewDirect(log, newClient("url"))
+	pm[dr.Id()] = dr
+
+	cm := make(map[string]*platform.CachedPlatform, len(pm))
+	for id, p := range pm {
+		cm[id] = platform.Cached(p, platform.CacheOptions{})
+	}
 
 	s := &PlatformService{
 		log: log,
 		pm:  pm,
+		cm:  cm,
+		srv: srv,
 	}
 	return s
 }
@@ -63,29 +114,72 @@ func (s PlatformService) GetRoom(platformId string, roomId string) *RoomDto {
 		s.log.Warn("cannot find platform", "id", platformId)
 		return nil
 	}
-	r, err := p.GetRoom(context.TODO(), roomId)
+	r, err := s.cached(p).GetRoom(context.TODO(), roomId)
 	if err != nil {
 		s.log.Warn("failed to get room", "id", roomId, "err", err)
 		return nil
 	}
-	piu := p.IconUrl()
 	s.log.Info("get room", "id", roomId)
-	return &RoomDto{
-		Id:    r.Id,
-		Title: r.Title,
-		Owner: OwnerDto{
-			Id:        r.Owner.Id,
-			Name:      r.Owner.Name,
-			AvatarUrl: r.Owner.AvatarUrl.String(),
-		},
-		IsOnline: r.IsOnline,
-		CoverUrl: r.CoverUrl.String(),
-		Platform: PlatformDto{
-			Id:      p.Id(),
-			Name:    p.Name(),
-			IconUrl: piu.String(),
-		},
+	rd := newRoomDto(p, r)
+	return &rd
+}
+
+// GetRooms returns the rooms keyed by room id, the rooms which cannot be got are omitted.
+func (s PlatformService) GetRooms(platformId string, roomIds []string) map[string]*RoomDto {
+	p, ok := s.pm[platformId]
+	if !ok {
+		s.log.Warn("cannot find platform", "id", platformId)
+		return nil
 	}
+	var rs map[string]platform.Room
+	cp := s.cached(p)
+	if _, ok := p.(platform.BatchRoomGetter); ok {
+		var err error
+		rs, err = cp.(platform.BatchRoomGetter).GetRooms(context.TODO(), roomIds)
+		if err != nil {
+			s.log.Warn("failed to get rooms in batch, fallback to one by one", "platformId", platformId, "err", err)
+			rs = s.getRooms(cp, roomIds)
+		}
+	} else {
+		rs = s.getRooms(cp, roomIds)
+	}
+	r := make(map[string]*RoomDto, len(rs))
+	for id, room := range rs {
+		rd := newRoomDto(p, room)
+		r[id] = &rd
+	}
+	s.log.Info("get rooms", "platformId", platformId, "count", len(r))
+	return r
+}
+
+// getRooms gets the rooms one by one with at most maxConcurrentRoomRequests concurrent requests.
+func (s PlatformService) getRooms(p platform.Platform, roomIds []string) map[string]platform.Room {
+	var (
+		mtx sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, maxConcurrentRoomRequests)
+	)
+	rs := make(map[string]platform.Room, len(roomIds))
+	for _, id := range roomIds {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			r, err := p.GetRoom(context.TODO(), id)
+			if err != nil {
+				s.log.Warn("failed to get room", "id", id, "err", err)
+				return
+			}
+			mtx.Lock()
+			rs[id] = r
+			mtx.Unlock()
+		}(id)
+	}
+	wg.Wait()
+	return rs
 }
 
 func (s PlatformService) GetQualities(platformId string, roomId string) []*QualityDto {
@@ -94,7 +188,7 @@ func (s PlatformService) GetQualities(platformId string, roomId string) []*Quali
 		s.log.Warn("cannot find platform", "id", platformId)
 		return nil
 	}
-	qs, err := p.GetQualities(context.TODO(), roomId)
+	qs, err := s.cached(p).GetQualities(context.TODO(), roomId)
 	if err != nil {
 		s.log.Warn("failed to get qualities", "roomId", roomId, "err", err)
 		return nil
@@ -117,7 +211,7 @@ func (s PlatformService) GetLiveUrls(platformId string, roomId string, qualityId
 		s.log.Warn("cannot find platform", "id", platformId)
 		return nil
 	}
-	us, err := p.GetLiveUrls(context.TODO(), roomId, qualityId)
+	us, err := s.cached(p).GetLiveUrls(context.TODO(), roomId, qualityId)
 	if err != nil {
 		s.log.Warn("failed to get live urls", "roomId", roomId, "qualityId", qualityId, "err", err)
 		return nil
@@ -129,3 +223,319 @@ func (s PlatformService) GetLiveUrls(platformId string, roomId string, qualityId
 	s.log.Info("get live urls", "roomId", roomId, "qualityId", qualityId, "count", len(r))
 	return r
 }
+
+// GetLiveStreams returns the live urls with their protocol, format, codec and expiry, the metadata is
+// guessed from the urls if the platform does not implement platform.StreamGetter.
+func (s PlatformService) GetLiveStreams(platformId string, roomId string, qualityId string) []*LiveUrlDto {
+	p, ok := s.pm[platformId]
+	if !ok {
+		s.log.Warn("cannot find platform", "id", platformId)
+		return nil
+	}
+	lss, err := platform.GetLiveStreams(context.TODO(), s.cached(p), roomId, qualityId)
+	if err != nil {
+		s.log.Warn("failed to get live streams", "roomId", roomId, "qualityId", qualityId, "err", err)
+		return nil
+	}
+	r := make([]*LiveUrlDto, len(lss))
+	for i, ls := range lss {
+		d := newLiveUrlDto(ls)
+		r[i] = &d
+	}
+	s.log.Info("get live streams", "roomId", roomId, "qualityId", qualityId, "count", len(r))
+	return r
+}
+
+// RankLiveUrls probes the live urls of the platform and returns them ranked by health, the fastest goes
+// first and the unhealthy ones go last. The urls of the hosts not allowed by the platform are not probed.
+func (s PlatformService) RankLiveUrls(platformId string, urls []string) []*LiveUrlHealthDto {
+	rs := s.srv.CheckStreams(context.TODO(), platformId, s.parseUrls(urls))
+	r := make([]*LiveUrlHealthDto, len(rs))
+	for i, res := range rs {
+		d := newLiveUrlHealthDto(res)
+		r[i] = &d
+	}
+	return r
+}
+
+// GetFailoverUrl returns a stable url which plays the live urls in the ranked order, it switches to the
+// next one when the current one stalls or returns an error. It returns an empty string if no url is valid
+// or allowed by the platform.
+func (s PlatformService) GetFailoverUrl(platformId string, urls []string) string {
+	us := s.parseUrls(urls)
+	if len(us) == 0 {
+		return ""
+	}
+	rs := s.srv.CheckStreams(context.TODO(), platformId, us)
+	ranked := make([]url.URL, len(rs))
+	for i, r := range rs {
+		ranked[i] = r.Url
+	}
+	u := s.srv.GetFailoverUrl(platformId, ranked)
+	if u == (url.URL{}) {
+		s.log.Warn("no failover candidate is allowed", "platformId", platformId, "count", len(ranked))
+		return ""
+	}
+	s.log.Info("get failover url", "platformId", platformId, "count", len(ranked), "first", ranked[0].Host)
+	return u.String()
+}
+
+func (s PlatformService) parseUrls(urls []string) []url.URL {
+	us := make([]url.URL, 0, len(urls))
+	for _, v := range urls {
+		u, err := url.Parse(v)
+		if err != nil {
+			s.log.Warn("invalid live url", "url", v, "err", err)
+			continue
+		}
+		us = append(us, *u)
+	}
+	return us
+}
+
+// SearchRooms returns nil if the platform does not implement platform.Searcher.
+func (s PlatformService) SearchRooms(platformId string, keyword string, page int) []*RoomDto {
+	p, ok := s.pm[platformId]
+	if !ok {
+		s.log.Warn("cannot find platform", "id", platformId)
+		return nil
+	}
+	sp, ok := p.(platform.Searcher)
+	if !ok {
+		s.log.Warn("platform does not support searching", "id", platformId)
+		return nil
+	}
+	rs, err := sp.SearchRooms(context.TODO(), keyword, page)
+	if err != nil {
+		s.log.Warn("failed to search rooms", "keyword", keyword, "page", page, "err", err)
+		return nil
+	}
+	r := make([]*RoomDto, len(rs))
+	for i, room := range rs {
+		rd := newRoomDto(p, room)
+		r[i] = &rd
+	}
+	s.log.Info("search rooms", "keyword", keyword, "page", page, "count", len(r))
+	return r
+}
+
+// GetFollowedRooms returns the rooms followed by the logged-in user with the online ones first, it returns
+// nil if the platform does not implement platform.FollowLister or the user is not logged in.
+func (s PlatformService) GetFollowedRooms(platformId string) []*RoomDto {
+	p, ok := s.pm[platformId]
+	if !ok {
+		s.log.Warn("cannot find platform", "id", platformId)
+		return nil
+	}
+	fp, ok := p.(platform.FollowLister)
+	if !ok {
+		s.log.Warn("platform does not support listing followed rooms", "id", platformId)
+		return nil
+	}
+	rs, err := fp.GetFollowedRooms(context.TODO())
+	if err != nil {
+		s.log.Warn("failed to get followed rooms", "platformId", platformId, "err", err)
+		return nil
+	}
+	r := make([]*RoomDto, len(rs))
+	for i, room := range rs {
+		rd := newRoomDto(p, room)
+		r[i] = &rd
+	}
+	s.log.Info("get followed rooms", "platformId", platformId, "count", len(r))
+	return r
+}
+
+// ResolveInput detects the platform of the pasted link and returns the canonical room id,
+// it returns nil if no platform accepts the link.
+func (s PlatformService) ResolveInput(input string) *ResolvedRoomDto {
+	ids := make([]string, 0, len(s.pm))
+	for id := range s.pm {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		rp, ok := s.pm[id].(platform.Resolver)
+		if !ok {
+			continue
+		}
+		rid, err := rp.ResolveRoomId(context.TODO(), input)
+		if errors.Is(err, platform.ErrUnsupportedInput) {
+			continue
+		}
+		if err != nil {
+			s.log.Warn("failed to resolve input", "input", input, "platformId", id, "err", err)
+			return nil
+		}
+		s.log.Info("resolve input", "input", input, "platformId", id, "roomId", rid)
+		return &ResolvedRoomDto{
+			PlatformId: id,
+			RoomId:     rid,
+		}
+	}
+	s.log.Warn("no platform accepts the input", "input", input)
+	return nil
+}
+
+// GetLoginQrCode returns nil if the platform does not implement platform.QrLoginProvider.
+func (s PlatformService) GetLoginQrCode(platformId string) *LoginQrCodeDto {
+	lp := s.qrLoginProvider(platformId)
+	if lp == nil {
+		return nil
+	}
+	qc, err := lp.GetLoginQrCode(context.TODO())
+	if err != nil {
+		s.log.Warn("failed to get login qr code", "platformId", platformId, "err", err)
+		return nil
+	}
+	png, err := qrcode.PNG(qc.Url.String(), loginQrCodeScale)
+	if err != nil {
+		s.log.Warn("failed to render login qr code", "platformId", platformId, "err", err)
+		return nil
+	}
+	s.log.Info("get login qr code", "platformId", platformId)
+	return &LoginQrCodeDto{
+		Key:        qc.Key,
+		Url:        qc.Url.String(),
+		PngDataUrl: "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}
+}
+
+// PollLogin returns the login state of the qr code, i.e. waiting, scanned, confirmed or expired, or an
+// empty string on failure.
+func (s PlatformService) PollLogin(platformId string, key string) string {
+	lp := s.qrLoginProvider(platformId)
+	if lp == nil {
+		return ""
+	}
+	st, err := lp.PollLogin(context.TODO(), key)
+	if err != nil {
+		s.log.Warn("failed to poll login", "platformId", platformId, "err", err)
+		return ""
+	}
+	if st == platform.LoginConfirmed {
+		// the qualities and the live urls may differ after logging in
+		s.invalidateAll(platformId)
+	}
+	return string(st)
+}
+
+func (s PlatformService) Logout(platformId string) bool {
+	lp := s.qrLoginProvider(platformId)
+	if lp == nil {
+		return false
+	}
+	if err := lp.Logout(context.TODO()); err != nil {
+		s.log.Warn("failed to logout", "platformId", platformId, "err", err)
+		return false
+	}
+	s.invalidateAll(platformId)
+	s.log.Info("logout", "platformId", platformId)
+	return true
+}
+
+// InvalidateRoom drops the cached room, qualities and live urls of the room, e.g. when the live urls
+// cannot be played. It returns false if the platform is not cached.
+func (s PlatformService) InvalidateRoom(platformId string, roomId string) bool {
+	cp, ok := s.cm[platformId]
+	if !ok {
+		s.log.Warn("cannot find cached platform", "id", platformId)
+		return false
+	}
+	cp.Invalidate(roomId)
+	s.log.Info("invalidate room", "platformId", platformId, "roomId", roomId)
+	return true
+}
+
+// GetCacheStats returns the hit and miss counters of the cached platforms keyed by platform id.
+func (s PlatformService) GetCacheStats() map[string]*CacheStatsDto {
+	r := make(map[string]*CacheStatsDto, len(s.cm))
+	for id, cp := range s.cm {
+		d := newCacheStatsDto(cp.Stats())
+		r[id] = &d
+	}
+	return r
+}
+
+// cached returns the cached platform of p, or p itself if it is not cached.
+func (s PlatformService) cached(p platform.Platform) platform.Platform {
+	if cp, ok := s.cm[p.Id()]; ok {
+		return cp
+	}
+	return p
+}
+
+func (s PlatformService) invalidateAll(platformId string) {
+	if cp, ok := s.cm[platformId]; ok {
+		cp.InvalidateAll()
+	}
+}
+
+func (s PlatformService) qrLoginProvider(platformId string) platform.QrLoginProvider {
+	p, ok := s.pm[platformId]
+	if !ok {
+		s.log.Warn("cannot find platform", "id", platformId)
+		return nil
+	}
+	lp, ok := p.(platform.QrLoginProvider)
+	if !ok {
+		s.log.Warn("platform does not support qr code login", "id", platformId)
+		return nil
+	}
+	return lp
+}
+
+func newRoomDto(p platform.Platform, r platform.Room) RoomDto {
+	piu := p.IconUrl()
+	return RoomDto{
+		Id:    r.Id,
+		Title: r.Title,
+		Owner: OwnerDto{
+			Id:        r.Owner.Id,
+			Name:      r.Owner.Name,
+			AvatarUrl: r.Owner.AvatarUrl.String(),
+		},
+		IsOnline: r.IsOnline,
+		CoverUrl: r.CoverUrl.String(),
+		Platform: PlatformDto{
+			Id:      p.Id(),
+			Name:    p.Name(),
+			IconUrl: piu.String(),
+		},
+	}
+}
+
+func newLiveUrlDto(ls platform.LiveStream) LiveUrlDto {
+	d := LiveUrlDto{
+		Url:      ls.Url.String(),
+		Protocol: ls.Protocol,
+		Format:   ls.Format,
+		Codec:    ls.Codec,
+	}
+	if !ls.ExpiresAt.IsZero() {
+		d.ExpiresAt = ls.ExpiresAt.UnixMilli()
+	}
+	return d
+}
+
+func newLiveUrlHealthDto(r health.Result) LiveUrlHealthDto {
+	d := LiveUrlHealthDto{
+		Url:        r.Url.String(),
+		StatusCode: r.StatusCode,
+		Ttfb:       r.TTFB.Milliseconds(),
+		Speed:      r.Speed,
+		Healthy:    r.Healthy(),
+	}
+	if r.Err != nil {
+		d.Error = r.Err.Error()
+	}
+	return d
+}
+
+func newCacheStatsDto(st platform.CacheStats) CacheStatsDto {
+	return CacheStatsDto{
+		Rooms:     CacheCounterDto{Hits: st.Rooms.Hits, Misses: st.Rooms.Misses},
+		Qualities: CacheCounterDto{Hits: st.Qualities.Hits, Misses: st.Qualities.Misses},
+		LiveUrls:  CacheCounterDto{Hits: st.LiveUrls.Hits, Misses: st.LiveUrls.Misses},
+	}
+}
@@ -26,3 +26,51 @@ type QualityDto struct {
 	Name     string `json:"name"`
 	Priority int8   `json:"priority"`
 }
+
+type ResolvedRoomDto struct {
+	PlatformId string `json:"platformId"`
+	RoomId     string `json:"roomId"`
+}
+
+type LiveUrlDto struct {
+	Url string `json:"url"`
+	// Protocol is http_stream or http_hls, empty if unknown.
+	Protocol string `json:"protocol"`
+	// Format is flv, ts or fmp4, empty if unknown.
+	Format string `json:"format"`
+	// Codec is avc or hevc, empty if unknown.
+	Codec string `json:"codec"`
+	// ExpiresAt is the unix time in milliseconds, 0 if unknown.
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+type LiveUrlHealthDto struct {
+	Url string `json:"url"`
+	// StatusCode is 0 if the request fails before the response.
+	StatusCode int `json:"statusCode"`
+	// Ttfb is in milliseconds.
+	Ttfb int64 `json:"ttfb"`
+	// Speed is in bytes per second.
+	Speed   float64 `json:"speed"`
+	Healthy bool    `json:"healthy"`
+	Error   string  `json:"error"`
+}
+
+type LoginQrCodeDto struct {
+	Key string `json:"key"`
+	// Url is the content of the qr code.
+	Url string `json:"url"`
+	// PngDataUrl is the qr code image which can be used as the src of img.
+	PngDataUrl string `json:"pngDataUrl"`
+}
+
+type CacheCounterDto struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+type CacheStatsDto struct {
+	Rooms     CacheCounterDto `json:"rooms"`
+	Qualities CacheCounterDto `json:"qualities"`
+	LiveUrls  CacheCounterDto `json:"liveUrls"`
+}
@@ -1,12 +1,19 @@
 package service
 
 import (
+	"asmblive/internal/health"
 	"asmblive/internal/platform"
+	"asmblive/internal/server"
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"net/url"
+	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -50,6 +57,80 @@ func (m mockPlatform) GetLiveUrls(ctx context.Context, roomId string, qualityId
 	return m.liveUrls, m.liveUrlsErr
 }
 
+type mockStreamGetter struct {
+	mockPlatform
+
+	streams    []platform.LiveStream
+	streamsErr error
+}
+
+func (m mockStreamGetter) GetLiveStreams(ctx context.Context, roomId string, qualityId string) ([]platform.LiveStream, error) {
+	return m.streams, m.streamsErr
+}
+
+type mockQrLoginProvider struct {
+	mockPlatform
+
+	qrCode   platform.LoginQrCode
+	state    platform.LoginState
+	loginErr error
+}
+
+func (m mockQrLoginProvider) GetLoginQrCode(ctx context.Context) (platform.LoginQrCode, error) {
+	return m.qrCode, m.loginErr
+}
+
+func (m mockQrLoginProvider) PollLogin(ctx context.Context, key string) (platform.LoginState, error) {
+	return m.state, m.loginErr
+}
+
+func (m mockQrLoginProvider) Logout(ctx context.Context) error {
+	return m.loginErr
+}
+
+type mockFollowLister struct {
+	mockPlatform
+
+	followed    []platform.Room
+	followedErr error
+}
+
+func (m mockFollowLister) GetFollowedRooms(ctx context.Context) ([]platform.Room, error) {
+	return m.followed, m.followedErr
+}
+
+type mockSearcher struct {
+	mockPlatform
+
+	rooms    []platform.Room
+	roomsErr error
+}
+
+func (m mockSearcher) SearchRooms(ctx context.Context, keyword string, page int) ([]platform.Room, error) {
+	return m.rooms, m.roomsErr
+}
+
+type mockBatchRoomGetter struct {
+	mockPlatform
+
+	rooms    map[string]platform.Room
+	roomsErr error
+}
+
+func (m mockBatchRoomGetter) GetRooms(ctx context.Context, roomIds []string) (map[string]platform.Room, error) {
+	return m.rooms, m.roomsErr
+}
+
+type mockResolver struct {
+	mockPlatform
+
+	resolve func(input string) (string, error)
+}
+
+func (m mockResolver) ResolveRoomId(ctx context.Context, input string) (string, error) {
+	return m.resolve(input)
+}
+
 func TestService_GetPlatforms(t *testing.T) {
 	type fields struct {
 		pm map[string]platform.Platform
@@ -255,6 +336,73 @@ func TestService_GetRoom(t *testing.T) {
 	}
 }
 
+func TestService_GetRooms(t *testing.T) {
+	room := platform.Room{Id: "testRoom", Title: "testRoomTitle", IsOnline: true}
+	roomDto := &RoomDto{
+		Id:       "testRoom",
+		Title:    "testRoomTitle",
+		IsOnline: true,
+		Platform: PlatformDto{Id: "testPlatform"},
+	}
+	type fields struct {
+		pm map[string]platform.Platform
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   map[string]*RoomDto
+	}{
+		{
+			name: "should return rooms in batch",
+			fields: fields{pm: map[string]platform.Platform{
+				"testPlatform": mockBatchRoomGetter{
+					mockPlatform: mockPlatform{id: "testPlatform", roomErr: errors.New("should not call")},
+					rooms:        map[string]platform.Room{"a": room},
+				},
+			}},
+			want: map[string]*RoomDto{"a": roomDto},
+		},
+		{
+			name: "should return rooms one by one since batch is not supported",
+			fields: fields{pm: map[string]platform.Platform{
+				"testPlatform": mockPlatform{id: "testPlatform", room: room},
+			}},
+			want: map[string]*RoomDto{"a": roomDto, "b": roomDto, "c": roomDto, "d": roomDto, "e": roomDto},
+		},
+		{
+			name: "should return rooms one by one since fail to get in batch",
+			fields: fields{pm: map[string]platform.Platform{
+				"testPlatform": mockBatchRoomGetter{
+					mockPlatform: mockPlatform{id: "testPlatform", room: room},
+					roomsErr:     errors.New("no rooms"),
+				},
+			}},
+			want: map[string]*RoomDto{"a": roomDto, "b": roomDto, "c": roomDto, "d": roomDto, "e": roomDto},
+		},
+		{
+			name: "should omit rooms which fail to get",
+			fields: fields{pm: map[string]platform.Platform{
+				"testPlatform": mockPlatform{id: "testPlatform", roomErr: errors.New("no room")},
+			}},
+			want: map[string]*RoomDto{},
+		},
+		{
+			name:   "should return nil since no platform",
+			fields: fields{pm: make(map[string]platform.Platform, 0)},
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := PlatformService{
+				log: slog.Default(),
+				pm:  tt.fields.pm,
+			}
+			assert.Equal(t, tt.want, s.GetRooms("testPlatform", []string{"a", "b", "c", "d", "e"}))
+		})
+	}
+}
+
 func TestService_GetQualities(t *testing.T) {
 	type fields struct {
 		pm map[string]platform.Platform
@@ -413,3 +561,326 @@ func TestService_GetLiveUrls(t *testing.T) {
 		})
 	}
 }
+
+func TestService_GetLiveStreams(t *testing.T) {
+	u := func(p string) url.URL {
+		return url.URL{Scheme: "https", Host: "test.com", Path: p}
+	}
+	tests := []struct {
+		name string
+		p    platform.Platform
+		want []*LiveUrlDto
+	}{
+		{
+			name: "should return streams of the platform",
+			p: mockStreamGetter{streams: []platform.LiveStream{{
+				Url:       u("/live"),
+				Protocol:  platform.ProtocolHls,
+				Format:    platform.FormatFmp4,
+				Codec:     platform.CodecHevc,
+				ExpiresAt: time.UnixMilli(1721816094000),
+			}}},
+			want: []*LiveUrlDto{{
+				Url:       "https://test.com/live",
+				Protocol:  "http_hls",
+				Format:    "fmp4",
+				Codec:     "hevc",
+				ExpiresAt: 1721816094000,
+			}},
+		},
+		{
+			name: "should infer streams from urls",
+			p:    mockPlatform{liveUrls: []url.URL{u("/live.flv"), u("/live")}},
+			want: []*LiveUrlDto{
+				{Url: "https://test.com/live.flv", Protocol: "http_stream", Format: "flv"},
+				{Url: "https://test.com/live"},
+			},
+		},
+		{
+			name: "should return nil since fail to get streams",
+			p:    mockStreamGetter{streamsErr: errors.New("no streams")},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := PlatformService{
+				log: slog.Default(),
+				pm:  map[string]platform.Platform{"testPlatform": tt.p},
+			}
+			assert.Equal(t, tt.want, s.GetLiveStreams("testPlatform", "testRoom", ""))
+		})
+	}
+	assert.Nil(t, PlatformService{log: slog.Default()}.GetLiveStreams("testPlatform", "testRoom", ""))
+}
+
+// mockStreamServer ranks the urls of the platform as rs, and plays the first candidate as the failover url.
+type mockStreamServer struct {
+	server.Server
+	platformId string
+	rs         []health.Result
+}
+
+func (m mockStreamServer) CheckStreams(_ context.Context, platformId string, urls []url.URL) []health.Result {
+	if platformId != m.platformId {
+		return nil
+	}
+	return m.rs
+}
+
+func (m mockStreamServer) GetFailoverUrl(platformId string, candidates []url.URL) url.URL {
+	if platformId != m.platformId || len(candidates) == 0 {
+		return url.URL{}
+	}
+	return url.URL{Scheme: "http", Host: "localhost:8080", Path: "/failover/test/" + path.Base(candidates[0].Path)}
+}
+
+func TestService_RankLiveUrls(t *testing.T) {
+	live := url.URL{Scheme: "https", Host: "test.com", Path: "/live.flv"}
+	expired := url.URL{Scheme: "https", Host: "test.com", Path: "/expired.flv"}
+	s := PlatformService{
+		log: slog.Default(),
+		srv: mockStreamServer{platformId: "testPlatform", rs: []health.Result{
+			{Url: live, StatusCode: http.StatusOK},
+			{Url: expired, StatusCode: http.StatusNotFound, Err: errors.New("failed to probe: unexpected status code: 404")},
+		}},
+	}
+	urls := []string{expired.String(), live.String(), "://invalid"}
+
+	t.Run("should rank the healthy url first", func(t *testing.T) {
+		rs := s.RankLiveUrls("testPlatform", urls)
+		assert.Len(t, rs, 2)
+		assert.Equal(t, live.String(), rs[0].Url)
+		assert.True(t, rs[0].Healthy)
+		assert.Equal(t, expired.String(), rs[1].Url)
+		assert.False(t, rs[1].Healthy)
+		assert.Equal(t, http.StatusNotFound, rs[1].StatusCode)
+		assert.Equal(t, "failed to probe: unexpected status code: 404", rs[1].Error)
+	})
+
+	t.Run("should return failover url", func(t *testing.T) {
+		u := s.GetFailoverUrl("testPlatform", urls)
+		assert.True(t, strings.HasSuffix(u, "/live.flv"))
+		assert.Contains(t, u, "/failover/")
+		assert.Empty(t, s.GetFailoverUrl("testPlatform", nil))
+	})
+
+	t.Run("should return empty url since no url is allowed by the platform", func(t *testing.T) {
+		assert.Empty(t, s.GetFailoverUrl("another", urls))
+	})
+}
+
+func TestService_SearchRooms(t *testing.T) {
+	type fields struct {
+		pm map[string]platform.Platform
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   []*RoomDto
+	}{
+		{
+			name: "should return rooms",
+			fields: fields{pm: map[string]platform.Platform{
+				"testPlatform": mockSearcher{
+					mockPlatform: mockPlatform{
+						id:      "testPlatform",
+						name:    "testPlatformName",
+						iconUrl: url.URL{Scheme: "https", Host: "test.com", Path: "/favicon.ico"},
+					},
+					rooms: []platform.Room{
+						{
+							Id:    "testRoom",
+							Title: "testRoomTitle",
+							Owner: platform.Owner{
+								Id:        "testOwner",
+								Name:      "testOwnerName",
+								AvatarUrl: url.URL{Scheme: "https", Host: "test.com", Path: "/avatar.png"},
+							},
+							IsOnline: true,
+						},
+					},
+				},
+			}},
+			want: []*RoomDto{
+				{
+					Id:    "testRoom",
+					Title: "testRoomTitle",
+					Owner: OwnerDto{
+						Id:        "testOwner",
+						Name:      "testOwnerName",
+						AvatarUrl: "https://test.com/avatar.png",
+					},
+					IsOnline: true,
+					Platform: PlatformDto{
+						Id:      "testPlatform",
+						Name:    "testPlatformName",
+						IconUrl: "https://test.com/favicon.ico",
+					},
+				},
+			},
+		},
+		{
+			name:   "should return nil since no platform",
+			fields: fields{pm: make(map[string]platform.Platform, 0)},
+			want:   nil,
+		},
+		{
+			name: "should return nil since searching is not supported",
+			fields: fields{pm: map[string]platform.Platform{
+				"testPlatform": mockPlatform{},
+			}},
+			want: nil,
+		},
+		{
+			name: "should return nil since fail to search",
+			fields: fields{pm: map[string]platform.Platform{
+				"testPlatform": mockSearcher{roomsErr: errors.New("no rooms")},
+			}},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := PlatformService{
+				log: slog.Default(),
+				pm:  tt.fields.pm,
+			}
+			assert.Equal(t, tt.want, s.SearchRooms("testPlatform", "keyword", 1))
+		})
+	}
+}
+
+func TestService_ResolveInput(t *testing.T) {
+	unsupported := func(string) (string, error) {
+		return "", fmt.Errorf("wrapped: %w", platform.ErrUnsupportedInput)
+	}
+	tests := []struct {
+		name string
+		pm   map[string]platform.Platform
+		want *ResolvedRoomDto
+	}{
+		{
+			name: "should return the room of the accepting platform",
+			pm: map[string]platform.Platform{
+				"a": mockResolver{resolve: unsupported},
+				"b": mockPlatform{},
+				"c": mockResolver{resolve: func(input string) (string, error) {
+					return "5441", nil
+				}},
+			},
+			want: &ResolvedRoomDto{PlatformId: "c", RoomId: "5441"},
+		},
+		{
+			name: "should return nil since no platform accepts",
+			pm: map[string]platform.Platform{
+				"a": mockResolver{resolve: unsupported},
+			},
+			want: nil,
+		},
+		{
+			name: "should return nil since fail to resolve",
+			pm: map[string]platform.Platform{
+				"a": mockResolver{resolve: func(input string) (string, error) {
+					return "", errors.New("room not found")
+				}},
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := PlatformService{
+				log: slog.Default(),
+				pm:  tt.pm,
+			}
+			assert.Equal(t, tt.want, s.ResolveInput("https://live.bilibili.com/528"))
+		})
+	}
+}
+
+func TestService_QrLogin(t *testing.T) {
+	s := PlatformService{
+		log: slog.Default(),
+		pm: map[string]platform.Platform{
+			"login": mockQrLoginProvider{
+				qrCode: platform.LoginQrCode{Key: "abc", Url: url.URL{Scheme: "https", Host: "test.com", Path: "/login"}},
+				state:  platform.LoginScanned,
+			},
+			"failed": mockQrLoginProvider{loginErr: errors.New("failed")},
+			"plain":  mockPlatform{},
+		},
+	}
+	qc := s.GetLoginQrCode("login")
+	assert.NotNil(t, qc)
+	assert.Equal(t, "abc", qc.Key)
+	assert.Equal(t, "https://test.com/login", qc.Url)
+	assert.True(t, strings.HasPrefix(qc.PngDataUrl, "data:image/png;base64,"))
+	assert.Equal(t, "scanned", s.PollLogin("login", "abc"))
+	assert.True(t, s.Logout("login"))
+
+	for _, id := range []string{"failed", "plain", "unknown"} {
+		assert.Nil(t, s.GetLoginQrCode(id))
+		assert.Empty(t, s.PollLogin(id, "abc"))
+		assert.False(t, s.Logout(id))
+	}
+}
+
+func TestService_GetFollowedRooms(t *testing.T) {
+	tests := []struct {
+		name string
+		pm   map[string]platform.Platform
+		want []*RoomDto
+	}{
+		{
+			name: "should return followed rooms",
+			pm: map[string]platform.Platform{
+				"testPlatform": mockFollowLister{
+					mockPlatform: mockPlatform{id: "testPlatform", name: "test"},
+					followed:     []platform.Room{{Id: "1", Title: "live", IsOnline: true}, {Id: "2"}},
+				},
+			},
+			want: []*RoomDto{
+				{Id: "1", Title: "live", IsOnline: true, Platform: PlatformDto{Id: "testPlatform", Name: "test"}},
+				{Id: "2", Platform: PlatformDto{Id: "testPlatform", Name: "test"}},
+			},
+		},
+		{
+			name: "should return nil since not logged in",
+			pm: map[string]platform.Platform{
+				"testPlatform": mockFollowLister{followedErr: errors.New("not logged in")},
+			},
+		},
+		{
+			name: "should return nil since not supported",
+			pm:   map[string]platform.Platform{"testPlatform": mockPlatform{}},
+		},
+		{
+			name: "should return nil since no platform",
+			pm:   map[string]platform.Platform{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := PlatformService{log: slog.Default(), pm: tt.pm}
+			assert.Equal(t, tt.want, s.GetFollowedRooms("testPlatform"))
+		})
+	}
+}
+
+func TestService_Cache(t *testing.T) {
+	p := mockPlatform{id: "testPlatform", room: platform.Room{Id: "testRoom"}}
+	s := PlatformService{
+		log: slog.Default(),
+		pm:  map[string]platform.Platform{"testPlatform": p},
+		cm:  map[string]*platform.CachedPlatform{"testPlatform": platform.Cached(p, platform.CacheOptions{})},
+	}
+	assert.NotNil(t, s.GetRoom("testPlatform", "testRoom"))
+	assert.NotNil(t, s.GetRoom("testPlatform", "testRoom"))
+	assert.True(t, s.InvalidateRoom("testPlatform", "testRoom"))
+	assert.NotNil(t, s.GetRoom("testPlatform", "testRoom"))
+	assert.False(t, s.InvalidateRoom("unknown", "testRoom"))
+	assert.Equal(t, map[string]*CacheStatsDto{
+		"testPlatform": {Rooms: CacheCounterDto{Hits: 1, Misses: 2}},
+	}, s.GetCacheStats())
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"asmblive/internal/platform"
+	"asmblive/internal/proxy"
+	"asmblive/internal/recorder"
+	"context"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RecordingProgressEvent is the name of the event emitted with RecordingDto.
+const RecordingProgressEvent = "recording:progress"
+
+type recording struct {
+	id         string
+	platformId string
+	roomId     string
+	qualityId  string
+	room       platform.Room
+	rec        *recorder.Recorder
+}
+
+// RecordingService records the live rooms into the recording directory.
+type RecordingService struct {
+	log *slog.Logger
+	ps  *PlatformService
+	st  *SettingService
+	em  Emitter
+
+	mtx  sync.Mutex
+	recs map[string]*recording
+	// starting holds the rooms being started keyed by platform, room and quality, the channel is closed when
+	// the start is done, so that the concurrent starts of a room do not create the recordings twice.
+	starting map[string]chan struct{}
+	// clients download the streams through the proxy of each platform, they are keyed by platform id.
+	clients map[string]*http.Client
+}
+
+func NewRecordingService(log *slog.Logger, ps *PlatformService, st *SettingService, em Emitter) *RecordingService {
+	log = log.With("module", "service/recording")
+	return &RecordingService{
+		log:      log,
+		ps:       ps,
+		st:       st,
+		em:       em,
+		recs:     make(map[string]*recording),
+		starting: make(map[string]chan struct{}),
+		clients:  make(map[string]*http.Client),
+	}
+}
+
+// StartRecording returns the id of the recording, it returns the running one if the room is being recorded
+// with the quality, and an empty string if it fails to start.
+func (s *RecordingService) StartRecording(platformId string, roomId string, qualityId string) string {
+	r, _ := s.start(platformId, roomId, qualityId, 0, 0)
+	if r == nil {
+		return ""
+	}
+	return r.id
+}
+
+// start returns the running recording of the room with the quality, or starts a new one and created is
+// true. It returns nil if it fails to start, maxBytes and maxDuration split the recording into files.
+func (s *RecordingService) start(platformId string, roomId string, qualityId string, maxBytes int64, maxDuration time.Duration) (r *recording, created bool) {
+	p, ok := s.ps.pm[platformId]
+	if !ok {
+		s.log.Warn("cannot find platform", "id", platformId)
+		return nil, false
+	}
+	r, done := s.reserve(platformId, roomId, qualityId)
+	if r != nil {
+		return r, false
+	}
+	defer done()
+	room, err := p.GetRoom(context.TODO(), roomId)
+	if err != nil {
+		s.log.Warn("failed to get room", "id", roomId, "err", err)
+		return nil, false
+	}
+	now := time.Now()
+	name := recorder.FileName(s.st.GetRecordingTemplate(), recorder.NameFields{
+		Platform: p.Name(),
+		RoomId:   roomId,
+		Owner:    room.Owner.Name,
+		Title:    room.Title,
+		Start:    now,
+	})
+	r = &recording{
+		id:         platformId + "-" + roomId + "-" + strconv.FormatInt(now.UnixMilli(), 10),
+		platformId: platformId,
+		roomId:     roomId,
+		qualityId:  qualityId,
+		room:       room,
+	}
+	// the progress may be reported before r.rec is set
+	started := make(chan struct{})
+	r.rec, err = recorder.Start(s.log, recorder.Options{
+		// the live urls expire, they are resolved again when the stream breaks
+		Resolve: func(ctx context.Context) ([]recorder.Stream, error) {
+			return resolveStreams(ctx, p, roomId, qualityId)
+		},
+		Path:   filepath.Join(s.st.GetRecordingDir(), name),
+		Client: s.client(platformId),
+		OnProgress: func(recorder.Progress) {
+			<-started
+			s.em.Emit(RecordingProgressEvent, newRecordingDto(r))
+		},
+		MaxBytes:    maxBytes,
+		MaxDuration: maxDuration,
+	})
+	if err != nil {
+		s.log.Warn("failed to start recording", "platformId", platformId, "roomId", roomId, "err", err)
+		return nil, false
+	}
+	close(started)
+	s.mtx.Lock()
+	s.recs[r.id] = r
+	s.mtx.Unlock()
+	s.log.Info("start recording", "id", r.id)
+	return r, true
+}
+
+// resolveStreams returns the upstream streams of the room, the player-facing relay urls are not recorded
+// since they are remuxed and carry no format.
+func resolveStreams(ctx context.Context, p platform.Platform, roomId string, qualityId string) ([]recorder.Stream, error) {
+	lss, err := platform.GetLiveStreams(ctx, p, roomId, qualityId)
+	if err != nil {
+		return nil, err
+	}
+	ss := make([]recorder.Stream, len(lss))
+	for i, ls := range lss {
+		ss[i] = recorder.Stream{Url: ls.Upstream(), Header: ls.Header, Hls: ls.Protocol == platform.ProtocolHls}
+	}
+	return ss, nil
+}
+
+// client returns the client of the platform, which is created on the first use.
+func (s *RecordingService) client(platformId string) *http.Client {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	c, ok := s.clients[platformId]
+	if !ok {
+		c = &http.Client{Transport: proxy.Transport(ProxyFunc(s.st, platformId))}
+		s.clients[platformId] = c
+	}
+	return c
+}
+
+// reserve returns the running recording of the room with the quality, or reserves the room for starting
+// and returns the func which releases it. It waits if the room is being started by another call.
+func (s *RecordingService) reserve(platformId string, roomId string, qualityId string) (*recording, func()) {
+	key := platformId + "/" + roomId + "/" + qualityId
+	for {
+		s.mtx.Lock()
+		if r := s.running(platformId, roomId, qualityId); r != nil {
+			s.mtx.Unlock()
+			return r, nil
+		}
+		ch, ok := s.starting[key]
+		if !ok {
+			ch = make(chan struct{})
+			s.starting[key] = ch
+			s.mtx.Unlock()
+			return nil, func() {
+				s.mtx.Lock()
+				delete(s.starting, key)
+				s.mtx.Unlock()
+				close(ch)
+			}
+		}
+		s.mtx.Unlock()
+		// the started recording is returned, or it is started again if the other call fails
+		<-ch
+	}
+}
+
+// running returns the running recording of the room with the quality, s.mtx must be held.
+func (s *RecordingService) running(platformId string, roomId string, qualityId string) *recording {
+	for _, r := range s.recs {
+		if r.platformId == platformId && r.roomId == roomId && r.qualityId == qualityId &&
+			r.rec.Progress().State == recorder.StateRecording {
+			return r
+		}
+	}
+	return nil
+}
+
+// StopRecording returns false if the recording does not exist.
+func (s *RecordingService) StopRecording(id string) bool {
+	s.mtx.Lock()
+	r, ok := s.recs[id]
+	s.mtx.Unlock()
+	if !ok {
+		s.log.Warn("cannot find recording", "id", id)
+		return false
+	}
+	r.rec.Stop()
+	s.log.Info("stop recording", "id", id)
+	return true
+}
+
+// ListRecordings returns the recordings of this session, the latest goes first.
+func (s *RecordingService) ListRecordings() []*RecordingDto {
+	s.mtx.Lock()
+	rs := make([]*RecordingDto, 0, len(s.recs))
+	for _, r := range s.recs {
+		d := newRecordingDto(r)
+		rs = append(rs, &d)
+	}
+	s.mtx.Unlock()
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].StartedAt > rs[j].StartedAt
+	})
+	return rs
+}
+
+// StopAll stops all recordings, it must be called on shutdown so that the files are closed.
+func (s *RecordingService) StopAll() {
+	s.mtx.Lock()
+	rs := make([]*recording, 0, len(s.recs))
+	for _, r := range s.recs {
+		rs = append(rs, r)
+	}
+	s.mtx.Unlock()
+	for _, r := range rs {
+		r.rec.Stop()
+	}
+}
+
+func newRecordingDto(r *recording) RecordingDto {
+	p := r.rec.Progress()
+	d := RecordingDto{
+		Id:         r.id,
+		PlatformId: r.platformId,
+		RoomId:     r.roomId,
+		QualityId:  r.qualityId,
+		Title:      r.room.Title,
+		OwnerName:  r.room.Owner.Name,
+		Path:       r.rec.Path(),
+		Paths:      r.rec.Paths(),
+		Bytes:      p.Bytes,
+		StartedAt:  r.rec.StartedAt().UnixMilli(),
+		Duration:   p.Duration.Milliseconds(),
+		State:      string(p.State),
+	}
+	if p.Err != nil {
+		d.Error = p.Err.Error()
+	}
+	return d
+}
@@ -0,0 +1,22 @@
+package service
+
+type RecordingDto struct {
+	Id         string `json:"id"`
+	PlatformId string `json:"platformId"`
+	RoomId     string `json:"roomId"`
+	QualityId  string `json:"qualityId"`
+	Title      string `json:"title"`
+	OwnerName  string `json:"ownerName"`
+	// Path is the current file, it is empty before any data is received.
+	Path string `json:"path"`
+	// Paths are all files, there are more than one if the recording is split.
+	Paths []string `json:"paths"`
+	Bytes int64    `json:"bytes"`
+	// StartedAt is in milliseconds.
+	StartedAt int64 `json:"startedAt"`
+	// Duration is in milliseconds.
+	Duration int64 `json:"duration"`
+	// State is one of recording, finished and failed.
+	State string `json:"state"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"asmblive/internal/platform"
+	"asmblive/internal/recorder"
+	"asmblive/internal/setting"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSettingStore map[string]string
+
+func (m mockSettingStore) Read() (map[string]string, error) {
+	return m, nil
+}
+
+func (m mockSettingStore) Write(map[string]string) error {
+	return errors.New("read only")
+}
+
+// slowPlatform gets the room slowly, so that the concurrent starts overlap.
+type slowPlatform struct {
+	mockPlatform
+}
+
+func (p slowPlatform) GetRoom(ctx context.Context, roomId string) (platform.Room, error) {
+	time.Sleep(50 * time.Millisecond)
+	return p.mockPlatform.GetRoom(ctx, roomId)
+}
+
+func TestRecordingService(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("FLV\x01\x05\x00\x00\x00\x09\x00\x00\x00\x00"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+	lu, _ := url.Parse(ts.URL + "/live.flv")
+	dir := t.TempDir()
+	em := make(mockEmitter, 100)
+	mp := mockPlatform{
+		name:     "test",
+		room:     platform.Room{Title: "title", Owner: platform.Owner{Name: "owner"}, IsOnline: true},
+		liveUrls: []url.URL{*lu},
+	}
+	s := NewRecordingService(slog.Default(), &PlatformService{
+		log: slog.Default(),
+		pm: map[string]platform.Platform{
+			"testPlatform":    mp,
+			"slowPlatform":    slowPlatform{mp},
+			"offlinePlatform": mockPlatform{liveUrls: []url.URL{}},
+		},
+	}, &SettingService{
+		Recording: setting.Recording{
+			Store: mockSettingStore{"recording_dir": dir, "recording_template": "{platform}-{owner}-{title}"},
+			Log:   slog.Default(),
+		},
+		Proxy: setting.Proxy{Store: mockSettingStore{}, Log: slog.Default()},
+	}, em)
+
+	id := s.StartRecording("testPlatform", "room", "q")
+	assert.NotEmpty(t, id)
+	assert.Equal(t, id, s.StartRecording("testPlatform", "room", "q"))
+	assert.Empty(t, s.StartRecording("offlinePlatform", "room", "q"))
+	assert.Empty(t, s.StartRecording("missingPlatform", "room", "q"))
+
+	assert.Eventually(t, func() bool {
+		rs := s.ListRecordings()
+		return len(rs) == 1 && rs[0].Bytes == 13
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, s.StopRecording(id))
+	assert.False(t, s.StopRecording("missing"))
+
+	rs := s.ListRecordings()
+	assert.Len(t, rs, 1)
+	assert.Equal(t, "finished", rs[0].State)
+	assert.Equal(t, "title", rs[0].Title)
+	assert.Equal(t, "owner", rs[0].OwnerName)
+	assert.True(t, strings.HasPrefix(rs[0].Path, dir))
+	assert.True(t, strings.HasSuffix(rs[0].Path, "test-owner-title.flv"))
+	_, err := os.Stat(rs[0].Path)
+	assert.NoError(t, err)
+
+	// the final progress is emitted
+	var last emitted
+	for len(em) > 0 {
+		last = <-em
+	}
+	assert.Equal(t, RecordingProgressEvent, last.name)
+	assert.Equal(t, "finished", last.data[0].(RecordingDto).State)
+
+	t.Run("should start the room once since started concurrently", func(t *testing.T) {
+		ids := make([]string, 10)
+		var wg sync.WaitGroup
+		for i := range ids {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ids[i] = s.StartRecording("slowPlatform", "room", "q")
+			}(i)
+		}
+		wg.Wait()
+		assert.NotEmpty(t, ids[0])
+		for _, id := range ids {
+			assert.Equal(t, ids[0], id)
+		}
+		assert.Len(t, s.ListRecordings(), 2)
+		s.StopAll()
+	})
+}
+
+func Test_resolveStreams(t *testing.T) {
+	origin, _ := url.Parse("https://cdn.test.com/live.m3u8")
+	relay, _ := url.Parse("http://127.0.0.1:8080/stream?origin=" + url.QueryEscape(origin.String()))
+	flv, _ := url.Parse("https://cdn.test.com/live.flv")
+	h := http.Header{"Referer": {"https://test.com/"}}
+	tests := []struct {
+		name string
+		p    platform.Platform
+		want []recorder.Stream
+	}{
+		{
+			name: "should record the origin of the relayed streams",
+			p: mockStreamGetter{streams: []platform.LiveStream{
+				{Url: *relay, Origin: *origin, Header: h, Protocol: platform.ProtocolHls},
+			}},
+			want: []recorder.Stream{{Url: *origin, Header: h, Hls: true}},
+		},
+		{
+			name: "should infer the streams from the live urls",
+			p:    mockPlatform{liveUrls: []url.URL{*origin, *flv}},
+			want: []recorder.Stream{{Url: *origin, Hls: true}, {Url: *flv}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveStreams(context.TODO(), tt.p, "room", "q")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
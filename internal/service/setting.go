@@ -1,22 +1,172 @@
 package service
 
 import (
+	"asmblive/internal/platform"
+	"asmblive/internal/platform/bili"
+	"asmblive/internal/proxy"
 	"asmblive/internal/setting"
 	"asmblive/internal/store"
+	"context"
 	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// BiliCookieExpiryEvent is the name of the event emitted with BiliAccountDto, when the stored cookie has
+// expired or will expire within biliCookieExpiryWarning.
+const BiliCookieExpiryEvent = "setting:biliCookieExpiry"
+
+const (
+	biliCookieCheckInterval = 6 * time.Hour
+	biliCookieExpiryWarning = 3 * 24 * time.Hour
 )
 
 type SettingService struct {
 	setting.Bili
+	setting.Watcher
+	setting.Recording
+	setting.RateLimit
+	setting.Proxy
+
+	log *slog.Logger
+	em  Emitter
+	// account returns the account of the stored bili cookie.
+	account func(ctx context.Context) (bili.Account, error)
+	now     func() time.Time
+	// runMtx guards stop, so that Start and Stop, which are bound to the frontend, can be called more
+	// than once.
+	runMtx sync.Mutex
+	stop   chan struct{}
+	wg     sync.WaitGroup
 }
 
-func NewSettingService(log *slog.Logger) *SettingService {
+func NewSettingService(log *slog.Logger, em Emitter) *SettingService {
 	log = log.With("module", "service/platform")
 	s := store.New[map[string]string]("settings", make(map[string]string))
-	return &SettingService{
+	ss := &SettingService{
 		Bili: setting.Bili{
 			Store: s,
 			Log:   log,
 		},
+		Watcher: setting.Watcher{
+			Store: s,
+			Log:   log,
+		},
+		Recording: setting.Recording{
+			Store: s,
+			Log:   log,
+		},
+		RateLimit: setting.RateLimit{
+			Store: s,
+			Log:   log,
+		},
+		Proxy: setting.Proxy{
+			Store: s,
+			Log:   log,
+		},
+		log: log,
+		em:  em,
+		now: time.Now,
+	}
+	// the server is not used to get the account
+	c := platform.NewClient(log, platform.Headers{}, platform.ClientOptions{Proxy: ProxyFunc(ss, "bili")})
+	bl := bili.NewBili(log, c, ProxyFunc(ss, "bili"), &ss.Bili, nil)
+	ss.account = bl.GetAccount
+	return ss
+}
+
+// CheckBiliCookie returns the account of the stored cookie, IsLogin is false if the cookie is missing or
+// expired. It returns nil if the account cannot be got.
+func (s *SettingService) CheckBiliCookie() *BiliAccountDto {
+	a, err := s.account(context.TODO())
+	if err != nil {
+		s.log.Warn("failed to check bili cookie", "err", err)
+		return nil
+	}
+	s.log.Info("check bili cookie", "isLogin", a.IsLogin, "uid", a.Uid)
+	d := newBiliAccountDto(a)
+	return &d
+}
+
+// Start checks the bili cookie periodically in background, it must be called on startup. It does
+// nothing if the check is running.
+func (s *SettingService) Start() {
+	s.runMtx.Lock()
+	defer s.runMtx.Unlock()
+	if s.stop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	s.stop = stop
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(biliCookieCheckInterval)
+		defer t.Stop()
+		for {
+			s.checkBiliCookieExpiry()
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// Stop stops checking, it must be called on shutdown.
+func (s *SettingService) Stop() {
+	s.runMtx.Lock()
+	defer s.runMtx.Unlock()
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	s.wg.Wait()
+	s.stop = nil
+}
+
+// checkBiliCookieExpiry emits BiliCookieExpiryEvent if the stored cookie has expired or will expire soon,
+// nothing is checked if no cookie is stored.
+func (s *SettingService) checkBiliCookieExpiry() {
+	if s.GetBiliCookie() == "" {
+		return
+	}
+	d := s.CheckBiliCookie()
+	if d == nil {
+		return
+	}
+	if d.IsLogin && (d.ExpiresAt == 0 || time.UnixMilli(d.ExpiresAt).Sub(s.now()) > biliCookieExpiryWarning) {
+		return
+	}
+	s.log.Warn("bili cookie has expired or will expire soon", "isLogin", d.IsLogin, "expiresAt", d.ExpiresAt)
+	s.em.Emit(BiliCookieExpiryEvent, *d)
+}
+
+// ProxyFunc returns the proxy of the requests of the platform as http.Transport.Proxy does, the global
+// proxy is used if the platformId is empty. The setting is read on each request, so that the changes take
+// effect immediately. It is not a method of SettingService, which would be bound to the frontend.
+func ProxyFunc(s *SettingService, platformId string) func(*http.Request) (*url.URL, error) {
+	return proxy.Func(func() (proxy.Config, error) {
+		c, err := s.GetProxySetting().Config(platformId)
+		if err != nil {
+			s.log.Error("invalid proxy setting", "platformId", platformId, "err", err)
+		}
+		return c, err
+	})
+}
+
+func newBiliAccountDto(a bili.Account) BiliAccountDto {
+	d := BiliAccountDto{
+		IsLogin: a.IsLogin,
+		Uid:     a.Uid,
+		Name:    a.Name,
+		IsVip:   a.IsVip,
+	}
+	if !a.ExpiresAt.IsZero() {
+		d.ExpiresAt = a.ExpiresAt.UnixMilli()
 	}
+	return d
 }
@@ -0,0 +1,11 @@
+package service
+
+type BiliAccountDto struct {
+	// IsLogin is false if the cookie is missing or expired.
+	IsLogin bool   `json:"isLogin"`
+	Uid     string `json:"uid"`
+	Name    string `json:"name"`
+	IsVip   bool   `json:"isVip"`
+	// ExpiresAt is the unix time of the cookie expiry in milliseconds, 0 if unknown.
+	ExpiresAt int64 `json:"expiresAt"`
+}
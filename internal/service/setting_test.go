@@ -0,0 +1,137 @@
+package service
+
+import (
+	"asmblive/internal/platform/bili"
+	"asmblive/internal/setting"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettingService_CheckBiliCookie(t *testing.T) {
+	s := &SettingService{
+		log: slog.Default(),
+		account: func(ctx context.Context) (bili.Account, error) {
+			return bili.Account{IsLogin: true, Uid: "2", Name: "name", IsVip: true, ExpiresAt: time.UnixMilli(1737187200000)}, nil
+		},
+	}
+	assert.Equal(t, &BiliAccountDto{IsLogin: true, Uid: "2", Name: "name", IsVip: true, ExpiresAt: 1737187200000}, s.CheckBiliCookie())
+
+	s.account = func(ctx context.Context) (bili.Account, error) {
+		return bili.Account{}, errors.New("network error")
+	}
+	assert.Nil(t, s.CheckBiliCookie())
+}
+
+func TestSettingService_checkBiliCookieExpiry(t *testing.T) {
+	now := time.Date(2024, 7, 24, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		name    string
+		cookie  string
+		account bili.Account
+		emitted bool
+	}{
+		{
+			name:    "should not emit since the cookie is valid",
+			cookie:  "SESSDATA=abc",
+			account: bili.Account{IsLogin: true, ExpiresAt: now.Add(30 * 24 * time.Hour)},
+		},
+		{
+			name:    "should not emit since the expiry is unknown",
+			cookie:  "SESSDATA=abc",
+			account: bili.Account{IsLogin: true},
+		},
+		{
+			name:    "should emit since the cookie will expire soon",
+			cookie:  "SESSDATA=abc",
+			account: bili.Account{IsLogin: true, ExpiresAt: now.Add(24 * time.Hour)},
+			emitted: true,
+		},
+		{
+			name:    "should emit since the cookie has expired",
+			cookie:  "SESSDATA=abc",
+			account: bili.Account{},
+			emitted: true,
+		},
+		{
+			name:    "should not emit since no cookie",
+			account: bili.Account{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			em := make(mockEmitter, 1)
+			s := &SettingService{
+				Bili: setting.Bili{Store: mockSettingStore{"bili_cookie": tt.cookie}, Log: slog.Default()},
+				log:  slog.Default(),
+				em:   em,
+				account: func(ctx context.Context) (bili.Account, error) {
+					return tt.account, nil
+				},
+				now: func() time.Time { return now },
+			}
+			s.checkBiliCookieExpiry()
+			if !tt.emitted {
+				assert.Empty(t, em)
+				return
+			}
+			e := <-em
+			assert.Equal(t, BiliCookieExpiryEvent, e.name)
+			assert.Equal(t, []any{newBiliAccountDto(tt.account)}, e.data)
+		})
+	}
+}
+
+func TestProxyFunc(t *testing.T) {
+	s := &SettingService{
+		log: slog.Default(),
+		Proxy: setting.Proxy{Store: mockSettingStore{
+			"proxy_url":           "http://127.0.0.1:8080",
+			"proxy_bypass":        "localhost",
+			"proxy_platform_huya": setting.ProxyDirect,
+		}, Log: slog.Default()},
+	}
+	req := func(u string) *http.Request {
+		r, _ := http.NewRequest(http.MethodGet, u, nil)
+		return r
+	}
+	pu, err := ProxyFunc(s, "bili")(req("https://api.bilibili.com/"))
+	assert.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:8080", pu.String())
+	pu, err = ProxyFunc(s, "bili")(req("http://localhost:3000/"))
+	assert.NoError(t, err)
+	assert.Nil(t, pu)
+	pu, err = ProxyFunc(s, "huya")(req("https://www.huya.com/"))
+	assert.NoError(t, err)
+	assert.Nil(t, pu)
+
+	// the setting is cached, the invalid one is read by another service
+	s = &SettingService{
+		log:   slog.Default(),
+		Proxy: setting.Proxy{Store: mockSettingStore{"proxy_url": "ftp://127.0.0.1"}, Log: slog.Default()},
+	}
+	_, err = ProxyFunc(s, "")(req("https://api.bilibili.com/"))
+	assert.Error(t, err)
+}
+
+func TestSettingService_Start_Stop(t *testing.T) {
+	t.Run("should start once and stop once", func(t *testing.T) {
+		s := &SettingService{
+			Bili: setting.Bili{Store: mockSettingStore{}, Log: slog.Default()},
+			log:  slog.Default(),
+		}
+		s.Start()
+		stop := s.stop
+		// starting twice must not replace the running check
+		s.Start()
+		assert.Equal(t, stop, s.stop)
+		s.Stop()
+		assert.Nil(t, s.stop)
+		assert.NotPanics(t, s.Stop)
+	})
+}
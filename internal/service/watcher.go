@@ -0,0 +1,130 @@
+package service
+
+import (
+	"asmblive/internal/notify"
+	"asmblive/internal/watcher"
+	"log/slog"
+	"time"
+)
+
+// RoomChangeEvent is the name of the event emitted with RoomChangeDto.
+const RoomChangeEvent = "watcher:change"
+
+// watcherGaps is the minimal delay between two requests to the same platform.
+var watcherGaps = map[string]time.Duration{
+	"bili":   500 * time.Millisecond,
+	"douyu":  500 * time.Millisecond,
+	"huya":   500 * time.Millisecond,
+	"twitch": 200 * time.Millisecond,
+	"url":    0,
+}
+
+// WatcherService watches the rooms on the boards in background, it emits RoomChangeEvent and
+// shows a desktop notification when a room goes online, goes offline or changes its title.
+type WatcherService struct {
+	log    *slog.Logger
+	w      *watcher.Watcher
+	em     Emitter
+	notify func(title string, body string) error
+}
+
+func NewWatcherService(log *slog.Logger, ps *PlatformService, bs *BoardService, ss *SettingService, em Emitter) *WatcherService {
+	log = log.With("module", "service/watcher")
+	s := &WatcherService{
+		log:    log,
+		em:     em,
+		notify: notify.Send,
+	}
+	s.w = watcher.New(log, ps.pm, watcher.Options{
+		Interval: func() time.Duration {
+			return time.Duration(ss.GetWatcherInterval()) * time.Second
+		},
+		Targets: func() []watcher.Target {
+			return watchedTargets(bs.GetBoards(), ss.GetWatchedBoards())
+		},
+		Gaps:     watcherGaps,
+		OnChange: s.onChange,
+	})
+	return s
+}
+
+// Start starts watching in background, it must be called on startup. It does nothing if the watcher
+// is running.
+func (s *WatcherService) Start() {
+	s.w.Start()
+}
+
+// Stop stops watching, it must be called on shutdown.
+func (s *WatcherService) Stop() {
+	s.w.Stop()
+}
+
+// GetStatuses returns the cached statuses of the watched rooms.
+func (s *WatcherService) GetStatuses() []*RoomStatusDto {
+	ss := s.w.Statuses()
+	r := make([]*RoomStatusDto, len(ss))
+	for i, st := range ss {
+		d := newRoomStatusDto(st)
+		r[i] = &d
+	}
+	return r
+}
+
+func (s *WatcherService) onChange(c watcher.Change) {
+	s.em.Emit(RoomChangeEvent, RoomChangeDto{
+		Status:       newRoomStatusDto(watcher.Status{Target: c.Target, Room: c.New, CheckedAt: time.Now()}),
+		WentOnline:   c.WentOnline(),
+		WentOffline:  c.WentOffline(),
+		TitleChanged: c.TitleChanged(),
+	})
+	var title string
+	switch {
+	case c.WentOnline():
+		title = c.New.Owner.Name + " 开播了"
+	case c.WentOffline():
+		title = c.New.Owner.Name + " 下播了"
+	default:
+		title = c.New.Owner.Name + " 更新了直播标题"
+	}
+	// notifying runs a process, it must not block the watcher
+	go func() {
+		if err := s.notify(title, c.New.Title); err != nil {
+			s.log.Warn("failed to notify", "err", err)
+		}
+	}()
+}
+
+// watchedTargets returns the distinct rooms on the boards, all boards are watched if boardIds is empty.
+func watchedTargets(boards []BoardDTO, boardIds []string) []watcher.Target {
+	watched := make(map[string]bool, len(boardIds))
+	for _, id := range boardIds {
+		watched[id] = true
+	}
+	seen := make(map[watcher.Target]bool)
+	ts := make([]watcher.Target, 0)
+	for _, b := range boards {
+		if len(watched) > 0 && !watched[b.Id] {
+			continue
+		}
+		for _, r := range b.Rooms {
+			t := watcher.Target{PlatformId: r.PlatformId, RoomId: r.Id}
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+func newRoomStatusDto(st watcher.Status) RoomStatusDto {
+	return RoomStatusDto{
+		PlatformId: st.PlatformId,
+		RoomId:     st.RoomId,
+		Title:      st.Room.Title,
+		OwnerName:  st.Room.Owner.Name,
+		IsOnline:   st.Room.IsOnline,
+		CheckedAt:  st.CheckedAt.UnixMilli(),
+	}
+}
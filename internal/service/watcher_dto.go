@@ -0,0 +1,18 @@
+package service
+
+type RoomStatusDto struct {
+	PlatformId string `json:"platformId"`
+	RoomId     string `json:"roomId"`
+	Title      string `json:"title"`
+	OwnerName  string `json:"ownerName"`
+	IsOnline   bool   `json:"isOnline"`
+	// CheckedAt is in milliseconds.
+	CheckedAt int64 `json:"checkedAt"`
+}
+
+type RoomChangeDto struct {
+	Status       RoomStatusDto `json:"status"`
+	WentOnline   bool          `json:"wentOnline"`
+	WentOffline  bool          `json:"wentOffline"`
+	TitleChanged bool          `json:"titleChanged"`
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"asmblive/internal/platform"
+	"asmblive/internal/watcher"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchedTargets(t *testing.T) {
+	boards := []BoardDTO{
+		{Id: "a", Rooms: []BoardRoomDTO{{Id: "1", PlatformId: "bili"}, {Id: "2", PlatformId: "bili"}}},
+		{Id: "b", Rooms: []BoardRoomDTO{{Id: "1", PlatformId: "bili"}, {Id: "1", PlatformId: "douyu"}}},
+	}
+	tests := []struct {
+		name     string
+		boardIds []string
+		want     []watcher.Target
+	}{
+		{
+			name: "should watch all boards if none is selected",
+			want: []watcher.Target{
+				{PlatformId: "bili", RoomId: "1"},
+				{PlatformId: "bili", RoomId: "2"},
+				{PlatformId: "douyu", RoomId: "1"},
+			},
+		},
+		{
+			name:     "should watch selected boards only",
+			boardIds: []string{"b"},
+			want: []watcher.Target{
+				{PlatformId: "bili", RoomId: "1"},
+				{PlatformId: "douyu", RoomId: "1"},
+			},
+		},
+		{
+			name:     "should watch nothing if selected boards are missing",
+			boardIds: []string{"c"},
+			want:     []watcher.Target{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, watchedTargets(boards, tt.boardIds))
+		})
+	}
+}
+
+func TestWatcherService_onChange(t *testing.T) {
+	t.Run("should emit the change and notify", func(t *testing.T) {
+		em := make(mockEmitter, 1)
+		notified := make(chan [2]string, 1)
+		s := &WatcherService{
+			log: slog.Default(),
+			em:  em,
+			notify: func(title string, body string) error {
+				notified <- [2]string{title, body}
+				return nil
+			},
+		}
+		s.onChange(watcher.Change{
+			Target: watcher.Target{PlatformId: "bili", RoomId: "1"},
+			Old:    platform.Room{Title: "t", Owner: platform.Owner{Name: "o"}},
+			New:    platform.Room{Title: "t", Owner: platform.Owner{Name: "o"}, IsOnline: true},
+		})
+		e := <-em
+		assert.Equal(t, RoomChangeEvent, e.name)
+		dto := e.data[0].(RoomChangeDto)
+		assert.Equal(t, "bili", dto.Status.PlatformId)
+		assert.Equal(t, "1", dto.Status.RoomId)
+		assert.True(t, dto.Status.IsOnline)
+		assert.True(t, dto.WentOnline)
+		assert.False(t, dto.WentOffline)
+		assert.False(t, dto.TitleChanged)
+		select {
+		case n := <-notified:
+			assert.Equal(t, [2]string{"o 开播了", "t"}, n)
+		case <-time.After(5 * time.Second):
+			t.Fatal("not notified")
+		}
+	})
+}
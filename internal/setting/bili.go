@@ -22,6 +22,8 @@ func (b Bili) GetBiliCookie() string {
 }
 
 func (b Bili) SetBiliCookie(cookie string) string {
+	writeMtx.Lock()
+	defer writeMtx.Unlock()
 	c, err := b.Store.Read()
 	if err != nil {
 		b.Log.Error("Failed to read bili cookie", "err", err)
@@ -35,3 +37,21 @@ func (b Bili) SetBiliCookie(cookie string) string {
 	}
 	return cookie
 }
+
+// DeleteBiliCookie removes the cookie, it returns false if the cookie cannot be removed.
+func (b Bili) DeleteBiliCookie() bool {
+	writeMtx.Lock()
+	defer writeMtx.Unlock()
+	c, err := b.Store.Read()
+	if err != nil {
+		b.Log.Error("Failed to read bili cookie", "err", err)
+		return false
+	}
+	delete(c, biliCookieKey)
+	err = b.Store.Write(c)
+	if err != nil {
+		b.Log.Error("Failed to write bili cookie", "err", err)
+		return false
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package setting
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"log/slog"
 	"testing"
@@ -113,3 +114,42 @@ func TestBili_SetBiliCookie(t *testing.T) {
 		})
 	}
 }
+
+func TestBili_DeleteBiliCookie(t *testing.T) {
+	tests := []struct {
+		name  string
+		store mockStore
+		want  bool
+	}{
+		{
+			name: "should delete cookie",
+			store: mockStore{
+				readReturn: map[string]string{biliCookieKey: "test_cookie", "other": "value"},
+				writeFunc: func(m map[string]string) error {
+					assert.Equal(t, map[string]string{"other": "value"}, m)
+					return nil
+				},
+			},
+			want: true,
+		},
+		{
+			name: "should return false since fail to write",
+			store: mockStore{
+				readReturn: map[string]string{biliCookieKey: "test_cookie"},
+				writeFunc: func(m map[string]string) error {
+					return errors.New("write failed")
+				},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Bili{
+				Store: tt.store,
+				Log:   slog.Default(),
+			}
+			assert.Equal(t, tt.want, b.DeleteBiliCookie())
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package setting
+
+import "sync"
+
+// writeMtx serializes the writes of all the settings, since they read, modify and write the same store, the
+// concurrent writes of the different settings would lose each other's changes.
+var writeMtx sync.Mutex
@@ -0,0 +1,71 @@
+package setting
+
+import (
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowStore copies the settings on each read and write, and reads slowly, so that the concurrent
+// read-modify-writes overlap.
+type slowStore struct {
+	mtx sync.Mutex
+	c   map[string]string
+}
+
+func (s *slowStore) Read() (map[string]string, error) {
+	s.mtx.Lock()
+	c := make(map[string]string, len(s.c))
+	for k, v := range s.c {
+		c[k] = v
+	}
+	s.mtx.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	return c, nil
+}
+
+func (s *slowStore) Write(c map[string]string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.c = make(map[string]string, len(c))
+	for k, v := range c {
+		s.c[k] = v
+	}
+	return nil
+}
+
+func TestSettings_concurrentWrites(t *testing.T) {
+	st := &slowStore{c: make(map[string]string)}
+	b := Bili{Store: st, Log: slog.Default()}
+	w := Watcher{Store: st, Log: slog.Default()}
+	r := Recording{Store: st, Log: slog.Default()}
+	rl := &RateLimit{Store: st, Log: slog.Default()}
+	p := &Proxy{Store: st, Log: slog.Default()}
+	var wg sync.WaitGroup
+	for _, f := range []func(){
+		func() { b.SetBiliCookie("cookie") },
+		func() { w.SetWatcherInterval(10) },
+		func() { w.SetWatchedBoards([]string{"a"}) },
+		func() { r.SetRecordingDir("dir") },
+		func() { r.SetRecordingTemplate("{title}") },
+		func() { rl.SetPlatformRateLimit("bili", PlatformRateLimit{Rate: 1, Burst: 1}) },
+		func() { p.SetProxySetting(ProxySetting{Url: "http://127.0.0.1:8080"}) },
+	} {
+		wg.Add(1)
+		go func(f func()) {
+			defer wg.Done()
+			f()
+		}(f)
+	}
+	wg.Wait()
+	assert.Equal(t, "cookie", b.GetBiliCookie())
+	assert.Equal(t, 10, w.GetWatcherInterval())
+	assert.Equal(t, []string{"a"}, w.GetWatchedBoards())
+	assert.Equal(t, "dir", r.GetRecordingDir())
+	assert.Equal(t, "{title}", r.GetRecordingTemplate())
+	assert.Equal(t, PlatformRateLimit{Rate: 1, Burst: 1}, rl.GetPlatformRateLimit("bili"))
+	assert.Equal(t, "http://127.0.0.1:8080", p.GetProxySetting().Url)
+}
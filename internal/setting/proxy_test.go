@@ -0,0 +1,155 @@
+package setting
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxy_GetProxySetting(t *testing.T) {
+	tests := []struct {
+		name  string
+		store mockStore
+		want  ProxySetting
+	}{
+		{
+			name: "should return setting",
+			store: mockStore{readReturn: map[string]string{
+				proxyUrlKey:                     "socks5://u:p@127.0.0.1:1080",
+				proxyBypassKey:                  "localhost,.example.com",
+				proxyPlatformKeyPrefix + "huya": ProxyDirect,
+			}},
+			want: ProxySetting{
+				Url:       "socks5://u:p@127.0.0.1:1080",
+				Bypass:    []string{"localhost", ".example.com"},
+				Platforms: map[string]string{"huya": ProxyDirect},
+			},
+		},
+		{
+			name:  "should return empty setting since not existing",
+			store: mockStore{readReturn: map[string]string{}},
+			want:  ProxySetting{Bypass: []string{}, Platforms: map[string]string{}},
+		},
+		{
+			name:  "should return empty setting since read error",
+			store: mockStore{readErr: errors.New("read error")},
+			want:  ProxySetting{Bypass: []string{}, Platforms: map[string]string{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Proxy{Store: tt.store, Log: slog.Default()}
+			assert.Equal(t, tt.want, p.GetProxySetting())
+		})
+	}
+
+	t.Run("should cache the setting until it is set", func(t *testing.T) {
+		m := map[string]string{proxyUrlKey: "http://127.0.0.1:8080", proxyBypassKey: "localhost"}
+		p := Proxy{Store: mockStore{
+			readReturn: m,
+			writeFunc:  func(map[string]string) error { return nil },
+		}, Log: slog.Default()}
+		ps := p.GetProxySetting()
+		assert.Equal(t, "http://127.0.0.1:8080", ps.Url)
+		// the cached setting is not shared with the callers
+		ps.Bypass[0] = "changed"
+		m[proxyUrlKey] = "http://127.0.0.1:8081"
+		assert.Equal(t, ProxySetting{
+			Url:       "http://127.0.0.1:8080",
+			Bypass:    []string{"localhost"},
+			Platforms: map[string]string{},
+		}, p.GetProxySetting())
+		assert.NotNil(t, p.SetProxySetting(ProxySetting{Url: "socks5://127.0.0.1:1080"}))
+		assert.Equal(t, "socks5://127.0.0.1:1080", p.GetProxySetting().Url)
+	})
+
+	t.Run("should not cache the setting since read error", func(t *testing.T) {
+		p := Proxy{Store: mockStore{readErr: errors.New("read error")}, Log: slog.Default()}
+		assert.Equal(t, "", p.GetProxySetting().Url)
+		p.Store = mockStore{readReturn: map[string]string{proxyUrlKey: "http://127.0.0.1:8080"}}
+		assert.Equal(t, "http://127.0.0.1:8080", p.GetProxySetting().Url)
+	})
+}
+
+func TestProxy_SetProxySetting(t *testing.T) {
+	t.Run("should store setting and drop stale platforms", func(t *testing.T) {
+		p := Proxy{Store: mockStore{
+			readReturn: map[string]string{
+				biliCookieKey:                    "cookie",
+				proxyPlatformKeyPrefix + "douyu": "http://127.0.0.1:8080",
+			},
+			writeFunc: func(m map[string]string) error {
+				assert.Equal(t, map[string]string{
+					biliCookieKey:                   "cookie",
+					proxyUrlKey:                     "http://127.0.0.1:8080",
+					proxyBypassKey:                  "localhost,10.0.0.0/8",
+					proxyPlatformKeyPrefix + "bili": "socks5://127.0.0.1:1080",
+				}, m)
+				return nil
+			},
+		}, Log: slog.Default()}
+		ps := p.SetProxySetting(ProxySetting{
+			Url:       " http://127.0.0.1:8080 ",
+			Bypass:    []string{"localhost", " ", "10.0.0.0/8"},
+			Platforms: map[string]string{"bili": "socks5://127.0.0.1:1080", "huya": ""},
+		})
+		assert.Equal(t, &ProxySetting{
+			Url:       "http://127.0.0.1:8080",
+			Bypass:    []string{"localhost", "10.0.0.0/8"},
+			Platforms: map[string]string{"bili": "socks5://127.0.0.1:1080"},
+		}, ps)
+	})
+
+	invalid := []ProxySetting{
+		{Url: "ftp://127.0.0.1"},
+		{Bypass: []string{"10.0.0.0/33"}},
+		{Platforms: map[string]string{"bili": "127.0.0.1:1080"}},
+	}
+	for _, ps := range invalid {
+		t.Run("should return nil since invalid", func(t *testing.T) {
+			p := Proxy{Store: mockStore{readReturn: map[string]string{}}, Log: slog.Default()}
+			assert.Nil(t, p.SetProxySetting(ps))
+		})
+	}
+
+	t.Run("should return nil since write error", func(t *testing.T) {
+		p := Proxy{Store: mockStore{
+			readReturn: map[string]string{},
+			writeFunc: func(m map[string]string) error {
+				return errors.New("write error")
+			},
+		}, Log: slog.Default()}
+		assert.Nil(t, p.SetProxySetting(ProxySetting{Url: "http://127.0.0.1:8080"}))
+	})
+}
+
+func TestProxySetting_Config(t *testing.T) {
+	ps := ProxySetting{
+		Url:       "http://127.0.0.1:8080",
+		Bypass:    []string{"localhost"},
+		Platforms: map[string]string{"bili": "socks5://127.0.0.1:1080", "huya": ProxyDirect},
+	}
+	tests := []struct {
+		platformId string
+		want       string
+	}{
+		{platformId: "", want: "http://127.0.0.1:8080"},
+		{platformId: "douyu", want: "http://127.0.0.1:8080"},
+		{platformId: "bili", want: "socks5://127.0.0.1:1080"},
+		{platformId: "huya", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platformId, func(t *testing.T) {
+			c, err := ps.Config(tt.platformId)
+			assert.NoError(t, err)
+			if tt.want == "" {
+				assert.Nil(t, c.Url)
+				return
+			}
+			assert.Equal(t, tt.want, c.Url.String())
+			assert.Equal(t, []string{"localhost"}, c.Bypass)
+		})
+	}
+}
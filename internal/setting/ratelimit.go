@@ -0,0 +1,99 @@
+package setting
+
+import (
+	"asmblive/internal/store"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+const rateLimitKeyPrefix = "rate_limit_"
+
+// DefaultPlatformRateLimit is the limit of the requests to each host of a platform if it is not set.
+var DefaultPlatformRateLimit = PlatformRateLimit{Rate: 5, Burst: 10}
+
+type PlatformRateLimit struct {
+	// Rate is the number of requests per second, 0 means unlimited.
+	Rate float64 `json:"rate"`
+	// Burst is the max number of requests sent at once.
+	Burst int `json:"burst"`
+}
+
+type RateLimit struct {
+	Store store.Store[map[string]string]
+	Log   *slog.Logger
+
+	// mtx guards cached, the limits are got on each request of the platforms, so that they are kept in
+	// memory keyed by platform id until they are changed by SetPlatformRateLimit.
+	mtx    sync.RWMutex
+	cached map[string]PlatformRateLimit
+}
+
+// GetPlatformRateLimit returns the cached limit of the platform, it is read from Store if it is not
+// cached. The limit is not cached if it cannot be read.
+func (r *RateLimit) GetPlatformRateLimit(platformId string) PlatformRateLimit {
+	r.mtx.RLock()
+	l, ok := r.cached[platformId]
+	r.mtx.RUnlock()
+	if ok {
+		return l
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if l, ok := r.cached[platformId]; ok {
+		return l
+	}
+	l, err := r.read(platformId)
+	if err != nil {
+		r.Log.Error("Failed to read platform rate limit", "err", err)
+		return DefaultPlatformRateLimit
+	}
+	if r.cached == nil {
+		r.cached = make(map[string]PlatformRateLimit)
+	}
+	r.cached[platformId] = l
+	return l
+}
+
+func (r *RateLimit) read(platformId string) (PlatformRateLimit, error) {
+	c, err := r.Store.Read()
+	if err != nil {
+		return DefaultPlatformRateLimit, err
+	}
+	v, ok := c[rateLimitKeyPrefix+platformId]
+	if !ok {
+		return DefaultPlatformRateLimit, nil
+	}
+	var l PlatformRateLimit
+	if _, err := fmt.Sscanf(v, "%g,%d", &l.Rate, &l.Burst); err != nil || l.Rate < 0 || l.Burst < 1 {
+		r.Log.Warn("Invalid platform rate limit", "platformId", platformId, "value", v)
+		return DefaultPlatformRateLimit, nil
+	}
+	return l, nil
+}
+
+// SetPlatformRateLimit stores the limit, the negative rate is treated as unlimited and the burst is at
+// least 1. The cached limit of the platform is dropped. It returns the default limit if the limit cannot be
+// stored.
+func (r *RateLimit) SetPlatformRateLimit(platformId string, limit PlatformRateLimit) PlatformRateLimit {
+	limit.Rate = max(limit.Rate, 0)
+	limit.Burst = max(limit.Burst, 1)
+	// the limit read before the write must not be cached after it
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delete(r.cached, platformId)
+	writeMtx.Lock()
+	defer writeMtx.Unlock()
+	c, err := r.Store.Read()
+	if err != nil {
+		r.Log.Error("Failed to read platform rate limit", "err", err)
+		return DefaultPlatformRateLimit
+	}
+	c[rateLimitKeyPrefix+platformId] = fmt.Sprintf("%g,%d", limit.Rate, limit.Burst)
+	err = r.Store.Write(c)
+	if err != nil {
+		r.Log.Error("Failed to write platform rate limit", "err", err)
+		return DefaultPlatformRateLimit
+	}
+	return limit
+}
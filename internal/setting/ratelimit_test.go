@@ -0,0 +1,98 @@
+package setting
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit_GetPlatformRateLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		store mockStore
+		want  PlatformRateLimit
+	}{
+		{
+			name:  "should return limit",
+			store: mockStore{readReturn: map[string]string{rateLimitKeyPrefix + "bili": "2.5,4"}},
+			want:  PlatformRateLimit{Rate: 2.5, Burst: 4},
+		},
+		{
+			name:  "should return unlimited",
+			store: mockStore{readReturn: map[string]string{rateLimitKeyPrefix + "bili": "0,1"}},
+			want:  PlatformRateLimit{Rate: 0, Burst: 1},
+		},
+		{
+			name:  "should return default since the limit of another platform is set",
+			store: mockStore{readReturn: map[string]string{rateLimitKeyPrefix + "huya": "1,1"}},
+			want:  DefaultPlatformRateLimit,
+		},
+		{
+			name:  "should return default since invalid",
+			store: mockStore{readReturn: map[string]string{rateLimitKeyPrefix + "bili": "abc"}},
+			want:  DefaultPlatformRateLimit,
+		},
+		{
+			name:  "should return default since the burst is invalid",
+			store: mockStore{readReturn: map[string]string{rateLimitKeyPrefix + "bili": "1,0"}},
+			want:  DefaultPlatformRateLimit,
+		},
+		{
+			name:  "should return default since read error",
+			store: mockStore{readErr: errors.New("read error")},
+			want:  DefaultPlatformRateLimit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := RateLimit{Store: tt.store, Log: slog.Default()}
+			assert.Equal(t, tt.want, r.GetPlatformRateLimit("bili"))
+		})
+	}
+
+	t.Run("should cache the limit until it is set", func(t *testing.T) {
+		m := map[string]string{rateLimitKeyPrefix + "bili": "1,2"}
+		r := RateLimit{Store: mockStore{
+			readReturn: m,
+			writeFunc:  func(map[string]string) error { return nil },
+		}, Log: slog.Default()}
+		assert.Equal(t, PlatformRateLimit{Rate: 1, Burst: 2}, r.GetPlatformRateLimit("bili"))
+		m[rateLimitKeyPrefix+"bili"] = "3,4"
+		assert.Equal(t, PlatformRateLimit{Rate: 1, Burst: 2}, r.GetPlatformRateLimit("bili"))
+		assert.Equal(t, PlatformRateLimit{Rate: 5, Burst: 6}, r.SetPlatformRateLimit("bili", PlatformRateLimit{Rate: 5, Burst: 6}))
+		assert.Equal(t, PlatformRateLimit{Rate: 5, Burst: 6}, r.GetPlatformRateLimit("bili"))
+	})
+
+	t.Run("should not cache the limit since read error", func(t *testing.T) {
+		r := RateLimit{Store: mockStore{readErr: errors.New("read error")}, Log: slog.Default()}
+		assert.Equal(t, DefaultPlatformRateLimit, r.GetPlatformRateLimit("bili"))
+		r.Store = mockStore{readReturn: map[string]string{rateLimitKeyPrefix + "bili": "1,2"}}
+		assert.Equal(t, PlatformRateLimit{Rate: 1, Burst: 2}, r.GetPlatformRateLimit("bili"))
+	})
+}
+
+func TestRateLimit_SetPlatformRateLimit(t *testing.T) {
+	t.Run("should store normalized limit", func(t *testing.T) {
+		r := RateLimit{Store: mockStore{
+			readReturn: map[string]string{},
+			writeFunc: func(m map[string]string) error {
+				assert.Equal(t, map[string]string{rateLimitKeyPrefix + "bili": "0,1"}, m)
+				return nil
+			},
+		}, Log: slog.Default()}
+		assert.Equal(t, PlatformRateLimit{Rate: 0, Burst: 1}, r.SetPlatformRateLimit("bili", PlatformRateLimit{Rate: -1}))
+	})
+
+	t.Run("should return default since write error", func(t *testing.T) {
+		r := RateLimit{Store: mockStore{
+			readReturn: map[string]string{},
+			writeFunc: func(m map[string]string) error {
+				return errors.New("write error")
+			},
+		}, Log: slog.Default()}
+		assert.Equal(t, DefaultPlatformRateLimit, r.SetPlatformRateLimit("bili", PlatformRateLimit{Rate: 1, Burst: 2}))
+	})
+
+}
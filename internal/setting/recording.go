@@ -0,0 +1,88 @@
+package setting
+
+import (
+	"asmblive/internal/store"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+const (
+	recordingDirKey      = "recording_dir"
+	recordingTemplateKey = "recording_template"
+)
+
+// DefaultRecordingTemplate is the file name template if it is not set, see recorder.FileName for the fields.
+const DefaultRecordingTemplate = "{platform}-{owner}-{title}-{start}"
+
+type Recording struct {
+	Store store.Store[map[string]string]
+	Log   *slog.Logger
+}
+
+// GetRecordingDir returns the directory of the recordings, it is `Videos/Asmblive` in the home directory by default.
+func (r Recording) GetRecordingDir() string {
+	c, err := r.Store.Read()
+	if err != nil {
+		r.Log.Error("Failed to read recording dir", "err", err)
+		return defaultRecordingDir()
+	}
+	if v := c[recordingDirKey]; v != "" {
+		return v
+	}
+	return defaultRecordingDir()
+}
+
+func (r Recording) SetRecordingDir(dir string) string {
+	writeMtx.Lock()
+	defer writeMtx.Unlock()
+	c, err := r.Store.Read()
+	if err != nil {
+		r.Log.Error("Failed to read recording dir", "err", err)
+		return ""
+	}
+	c[recordingDirKey] = dir
+	err = r.Store.Write(c)
+	if err != nil {
+		r.Log.Error("Failed to write recording dir", "err", err)
+		return ""
+	}
+	return r.GetRecordingDir()
+}
+
+func (r Recording) GetRecordingTemplate() string {
+	c, err := r.Store.Read()
+	if err != nil {
+		r.Log.Error("Failed to read recording template", "err", err)
+		return DefaultRecordingTemplate
+	}
+	if v := c[recordingTemplateKey]; v != "" {
+		return v
+	}
+	return DefaultRecordingTemplate
+}
+
+func (r Recording) SetRecordingTemplate(template string) string {
+	writeMtx.Lock()
+	defer writeMtx.Unlock()
+	c, err := r.Store.Read()
+	if err != nil {
+		r.Log.Error("Failed to read recording template", "err", err)
+		return ""
+	}
+	c[recordingTemplateKey] = template
+	err = r.Store.Write(c)
+	if err != nil {
+		r.Log.Error("Failed to write recording template", "err", err)
+		return ""
+	}
+	return r.GetRecordingTemplate()
+}
+
+func defaultRecordingDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "Asmblive"
+	}
+	return filepath.Join(home, "Videos", "Asmblive")
+}
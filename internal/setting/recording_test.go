@@ -0,0 +1,63 @@
+package setting
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecording_GetRecordingDir(t *testing.T) {
+	tests := []struct {
+		name  string
+		store mockStore
+		want  string
+	}{
+		{
+			name:  "should return dir",
+			store: mockStore{readReturn: map[string]string{recordingDirKey: "/tmp/videos"}},
+			want:  "/tmp/videos",
+		},
+		{
+			name:  "should return default since not existing",
+			store: mockStore{readReturn: map[string]string{}},
+			want:  defaultRecordingDir(),
+		},
+		{
+			name:  "should return default since read error",
+			store: mockStore{readErr: errors.New("read error")},
+			want:  defaultRecordingDir(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Recording{Store: tt.store, Log: slog.Default()}
+			assert.Equal(t, tt.want, r.GetRecordingDir())
+		})
+	}
+}
+
+func TestRecording_SetRecordingTemplate(t *testing.T) {
+	t.Run("should reset to default", func(t *testing.T) {
+		data := map[string]string{recordingTemplateKey: "{owner}"}
+		r := Recording{Store: mockStore{
+			readReturn: data,
+			writeFunc: func(m map[string]string) error {
+				assert.Equal(t, map[string]string{recordingTemplateKey: ""}, m)
+				return nil
+			},
+		}, Log: slog.Default()}
+		assert.Equal(t, DefaultRecordingTemplate, r.SetRecordingTemplate(""))
+	})
+
+	t.Run("should return empty since write error", func(t *testing.T) {
+		r := Recording{Store: mockStore{
+			readReturn: map[string]string{},
+			writeFunc: func(m map[string]string) error {
+				return errors.New("write error")
+			},
+		}, Log: slog.Default()}
+		assert.Equal(t, "", r.SetRecordingTemplate("{owner}"))
+	})
+}
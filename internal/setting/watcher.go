@@ -0,0 +1,91 @@
+package setting
+
+import (
+	"asmblive/internal/store"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+const (
+	watcherIntervalKey = "watcher_interval"
+	watcherBoardsKey   = "watcher_boards"
+)
+
+// DefaultWatcherInterval is the poll interval in seconds if it is not set.
+const DefaultWatcherInterval = 60
+
+type Watcher struct {
+	Store store.Store[map[string]string]
+	Log   *slog.Logger
+}
+
+// GetWatcherInterval returns the poll interval in seconds, 0 means the watcher is disabled.
+func (w Watcher) GetWatcherInterval() int {
+	c, err := w.Store.Read()
+	if err != nil {
+		w.Log.Error("Failed to read watcher interval", "err", err)
+		return DefaultWatcherInterval
+	}
+	v, ok := c[watcherIntervalKey]
+	if !ok {
+		return DefaultWatcherInterval
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		w.Log.Warn("Invalid watcher interval", "value", v)
+		return DefaultWatcherInterval
+	}
+	return i
+}
+
+func (w Watcher) SetWatcherInterval(seconds int) int {
+	if seconds < 0 {
+		seconds = 0
+	}
+	writeMtx.Lock()
+	defer writeMtx.Unlock()
+	c, err := w.Store.Read()
+	if err != nil {
+		w.Log.Error("Failed to read watcher interval", "err", err)
+		return -1
+	}
+	c[watcherIntervalKey] = strconv.Itoa(seconds)
+	err = w.Store.Write(c)
+	if err != nil {
+		w.Log.Error("Failed to write watcher interval", "err", err)
+		return -1
+	}
+	return seconds
+}
+
+// GetWatchedBoards returns the ids of the watched boards, an empty slice means all boards are watched.
+func (w Watcher) GetWatchedBoards() []string {
+	c, err := w.Store.Read()
+	if err != nil {
+		w.Log.Error("Failed to read watched boards", "err", err)
+		return make([]string, 0)
+	}
+	v := c[watcherBoardsKey]
+	if v == "" {
+		return make([]string, 0)
+	}
+	return strings.Split(v, ",")
+}
+
+func (w Watcher) SetWatchedBoards(boardIds []string) []string {
+	writeMtx.Lock()
+	defer writeMtx.Unlock()
+	c, err := w.Store.Read()
+	if err != nil {
+		w.Log.Error("Failed to read watched boards", "err", err)
+		return nil
+	}
+	c[watcherBoardsKey] = strings.Join(boardIds, ",")
+	err = w.Store.Write(c)
+	if err != nil {
+		w.Log.Error("Failed to write watched boards", "err", err)
+		return nil
+	}
+	return boardIds
+}
@@ -0,0 +1,80 @@
+package setting
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcher_GetWatcherInterval(t *testing.T) {
+	tests := []struct {
+		name  string
+		store mockStore
+		want  int
+	}{
+		{
+			name:  "should return interval",
+			store: mockStore{readReturn: map[string]string{watcherIntervalKey: "30"}},
+			want:  30,
+		},
+		{
+			name:  "should return default since not existing",
+			store: mockStore{readReturn: map[string]string{}},
+			want:  DefaultWatcherInterval,
+		},
+		{
+			name:  "should return default since invalid",
+			store: mockStore{readReturn: map[string]string{watcherIntervalKey: "abc"}},
+			want:  DefaultWatcherInterval,
+		},
+		{
+			name:  "should return default since read error",
+			store: mockStore{readErr: errors.New("read error")},
+			want:  DefaultWatcherInterval,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := Watcher{Store: tt.store, Log: slog.Default()}
+			assert.Equal(t, tt.want, w.GetWatcherInterval())
+		})
+	}
+}
+
+func TestWatcher_SetWatcherInterval(t *testing.T) {
+	t.Run("should set interval", func(t *testing.T) {
+		w := Watcher{Store: mockStore{
+			readReturn: map[string]string{},
+			writeFunc: func(m map[string]string) error {
+				assert.Equal(t, map[string]string{watcherIntervalKey: "0"}, m)
+				return nil
+			},
+		}, Log: slog.Default()}
+		assert.Equal(t, 0, w.SetWatcherInterval(-5))
+	})
+}
+
+func TestWatcher_WatchedBoards(t *testing.T) {
+	t.Run("should return watched boards", func(t *testing.T) {
+		w := Watcher{Store: mockStore{readReturn: map[string]string{watcherBoardsKey: "a,b"}}, Log: slog.Default()}
+		assert.Equal(t, []string{"a", "b"}, w.GetWatchedBoards())
+	})
+
+	t.Run("should return empty since not existing", func(t *testing.T) {
+		w := Watcher{Store: mockStore{readReturn: map[string]string{}}, Log: slog.Default()}
+		assert.Equal(t, []string{}, w.GetWatchedBoards())
+	})
+
+	t.Run("should set watched boards", func(t *testing.T) {
+		w := Watcher{Store: mockStore{
+			readReturn: map[string]string{},
+			writeFunc: func(m map[string]string) error {
+				assert.Equal(t, map[string]string{watcherBoardsKey: "a,b"}, m)
+				return nil
+			},
+		}, Log: slog.Default()}
+		assert.Equal(t, []string{"a", "b"}, w.SetWatchedBoards([]string{"a", "b"}))
+	})
+}
@@ -0,0 +1,229 @@
+package watcher
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultGap is the minimal delay between two requests to the same platform if it is not configured.
+const DefaultGap = time.Second
+
+// Target is a room to be watched.
+type Target struct {
+	PlatformId string
+	RoomId     string
+}
+
+// Status is the latest known state of a target.
+type Status struct {
+	Target
+	Room      platform.Room
+	CheckedAt time.Time
+}
+
+// Change is reported when a known room goes online, goes offline or changes its title.
+type Change struct {
+	Target
+	Old platform.Room
+	New platform.Room
+}
+
+func (c Change) WentOnline() bool {
+	return !c.Old.IsOnline && c.New.IsOnline
+}
+
+func (c Change) WentOffline() bool {
+	return c.Old.IsOnline && !c.New.IsOnline
+}
+
+func (c Change) TitleChanged() bool {
+	return c.Old.Title != c.New.Title
+}
+
+type Options struct {
+	// Interval returns the delay between two polls, it is called before each poll so that the
+	// setting takes effect immediately, the watcher pauses if it returns 0.
+	Interval func() time.Duration
+	// Targets returns the rooms to be polled, it is called before each poll.
+	Targets func() []Target
+	// Gaps is the minimal delay between two requests to the same platform, keyed by platform id.
+	Gaps map[string]time.Duration
+	// OnChange is called for every change, it must not block.
+	OnChange func(Change)
+	// OnStatus is called for every successful check including the first one, it must not block.
+	OnStatus func(Status)
+}
+
+// Watcher polls the rooms in background and caches their statuses.
+type Watcher struct {
+	log  *slog.Logger
+	pm   map[string]platform.Platform
+	opts Options
+
+	mtx      sync.RWMutex
+	statuses map[Target]Status
+
+	// runMtx guards cancel and done, so that Start and Stop can be called more than once.
+	runMtx sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func New(log *slog.Logger, pm map[string]platform.Platform, opts Options) *Watcher {
+	log = log.With("module", "watcher")
+	return &Watcher{
+		log:      log,
+		pm:       pm,
+		opts:     opts,
+		statuses: make(map[Target]Status),
+	}
+}
+
+// Start starts polling in background, it does nothing if the watcher is running.
+func (w *Watcher) Start() {
+	w.runMtx.Lock()
+	defer w.runMtx.Unlock()
+	if w.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	w.cancel = cancel
+	w.done = done
+	go func() {
+		defer close(done)
+		for {
+			iv := w.opts.Interval()
+			if iv > 0 {
+				w.poll(ctx)
+			} else {
+				// check the setting again later
+				iv = DefaultGap * 10
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(iv):
+			}
+		}
+	}()
+	w.log.Info("watcher started")
+}
+
+// Stop stops polling, it blocks until the running poll is aborted.
+func (w *Watcher) Stop() {
+	w.runMtx.Lock()
+	defer w.runMtx.Unlock()
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	<-w.done
+	w.cancel = nil
+	w.done = nil
+	w.log.Info("watcher stopped")
+}
+
+// Statuses returns the cached statuses of the current targets.
+func (w *Watcher) Statuses() []Status {
+	ts := w.opts.Targets()
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+	ss := make([]Status, 0, len(ts))
+	for _, t := range ts {
+		if s, ok := w.statuses[t]; ok {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+// poll gets all targets once, the platforms are polled concurrently but the rooms of a platform
+// are polled in batch if supported, or one by one with the gap of the platform.
+func (w *Watcher) poll(ctx context.Context) {
+	byPlatform := make(map[string][]Target)
+	for _, t := range w.opts.Targets() {
+		byPlatform[t.PlatformId] = append(byPlatform[t.PlatformId], t)
+	}
+	var wg sync.WaitGroup
+	for pid, ts := range byPlatform {
+		p, ok := w.pm[pid]
+		if !ok {
+			w.log.Warn("cannot find platform", "id", pid)
+			continue
+		}
+		gap, ok := w.opts.Gaps[pid]
+		if !ok {
+			gap = DefaultGap
+		}
+		wg.Add(1)
+		go func(p platform.Platform, ts []Target, gap time.Duration) {
+			defer wg.Done()
+			if bp, ok := p.(platform.BatchRoomGetter); ok && w.checkBatch(ctx, bp, ts) {
+				return
+			}
+			for i, t := range ts {
+				if i > 0 {
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(gap):
+					}
+				}
+				w.check(ctx, p, t)
+			}
+		}(p, ts, gap)
+	}
+	wg.Wait()
+}
+
+// checkBatch returns false if the rooms cannot be got in batch.
+func (w *Watcher) checkBatch(ctx context.Context, bp platform.BatchRoomGetter, ts []Target) bool {
+	ids := make([]string, len(ts))
+	for i, t := range ts {
+		ids[i] = t.RoomId
+	}
+	rs, err := bp.GetRooms(ctx, ids)
+	if err != nil {
+		w.log.Warn("failed to get rooms in batch", "platformId", ts[0].PlatformId, "err", err)
+		return false
+	}
+	for _, t := range ts {
+		if r, ok := rs[t.RoomId]; ok {
+			w.update(t, r)
+		}
+	}
+	return true
+}
+
+func (w *Watcher) check(ctx context.Context, p platform.Platform, t Target) {
+	r, err := p.GetRoom(ctx, t.RoomId)
+	if err != nil {
+		w.log.Warn("failed to get room", "platformId", t.PlatformId, "roomId", t.RoomId, "err", err)
+		return
+	}
+	w.update(t, r)
+}
+
+func (w *Watcher) update(t Target, r platform.Room) {
+	st := Status{Target: t, Room: r, CheckedAt: time.Now()}
+	w.mtx.Lock()
+	old, known := w.statuses[t]
+	w.statuses[t] = st
+	w.mtx.Unlock()
+	if w.opts.OnStatus != nil {
+		w.opts.OnStatus(st)
+	}
+	// the first status is not a change
+	if !known || w.opts.OnChange == nil {
+		return
+	}
+	c := Change{Target: t, Old: old.Room, New: r}
+	if c.WentOnline() || c.WentOffline() || c.TitleChanged() {
+		w.log.Info("room changed", "platformId", t.PlatformId, "roomId", t.RoomId, "isOnline", r.IsOnline, "title", r.Title)
+		w.opts.OnChange(c)
+	}
+}
@@ -0,0 +1,190 @@
+package watcher
+
+import (
+	"asmblive/internal/platform"
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockPlatform returns the rooms in order for every call of GetRoom.
+type mockPlatform struct {
+	mtx   sync.Mutex
+	rooms map[string][]platform.Room
+	calls []time.Time
+}
+
+func (m *mockPlatform) Id() string {
+	return "test"
+}
+
+func (m *mockPlatform) Name() string {
+	return "test"
+}
+
+func (m *mockPlatform) IconUrl() url.URL {
+	return url.URL{}
+}
+
+func (m *mockPlatform) GetRoom(_ context.Context, roomId string) (platform.Room, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.calls = append(m.calls, time.Now())
+	rs := m.rooms[roomId]
+	if len(rs) == 0 {
+		return platform.Room{}, errors.New("no room")
+	}
+	r := rs[0]
+	if len(rs) > 1 {
+		m.rooms[roomId] = rs[1:]
+	}
+	return r, nil
+}
+
+func (m *mockPlatform) GetQualities(context.Context, string) ([]platform.Quality, error) {
+	panic("should not call")
+}
+
+func (m *mockPlatform) GetLiveUrls(context.Context, string, string) ([]url.URL, error) {
+	panic("should not call")
+}
+
+// mockBatchPlatform gets the rooms in batch, it falls back to GetRoom if err is set.
+type mockBatchPlatform struct {
+	*mockPlatform
+
+	err     error
+	batches int
+}
+
+func (m *mockBatchPlatform) GetRooms(ctx context.Context, roomIds []string) (map[string]platform.Room, error) {
+	m.batches++
+	if m.err != nil {
+		return nil, m.err
+	}
+	rs := make(map[string]platform.Room)
+	for _, id := range roomIds {
+		if r, err := m.mockPlatform.GetRoom(ctx, id); err == nil {
+			rs[id] = r
+		}
+	}
+	return rs, nil
+}
+
+func TestWatcher_poll(t *testing.T) {
+	t.Run("should report changes after the first poll", func(t *testing.T) {
+		mp := &mockPlatform{rooms: map[string][]platform.Room{
+			"1": {
+				{Id: "1", Title: "a", IsOnline: false},
+				{Id: "1", Title: "a", IsOnline: true},
+				{Id: "1", Title: "b", IsOnline: false},
+			},
+			"2": {
+				{Id: "2", Title: "c", IsOnline: true},
+			},
+		}}
+		var changes []Change
+		var statuses []Status
+		w := New(slog.Default(), map[string]platform.Platform{"test": mp}, Options{
+			Targets: func() []Target {
+				return []Target{{PlatformId: "test", RoomId: "1"}, {PlatformId: "test", RoomId: "2"}}
+			},
+			Gaps:     map[string]time.Duration{"test": 10 * time.Millisecond},
+			OnChange: func(c Change) { changes = append(changes, c) },
+			OnStatus: func(s Status) { statuses = append(statuses, s) },
+		})
+		w.poll(context.Background())
+		assert.Empty(t, changes)
+		assert.Len(t, w.Statuses(), 2)
+		// the first statuses are reported
+		assert.Len(t, statuses, 2)
+
+		w.poll(context.Background())
+		assert.Len(t, changes, 1)
+		assert.True(t, changes[0].WentOnline())
+		assert.False(t, changes[0].TitleChanged())
+
+		w.poll(context.Background())
+		assert.Len(t, changes, 2)
+		assert.True(t, changes[1].WentOffline())
+		assert.True(t, changes[1].TitleChanged())
+
+		// the rooms of a platform are polled with the gap
+		for i := 1; i < len(mp.calls); i += 2 {
+			assert.GreaterOrEqual(t, mp.calls[i].Sub(mp.calls[i-1]), 10*time.Millisecond)
+		}
+	})
+
+	t.Run("should keep the last status since failing to get room", func(t *testing.T) {
+		mp := &mockPlatform{rooms: map[string][]platform.Room{}}
+		w := New(slog.Default(), map[string]platform.Platform{"test": mp}, Options{
+			Targets: func() []Target {
+				return []Target{{PlatformId: "test", RoomId: "1"}, {PlatformId: "missing", RoomId: "1"}}
+			},
+		})
+		w.poll(context.Background())
+		assert.Empty(t, w.Statuses())
+	})
+
+	t.Run("should poll rooms in batch if supported", func(t *testing.T) {
+		for _, err := range []error{nil, errors.New("no batch")} {
+			mp := &mockBatchPlatform{
+				mockPlatform: &mockPlatform{rooms: map[string][]platform.Room{
+					"1": {{Id: "1", IsOnline: false}, {Id: "1", IsOnline: true}},
+					"2": {{Id: "2", IsOnline: true}},
+				}},
+				err: err,
+			}
+			var changes []Change
+			w := New(slog.Default(), map[string]platform.Platform{"test": mp}, Options{
+				Targets: func() []Target {
+					return []Target{{PlatformId: "test", RoomId: "1"}, {PlatformId: "test", RoomId: "2"}}
+				},
+				Gaps:     map[string]time.Duration{"test": 0},
+				OnChange: func(c Change) { changes = append(changes, c) },
+			})
+			w.poll(context.Background())
+			w.poll(context.Background())
+			assert.Equal(t, 2, mp.batches)
+			assert.Len(t, w.Statuses(), 2)
+			assert.Len(t, changes, 1)
+			assert.True(t, changes[0].WentOnline())
+		}
+	})
+}
+
+func TestWatcher_Start_Stop(t *testing.T) {
+	t.Run("should poll until stopped", func(t *testing.T) {
+		mp := &mockPlatform{rooms: map[string][]platform.Room{"1": {{Id: "1"}}}}
+		w := New(slog.Default(), map[string]platform.Platform{"test": mp}, Options{
+			Interval: func() time.Duration { return 5 * time.Millisecond },
+			Targets: func() []Target {
+				return []Target{{PlatformId: "test", RoomId: "1"}}
+			},
+		})
+		w.Start()
+		// starting twice must not leak a second polling goroutine
+		w.Start()
+		assert.Eventually(t, func() bool {
+			mp.mtx.Lock()
+			defer mp.mtx.Unlock()
+			return len(mp.calls) >= 2
+		}, 5*time.Second, 5*time.Millisecond)
+		w.Stop()
+		mp.mtx.Lock()
+		n := len(mp.calls)
+		mp.mtx.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mp.mtx.Lock()
+		defer mp.mtx.Unlock()
+		assert.Equal(t, n, len(mp.calls))
+		// stopping twice is a no-op
+		w.Stop()
+	})
+}
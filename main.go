@@ -6,6 +6,8 @@ import (
 	"context"
 	"embed"
 	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
 
 	"github.com/wailsapp/wails/v2"
@@ -23,19 +25,36 @@ func main() {
 	log := slog.New(hdl)
 	log = log.With("version", vs.GetVersion())
 
-	sv := server.New(log)
+	em := &emitter{}
 
-	stSrv := service.NewSettingService(log)
+	stSrv := service.NewSettingService(log, em)
+	// the requests of the server are sent through the proxy of the platform which allows the host
+	sv := server.New(log, func(platformId string) func(*http.Request) (*url.URL, error) {
+		return service.ProxyFunc(stSrv, platformId)
+	})
 	pfSrv := service.NewPlatformService(log, stSrv, sv)
 	bSrv := service.NewBoardService(log, sv)
+	cSrv := service.NewChatService(log, pfSrv, em)
+	wSrv := service.NewWatcherService(log, pfSrv, bSrv, stSrv, em)
+	rSrv := service.NewRecordingService(log, pfSrv, stSrv, em)
+	arSrv := service.NewAutoRecordService(log, pfSrv, rSrv)
 
 	startup := func(ctx context.Context) {
+		em.ctx = ctx
 		if err := sv.Start(); err != nil {
 			log.Error("failed to start server", "err", err)
 			panic(err)
 		}
+		stSrv.Start()
+		wSrv.Start()
+		arSrv.Start()
 	}
 	shutdown := func(ctx context.Context) {
+		stSrv.Stop()
+		wSrv.Stop()
+		cSrv.CloseAll()
+		arSrv.Stop()
+		rSrv.StopAll()
 		if err := sv.Stop(ctx); err != nil {
 			log.Error("failed to stop server", "err", err)
 			panic(err)
@@ -57,6 +76,10 @@ func main() {
 			pfSrv,
 			bSrv,
 			stSrv,
+			cSrv,
+			wSrv,
+			rSrv,
+			arSrv,
 		},
 		Logger: logger{log: log.With("module", "wails")},
 		DragAndDrop: &options.DragAndDrop{